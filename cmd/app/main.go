@@ -6,22 +6,27 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // timezone validation must not depend on the host's zoneinfo
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 	"go.uber.org/zap"
 
 	"github.com/iots1/mingkwan-api/config"
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
 	"github.com/iots1/mingkwan-api/internal/modules"
 	"github.com/iots1/mingkwan-api/internal/shared/adapters"
 	"github.com/iots1/mingkwan-api/internal/shared/cache"
 	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 
 	_ "github.com/iots1/mingkwan-api/docs"
@@ -58,6 +63,12 @@ func main() {
 	appConfig := config.LoadAppConfig()
 	mongoConfig := config.LoadMongoConfig()
 	redisConfig := config.LoadRedisConfig()
+	authCookieConfig := config.LoadAuthCookieConfig()
+	corsConfig := config.LoadCORSConfig()
+	oauthConfig := config.LoadOAuthConfig()
+	userRetentionConfig := config.LoadUserRetentionConfig()
+	userStorageConfig := config.LoadUserStorageConfig()
+	userCacheConfig := config.LoadUserCacheConfig()
 	loggerLevel := config.LoadLoggerConfig()
 
 	// --- Initialize Zap Logger FIRST ---
@@ -87,6 +98,33 @@ func main() {
 	db := mongoClient.GetDatabase()
 	utils.Logger.Info("Connected to MongoDB", zap.String("database", mongoConfig.DBName))
 
+	// 2.2. PostgreSQL, only if users are stored there
+	postgresClient := infrastructure.NewPostgresClient(userStorageConfig.PostgresURL)
+	var pgPool *pgxpool.Pool
+	switch userStorageConfig.Backend {
+	case config.UserStorageMongo:
+	case config.UserStoragePostgres:
+		if pgPool, err = postgresClient.Connect(initCtx); err != nil {
+			utils.Logger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+		}
+	default:
+		utils.Logger.Fatal("Unknown USER_STORAGE backend", zap.String("backend", userStorageConfig.Backend))
+	}
+	utils.Logger.Info("User storage selected", zap.String("backend", userStorageConfig.Backend))
+
+	// "app migrate ..." runs schema migrations instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(appCtx, db, pgPool, os.Args[2:])
+		postgresClient.Disconnect()
+		mongoClient.Disconnect(context.Background())
+		utils.SyncLogger()
+		os.Exit(code)
+	}
+
+	schemaCtx, schemaCancel := context.WithTimeout(appCtx, 30*time.Second)
+	prepareSchema(schemaCtx, db, pgPool)
+	schemaCancel()
+
 	redisClientConn := infrastructure.NewRedisClient(redisConfig.Addr, redisConfig.Password, redisConfig.DB)
 	var rdb *redis.Client                                        // Declare rdb here
 	if rdb, err = redisClientConn.Connect(initCtx); err != nil { // Assign to existing rdb and err
@@ -95,15 +133,6 @@ func main() {
 	utils.Logger.Info("Connected to general Redis client", zap.String("address", redisConfig.Addr), zap.Int("db", redisConfig.DB))
 
 	cacheManager := cache.NewCacheManager(rdb)
-	testKey := "my_test_key"
-	testValue := "hello from redis cache"
-	if err = cacheManager.Set(initCtx, testKey, testValue, 1*time.Minute); err != nil { // Assign to existing err
-		utils.Logger.Warn("Failed to set test key in Redis cache", zap.Error(err))
-	} else {
-		if val, getErr := cacheManager.Get(initCtx, testKey); getErr == nil {
-			utils.Logger.Debug("Retrieved test key from Redis cache", zap.String("key", testKey), zap.String("value", val))
-		}
-	}
 
 	// Initialize In-Memory Event Bus (InMemPubSub)
 	inMemPubSub := event.NewInMemoryBus()
@@ -119,25 +148,55 @@ func main() {
 	highPublisher := event.NewHighImportancePublisher(asynqConcreteClient)
 	utils.Logger.Info("Initialized Low and High Importance Publishers.")
 
+	// Initialize Asynq Worker; modules register their task handlers during setup
+	taskWorker := event.NewAsynqWorker(asynqRedisOpt, 10)
+	taskWorker.HandleFunc(event.SendWelcomeEmailTaskName, event.SendWelcomeEmailHandler)
+	taskScheduler := event.NewAsynqScheduler(asynqRedisOpt)
+
 	appDeps := infrastructure.NewAppDependencies(
 		appCtx,
 		db,
+		pgPool,
 		rdb,
+		cacheManager,
 		lowPublisher,
 		highPublisher,
 		inMemPubSub,
 		appConfig,
+		authCookieConfig,
+		oauthConfig,
+		userRetentionConfig,
+		userStorageConfig,
+		userCacheConfig,
 		passwordHasher,
+		taskWorker,
+		taskScheduler,
 	)
 
 	app := fiber.New()
 
-	// Enable CORS
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Content-Type,Authorization",
-	}))
+	// Enable CORS. Credentialed requests (cookie sessions) are only allowed from the
+	// configured origins; without a list any origin may call the API with bearer tokens.
+	corsSettings := cors.Config{
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Content-Type,Authorization,X-Request-ID,X-CSRF-Token,If-Match",
+		ExposeHeaders: "X-Request-ID,ETag",
+	}
+	if len(corsConfig.AllowedOrigins) > 0 {
+		corsSettings.AllowOrigins = strings.Join(corsConfig.AllowedOrigins, ",")
+		corsSettings.AllowCredentials = true
+	} else if authCookieConfig.Enabled {
+		utils.Logger.Warn("Cookie sessions are enabled but CORS_ALLOWED_ORIGINS is empty; cross-origin browsers cannot send credentials.")
+	}
+	app.Use(cors.New(corsSettings))
+
+	// Attach IP, user agent and correlation ID to every request for auditing
+	app.Use(middleware.RequestMeta())
+
+	// Double-submit CSRF check for requests authenticated by session cookies. It runs
+	// after RequestMeta so that rejected requests carry a correlation ID.
+	app.Use(middleware.CSRFProtection(authDelivery.AccessTokenCookie, authDelivery.RefreshTokenCookie))
 
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
@@ -151,12 +210,23 @@ func main() {
 
 	// API Routes Group
 	apiV1 := app.Group("/api/v1")
-	userUsecase := modules.SetupUserModule(apiV1, appDeps)
+	authMiddleware := modules.NewAuthMiddleware(appDeps)
+	auditUsecase := modules.SetupAuditModule(apiV1, appDeps, authMiddleware)
+
+	userUsecase, userHistoryUsecase, userMergeUsecase := modules.SetupUserModule(apiV1, appDeps, auditUsecase, authMiddleware)
 	if userUsecase == nil {
 		utils.Logger.Fatal("Failed to setup User Module: userUcase is nil")
 	}
 
-	modules.SetupAuthModule(apiV1, appDeps, *userUsecase)
+	groupUsecase := modules.SetupGroupModule(apiV1, appDeps, *userUsecase, auditUsecase, authMiddleware)
+
+	authUsecase := modules.SetupAuthModule(apiV1, appDeps, *userUsecase, groupUsecase, auditUsecase, authMiddleware)
+
+	preferenceUsecase := modules.SetupPreferenceModule(apiV1, appDeps, authMiddleware)
+
+	modules.SetupUserMergeRepointers(userMergeUsecase, authUsecase, groupUsecase, preferenceUsecase)
+
+	modules.SetupPrivacyModule(apiV1, appDeps, *userUsecase, userHistoryUsecase, *authUsecase, groupUsecase, preferenceUsecase, auditUsecase, authMiddleware)
 
 	// Health check endpoint
 	// @Summary Health check
@@ -179,6 +249,14 @@ func main() {
 
 	// ... other routes and middleware
 
+	// --- 4. Start Asynq Worker now that all task handlers are registered ---
+	if err = taskWorker.Start(); err != nil {
+		utils.Logger.Fatal("Failed to start Asynq worker", zap.Error(err))
+	}
+	if err = taskScheduler.Start(); err != nil {
+		utils.Logger.Fatal("Failed to start Asynq scheduler", zap.Error(err))
+	}
+
 	// --- 5. Start Server in a Goroutine ---
 	go func() {
 		port := fmt.Sprintf(":%d", appConfig.Port)
@@ -212,9 +290,14 @@ func main() {
 	mongoClient.Disconnect(shutdownCtx)
 	utils.Logger.Info("MongoDB disconnected.")
 
+	postgresClient.Disconnect()
+
 	redisClientConn.Disconnect()
 	utils.Logger.Info("General Redis client disconnected.")
 
+	taskScheduler.Shutdown()
+	taskWorker.Shutdown()
+
 	// Ensure Asynq client is closed
 	if asynqConcreteClient != nil {
 		if err = asynqConcreteClient.Close(); err != nil {
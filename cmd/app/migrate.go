@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/modules"
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const migrateUsage = `Usage: app migrate <command> [flags]
+
+Commands:
+  up      apply pending migrations (-to VERSION stops after that version)
+  down    revert applied migrations (-steps N, default 1) of one database
+          (-db mongo|postgres, default mongo)
+  status  list migrations and whether they are applied
+
+PostgreSQL migrations are included when USER_STORAGE=postgres.
+`
+
+// runMigrate runs the migrate subcommand with args (without "migrate") and returns
+// the process exit code. pg is nil unless users are stored in PostgreSQL.
+func runMigrate(ctx context.Context, db *mongo.Database, pg *pgxpool.Pool, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	target := flags.Int64("to", 0, "last version to apply (default: all)")
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	database := flags.String("db", databaseMongo, "database to revert migrations of: mongo or postgres")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	migrator := migration.NewMigrator(db, modules.Migrations(), modules.MigrationsCollection)
+	var sqlMigrator *migration.SQLMigrator
+	if pg != nil {
+		var err error
+		if sqlMigrator, err = newSQLMigrator(pg); err != nil {
+			utils.Logger.Error("Migrate: Failed to load SQL migrations", zap.Error(err))
+			return 1
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		// Migrations may rely on the declared indexes, e.g. to detect duplicates.
+		if err := migration.EnsureIndexes(ctx, db, modules.Indexes()); err != nil {
+			utils.Logger.Error("Migrate: Failed to ensure indexes", zap.Error(err))
+			return 1
+		}
+		applied, err := migrator.Up(ctx, *target)
+		if code := reportMigrations("MongoDB", "Applied", applied, err); code != 0 || sqlMigrator == nil {
+			return code
+		}
+		sqlApplied, err := sqlMigrator.Up(ctx, *target)
+		return reportMigrations("PostgreSQL", "Applied", sqlApplied, err)
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "-steps must be at least 1")
+			return 2
+		}
+		switch *database {
+		case databaseMongo:
+			reverted, err := migrator.Down(ctx, *steps)
+			return reportMigrations("MongoDB", "Reverted", reverted, err)
+		case databasePostgres:
+			if sqlMigrator == nil {
+				fmt.Fprintln(os.Stderr, "PostgreSQL is not in use; set USER_STORAGE=postgres and POSTGRES_URL")
+				return 2
+			}
+			reverted, err := sqlMigrator.Down(ctx, *steps)
+			return reportMigrations("PostgreSQL", "Reverted", reverted, err)
+		default:
+			fmt.Fprintln(os.Stderr, "-db must be mongo or postgres")
+			return 2
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			utils.Logger.Error("Migrate: Failed to read migration status", zap.Error(err))
+			return 1
+		}
+		groups := []migrationStatusGroup{{Database: databaseMongo, Statuses: statuses}}
+		if sqlMigrator != nil {
+			sqlStatuses, err := sqlMigrator.Status(ctx)
+			if err != nil {
+				utils.Logger.Error("Migrate: Failed to read SQL migration status", zap.Error(err))
+				return 1
+			}
+			groups = append(groups, migrationStatusGroup{Database: databasePostgres, Statuses: sqlStatuses})
+		}
+		printMigrationStatus(groups)
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+}
+
+// Values of the -db flag.
+const (
+	databaseMongo    = "mongo"
+	databasePostgres = "postgres"
+)
+
+func newSQLMigrator(pg *pgxpool.Pool) (*migration.SQLMigrator, error) {
+	migrations, err := modules.SQLMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return migration.NewSQLMigrator(pg, migrations, modules.SQLMigrationsTable), nil
+}
+
+func reportMigrations[M fmt.Stringer](database, verb string, done []M, err error) int {
+	for _, m := range done {
+		fmt.Printf("%s %s\n", verb, m)
+	}
+	if err != nil {
+		if errors.Is(err, migration.ErrLocked) {
+			utils.Logger.Error("Migrate: Another process is running migrations", zap.Error(err))
+		} else {
+			utils.Logger.Error("Migrate: Migration failed", zap.Error(err))
+		}
+		return 1
+	}
+	if len(done) == 0 {
+		fmt.Printf("%s: nothing to do.\n", database)
+	}
+	return 0
+}
+
+type migrationStatusGroup struct {
+	Database string
+	Statuses []migration.Status
+}
+
+func printMigrationStatus(groups []migrationStatusGroup) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DB\tVERSION\tMODULE\tAPPLIED AT\tDESCRIPTION")
+	for _, group := range groups {
+		for _, s := range group.Statuses {
+			printMigrationStatusRow(w, group.Database, s)
+		}
+	}
+	w.Flush()
+}
+
+func printMigrationStatusRow(w *tabwriter.Writer, database string, s migration.Status) {
+	appliedAt := "pending"
+	if s.AppliedAt != nil {
+		appliedAt = s.AppliedAt.Format(time.RFC3339)
+	}
+	description := s.Description
+	if s.Unknown {
+		description += " (not registered)"
+	} else if !s.Reversible {
+		description += " (irreversible)"
+	}
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", database, s.Version, s.Module, appliedAt, description)
+}
+
+// prepareSchema ensures the declared indexes and warns about pending migrations. The
+// server still starts: pending migrations are run with the migrate subcommand.
+func prepareSchema(ctx context.Context, db *mongo.Database, pg *pgxpool.Pool) {
+	if err := migration.EnsureIndexes(ctx, db, modules.Indexes()); err != nil {
+		// Without the unique indexes, concurrent writes can create duplicates.
+		utils.Logger.Error("Failed to ensure MongoDB indexes", zap.Error(err))
+	}
+
+	migrator := migration.NewMigrator(db, modules.Migrations(), modules.MigrationsCollection)
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		utils.Logger.Error("Failed to check pending migrations", zap.Error(err))
+		return
+	}
+	for _, m := range pending {
+		utils.Logger.Warn("Pending migration; run `migrate up`",
+			zap.Int64("version", m.Version), zap.String("module", m.Module), zap.String("description", m.Description))
+	}
+
+	if pg == nil {
+		return
+	}
+	sqlMigrator, err := newSQLMigrator(pg)
+	if err != nil {
+		utils.Logger.Error("Failed to load SQL migrations", zap.Error(err))
+		return
+	}
+	sqlPending, err := sqlMigrator.Pending(ctx)
+	if err != nil {
+		utils.Logger.Error("Failed to check pending SQL migrations", zap.Error(err))
+		return
+	}
+	for _, m := range sqlPending {
+		utils.Logger.Warn("Pending PostgreSQL migration; run `migrate up`",
+			zap.Int64("version", m.Version), zap.String("module", m.Module), zap.String("description", m.Description))
+	}
+}
@@ -0,0 +1,35 @@
+package adapters
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+// AuditIndexes declares the indexes of the audit log. Queries sort by timestamp, so
+// the filtered fields lead and the timestamp follows.
+func AuditIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("audit_logs_timestamp"),
+			},
+			{
+				Keys:    bson.D{{Key: "actor_id", Value: 1}, {Key: "timestamp", Value: -1}},
+				Options: options.Index().SetName("audit_logs_actor_timestamp"),
+			},
+			{
+				Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "timestamp", Value: -1}},
+				Options: options.Index().SetName("audit_logs_target_timestamp"),
+			},
+			{
+				Keys:    bson.D{{Key: "correlation_id", Value: 1}},
+				Options: options.Index().SetName("audit_logs_correlation_id").SetSparse(true),
+			},
+		},
+	}
+}
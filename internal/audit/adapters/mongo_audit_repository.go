@@ -0,0 +1,132 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/audit/domain"
+	"github.com/iots1/mingkwan-api/internal/audit/repository"
+)
+
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditRepository(db *mongo.Database, collectionName string) *MongoAuditRepository {
+	return &MongoAuditRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	res, err := r.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = oid
+	}
+	return nil
+}
+
+func (r *MongoAuditRepository) Find(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	query := buildAuditQuery(filter)
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((filter.Page - 1) * filter.Limit)).
+		SetLimit(int64(filter.Limit))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit entries cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	entries := []domain.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+func (r *MongoAuditRepository) Stream(ctx context.Context, filter domain.AuditFilter, fn func(entry *domain.AuditEntry) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, buildAuditQuery(filter), opts)
+	if err != nil {
+		return fmt.Errorf("failed to get audit entries cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry domain.AuditEntry
+		if err := cursor.Decode(&entry); err != nil {
+			return fmt.Errorf("failed to decode audit entry: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("audit entries cursor error: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoAuditRepository) PseudonymizeSubject(ctx context.Context, subjectID string) (int64, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"actor_id": subjectID}, bson.M{"target_id": subjectID}}}
+	update := bson.M{"$unset": bson.M{"ip": "", "user_agent": "", "metadata.email": "", "metadata.name": ""}}
+	res, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pseudonymize audit entries: %w", err)
+	}
+	return res.ModifiedCount, nil
+}
+
+func buildAuditQuery(filter domain.AuditFilter) bson.M {
+	query := bson.M{}
+	if filter.ActorID != "" {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetType != "" {
+		query["target_type"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	if filter.Result != "" {
+		query["result"] = filter.Result
+	}
+	if filter.CorrelationID != "" {
+		query["correlation_id"] = filter.CorrelationID
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+	timeRange := bson.M{}
+	if !filter.From.IsZero() {
+		timeRange["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		timeRange["$lte"] = filter.To
+	}
+	if len(timeRange) > 0 {
+		query["timestamp"] = timeRange
+	}
+	return query
+}
+
+var _ repository.AuditRepository = (*MongoAuditRepository)(nil)
@@ -0,0 +1,147 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditModel "github.com/iots1/mingkwan-api/internal/audit/models"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const exportTimeout = 10 * time.Minute
+
+type AuditHandler struct {
+	auditUsecase auditUsecase.AuditUsecase
+}
+
+func NewAuditHandler(auditUsecase auditUsecase.AuditUsecase) *AuditHandler {
+	return &AuditHandler{auditUsecase: auditUsecase}
+}
+
+func (h *AuditHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
+	logFields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status_code", statusCode),
+		zap.String("message", message),
+	}
+	if err != nil {
+		logFields = append(logFields, zap.Error(err))
+	}
+	if validationErrors != nil {
+		logFields = append(logFields, zap.Any("validation_errors", validationErrors))
+	}
+	utils.Logger.Error("API Error", logFields...)
+
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Errors:    validationErrors,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
+
+func (h *AuditHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+	})
+}
+
+// parseFilter reads and validates the audit query string. It returns validation
+// errors separately so the caller can report them field by field.
+func parseFilter(c *fiber.Ctx) (domain.AuditFilter, map[string][]string, error) {
+	var query auditModel.AuditLogQuery
+	if err := c.QueryParser(&query); err != nil {
+		return domain.AuditFilter{}, nil, err
+	}
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		return domain.AuditFilter{}, utils.FormatValidationErrors(err), err
+	}
+	filter, err := query.ToFilter()
+	if err != nil {
+		return domain.AuditFilter{}, nil, err
+	}
+	return filter, nil, nil
+}
+
+func (h *AuditHandler) ListAuditLogs(c *fiber.Ctx) error {
+	filter, validationErrors, err := parseFilter(c)
+	if validationErrors != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, validationErrors)
+	}
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+	filter.Normalize()
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	entries, total, err := h.auditUsecase.FindEntries(ctx, filter)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve audit logs", err, nil)
+	}
+
+	return h.sendSuccessResponse(c, fiber.StatusOK, auditModel.AuditLogListResponse{
+		Entries: entries,
+		Total:   total,
+		Page:    filter.Page,
+		Limit:   filter.Limit,
+	}, len(entries))
+}
+
+// ExportAuditLogs streams every entry matching the filter as newline-delimited JSON.
+func (h *AuditHandler) ExportAuditLogs(c *fiber.Ctx) error {
+	filter, validationErrors, err := parseFilter(c)
+	if validationErrors != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, validationErrors)
+	}
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-logs.ndjson"`)
+
+	// The stream writer runs after the handler returns, so it must not use the
+	// request context, which fasthttp recycles.
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+
+		encoder := json.NewEncoder(w)
+		written := 0
+		err := h.auditUsecase.ExportEntries(ctx, filter, func(entry *domain.AuditEntry) error {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+			written++
+			if written%500 == 0 {
+				return w.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			utils.Logger.Error("ExportAuditLogs: Export aborted", zap.Int("written", written), zap.Error(err))
+		}
+		if err := w.Flush(); err != nil {
+			utils.Logger.Warn("ExportAuditLogs: Failed to flush export stream", zap.Error(err))
+		}
+		utils.Logger.Info("Audit logs exported", zap.Int("count", written))
+	})
+	return nil
+}
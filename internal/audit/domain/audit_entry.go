@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEntry is a single, immutable record of a security-relevant event.
+type AuditEntry struct {
+	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Timestamp     time.Time              `bson:"timestamp" json:"timestamp"`
+	ActorID       string                 `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	Action        string                 `bson:"action" json:"action"`
+	TargetType    string                 `bson:"target_type,omitempty" json:"target_type,omitempty"`
+	TargetID      string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	IP            string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent     string                 `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Result        string                 `bson:"result" json:"result"`
+	Reason        string                 `bson:"reason,omitempty" json:"reason,omitempty"`
+	CorrelationID string                 `bson:"correlation_id,omitempty" json:"correlation_id,omitempty"`
+	Metadata      map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const (
+	TargetTypeUser       = "user"
+	TargetTypeUserImport = "user_import"
+	TargetTypeGroup      = "group"
+)
+
+const (
+	ActionAuthRegister      = "auth.register"
+	ActionAuthLogin         = "auth.login"
+	ActionAuthTokenRefresh  = "auth.token_refresh"
+	ActionAuthNewDevice     = "auth.new_device_login"
+	ActionAuthSessionRevoke = "auth.session_revoke"
+	ActionAuthTokenRevoke   = "auth.token_revoke"
+	ActionAuthReauth        = "auth.reauthenticate"
+	ActionUserCreate        = "user.create"
+	ActionUserUpdate        = "user.update"
+	ActionUserDelete        = "user.delete"
+	ActionUserRestore       = "user.restore"
+	ActionUserDeactivate    = "user.deactivate"
+	ActionUserReactivate    = "user.reactivate"
+	ActionUserPurge         = "user.purge"
+	ActionUserImport        = "user.import"
+	ActionUserExport        = "user.export"
+	ActionUserErase         = "user.erase"
+	ActionUserEmailChange   = "user.email_change"
+	ActionUserMerge         = "user.merge"
+	ActionGroupCreate       = "group.create"
+	ActionGroupUpdate       = "group.update"
+	ActionGroupDelete       = "group.delete"
+	ActionGroupMemberAdd    = "group.member_add"
+	ActionGroupMemberRemove = "group.member_remove"
+	ActionPrivacyExport     = "privacy.data_export"
+	ActionPrivacyErasure    = "privacy.erasure"
+)
+
+// AuditFilter narrows down audit queries. Zero values are ignored.
+type AuditFilter struct {
+	ActorID       string
+	Action        string
+	TargetType    string
+	TargetID      string
+	Result        string
+	CorrelationID string
+	IP            string
+	From          time.Time
+	To            time.Time
+	Page          int
+	Limit         int
+}
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// Normalize applies pagination defaults and bounds.
+func (f *AuditFilter) Normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = DefaultPageLimit
+	}
+	if f.Limit > MaxPageLimit {
+		f.Limit = MaxPageLimit
+	}
+}
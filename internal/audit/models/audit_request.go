@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/audit/domain"
+)
+
+type AuditLogQuery struct {
+	ActorID       string `query:"actor_id"`
+	Action        string `query:"action"`
+	TargetType    string `query:"target_type"`
+	TargetID      string `query:"target_id"`
+	Result        string `query:"result" validate:"omitempty,oneof=success failure"`
+	CorrelationID string `query:"correlation_id"`
+	IP            string `query:"ip" validate:"omitempty,ip"`
+	From          string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To            string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page          int    `query:"page" validate:"omitempty,min=1"`
+	Limit         int    `query:"limit" validate:"omitempty,min=1,max=500"`
+}
+
+// ToFilter converts the validated query into a domain filter.
+func (q AuditLogQuery) ToFilter() (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{
+		ActorID:       q.ActorID,
+		Action:        q.Action,
+		TargetType:    q.TargetType,
+		TargetID:      q.TargetID,
+		Result:        q.Result,
+		CorrelationID: q.CorrelationID,
+		IP:            q.IP,
+		Page:          q.Page,
+		Limit:         q.Limit,
+	}
+	var err error
+	if q.From != "" {
+		if filter.From, err = time.Parse(time.RFC3339, q.From); err != nil {
+			return filter, fmt.Errorf("invalid 'from' timestamp: %w", err)
+		}
+	}
+	if q.To != "" {
+		if filter.To, err = time.Parse(time.RFC3339, q.To); err != nil {
+			return filter, fmt.Errorf("invalid 'to' timestamp: %w", err)
+		}
+	}
+	return filter, nil
+}
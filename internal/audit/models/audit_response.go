@@ -0,0 +1,10 @@
+package models
+
+import "github.com/iots1/mingkwan-api/internal/audit/domain"
+
+type AuditLogListResponse struct {
+	Entries []domain.AuditEntry `json:"entries"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	Limit   int                 `json:"limit"`
+}
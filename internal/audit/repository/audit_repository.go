@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/iots1/mingkwan-api/internal/audit/domain"
+)
+
+// AuditRepository is append-only on purpose: entries can be written and read but
+// never updated or removed through the application. The one exception is
+// PseudonymizeSubject, which serves erasure requests under the GDPR and PDPA.
+type AuditRepository interface {
+	Append(ctx context.Context, entry *domain.AuditEntry) error
+	Find(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error)
+	Stream(ctx context.Context, filter domain.AuditFilter, fn func(entry *domain.AuditEntry) error) error
+	// PseudonymizeSubject strips the personal data (IP, user agent, emails and names
+	// in metadata) from entries where subjectID is the actor or the target. The IDs
+	// stay, so the trail remains complete, and returns how many entries changed.
+	PseudonymizeSubject(ctx context.Context, subjectID string) (int64, error)
+}
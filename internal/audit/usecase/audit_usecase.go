@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/audit/domain"
+	"github.com/iots1/mingkwan-api/internal/audit/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const recordTimeout = 3 * time.Second
+
+type AuditUsecase struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditUsecase(repo repository.AuditRepository) *AuditUsecase {
+	return &AuditUsecase{repo: repo}
+}
+
+// Record appends an audit entry. Actor, IP, user agent and correlation ID are taken
+// from the request metadata in ctx unless already set on entry. Failing to write
+// the audit trail is logged but never fails the calling operation.
+// A nil *AuditUsecase is valid and records nothing.
+func (s *AuditUsecase) Record(ctx context.Context, entry domain.AuditEntry) {
+	if s == nil {
+		return
+	}
+
+	meta := utils.RequestMetaFromContext(ctx)
+	if entry.ActorID == "" {
+		entry.ActorID = meta.ActorID
+	}
+	if entry.IP == "" {
+		entry.IP = meta.IP
+	}
+	if entry.UserAgent == "" {
+		entry.UserAgent = meta.UserAgent
+	}
+	if entry.CorrelationID == "" {
+		entry.CorrelationID = meta.CorrelationID
+	}
+	if entry.Result == "" {
+		entry.Result = domain.ResultSuccess
+	}
+	entry.Timestamp = time.Now().UTC()
+
+	// Detach from the caller's cancellation so that an entry is still written when the
+	// request context is already done (e.g. after a timeout).
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	if err := s.repo.Append(writeCtx, &entry); err != nil {
+		utils.Logger.Error("AuditUsecase: Failed to append audit entry",
+			zap.String("action", entry.Action),
+			zap.String("actor_id", entry.ActorID),
+			zap.String("target_id", entry.TargetID),
+			zap.String("correlation_id", entry.CorrelationID),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *AuditUsecase) FindEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, int64, error) {
+	filter.Normalize()
+
+	entries, total, err := s.repo.Find(ctx, filter)
+	if err != nil {
+		utils.Logger.Error("AuditUsecase: Failed to query audit entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// PseudonymizeSubject removes the personal data of an erased user from the audit
+// trail and returns how many entries were changed.
+func (s *AuditUsecase) PseudonymizeSubject(ctx context.Context, subjectID string) (int64, error) {
+	n, err := s.repo.PseudonymizeSubject(ctx, subjectID)
+	if err != nil {
+		utils.Logger.Error("AuditUsecase: Failed to pseudonymize audit entries", zap.String("subject_id", subjectID), zap.Error(err))
+		return 0, fmt.Errorf("failed to pseudonymize audit entries: %w", err)
+	}
+	return n, nil
+}
+
+func (s *AuditUsecase) ExportEntries(ctx context.Context, filter domain.AuditFilter, fn func(entry *domain.AuditEntry) error) error {
+	if err := s.repo.Stream(ctx, filter, fn); err != nil {
+		utils.Logger.Error("AuditUsecase: Failed to export audit entries", zap.Error(err))
+		return fmt.Errorf("failed to export audit entries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+// SessionIndexes declares the indexes of the sessions collection.
+func SessionIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}},
+				Options: options.Index().SetName("auth_sessions_user_last_seen"),
+			},
+			// The "this wasn't me" link of new device alerts.
+			{
+				Keys:    bson.D{{Key: "not_me_token_hash", Value: 1}},
+				Options: options.Index().SetName("auth_sessions_not_me_token").SetSparse(true),
+			},
+		},
+	}
+}
+
+// KnownDeviceIndexes declares the indexes of the known devices collection.
+func KnownDeviceIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "fingerprint", Value: 1}},
+				Options: options.Index().SetName("known_devices_user_fingerprint"),
+			},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}},
+				Options: options.Index().SetName("known_devices_user_last_seen"),
+			},
+		},
+	}
+}
@@ -1,22 +1,87 @@
 package adapters
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	// FirstPartyClientID is the OAuth client ID recorded in tokens issued by the
+	// login and refresh endpoints.
+	FirstPartyClientID = "mingkwan-api"
+
+	// StepUpTokenLifetime is the lifetime of the elevated access token returned by
+	// re-authentication.
+	StepUpTokenLifetime = 5 * time.Minute
+
+	// Authentication method references (RFC 8176) recorded in the amr claim.
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+)
+
+var ErrInvalidTokenType = errors.New("unexpected token type")
+
 // Claims defines the JWT claims structure.
 type Claims struct {
-	UserID string `json:"userId"`
+	UserID    string   `json:"userId"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	TokenType string   `json:"typ"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	// AuthTime is when the user last proved their identity (Unix seconds). It is
+	// carried over on refresh and only renewed by login or re-authentication.
+	AuthTime int64    `json:"auth_time,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenParams describes the subject a token pair is issued for. Scope defaults to
+// the space-separated roles and ClientID to FirstPartyClientID.
+type TokenParams struct {
+	UserID    string
+	Roles     []string
+	SessionID string
+	Scope     string
+	ClientID  string
+	AuthTime  time.Time
+	AMR       []string
+}
+
+// AuthenticatedAt returns the auth_time claim, or the zero time if it is absent.
+func (c *Claims) AuthenticatedAt() time.Time {
+	if c.AuthTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.AuthTime, 0)
+}
+
+// HasRole reports whether the token carries the given role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // JWTTokenGenerator defines the interface for generating and parsing JWTs.
 type JWTTokenGenerator interface {
-	GenerateTokens(userID string) (accessToken, refreshToken string, err error)
+	GenerateTokens(params TokenParams) (accessToken, refreshToken string, err error)
+	GenerateStepUpToken(params TokenParams) (accessToken string, err error)
 	ParseAccessToken(tokenString string) (*Claims, error)
 	ParseRefreshToken(tokenString string) (*Claims, error)
+	// AccessTokenLifetime is how long the access tokens of GenerateTokens are valid.
+	AccessTokenLifetime() time.Duration
 }
 
 // JWTTokenConfig holds configuration for JWT generation.
@@ -41,25 +106,27 @@ func NewJWTTokenGenerator(secret string) JWTTokenGenerator {
 	}
 }
 
-func (j *JWTGenerator) GenerateTokens(userID string) (accessToken, refreshToken string, err error) {
+func (j *JWTGenerator) GenerateTokens(params TokenParams) (accessToken, refreshToken string, err error) {
+	params = withTokenDefaults(params)
+
 	// Access Token
-	accessClaims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(j.config.AccessExpMinutes))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-	accessToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString([]byte(j.config.Secret))
+	accessToken, err = j.signAccessToken(params, j.AccessTokenLifetime())
 	if err != nil {
 		return "", "", err
 	}
 
 	// Refresh Token
 	refreshClaims := &Claims{
-		UserID: userID,
+		UserID:    params.UserID,
+		SessionID: params.SessionID,
+		TokenType: TokenTypeRefresh,
+		Scope:     params.Scope,
+		ClientID:  params.ClientID,
+		AuthTime:  authTimeClaim(params.AuthTime),
+		AMR:       params.AMR,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   params.UserID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * time.Duration(j.config.RefreshExpDays))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -73,12 +140,73 @@ func (j *JWTGenerator) GenerateTokens(userID string) (accessToken, refreshToken
 	return accessToken, refreshToken, nil
 }
 
+// GenerateStepUpToken issues a short-lived access token after re-authentication.
+// No refresh token is issued: the elevation ends when the token expires.
+func (j *JWTGenerator) AccessTokenLifetime() time.Duration {
+	return time.Minute * time.Duration(j.config.AccessExpMinutes)
+}
+
+func (j *JWTGenerator) GenerateStepUpToken(params TokenParams) (string, error) {
+	return j.signAccessToken(withTokenDefaults(params), StepUpTokenLifetime)
+}
+
+func (j *JWTGenerator) signAccessToken(params TokenParams, lifetime time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:    params.UserID,
+		Roles:     params.Roles,
+		SessionID: params.SessionID,
+		TokenType: TokenTypeAccess,
+		Scope:     params.Scope,
+		ClientID:  params.ClientID,
+		AuthTime:  authTimeClaim(params.AuthTime),
+		AMR:       params.AMR,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   params.UserID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(j.config.Secret))
+}
+
+func withTokenDefaults(params TokenParams) TokenParams {
+	if params.Scope == "" {
+		params.Scope = strings.Join(params.Roles, " ")
+	}
+	if params.ClientID == "" {
+		params.ClientID = FirstPartyClientID
+	}
+	return params
+}
+
+func authTimeClaim(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
 func (j *JWTGenerator) ParseAccessToken(tokenString string) (*Claims, error) {
-	// ... Parse Access Token logic
-	return nil, nil // Placeholder
+	return j.parse(tokenString, TokenTypeAccess)
 }
 
 func (j *JWTGenerator) ParseRefreshToken(tokenString string) (*Claims, error) {
-	// ... Parse Refresh Token logic
-	return nil, nil // Placeholder
+	return j.parse(tokenString, TokenTypeRefresh)
+}
+
+func (j *JWTGenerator) parse(tokenString, expectedType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(j.config.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if claims.TokenType != expectedType {
+		return nil, ErrInvalidTokenType
+	}
+	return claims, nil
 }
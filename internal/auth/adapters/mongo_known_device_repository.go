@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/auth/domain"
+	"github.com/iots1/mingkwan-api/internal/auth/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type MongoKnownDeviceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoKnownDeviceRepository(db *mongo.Database, collectionName string) *MongoKnownDeviceRepository {
+	return &MongoKnownDeviceRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+// GetKnownDevice returns nil without an error when the fingerprint is unknown.
+func (r *MongoKnownDeviceRepository) GetKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) (*domain.KnownDevice, error) {
+	var device domain.KnownDevice
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "fingerprint": fingerprint}).Decode(&device)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find known device: %w", err)
+	}
+	return &device, nil
+}
+
+func (r *MongoKnownDeviceRepository) ListKnownDevices(ctx context.Context, userID userDomain.UserID) ([]domain.KnownDevice, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get known devices cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	devices := []domain.KnownDevice{}
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, fmt.Errorf("failed to decode known devices: %w", err)
+	}
+	return devices, nil
+}
+
+func (r *MongoKnownDeviceRepository) CountKnownDevices(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count known devices: %w", err)
+	}
+	return count, nil
+}
+
+func (r *MongoKnownDeviceRepository) AddKnownDevice(ctx context.Context, device *domain.KnownDevice) error {
+	if device.ID.IsZero() {
+		device.ID = primitive.NewObjectID()
+	}
+	if _, err := r.collection.InsertOne(ctx, device); err != nil {
+		return fmt.Errorf("failed to insert known device: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoKnownDeviceRepository) TouchKnownDevice(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error {
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"last_seen_at": seenAt}})
+	if err != nil {
+		return fmt.Errorf("failed to touch known device: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoKnownDeviceRepository) RemoveKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "fingerprint": fingerprint})
+	if err != nil {
+		return fmt.Errorf("failed to remove known device: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoKnownDeviceRepository) DeleteKnownDevicesByUser(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete known devices: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+var _ repository.KnownDeviceRepository = (*MongoKnownDeviceRepository)(nil)
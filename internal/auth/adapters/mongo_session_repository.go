@@ -0,0 +1,134 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/auth/domain"
+	"github.com/iots1/mingkwan-api/internal/auth/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSessionRepository(db *mongo.Database, collectionName string) *MongoSessionRepository {
+	return &MongoSessionRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoSessionRepository) CreateSession(ctx context.Context, session *domain.Session) (*domain.Session, error) {
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	if _, err := r.collection.InsertOne(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to insert session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *MongoSessionRepository) GetSessionByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *MongoSessionRepository) GetSessionByNotMeTokenHash(ctx context.Context, tokenHash string) (*domain.Session, error) {
+	return r.findOne(ctx, bson.M{"not_me_token_hash": tokenHash})
+}
+
+func (r *MongoSessionRepository) findOne(ctx context.Context, filter bson.M) (*domain.Session, error) {
+	var session domain.Session
+	err := r.collection.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *MongoSessionRepository) ListSessionsByUser(ctx context.Context, userID userDomain.UserID) ([]domain.Session, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []domain.Session{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to decode sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *MongoSessionRepository) TouchSession(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error {
+	_, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"last_seen_at": seenAt}})
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoSessionRepository) RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoke_reason": reason}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *MongoSessionRepository) RevokeAllSessions(ctx context.Context, userID userDomain.UserID, reason string) ([]primitive.ObjectID, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": nil}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find active sessions: %w", err)
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to decode active sessions: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ID)
+	}
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoke_reason": reason}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *MongoSessionRepository) DeleteSessionsByUser(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+var _ repository.SessionRepository = (*MongoSessionRepository)(nil)
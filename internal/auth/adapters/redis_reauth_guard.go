@@ -0,0 +1,80 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iots1/mingkwan-api/internal/auth/repository"
+)
+
+const (
+	reauthFailuresKeyPrefix = "auth:reauth_failures:"
+	totpLastStepKeyPrefix   = "auth:totp_last_step:"
+)
+
+// useTOTPStepScript sets the last used step only if the new one is after it, so
+// that two concurrent requests cannot both spend the same code.
+var useTOTPStepScript = redis.NewScript(`
+local last = redis.call('GET', KEYS[1])
+if last and tonumber(ARGV[1]) <= tonumber(last) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// RedisReauthGuard keeps re-authentication failure counters and the last used TOTP
+// steps in Redis.
+type RedisReauthGuard struct {
+	client *redis.Client
+}
+
+func NewRedisReauthGuard(client *redis.Client) *RedisReauthGuard {
+	return &RedisReauthGuard{client: client}
+}
+
+func (g *RedisReauthGuard) FailedAttempts(ctx context.Context, userID string) (int64, error) {
+	n, err := g.client.Get(ctx, reauthFailuresKeyPrefix+userID).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get re-authentication failures of user '%s': %w", userID, err)
+	}
+	return n, nil
+}
+
+func (g *RedisReauthGuard) RecordFailedAttempt(ctx context.Context, userID string, window time.Duration) (int64, error) {
+	key := reauthFailuresKeyPrefix + userID
+	n, err := g.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count re-authentication failure of user '%s': %w", userID, err)
+	}
+	if n == 1 {
+		if err := g.client.Expire(ctx, key, window).Err(); err != nil {
+			return n, fmt.Errorf("failed to expire re-authentication failures of user '%s': %w", userID, err)
+		}
+	}
+	return n, nil
+}
+
+func (g *RedisReauthGuard) ResetFailedAttempts(ctx context.Context, userID string) error {
+	if err := g.client.Del(ctx, reauthFailuresKeyPrefix+userID).Err(); err != nil {
+		return fmt.Errorf("failed to reset re-authentication failures of user '%s': %w", userID, err)
+	}
+	return nil
+}
+
+func (g *RedisReauthGuard) UseTOTPStep(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error) {
+	ok, err := useTOTPStepScript.Run(ctx, g.client, []string{totpLastStepKeyPrefix + userID}, step, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step of user '%s': %w", userID, err)
+	}
+	return ok == 1, nil
+}
+
+var _ repository.ReauthGuard = (*RedisReauthGuard)(nil)
@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	// Codes from one step before and after the current one are accepted to tolerate
+	// clock drift between the server and the authenticator app.
+	totpSkewSteps = 1
+)
+
+// TOTPCodeLifetime is how long a code stays acceptable, given the clock drift
+// tolerance. A used code must be remembered at least this long to stop a replay.
+const TOTPCodeLifetime = (2*totpSkewSteps + 1) * totpPeriod
+
+// VerifyTOTP checks that code is a valid RFC 6238 code (HMAC-SHA1, 6 digits, 30
+// second steps) for the base32-encoded secret at time t. It returns the time step
+// the code belongs to, which callers record to reject the code if it is replayed.
+func VerifyTOTP(secret, code string, t time.Time) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	step := t.Unix() / int64(totpPeriod/time.Second)
+	matched, valid := int64(0), 0
+	for offset := int64(-totpSkewSteps); offset <= totpSkewSteps; offset++ {
+		expected := hotp(key, uint64(step+offset))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			matched, valid = step+offset, 1
+		}
+	}
+	return matched, valid == 1
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	normalized = strings.TrimRight(normalized, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+}
+
+// hotp computes an RFC 4226 one-time password for counter.
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
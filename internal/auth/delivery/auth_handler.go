@@ -6,9 +6,9 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 
+	"github.com/iots1/mingkwan-api/config"
 	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
 	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
 	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
@@ -32,10 +32,11 @@ type AuthHandler struct {
 	userUsecase    userUsecase.UserUsecase
 	jwtGenerator   authAdapter.JWTTokenGenerator
 	passwordHasher sharedAdapter.PasswordHasher
+	cookieConfig   config.AuthCookieConfig
 }
 
-func NewAuthHandler(authUsecase authUsecase.AuthUsecase, userUsecase userUsecase.UserUsecase, jwtGenerator authAdapter.JWTTokenGenerator, passwordHasher sharedAdapter.PasswordHasher) *AuthHandler {
-	return &AuthHandler{authUsecase: authUsecase, userUsecase: userUsecase, jwtGenerator: jwtGenerator, passwordHasher: passwordHasher}
+func NewAuthHandler(authUsecase authUsecase.AuthUsecase, userUsecase userUsecase.UserUsecase, jwtGenerator authAdapter.JWTTokenGenerator, passwordHasher sharedAdapter.PasswordHasher, cookieConfig config.AuthCookieConfig) *AuthHandler {
+	return &AuthHandler{authUsecase: authUsecase, userUsecase: userUsecase, jwtGenerator: jwtGenerator, passwordHasher: passwordHasher, cookieConfig: cookieConfig}
 }
 
 func (h *AuthHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
@@ -64,6 +65,19 @@ func (h *AuthHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message st
 	})
 }
 
+// sendTokenResponse returns the token pair in the body or, in cookie session mode,
+// as HttpOnly cookies with only the CSRF token in the body.
+func (h *AuthHandler) sendTokenResponse(c *fiber.Ctx, statusCode int, tokens *authModel.AuthResponse) error {
+	if !h.cookieConfig.Enabled {
+		return h.sendSuccessResponse(c, statusCode, tokens, 1)
+	}
+	cookieResp, err := setSessionCookies(c, h.cookieConfig, tokens, h.jwtGenerator.AccessTokenLifetime())
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to issue session cookies", err, nil)
+	}
+	return h.sendSuccessResponse(c, statusCode, cookieResp, 1)
+}
+
 func (h *AuthHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
 	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
 		Code:    statusCode,
@@ -89,144 +103,221 @@ func (s *AuthHandler) Register(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	existingUser, err := s.userUsecase.GetUserByEmail(ctx, req.Email)
-	if err != nil && !errors.Is(err, ErrUserNotFound) {
-		utils.Logger.Error("Error checking existing user by email", zap.Error(err), zap.String("email", req.Email))
-		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to check existing user", err, nil)
-	}
-	if existingUser != nil {
-		utils.Logger.Warn("Registration failed: Email already exists", zap.String("email", req.Email))
-		return s.sendErrorResponse(c, fiber.StatusConflict, ErrEmailAlreadyExists.Error(), nil, nil)
-	}
-
-	hashedPassword, err := s.passwordHasher.HashPassword(req.Password)
-	if err != nil {
-		utils.Logger.Error("Failed to hash password during registration", zap.Error(err))
-		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to hash password", err, nil)
-	}
-
 	newUser := &userDomain.User{
-		ID:        primitive.NewObjectID(),
 		Name:      req.Name,
 		Email:     req.Email,
-		Password:  hashedPassword,
+		Password:  req.Password, // hashed by the user use case
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		IsActive:  true,
 	}
 
-	createdUser, err := s.userUsecase.CreateUser(ctx, newUser)
+	resp, err := s.authUsecase.Register(ctx, newUser, ensureDeviceCookie(c))
 	if err != nil {
 		if errors.Is(err, userDomain.ErrUserAlreadyExists) {
 			utils.Logger.Info("Register: User already exists", zap.String("email", req.Email))
-			return s.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+			return s.sendErrorResponse(c, fiber.StatusConflict, ErrEmailAlreadyExists.Error(), nil, nil)
 		}
-		utils.Logger.Error("Failed to create user in database", zap.Error(err), zap.String("email", req.Email))
-		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to create user", err, nil)
+		utils.Logger.Error("Register: Failed to register user", zap.Error(err), zap.String("email", req.Email))
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to register user", err, nil)
 	}
 
-	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(createdUser.ID.Hex())
-	if err != nil {
-		utils.Logger.Error("Failed to generate tokens after registration", zap.Error(err), zap.String("userID", createdUser.ID.Hex()))
-		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to generate tokens", err, nil)
+	return s.sendTokenResponse(c, fiber.StatusCreated, resp)
+}
+
+func (s *AuthHandler) Login(c *fiber.Ctx) error {
+	var req authModel.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("Login: Invalid request body", zap.Error(err))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("Login: Validation failed", zap.Any("validation_details", formattedErrors))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
 	}
 
-	// Publish event (e.g., UserRegisteredEvent)
-	// s.lowPublisher.Publish(ctx, event.NewUserRegisteredEvent(createdUser.ID.Hex(), createdUser.Email))
-	utils.Logger.Info("User registered successfully", zap.String("userID", createdUser.ID.Hex()), zap.String("email", createdUser.Email))
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := s.authUsecase.Login(ctx, &req, ensureDeviceCookie(c))
+	if err != nil {
+		if errors.Is(err, authUsecase.ErrInvalidCredentials) {
+			return s.sendErrorResponse(c, fiber.StatusUnauthorized, ErrInvalidCredentials.Error(), nil, nil)
+		}
+		if errors.Is(err, authUsecase.ErrAccountInactive) {
+			return s.sendErrorResponse(c, fiber.StatusForbidden, err.Error(), nil, nil)
+		}
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to login", err, nil)
+	}
 
-	return s.sendSuccessResponse(c, fiber.StatusCreated, &authModel.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, 1)
+	return s.sendTokenResponse(c, fiber.StatusOK, resp)
 }
 
-func (s *AuthHandler) Login(ctx context.Context, req *authModel.LoginRequest) (*authModel.AuthResponse, error) {
-	utils.Logger.Debug("Attempting user login", zap.String("email", req.Email))
+func (s *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req authModel.RefreshRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.Logger.Warn("Refresh: Invalid request body", zap.Error(err))
+			return s.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+		}
+	}
+	if req.RefreshToken == "" && s.cookieConfig.Enabled {
+		req.RefreshToken = c.Cookies(RefreshTokenCookie)
+	}
+
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("Refresh: Validation failed", zap.Any("validation_details", formattedErrors))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
 
-	user, err := s.userUsecase.GetUserByEmail(ctx, req.Email)
+	resp, err := s.authUsecase.RefreshTokens(ctx, &req)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			utils.Logger.Warn("Login failed: User not found", zap.String("email", req.Email))
-			return nil, ErrInvalidCredentials
+		if errors.Is(err, authUsecase.ErrInvalidToken) {
+			return s.sendErrorResponse(c, fiber.StatusUnauthorized, ErrInvalidToken.Error(), nil, nil)
 		}
-		utils.Logger.Error("Error finding user by email during login", zap.Error(err), zap.String("email", req.Email))
-		return nil, err
+		if errors.Is(err, authUsecase.ErrAccountInactive) {
+			return s.sendErrorResponse(c, fiber.StatusForbidden, err.Error(), nil, nil)
+		}
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to refresh tokens", err, nil)
 	}
 
-	// Check password
-	if !s.passwordHasher.CheckPasswordHash(req.Password, user.Password) {
-		utils.Logger.Warn("Login failed: Invalid password", zap.String("email", req.Email))
-		return nil, ErrInvalidCredentials
+	return s.sendTokenResponse(c, fiber.StatusOK, resp)
+}
+
+func (s *AuthHandler) GetProfile(c *fiber.Ctx) error {
+	claims := GetClaims(c)
+	if claims == nil {
+		return s.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
 	}
 
-	// Generate tokens
-	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(user.ID.Hex())
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	profile, err := s.authUsecase.GetProfile(ctx, claims.UserID)
 	if err != nil {
-		utils.Logger.Error("Failed to generate tokens after login", zap.Error(err), zap.String("userID", user.ID.Hex()))
-		return nil, errors.New("failed to generate tokens")
+		if errors.Is(err, authUsecase.ErrUserNotFound) {
+			return s.sendErrorResponse(c, fiber.StatusNotFound, ErrUserNotFound.Error(), nil, nil)
+		}
+		if errors.Is(err, authUsecase.ErrInvalidToken) {
+			return s.sendErrorResponse(c, fiber.StatusUnauthorized, ErrInvalidToken.Error(), nil, nil)
+		}
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve profile", err, nil)
 	}
 
-	// Publish event (e.g., UserLoggedInEvent)
-	// s.highPublisher.Publish(ctx, event.NewUserLoggedInEvent(user.ID.Hex()))
-	utils.Logger.Info("User logged in successfully", zap.String("userID", user.ID.Hex()), zap.String("email", user.Email))
-
-	return &authModel.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.sendSuccessResponse(c, fiber.StatusOK, profile, 1)
 }
 
-func (s *AuthHandler) RefreshTokens(ctx context.Context, req *authModel.RefreshRequest) (*authModel.AuthResponse, error) {
-	claims, err := s.jwtGenerator.ParseRefreshToken(req.RefreshToken)
-	if err != nil {
-		utils.Logger.Warn("Refresh token invalid or expired", zap.Error(err))
-		return nil, ErrInvalidToken
+// Reauthenticate exchanges a fresh proof of identity (password or TOTP code) for a
+// short-lived elevated access token accepted by routes guarded with RequireRecentAuth.
+func (s *AuthHandler) Reauthenticate(c *fiber.Ctx) error {
+	claims := GetClaims(c)
+	if claims == nil {
+		return s.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
 	}
 
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		utils.Logger.Warn("Invalid user ID format in refresh token", zap.String("userID", claims.UserID), zap.Error(err))
-		return nil, ErrInvalidToken
+	var req authModel.ReauthenticateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("Reauthenticate: Invalid request body", zap.Error(err))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("Reauthenticate: Validation failed", zap.Any("validation_details", formattedErrors))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
 	}
 
-	user, err := s.userUsecase.GetUserByID(ctx, userID)
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := s.authUsecase.Reauthenticate(ctx, claims.UserID, claims.SessionID, &req)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			utils.Logger.Warn("Refresh failed: User not found for token", zap.String("userID", claims.UserID))
-			return nil, ErrInvalidToken
+		if errors.Is(err, authUsecase.ErrInvalidCredentials) {
+			return s.sendErrorResponse(c, fiber.StatusUnauthorized, "Invalid credentials", nil, nil)
 		}
-		utils.Logger.Error("Error finding user for refresh token", zap.Error(err), zap.String("userID", claims.UserID))
-		return nil, err
+		if errors.Is(err, authUsecase.ErrTooManyAttempts) {
+			return s.sendErrorResponse(c, fiber.StatusTooManyRequests, err.Error(), nil, nil)
+		}
+		if errors.Is(err, authUsecase.ErrTOTPNotEnrolled) {
+			return s.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		if errors.Is(err, authUsecase.ErrAccountInactive) {
+			return s.sendErrorResponse(c, fiber.StatusForbidden, err.Error(), nil, nil)
+		}
+		if errors.Is(err, authUsecase.ErrInvalidToken) {
+			return s.sendErrorResponse(c, fiber.StatusUnauthorized, ErrInvalidToken.Error(), nil, nil)
+		}
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to re-authenticate", err, nil)
 	}
 
-	newAccessToken, newRefreshToken, err := s.jwtGenerator.GenerateTokens(user.ID.Hex())
-	if err != nil {
-		utils.Logger.Error("Failed to generate new tokens during refresh", zap.Error(err), zap.String("userID", user.ID.Hex()))
-		return nil, errors.New("failed to generate new tokens")
+	if s.cookieConfig.Enabled {
+		setAccessTokenCookie(c, s.cookieConfig, resp.AccessToken, authAdapter.StepUpTokenLifetime)
+		resp.AccessToken = ""
+	}
+	return s.sendSuccessResponse(c, fiber.StatusOK, resp, 1)
+}
+
+// ReportNotMe is the target of the "this wasn't me" link in new-device login alerts.
+// It is unauthenticated: possession of the single-use token is the proof.
+func (s *AuthHandler) ReportNotMe(c *fiber.Ctx) error {
+	var req authModel.NotMeRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("ReportNotMe: Invalid request body", zap.Error(err))
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
 	}
 
-	utils.Logger.Info("Tokens refreshed successfully", zap.String("userID", user.ID.Hex()))
-	return &authModel.AuthResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-	}, nil
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		return s.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.authUsecase.ReportNotMe(ctx, req.Token); err != nil {
+		if errors.Is(err, authUsecase.ErrInvalidNotMeToken) {
+			return s.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to revoke session", err, nil)
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
 }
 
-func (s *AuthHandler) GetProfile(ctx context.Context, userID primitive.ObjectID) (*authModel.ProfileResponse, error) {
-	user, err := s.userUsecase.GetUserByID(ctx, userID)
+func (s *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	claims := GetClaims(c)
+	if claims == nil {
+		return s.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := s.authUsecase.ListSessions(ctx, claims.UserID)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			utils.Logger.Warn("Profile retrieval failed: User not found", zap.String("userID", userID.String()))
-			return nil, ErrUserNotFound
-		}
-		utils.Logger.Error("Error finding user by ID for profile", zap.Error(err), zap.String("userID", userID.String()))
-		return nil, err
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve sessions", err, nil)
+	}
+	return s.sendSuccessResponse(c, fiber.StatusOK, sessions, len(sessions))
+}
+
+func (s *AuthHandler) ListKnownDevices(c *fiber.Ctx) error {
+	claims := GetClaims(c)
+	if claims == nil {
+		return s.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
 	}
 
-	return &authModel.ProfileResponse{
-		ID:    user.ID.Hex(),
-		Name:  user.Name,
-		Email: user.Email,
-	}, nil
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	devices, err := s.authUsecase.ListKnownDevices(ctx, claims.UserID)
+	if err != nil {
+		return s.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve devices", err, nil)
+	}
+	return s.sendSuccessResponse(c, fiber.StatusOK, devices, len(devices))
 }
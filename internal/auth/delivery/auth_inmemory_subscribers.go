@@ -0,0 +1,98 @@
+package delivery
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// AuthInmemoryEventSubscribers reacts to user lifecycle events that affect
+// authentication.
+type AuthInmemoryEventSubscribers struct {
+	inMemoryBus *event.InMemPubSub
+	authUsecase authUsecase.AuthUsecase
+}
+
+func NewAuthInmemoryEventSubscribers(bus *event.InMemPubSub, authUsecase authUsecase.AuthUsecase) *AuthInmemoryEventSubscribers {
+	return &AuthInmemoryEventSubscribers{inMemoryBus: bus, authUsecase: authUsecase}
+}
+
+func (s *AuthInmemoryEventSubscribers) StartAllSubscribers(ctx context.Context) {
+	go s.listenToUserDeactivatedEvents(ctx)
+	go s.listenToUserErasedEvents(ctx)
+	utils.Logger.Info("AuthFeature/In-Memory Subscribers: All listeners started.")
+}
+
+// listenToUserDeactivatedEvents revokes the sessions of deactivated users. Login and
+// refresh reject inactive users independently, so a missed event only leaves already
+// issued access tokens valid until they expire.
+func (s *AuthInmemoryEventSubscribers) listenToUserDeactivatedEvents(ctx context.Context) {
+	ch := s.inMemoryBus.SubscribeEvent(event.UserDeactivatedInMemoryEvent)
+	utils.Logger.Info("AuthFeature/In-Memory Subscriber: Listening for 'user.deactivated.inmemory' events.")
+
+	for {
+		select {
+		case eventData := <-ch:
+			payload, ok := eventData.(event.UserActivationPayload)
+			if !ok {
+				utils.Logger.Warn("AuthFeature/In-Memory Subscriber: Received unexpected payload type for 'user.deactivated.inmemory' event.",
+					zap.Any("event_data", eventData))
+				continue
+			}
+
+			revokeCtx, cancel := context.WithTimeout(utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: payload.ActorID}), 10*time.Second)
+			revoked, err := s.authUsecase.RevokeAllSessions(revokeCtx, userDomain.UserID(payload.UserID), authDomain.RevokeReasonUserDeactivated)
+			cancel()
+			if err != nil {
+				utils.Logger.Error("AuthFeature/In-Memory Subscriber: Failed to revoke sessions of deactivated user",
+					zap.String("user_id", payload.UserID), zap.Error(err))
+				continue
+			}
+			utils.Logger.Info("AuthFeature/In-Memory Subscriber: Revoked sessions of deactivated user",
+				zap.String("user_id", payload.UserID), zap.Int("sessions", revoked))
+		case <-ctx.Done():
+			utils.Logger.Info("AuthFeature/In-Memory Subscriber: 'user.deactivated.inmemory' event listener stopped.", zap.Error(ctx.Err()))
+			return
+		}
+	}
+}
+
+// listenToUserErasedEvents erases sessions and known devices created while the erasure
+// job ran, e.g. by a login that completed just before the user was anonymized.
+func (s *AuthInmemoryEventSubscribers) listenToUserErasedEvents(ctx context.Context) {
+	ch := s.inMemoryBus.SubscribeEvent(event.UserErasedInMemoryEvent)
+	utils.Logger.Info("AuthFeature/In-Memory Subscriber: Listening for 'user.erased.inmemory' events.")
+
+	for {
+		select {
+		case eventData := <-ch:
+			payload, ok := eventData.(event.UserErasedPayload)
+			if !ok {
+				utils.Logger.Warn("AuthFeature/In-Memory Subscriber: Received unexpected payload type for 'user.erased.inmemory' event.",
+					zap.Any("event_data", eventData))
+				continue
+			}
+
+			eraseCtx, cancel := context.WithTimeout(utils.WithRequestMeta(ctx, &utils.RequestMeta{CorrelationID: payload.RequestID}), 10*time.Second)
+			sessions, devices, err := s.authUsecase.EraseUserData(eraseCtx, userDomain.UserID(payload.UserID))
+			cancel()
+			if err != nil {
+				utils.Logger.Error("AuthFeature/In-Memory Subscriber: Failed to erase authentication data of erased user",
+					zap.String("user_id", payload.UserID), zap.Error(err))
+				continue
+			}
+			utils.Logger.Info("AuthFeature/In-Memory Subscriber: Erased remaining authentication data of erased user",
+				zap.String("user_id", payload.UserID), zap.Int64("sessions", sessions), zap.Int64("devices", devices))
+		case <-ctx.Done():
+			utils.Logger.Info("AuthFeature/In-Memory Subscriber: 'user.erased.inmemory' event listener stopped.", zap.Error(ctx.Err()))
+			return
+		}
+	}
+}
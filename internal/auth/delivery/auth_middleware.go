@@ -0,0 +1,167 @@
+package delivery
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
+	authRepository "github.com/iots1/mingkwan-api/internal/auth/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// ClaimsLocalKey is the fiber Locals key holding the authenticated *authAdapter.Claims.
+const ClaimsLocalKey = "auth_claims"
+
+// RoleResolver returns the roles a user holds now, which may be fewer than their
+// token claims.
+type RoleResolver interface {
+	CurrentRoles(ctx context.Context, userID string) ([]string, error)
+}
+
+// AuthMiddleware guards routes with the access tokens issued by the auth module.
+type AuthMiddleware struct {
+	jwtGenerator   authAdapter.JWTTokenGenerator
+	revocations    authRepository.RevocationStore
+	roles          RoleResolver // nil until SetRoleResolver
+	cookieSessions bool
+}
+
+func NewAuthMiddleware(jwtGenerator authAdapter.JWTTokenGenerator, revocations authRepository.RevocationStore, cookieSessions bool) *AuthMiddleware {
+	return &AuthMiddleware{jwtGenerator: jwtGenerator, revocations: revocations, cookieSessions: cookieSessions}
+}
+
+// SetRoleResolver makes RequireRole confirm roles with resolver. The middleware is
+// created before the modules that can resolve roles, so it is set afterwards.
+func (m *AuthMiddleware) SetRoleResolver(resolver RoleResolver) {
+	m.roles = resolver
+}
+
+// RequireAuth rejects requests without a valid access token and records the
+// authenticated user as the request actor. The token is read from the bearer
+// header or, in cookie session mode, from the access token cookie (CSRF for those
+// requests is enforced by middleware.CSRFProtection).
+func (m *AuthMiddleware) RequireAuth() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := m.accessToken(c)
+		if tokenString == "" {
+			return sendUnauthorized(c, "Missing access token")
+		}
+
+		claims, err := m.jwtGenerator.ParseAccessToken(tokenString)
+		if err != nil {
+			utils.Logger.Warn("AuthMiddleware: Invalid access token", zap.String("path", c.Path()), zap.Error(err))
+			return sendUnauthorized(c, ErrInvalidToken.Error())
+		}
+
+		revoked, err := authAdapter.IsClaimsRevoked(c.Context(), m.revocations, claims)
+		if err != nil {
+			// Fail closed: a token we cannot check might belong to a revoked session.
+			utils.Logger.Error("AuthMiddleware: Failed to check token revocation", zap.String("session_id", claims.SessionID), zap.Error(err))
+			return sendAuthError(c, fiber.StatusServiceUnavailable, "Unable to verify session")
+		}
+		if revoked {
+			utils.Logger.Warn("AuthMiddleware: Revoked token used", zap.String("user_id", claims.UserID), zap.String("session_id", claims.SessionID))
+			return sendUnauthorized(c, ErrInvalidToken.Error())
+		}
+
+		c.Locals(ClaimsLocalKey, claims)
+		meta := middleware.GetRequestMeta(c)
+		meta.ActorID = claims.UserID
+		meta.ActorRoles = claims.Roles
+		meta.SessionID = claims.SessionID
+		meta.AuthTime = claims.AuthenticatedAt()
+		return c.Next()
+	}
+}
+
+// OptionalAuth authenticates requests that present an access token, like
+// RequireAuth, and passes anonymous requests through with no actor. A presented but
+// invalid token is still rejected.
+func (m *AuthMiddleware) OptionalAuth() fiber.Handler {
+	requireAuth := m.RequireAuth()
+	return func(c *fiber.Ctx) error {
+		if m.accessToken(c) == "" {
+			return c.Next()
+		}
+		return requireAuth(c)
+	}
+}
+
+// accessToken returns the access token of the request, "" if it has none.
+func (m *AuthMiddleware) accessToken(c *fiber.Ctx) string {
+	tokenString, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found && m.cookieSessions {
+		tokenString = c.Cookies(AccessTokenCookie)
+	}
+	return tokenString
+}
+
+// RequireRecentAuth must run after RequireAuth and rejects tokens whose auth_time
+// is older than maxAge with a step-up challenge.
+func (m *AuthMiddleware) RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		meta := middleware.GetRequestMeta(c)
+		if !middleware.HasRecentAuth(meta, maxAge) {
+			utils.Logger.Info("AuthMiddleware: Step-up authentication required",
+				zap.String("user_id", meta.ActorID), zap.String("path", c.Path()))
+			return middleware.SendStepUpRequired(c, maxAge)
+		}
+		return c.Next()
+	}
+}
+
+// RequireRole must run after RequireAuth and rejects callers holding none of roles.
+// A role must be in the token and, once a RoleResolver is set, still be held, so
+// that removing a user from a group takes effect on their next request.
+func (m *AuthMiddleware) RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaims(c)
+		if claims == nil {
+			return sendUnauthorized(c, "Authentication required")
+		}
+		claimed := slices.ContainsFunc(roles, claims.HasRole)
+		if claimed && m.roles != nil {
+			current, err := m.roles.CurrentRoles(c.Context(), claims.UserID)
+			if err != nil {
+				// Fail closed: the role may have been withdrawn.
+				utils.Logger.Error("AuthMiddleware: Failed to resolve current roles", zap.String("user_id", claims.UserID), zap.Error(err))
+				return sendAuthError(c, fiber.StatusServiceUnavailable, "Unable to verify permissions")
+			}
+			claimed = slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(current, role) })
+		}
+		if claimed {
+			return c.Next()
+		}
+		utils.Logger.Warn("AuthMiddleware: Forbidden, missing role",
+			zap.String("user_id", claims.UserID), zap.Strings("required_roles", roles), zap.String("path", c.Path()))
+		return sendAuthError(c, fiber.StatusForbidden, "Insufficient permissions")
+	}
+}
+
+// GetClaims returns the claims stored by RequireAuth, or nil for anonymous requests.
+func GetClaims(c *fiber.Ctx) *authAdapter.Claims {
+	claims, _ := c.Locals(ClaimsLocalKey).(*authAdapter.Claims)
+	return claims
+}
+
+func sendUnauthorized(c *fiber.Ctx, message string) error {
+	return sendAuthError(c, fiber.StatusUnauthorized, message)
+}
+
+func sendAuthError(c *fiber.Ctx, statusCode int, message string) error {
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
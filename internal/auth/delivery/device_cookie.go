@@ -0,0 +1,32 @@
+package delivery
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	deviceCookieName   = "mk_device_id"
+	deviceCookieMaxAge = 2 * 365 * 24 * time.Hour
+)
+
+// ensureDeviceCookie returns the caller's long-lived device identifier, issuing a
+// new one when the cookie is missing or malformed.
+func ensureDeviceCookie(c *fiber.Ctx) string {
+	deviceID := c.Cookies(deviceCookieName)
+	if _, err := uuid.Parse(deviceID); err != nil {
+		deviceID = uuid.NewString()
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     deviceCookieName,
+		Value:    deviceID,
+		Path:     "/",
+		Expires:  time.Now().Add(deviceCookieMaxAge),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return deviceID
+}
@@ -0,0 +1,120 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
+	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// OAuthClientLocalKey is the fiber Locals key holding the authenticated client ID.
+const OAuthClientLocalKey = "oauth_client_id"
+
+// OAuthHandler serves the RFC 7662 introspection and RFC 7009 revocation endpoints.
+// Both are form-encoded and answer in the OAuth error format.
+type OAuthHandler struct {
+	authUsecase authUsecase.AuthUsecase
+	clients     map[string]string
+}
+
+func NewOAuthHandler(authUsecase authUsecase.AuthUsecase, clients map[string]string) *OAuthHandler {
+	return &OAuthHandler{authUsecase: authUsecase, clients: clients}
+}
+
+// RequireClientCredentials authenticates the calling client with HTTP Basic
+// credentials or, as RFC 6749 section 2.3.1 also allows, client_id and
+// client_secret form parameters.
+func (h *OAuthHandler) RequireClientCredentials() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clientID, clientSecret, ok := parseBasicAuth(c.Get(fiber.HeaderAuthorization))
+		if !ok {
+			clientID, clientSecret = c.FormValue("client_id"), c.FormValue("client_secret")
+		}
+
+		expected, known := h.clients[clientID]
+		if clientID == "" || !known || subtle.ConstantTimeCompare([]byte(expected), []byte(clientSecret)) != 1 {
+			utils.Logger.Warn("OAuthHandler: Client authentication failed", zap.String("client_id", clientID), zap.String("ip", c.IP()))
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+			return sendOAuthError(c, fiber.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		}
+
+		c.Locals(OAuthClientLocalKey, clientID)
+		return c.Next()
+	}
+}
+
+// Introspect handles POST /oauth/introspect.
+func (h *OAuthHandler) Introspect(c *fiber.Ctx) error {
+	token := c.FormValue("token")
+	if token == "" {
+		return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "The token parameter is required")
+	}
+
+	resp, err := h.authUsecase.IntrospectToken(c.Context(), token, c.FormValue("token_type_hint"))
+	if err != nil {
+		utils.Logger.Error("OAuthHandler: Token introspection failed", zap.String("client_id", oauthClientID(c)), zap.Error(err))
+		return sendOAuthError(c, fiber.StatusServiceUnavailable, "temporarily_unavailable", "Unable to determine token state")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// Revoke handles POST /oauth/revoke. It answers 200 for unknown tokens as well, so
+// clients cannot probe which tokens are valid.
+func (h *OAuthHandler) Revoke(c *fiber.Ctx) error {
+	token := c.FormValue("token")
+	if token == "" {
+		return sendOAuthError(c, fiber.StatusBadRequest, "invalid_request", "The token parameter is required")
+	}
+
+	if err := h.authUsecase.RevokeToken(c.Context(), token, c.FormValue("token_type_hint"), oauthClientID(c)); err != nil {
+		utils.Logger.Error("OAuthHandler: Token revocation failed", zap.String("client_id", oauthClientID(c)), zap.Error(err))
+		return sendOAuthError(c, fiber.StatusServiceUnavailable, "temporarily_unavailable", "Unable to revoke token")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func oauthClientID(c *fiber.Ctx) string {
+	clientID, _ := c.Locals(OAuthClientLocalKey).(string)
+	return clientID
+}
+
+// parseBasicAuth decodes RFC 6749 client credentials, whose ID and secret are
+// form-urlencoded before being base64 encoded.
+func parseBasicAuth(header string) (clientID, clientSecret string, ok bool) {
+	encoded, found := strings.CutPrefix(header, "Basic ")
+	if !found {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	rawID, rawSecret, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", false
+	}
+	if clientID, err = url.QueryUnescape(rawID); err != nil {
+		return "", "", false
+	}
+	if clientSecret, err = url.QueryUnescape(rawSecret); err != nil {
+		return "", "", false
+	}
+	return clientID, clientSecret, true
+}
+
+func sendOAuthError(c *fiber.Ctx, statusCode int, code, description string) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(statusCode).JSON(authModel.OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
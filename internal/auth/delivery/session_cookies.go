@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iots1/mingkwan-api/config"
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+)
+
+const (
+	AccessTokenCookie  = "mk_access_token"
+	RefreshTokenCookie = "mk_refresh_token"
+
+	// The refresh cookie is only sent to the auth endpoints that need it.
+	refreshCookiePath = "/api/v1/auth"
+)
+
+// setSessionCookies moves the token pair into HttpOnly cookies and issues a fresh
+// CSRF token, which the client must echo in the X-CSRF-Token header. The access
+// cookie lives as long as the access token; the token itself is still validated on
+// every request.
+func setSessionCookies(c *fiber.Ctx, cfg config.AuthCookieConfig, tokens *authModel.AuthResponse, accessLifetime time.Duration) (*authModel.CookieSessionResponse, error) {
+	csrfToken, err := generateCSRFToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	setAccessTokenCookie(c, cfg, tokens.AccessToken, accessLifetime)
+	c.Cookie(&fiber.Cookie{
+		Name:     RefreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     refreshCookiePath,
+		Domain:   cfg.Domain,
+		Expires:  now.Add(authDomain.SessionLifetime),
+		HTTPOnly: true,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
+	})
+	// Readable by the frontend on purpose: that is what makes double-submit work.
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  now.Add(authDomain.SessionLifetime),
+		HTTPOnly: false,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
+	})
+
+	return &authModel.CookieSessionResponse{CSRFToken: csrfToken}, nil
+}
+
+// setAccessTokenCookie replaces the access token cookie, e.g. with the elevated token
+// returned by re-authentication.
+func setAccessTokenCookie(c *fiber.Ctx, cfg config.AuthCookieConfig, accessToken string, maxAge time.Duration) {
+	c.Cookie(&fiber.Cookie{
+		Name:     AccessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		Expires:  time.Now().Add(maxAge),
+		HTTPOnly: true,
+		Secure:   cfg.Secure,
+		SameSite: cfg.SameSite,
+	})
+}
+
+func generateCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// KnownDevice is a device fingerprint the user has logged in from before.
+type KnownDevice struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      userDomain.UserID  `bson:"user_id" json:"user_id"`
+	Fingerprint string             `bson:"fingerprint" json:"fingerprint"`
+	DeviceID    string             `bson:"device_id" json:"-"`
+	UserAgent   string             `bson:"user_agent" json:"user_agent"`
+	IPSubnet    string             `bson:"ip_subnet" json:"ip_subnet"`
+	FirstSeenAt time.Time          `bson:"first_seen_at" json:"first_seen_at"`
+	LastSeenAt  time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+}
+
+// DeviceFingerprint identifies the device and network a login came from.
+type DeviceFingerprint struct {
+	Hash      string
+	DeviceID  string
+	UserAgent string
+	IPSubnet  string
+}
+
+// NewDeviceFingerprint derives a fingerprint from the user agent, the IP subnet
+// (/24 for IPv4, /48 for IPv6) and the long-lived device cookie. Using the subnet
+// rather than the exact address keeps DHCP churn from looking like a new device.
+func NewDeviceFingerprint(userAgent, ip, deviceID string) DeviceFingerprint {
+	subnet := IPSubnet(ip)
+	ua := strings.TrimSpace(userAgent)
+	sum := sha256.Sum256([]byte(ua + "|" + subnet + "|" + deviceID))
+	return DeviceFingerprint{
+		Hash:      hex.EncodeToString(sum[:]),
+		DeviceID:  deviceID,
+		UserAgent: ua,
+		IPSubnet:  subnet,
+	}
+}
+
+// IPSubnet masks ip to its /24 (IPv4) or /48 (IPv6) network. Unparseable input is
+// returned unchanged.
+func IPSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
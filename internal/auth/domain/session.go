@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// Session is a login of a user on a device. Access and refresh tokens carry the
+// session ID so that a session can be revoked before its tokens expire.
+type Session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         userDomain.UserID  `bson:"user_id" json:"user_id"`
+	DeviceID       string             `bson:"device_id" json:"device_id"`
+	Fingerprint    string             `bson:"fingerprint" json:"-"`
+	IP             string             `bson:"ip" json:"ip"`
+	UserAgent      string             `bson:"user_agent" json:"user_agent"`
+	NewDevice      bool               `bson:"new_device" json:"new_device"`
+	NotMeTokenHash string             `bson:"not_me_token_hash,omitempty" json:"-"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt     time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	RevokedAt      *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokeReason   string             `bson:"revoke_reason,omitempty" json:"revoke_reason,omitempty"`
+}
+
+// IsRevoked reports whether the session may no longer be used.
+func (s *Session) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+const (
+	RevokeReasonNotMe  = "reported_not_me"
+	RevokeReasonLogout = "logout"
+	// The refresh token was revoked through the OAuth revocation endpoint.
+	RevokeReasonTokenRevoked = "token_revoked"
+	// The account was deactivated by an admin.
+	RevokeReasonUserDeactivated = "user_deactivated"
+	// The user's personal data was erased on their request.
+	RevokeReasonUserErased = "user_erased"
+	// The user was merged into another user.
+	RevokeReasonUserMerged = "user_merged"
+)
+
+// SessionLifetime bounds how long a revoked session needs to be remembered: after
+// that, every token issued for it has expired anyway.
+const SessionLifetime = 7 * 24 * time.Hour
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been revoked")
+)
@@ -11,6 +11,19 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// RefreshRequest carries the refresh token in the body; in cookie session mode it is
+// filled from the refresh token cookie instead.
 type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
+
+type NotMeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ReauthenticateRequest proves the user's identity again with exactly one of the
+// account password or a TOTP code.
+type ReauthenticateRequest struct {
+	Password string `json:"password" validate:"required_without=TOTPCode"`
+	TOTPCode string `json:"totpCode" validate:"omitempty,len=6,numeric,excluded_with=Password"`
+}
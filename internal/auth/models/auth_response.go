@@ -5,6 +5,19 @@ type AuthResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+// CookieSessionResponse is returned instead of AuthResponse when tokens are delivered
+// as HttpOnly cookies.
+type CookieSessionResponse struct {
+	CSRFToken string `json:"csrfToken"`
+}
+
+// ReauthenticateResponse carries the elevated access token. In cookie session mode
+// the token is set as the access token cookie and AccessToken is left empty.
+type ReauthenticateResponse struct {
+	AccessToken string `json:"accessToken,omitempty"`
+	ExpiresIn   int    `json:"expiresIn"`
+}
+
 type ProfileResponse struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
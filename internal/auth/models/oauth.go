@@ -0,0 +1,21 @@
+package models
+
+// IntrospectionResponse is the RFC 7662 token introspection response. Inactive
+// tokens carry nothing but Active, so the optional members are omitted when empty.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// OAuthErrorResponse is the RFC 6749 error body used by the OAuth endpoints, which
+// answer in the OAuth format instead of the API's common envelope.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
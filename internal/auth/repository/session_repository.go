@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/auth/domain"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *domain.Session) (*domain.Session, error)
+	GetSessionByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error)
+	GetSessionByNotMeTokenHash(ctx context.Context, tokenHash string) (*domain.Session, error)
+	ListSessionsByUser(ctx context.Context, userID userDomain.UserID) ([]domain.Session, error)
+	TouchSession(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error
+	RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error
+	// RevokeAllSessions revokes every active session of the user and returns their IDs.
+	RevokeAllSessions(ctx context.Context, userID userDomain.UserID, reason string) ([]primitive.ObjectID, error)
+	// DeleteSessionsByUser removes every session of the user and returns how many.
+	DeleteSessionsByUser(ctx context.Context, userID userDomain.UserID) (int64, error)
+}
+
+type KnownDeviceRepository interface {
+	GetKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) (*domain.KnownDevice, error)
+	ListKnownDevices(ctx context.Context, userID userDomain.UserID) ([]domain.KnownDevice, error)
+	CountKnownDevices(ctx context.Context, userID userDomain.UserID) (int64, error)
+	AddKnownDevice(ctx context.Context, device *domain.KnownDevice) error
+	TouchKnownDevice(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error
+	RemoveKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) error
+	DeleteKnownDevicesByUser(ctx context.Context, userID userDomain.UserID) (int64, error)
+}
+
+// RevocationStore is a fast lookup of revoked sessions and individual tokens (by
+// jti), consulted on every authenticated request.
+type RevocationStore interface {
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// ReauthGuard throttles step-up re-authentication: it counts failed attempts per
+// user and remembers the last TOTP time step each user spent, so that an observed
+// code cannot be replayed.
+type ReauthGuard interface {
+	FailedAttempts(ctx context.Context, userID string) (int64, error)
+	// RecordFailedAttempt counts a failure and returns the failures so far. The count
+	// is reset window after the first failure.
+	RecordFailedAttempt(ctx context.Context, userID string, window time.Duration) (int64, error)
+	ResetFailedAttempts(ctx context.Context, userID string) error
+	// UseTOTPStep records step as the last TOTP step used by userID. It returns false,
+	// and records nothing, if step is not after the last recorded one.
+	UseTOTPStep(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+var ErrInvalidNotMeToken = errors.New("invalid or expired login alert token")
+
+// startSession records a new session for user on the calling device. When the
+// device fingerprint is unknown it is added to the user's known devices and, if
+// notifyNewDevice is set and the user already had known devices, the owner is
+// notified with a link to report the login.
+func (s *AuthUsecase) startSession(ctx context.Context, user *userDomain.User, deviceID string, notifyNewDevice bool) (*authDomain.Session, error) {
+	meta := utils.RequestMetaFromContext(ctx)
+	fingerprint := authDomain.NewDeviceFingerprint(meta.UserAgent, meta.IP, deviceID)
+	now := time.Now()
+
+	session := &authDomain.Session{
+		ID:          primitive.NewObjectID(),
+		UserID:      user.ID,
+		DeviceID:    deviceID,
+		Fingerprint: fingerprint.Hash,
+		IP:          meta.IP,
+		UserAgent:   fingerprint.UserAgent,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+	}
+
+	known, err := s.devices.GetKnownDevice(ctx, user.ID, fingerprint.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up known device: %w", err)
+	}
+
+	var notMeToken string
+	if known != nil {
+		if err := s.devices.TouchKnownDevice(ctx, known.ID, now); err != nil {
+			utils.Logger.Warn("AuthUsecase: Failed to update known device", zap.String("userID", user.ID.String()), zap.Error(err))
+		}
+	} else {
+		knownCount, err := s.devices.CountKnownDevices(ctx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count known devices: %w", err)
+		}
+		if err := s.devices.AddKnownDevice(ctx, &authDomain.KnownDevice{
+			UserID:      user.ID,
+			Fingerprint: fingerprint.Hash,
+			DeviceID:    deviceID,
+			UserAgent:   fingerprint.UserAgent,
+			IPSubnet:    fingerprint.IPSubnet,
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to add known device: %w", err)
+		}
+
+		// The very first device of an account (or of an account created before device
+		// tracking existed) is trusted silently.
+		if notifyNewDevice && knownCount > 0 {
+			session.NewDevice = true
+			if notMeToken, err = generateOpaqueToken(); err != nil {
+				return nil, fmt.Errorf("failed to generate login alert token: %w", err)
+			}
+			session.NotMeTokenHash = hashOpaqueToken(notMeToken)
+		}
+	}
+
+	if _, err := s.sessions.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	if session.NewDevice {
+		s.notifyNewDeviceLogin(ctx, user, session, fingerprint, notMeToken)
+	}
+	return session, nil
+}
+
+func (s *AuthUsecase) notifyNewDeviceLogin(ctx context.Context, user *userDomain.User, session *authDomain.Session, fingerprint authDomain.DeviceFingerprint, notMeToken string) {
+	payload := event.NewDeviceLoginPayload{
+		UserID:    user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		SessionID: session.ID.Hex(),
+		IP:        session.IP,
+		IPSubnet:  fingerprint.IPSubnet,
+		UserAgent: session.UserAgent,
+		LoginAt:   session.CreatedAt,
+		NotMeURL:  s.publicURL + "/login-alerts/not-me?token=" + url.QueryEscape(notMeToken),
+	}
+	if err := s.highPublisher.Publish(ctx, event.NewDeviceLoginNotificationTask, payload); err != nil {
+		utils.Logger.Error("AuthUsecase: Failed to enqueue new-device login notification",
+			zap.String("userID", user.ID.String()), zap.String("sessionID", session.ID.Hex()), zap.Error(err))
+	}
+	s.recordAudit(ctx, auditDomain.ActionAuthNewDevice, user.ID.String(), nil, map[string]interface{}{
+		"session_id": session.ID.Hex(),
+		"ip_subnet":  fingerprint.IPSubnet,
+	})
+}
+
+// ensureSessionActive returns authDomain.ErrSessionRevoked if the session has been
+// revoked. Tokens issued before sessions existed carry no session ID and are accepted.
+func (s *AuthUsecase) ensureSessionActive(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	oid, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return authDomain.ErrSessionNotFound
+	}
+	session, err := s.sessions.GetSessionByID(ctx, oid)
+	if err != nil {
+		return err
+	}
+	if session.IsRevoked() {
+		return authDomain.ErrSessionRevoked
+	}
+	if err := s.sessions.TouchSession(ctx, oid, time.Now()); err != nil {
+		utils.Logger.Warn("AuthUsecase: Failed to update session last seen", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	return nil
+}
+
+// ReportNotMe revokes the session behind a new-device login alert and forgets the
+// device, so the next login from it triggers another alert.
+func (s *AuthUsecase) ReportNotMe(ctx context.Context, notMeToken string) error {
+	session, err := s.sessions.GetSessionByNotMeTokenHash(ctx, hashOpaqueToken(notMeToken))
+	if err != nil {
+		if errors.Is(err, authDomain.ErrSessionNotFound) {
+			return ErrInvalidNotMeToken
+		}
+		return fmt.Errorf("failed to find session for login alert: %w", err)
+	}
+	if time.Since(session.CreatedAt) > authDomain.SessionLifetime {
+		return ErrInvalidNotMeToken
+	}
+
+	if !session.IsRevoked() {
+		if err := s.revokeSession(ctx, session, authDomain.RevokeReasonNotMe); err != nil {
+			return err
+		}
+	}
+	if err := s.devices.RemoveKnownDevice(ctx, session.UserID, session.Fingerprint); err != nil {
+		utils.Logger.Error("AuthUsecase: Failed to remove reported device", zap.String("userID", session.UserID.String()), zap.Error(err))
+		return fmt.Errorf("failed to remove reported device: %w", err)
+	}
+
+	utils.Logger.Info("Login reported as not me, session revoked",
+		zap.String("userID", session.UserID.String()), zap.String("sessionID", session.ID.Hex()))
+	return nil
+}
+
+func (s *AuthUsecase) revokeSession(ctx context.Context, session *authDomain.Session, reason string) error {
+	if err := s.sessions.RevokeSession(ctx, session.ID, reason); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if err := s.revocations.RevokeSession(ctx, session.ID.Hex(), authDomain.SessionLifetime); err != nil {
+		// The session is already marked revoked in the database, which blocks refreshes;
+		// only access tokens issued earlier stay valid until they expire.
+		utils.Logger.Error("AuthUsecase: Failed to publish session revocation", zap.String("sessionID", session.ID.Hex()), zap.Error(err))
+	}
+	s.recordAudit(ctx, auditDomain.ActionAuthSessionRevoke, session.UserID.String(), nil, map[string]interface{}{
+		"session_id": session.ID.Hex(),
+		"reason":     reason,
+	})
+	return nil
+}
+
+// RevokeAllSessions revokes every active session of the user, e.g. after the
+// account was deactivated. It returns how many sessions were revoked.
+func (s *AuthUsecase) RevokeAllSessions(ctx context.Context, userID userDomain.UserID, reason string) (int, error) {
+	sessionIDs, err := s.sessions.RevokeAllSessions(ctx, userID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	for _, sessionID := range sessionIDs {
+		if err := s.revocations.RevokeSession(ctx, sessionID.Hex(), authDomain.SessionLifetime); err != nil {
+			utils.Logger.Error("AuthUsecase: Failed to publish session revocation", zap.String("sessionID", sessionID.Hex()), zap.Error(err))
+		}
+	}
+	if len(sessionIDs) > 0 {
+		s.recordAudit(ctx, auditDomain.ActionAuthSessionRevoke, userID.String(), nil, map[string]interface{}{
+			"session_count": len(sessionIDs),
+			"reason":        reason,
+		})
+	}
+	return len(sessionIDs), nil
+}
+
+// EraseUserData revokes every session of the user and deletes their sessions and
+// known devices, which hold IP addresses and user agents. It returns how many
+// sessions and devices were deleted.
+func (s *AuthUsecase) EraseUserData(ctx context.Context, userID userDomain.UserID) (int64, int64, error) {
+	if _, err := s.RevokeAllSessions(ctx, userID, authDomain.RevokeReasonUserErased); err != nil {
+		return 0, 0, err
+	}
+	sessions, err := s.sessions.DeleteSessionsByUser(ctx, userID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	devices, err := s.devices.DeleteKnownDevicesByUser(ctx, userID)
+	if err != nil {
+		return sessions, 0, fmt.Errorf("failed to delete known devices: %w", err)
+	}
+	utils.Logger.Info("AuthUsecase: Erased authentication data", zap.String("userID", userID.String()),
+		zap.Int64("sessions", sessions), zap.Int64("devices", devices))
+	return sessions, devices, nil
+}
+
+// ListSessions returns the sessions of the given user, most recently used first.
+func (s *AuthUsecase) ListSessions(ctx context.Context, userID string) ([]authDomain.Session, error) {
+	oid, err := userDomain.ParseUserID(userID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	sessions, err := s.sessions.ListSessionsByUser(ctx, oid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// ListKnownDevices returns the devices the given user has logged in from.
+func (s *AuthUsecase) ListKnownDevices(ctx context.Context, userID string) ([]authDomain.KnownDevice, error) {
+	oid, err := userDomain.ParseUserID(userID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	devices, err := s.devices.ListKnownDevices(ctx, oid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list known devices: %w", err)
+	}
+	return devices, nil
+}
+
+func generateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,12 +3,18 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
 	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
 	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
+	authRepository "github.com/iots1/mingkwan-api/internal/auth/repository"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
 
 	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
@@ -23,50 +29,157 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid email or password")
 	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrTOTPNotEnrolled    = errors.New("no authenticator app is enrolled for this account")
+	ErrAccountInactive    = errors.New("account is deactivated")
+	ErrTooManyAttempts    = errors.New("too many failed attempts, try again later")
+)
+
+// A user who fails to re-authenticate maxReauthFailures times is locked out of
+// re-authentication until reauthLockout after the first failure.
+const (
+	maxReauthFailures = 5
+	reauthLockout     = 15 * time.Minute
 )
 
 type AuthUsecase struct {
 	userUsecase    userUsecase.UserUsecase
+	groups         *groupUsecase.GroupUsecase
 	jwtGenerator   authAdapter.JWTTokenGenerator
 	passwordHasher sharedAdapter.PasswordHasher
 	lowPublisher   event.Publisher
 	highPublisher  event.Publisher
+	audit          *auditUsecase.AuditUsecase
+	sessions       authRepository.SessionRepository
+	devices        authRepository.KnownDeviceRepository
+	revocations    authRepository.RevocationStore
+	reauthGuard    authRepository.ReauthGuard
+	publicURL      string
 }
 
 func NewAuthUsecase(
 	userUsecase userUsecase.UserUsecase,
+	groups *groupUsecase.GroupUsecase,
 	jwtGenerator authAdapter.JWTTokenGenerator,
 	passwordHasher sharedAdapter.PasswordHasher,
 	inMemPubSub event.Publisher,
 	asynqClient event.Publisher,
+	audit *auditUsecase.AuditUsecase,
+	sessions authRepository.SessionRepository,
+	devices authRepository.KnownDeviceRepository,
+	revocations authRepository.RevocationStore,
+	reauthGuard authRepository.ReauthGuard,
+	publicURL string,
 ) *AuthUsecase {
 
 	return &AuthUsecase{
 		userUsecase:    userUsecase,
+		groups:         groups,
 		jwtGenerator:   jwtGenerator,
 		passwordHasher: passwordHasher,
 		lowPublisher:   inMemPubSub,
 		highPublisher:  asynqClient,
+		audit:          audit,
+		sessions:       sessions,
+		devices:        devices,
+		revocations:    revocations,
+		reauthGuard:    reauthGuard,
+		publicURL:      publicURL,
 	}
 }
 
-// Register creates a new user.
-func (s *AuthUsecase) Register(ctx context.Context, data *userDomain.User) (*authModel.AuthResponse, error) {
+// recordAudit writes an audit entry for an authentication event. actorID is passed
+// explicitly because these requests are not yet authenticated.
+func (s *AuthUsecase) recordAudit(ctx context.Context, action, actorID string, opErr error, metadata map[string]interface{}) {
+	entry := auditDomain.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: auditDomain.TargetTypeUser,
+		TargetID:   actorID,
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
+
+// tokenRoles returns the roles to put in a user's tokens: their own and those of
+// their groups. Group roles that cannot be resolved are left out, which withholds
+// privileges rather than granting them; the next refresh tries again. Changes to
+// groups reach a user's tokens when they are next issued or refreshed, so role
+// checks also consult CurrentRoles.
+func (s *AuthUsecase) tokenRoles(ctx context.Context, user *userDomain.User) []string {
+	if s.groups == nil {
+		return user.Roles
+	}
+	groupRoles, err := s.groups.GroupRoles(ctx, user.ID)
+	if err != nil {
+		utils.Logger.Warn("AuthUsecase: Failed to resolve group roles, issuing tokens without them", zap.String("userID", user.ID.String()), zap.Error(err))
+		return user.Roles
+	}
+	if len(groupRoles) == 0 {
+		return user.Roles
+	}
+	roles := slices.Concat(user.Roles, groupRoles)
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+// CurrentRoles returns the roles the user holds now, resolved like tokenRoles. A
+// deactivated or deleted user holds none. RequireRole checks it so that a role
+// withdrawn from a user or their groups stops working before their tokens expire.
+func (s *AuthUsecase) CurrentRoles(ctx context.Context, userID string) ([]string, error) {
+	id, err := userDomain.ParseUserID(userID)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := s.userUsecase.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, nil
+	}
+	return s.tokenRoles(ctx, user), nil
+}
+
+// Register creates a new user and starts a session on the calling device, which
+// becomes the user's first known device.
+func (s *AuthUsecase) Register(ctx context.Context, data *userDomain.User, deviceID string) (*authModel.AuthResponse, error) {
 
 	createdUser, err := s.userUsecase.CreateUser(ctx, data)
 	if err != nil {
 		utils.Logger.Error("Failed to create user in database", zap.Error(err), zap.String("email", data.Email))
-		return nil, errors.New("failed to create user")
+		s.recordAudit(ctx, auditDomain.ActionAuthRegister, "", err, map[string]interface{}{"email": data.Email})
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	session, err := s.startSession(ctx, createdUser, deviceID, false)
+	if err != nil {
+		utils.Logger.Error("Failed to start session after registration", zap.Error(err), zap.String("userID", createdUser.ID.String()))
+		return nil, fmt.Errorf("failed to start session: %w", err)
 	}
 
 	// Generate tokens
-	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(createdUser.ID.Hex())
+	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(authAdapter.TokenParams{
+		UserID:    createdUser.ID.String(),
+		Roles:     s.tokenRoles(ctx, createdUser),
+		SessionID: session.ID.Hex(),
+		AuthTime:  time.Now(),
+		AMR:       []string{authAdapter.AMRPassword},
+	})
 	if err != nil {
-		utils.Logger.Error("Failed to generate tokens after registration", zap.Error(err), zap.String("userID", createdUser.ID.Hex()))
+		utils.Logger.Error("Failed to generate tokens after registration", zap.Error(err), zap.String("userID", createdUser.ID.String()))
 		return nil, errors.New("failed to generate tokens")
 	}
 
-	utils.Logger.Info("User registered successfully", zap.String("userID", createdUser.ID.Hex()), zap.String("email", createdUser.Email))
+	s.recordAudit(ctx, auditDomain.ActionAuthRegister, createdUser.ID.String(), nil, map[string]interface{}{"email": createdUser.Email})
+	utils.Logger.Info("User registered successfully", zap.String("userID", createdUser.ID.String()), zap.String("email", createdUser.Email))
 
 	return &authModel.AuthResponse{
 		AccessToken:  accessToken,
@@ -74,15 +187,17 @@ func (s *AuthUsecase) Register(ctx context.Context, data *userDomain.User) (*aut
 	}, nil
 }
 
-// Login authenticates a user and generates tokens.
-func (s *AuthUsecase) Login(ctx context.Context, req *authModel.LoginRequest) (*authModel.AuthResponse, error) {
+// Login authenticates a user, starts a session for deviceID and generates tokens.
+// Logins from an unknown device fingerprint notify the account owner.
+func (s *AuthUsecase) Login(ctx context.Context, req *authModel.LoginRequest, deviceID string) (*authModel.AuthResponse, error) {
 	utils.Logger.Info("Attempting user login", zap.String("email", req.Email))
 
 	// Find user by email
 	user, err := s.userUsecase.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
 			utils.Logger.Warn("Login failed: User not found", zap.String("email", req.Email))
+			s.recordAudit(ctx, auditDomain.ActionAuthLogin, "", ErrInvalidCredentials, map[string]interface{}{"email": req.Email, "cause": "unknown_email"})
 			return nil, ErrInvalidCredentials
 		}
 		utils.Logger.Error("Error finding user by email during login", zap.Error(err), zap.String("email", req.Email))
@@ -92,19 +207,43 @@ func (s *AuthUsecase) Login(ctx context.Context, req *authModel.LoginRequest) (*
 	// Check password
 	if !s.passwordHasher.CheckPasswordHash(req.Password, user.Password) {
 		utils.Logger.Warn("Login failed: Invalid password", zap.String("email", req.Email))
+		s.recordAudit(ctx, auditDomain.ActionAuthLogin, user.ID.String(), ErrInvalidCredentials, map[string]interface{}{"email": req.Email, "cause": "invalid_password"})
 		return nil, ErrInvalidCredentials
 	}
 
+	// Checked after the password so that the response does not reveal the account
+	// state to someone who does not know the password.
+	if !user.IsActive {
+		utils.Logger.Warn("Login failed: Account is deactivated", zap.String("userID", user.ID.String()))
+		s.recordAudit(ctx, auditDomain.ActionAuthLogin, user.ID.String(), ErrAccountInactive, map[string]interface{}{"email": req.Email, "cause": "inactive"})
+		return nil, ErrAccountInactive
+	}
+
+	session, err := s.startSession(ctx, user, deviceID, true)
+	if err != nil {
+		utils.Logger.Error("Failed to start session after login", zap.Error(err), zap.String("userID", user.ID.String()))
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+
 	// Generate tokens
-	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(user.ID.Hex())
+	accessToken, refreshToken, err := s.jwtGenerator.GenerateTokens(authAdapter.TokenParams{
+		UserID:    user.ID.String(),
+		Roles:     s.tokenRoles(ctx, user),
+		SessionID: session.ID.Hex(),
+		AuthTime:  time.Now(),
+		AMR:       []string{authAdapter.AMRPassword},
+	})
 	if err != nil {
-		utils.Logger.Error("Failed to generate tokens after login", zap.Error(err), zap.String("userID", user.ID.Hex()))
+		utils.Logger.Error("Failed to generate tokens after login", zap.Error(err), zap.String("userID", user.ID.String()))
 		return nil, errors.New("failed to generate tokens")
 	}
 
-	// Publish event (e.g., UserLoggedInEvent)
-	// s.highPublisher.Publish(ctx, event.NewUserLoggedInEvent(user.ID.Hex()))
-	utils.Logger.Info("User logged in successfully", zap.String("userID", user.ID.Hex()), zap.String("email", user.Email))
+	s.recordAudit(ctx, auditDomain.ActionAuthLogin, user.ID.String(), nil, map[string]interface{}{
+		"email":      user.Email,
+		"session_id": session.ID.Hex(),
+		"new_device": session.NewDevice,
+	})
+	utils.Logger.Info("User logged in successfully", zap.String("userID", user.ID.String()), zap.String("email", user.Email))
 
 	return &authModel.AuthResponse{
 		AccessToken:  accessToken,
@@ -120,10 +259,11 @@ func (s *AuthUsecase) RefreshTokens(ctx context.Context, req *authModel.RefreshR
 	claims, err := s.jwtGenerator.ParseRefreshToken(req.RefreshToken)
 	if err != nil {
 		utils.Logger.Warn("Refresh token invalid or expired", zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionAuthTokenRefresh, "", ErrInvalidToken, nil)
 		return nil, ErrInvalidToken
 	}
 
-	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	userID, err := userDomain.ParseUserID(claims.UserID)
 	if err != nil {
 		utils.Logger.Warn("Invalid user ID format in refresh token", zap.String("userID", claims.UserID), zap.Error(err))
 		return nil, ErrInvalidToken
@@ -132,32 +272,142 @@ func (s *AuthUsecase) RefreshTokens(ctx context.Context, req *authModel.RefreshR
 	// Check if user exists (optional, but good practice for security)
 	user, err := s.userUsecase.GetUserByID(ctx, userID)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
 			utils.Logger.Warn("Refresh failed: User not found for token", zap.String("userID", claims.UserID))
+			s.recordAudit(ctx, auditDomain.ActionAuthTokenRefresh, claims.UserID, ErrInvalidToken, nil)
 			return nil, ErrInvalidToken
 		}
 		utils.Logger.Error("Error finding user for refresh token", zap.Error(err), zap.String("userID", claims.UserID))
 		return nil, err
 	}
+	if !user.IsActive {
+		utils.Logger.Warn("Refresh failed: Account is deactivated", zap.String("userID", claims.UserID))
+		s.recordAudit(ctx, auditDomain.ActionAuthTokenRefresh, claims.UserID, ErrAccountInactive, nil)
+		return nil, ErrAccountInactive
+	}
 
-	// Generate new tokens
-	newAccessToken, newRefreshToken, err := s.jwtGenerator.GenerateTokens(user.ID.Hex())
+	if err := s.ensureSessionActive(ctx, claims.SessionID); err != nil {
+		utils.Logger.Warn("Refresh failed: Session is not active", zap.String("userID", claims.UserID), zap.String("sessionID", claims.SessionID), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionAuthTokenRefresh, claims.UserID, err, map[string]interface{}{"session_id": claims.SessionID})
+		return nil, ErrInvalidToken
+	}
+
+	// Generate new tokens. Refreshing is not a proof of identity, so auth_time and
+	// amr are carried over unchanged.
+	newAccessToken, newRefreshToken, err := s.jwtGenerator.GenerateTokens(authAdapter.TokenParams{
+		UserID:    user.ID.String(),
+		Roles:     s.tokenRoles(ctx, user),
+		SessionID: claims.SessionID,
+		AuthTime:  claims.AuthenticatedAt(),
+		AMR:       claims.AMR,
+	})
 	if err != nil {
-		utils.Logger.Error("Failed to generate new tokens during refresh", zap.Error(err), zap.String("userID", user.ID.Hex()))
+		utils.Logger.Error("Failed to generate new tokens during refresh", zap.Error(err), zap.String("userID", user.ID.String()))
 		return nil, errors.New("failed to generate new tokens")
 	}
 
-	utils.Logger.Info("Tokens refreshed successfully", zap.String("userID", user.ID.Hex()))
+	s.recordAudit(ctx, auditDomain.ActionAuthTokenRefresh, user.ID.String(), nil, nil)
+	utils.Logger.Info("Tokens refreshed successfully", zap.String("userID", user.ID.String()))
 	return &authModel.AuthResponse{
 		AccessToken:  newAccessToken,
 		RefreshToken: newRefreshToken,
 	}, nil
 }
 
+// Reauthenticate verifies the password or TOTP code of an already authenticated user
+// and issues a short-lived access token with a fresh auth_time for the same session.
+// After maxReauthFailures failures it returns ErrTooManyAttempts until the lockout
+// ends, and a TOTP code is accepted only once.
+func (s *AuthUsecase) Reauthenticate(ctx context.Context, userID, sessionID string, req *authModel.ReauthenticateRequest) (*authModel.ReauthenticateResponse, error) {
+	oid, err := userDomain.ParseUserID(userID)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.userUsecase.GetUserByID(ctx, oid)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrAccountInactive
+	}
+	if err := s.ensureSessionActive(ctx, sessionID); err != nil {
+		utils.Logger.Warn("Reauthentication failed: Session is not active", zap.String("userID", userID), zap.String("sessionID", sessionID), zap.Error(err))
+		return nil, ErrInvalidToken
+	}
+
+	failures, err := s.reauthGuard.FailedAttempts(ctx, userID)
+	if err != nil {
+		utils.Logger.Error("AuthUsecase: Failed to check re-authentication failures", zap.String("userID", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to check re-authentication failures: %w", err)
+	}
+	if failures >= maxReauthFailures {
+		utils.Logger.Warn("Reauthentication refused: Too many failed attempts", zap.String("userID", userID))
+		s.recordAudit(ctx, auditDomain.ActionAuthReauth, userID, ErrTooManyAttempts, nil)
+		return nil, ErrTooManyAttempts
+	}
+
+	method := authAdapter.AMRPassword
+	verified := false
+	if req.TOTPCode != "" {
+		method = authAdapter.AMROTP
+		if user.TOTPSecret == "" {
+			s.recordAudit(ctx, auditDomain.ActionAuthReauth, userID, ErrTOTPNotEnrolled, map[string]interface{}{"method": method})
+			return nil, ErrTOTPNotEnrolled
+		}
+		var step int64
+		step, verified = authAdapter.VerifyTOTP(user.TOTPSecret, req.TOTPCode, time.Now())
+		if verified {
+			// A code is only good once: it is rejected if it, or a later one, was used.
+			verified, err = s.reauthGuard.UseTOTPStep(ctx, userID, step, authAdapter.TOTPCodeLifetime)
+			if err != nil {
+				utils.Logger.Error("AuthUsecase: Failed to record TOTP step", zap.String("userID", userID), zap.Error(err))
+				return nil, fmt.Errorf("failed to record TOTP step: %w", err)
+			}
+		}
+	} else {
+		verified = s.passwordHasher.CheckPasswordHash(req.Password, user.Password)
+	}
+	if !verified {
+		utils.Logger.Warn("Reauthentication failed: Invalid credentials", zap.String("userID", userID), zap.String("method", method))
+		if _, err := s.reauthGuard.RecordFailedAttempt(ctx, userID, reauthLockout); err != nil {
+			utils.Logger.Error("AuthUsecase: Failed to count re-authentication failure", zap.String("userID", userID), zap.Error(err))
+		}
+		s.recordAudit(ctx, auditDomain.ActionAuthReauth, userID, ErrInvalidCredentials, map[string]interface{}{"method": method})
+		return nil, ErrInvalidCredentials
+	}
+	if failures > 0 {
+		if err := s.reauthGuard.ResetFailedAttempts(ctx, userID); err != nil {
+			utils.Logger.Error("AuthUsecase: Failed to reset re-authentication failures", zap.String("userID", userID), zap.Error(err))
+		}
+	}
+
+	accessToken, err := s.jwtGenerator.GenerateStepUpToken(authAdapter.TokenParams{
+		UserID:    userID,
+		Roles:     s.tokenRoles(ctx, user),
+		SessionID: sessionID,
+		AuthTime:  time.Now(),
+		AMR:       []string{method},
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to generate step-up token", zap.Error(err), zap.String("userID", userID))
+		return nil, errors.New("failed to generate tokens")
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionAuthReauth, userID, nil, map[string]interface{}{"method": method, "session_id": sessionID})
+	utils.Logger.Info("User re-authenticated", zap.String("userID", userID), zap.String("method", method))
+	return &authModel.ReauthenticateResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int(authAdapter.StepUpTokenLifetime.Seconds()),
+	}, nil
+}
+
 func (s *AuthUsecase) GetProfile(ctx context.Context, userID string) (*authModel.ProfileResponse, error) {
 	utils.Logger.Info("Attempting to retrieve user profile", zap.String("userID", userID))
 
-	oid, err := primitive.ObjectIDFromHex(userID)
+	oid, err := userDomain.ParseUserID(userID)
 	if err != nil {
 		utils.Logger.Warn("Invalid user ID format", zap.String("userID", userID), zap.Error(err))
 		return nil, ErrInvalidToken
@@ -165,7 +415,7 @@ func (s *AuthUsecase) GetProfile(ctx context.Context, userID string) (*authModel
 
 	user, err := s.userUsecase.GetUserByID(ctx, oid)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
 			utils.Logger.Warn("Profile retrieval failed: User not found", zap.String("userID", userID))
 			return nil, ErrUserNotFound
 		}
@@ -174,7 +424,7 @@ func (s *AuthUsecase) GetProfile(ctx context.Context, userID string) (*authModel
 	}
 
 	return &authModel.ProfileResponse{
-		ID:    user.ID.Hex(),
+		ID:    user.ID.String(),
 		Name:  user.Name,
 		Email: user.Email,
 	}, nil
@@ -0,0 +1,510 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
+	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
+	groupAdapter "github.com/iots1/mingkwan-api/internal/group/adapters"
+	groupDomain "github.com/iots1/mingkwan-api/internal/group/domain"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	sharedAdapter "github.com/iots1/mingkwan-api/internal/shared/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userAdapter "github.com/iots1/mingkwan-api/internal/user/adapters"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase/usecasetest"
+)
+
+const testPassword = "correct horse"
+
+type authFixture struct {
+	usecase     *AuthUsecase
+	users       *userAdapter.MemoryUserRepository
+	groups      *groupUsecase.GroupUsecase
+	sessions    *fakeSessionRepository
+	devices     *fakeKnownDeviceRepository
+	revocations *fakeRevocationStore
+	reauth      *fakeReauthGuard
+	jwt         authAdapter.JWTTokenGenerator
+	highPub     *eventtest.RecordingPublisher
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	users := usecasetest.NewFixture()
+	f := &authFixture{
+		users:       users.Repo,
+		sessions:    newFakeSessionRepository(),
+		devices:     &fakeKnownDeviceRepository{},
+		revocations: newFakeRevocationStore(),
+		reauth:      newFakeReauthGuard(),
+		jwt:         authAdapter.NewJWTTokenGenerator("test-secret"),
+		highPub:     users.HighPub,
+	}
+	f.groups = groupUsecase.NewGroupUsecase(groupAdapter.NewMemoryGroupRepository(), groupAdapter.NewMemoryNestingLock(), *users.Users, nil)
+	f.usecase = NewAuthUsecase(*users.Users, f.groups, f.jwt, sharedAdapter.NewPasswordHasher(), users.LowPub, f.highPub, nil,
+		f.sessions, f.devices, f.revocations, f.reauth, usecasetest.AppURL)
+	return f
+}
+
+// register creates an active user with testPassword through the usecase.
+func (f *authFixture) register(t *testing.T, email string) *userDomain.User {
+	t.Helper()
+	ctx := deviceContext("Browser A", "203.0.113.10")
+	if _, err := f.usecase.Register(ctx, &userDomain.User{Name: "Owner", Email: email, Password: testPassword, IsActive: true}, "device-a"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	user, err := f.users.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	return user
+}
+
+func deviceContext(userAgent, ip string) context.Context {
+	return utils.WithRequestMeta(context.Background(), &utils.RequestMeta{UserAgent: userAgent, IP: ip})
+}
+
+func TestAuthUsecase_Register(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		email    string
+		wantErr  error
+	}{
+		{name: "new account", email: "new@example.com"},
+		{name: "email taken", existing: "taken@example.com", email: "TAKEN@example.com", wantErr: userDomain.ErrUserAlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			if tt.existing != "" {
+				f.register(t, tt.existing)
+			}
+			sessionsBefore := len(f.sessions.sessions)
+
+			resp, err := f.usecase.Register(deviceContext("Browser A", "203.0.113.10"),
+				&userDomain.User{Name: "New", Email: tt.email, Password: testPassword, IsActive: true}, "device-a")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(f.sessions.sessions) != sessionsBefore {
+					t.Error("failed registration started a session")
+				}
+				return
+			}
+
+			claims, err := f.jwt.ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			session, err := f.usecase.sessions.GetSessionByID(context.Background(), mustObjectID(t, claims.SessionID))
+			if err != nil {
+				t.Fatalf("session of the access token: %v", err)
+			}
+			if session.UserID.String() != claims.UserID || session.NewDevice {
+				t.Errorf("session = %+v, want a session of the new user on a trusted device", session)
+			}
+			if len(f.devices.devices) != 1 {
+				t.Errorf("known devices = %d, want 1", len(f.devices.devices))
+			}
+		})
+	}
+}
+
+func TestAuthUsecase_Login(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		password      string
+		userAgent     string
+		deactivate    bool
+		wantErr       error
+		wantNewDevice bool
+	}{
+		{name: "known device", email: "owner@example.com", password: testPassword, userAgent: "Browser A"},
+		{name: "email in other case", email: "Owner@Example.com", password: testPassword, userAgent: "Browser A"},
+		{name: "new device", email: "owner@example.com", password: testPassword, userAgent: "Browser B", wantNewDevice: true},
+		{name: "wrong password", email: "owner@example.com", password: "wrong", userAgent: "Browser A", wantErr: ErrInvalidCredentials},
+		{name: "unknown email", email: "nobody@example.com", password: testPassword, userAgent: "Browser A", wantErr: ErrInvalidCredentials},
+		{name: "deactivated", email: "owner@example.com", password: testPassword, userAgent: "Browser A", deactivate: true, wantErr: ErrAccountInactive},
+		{name: "deactivated with wrong password", email: "owner@example.com", password: "wrong", userAgent: "Browser A", deactivate: true, wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			user := f.register(t, "owner@example.com")
+			if tt.deactivate {
+				if _, err := f.users.SetActive(context.Background(), user.ID, false, "admin", "test"); err != nil {
+					t.Fatalf("SetActive: %v", err)
+				}
+			}
+
+			resp, err := f.usecase.Login(deviceContext(tt.userAgent, "203.0.113.10"),
+				&authModel.LoginRequest{Email: tt.email, Password: tt.password}, "device-a")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			alerts := f.highPub.MessagesFor(event.NewDeviceLoginNotificationTask)
+			if tt.wantErr != nil {
+				if len(alerts) != 0 {
+					t.Errorf("failed login sent %d new-device alerts", len(alerts))
+				}
+				return
+			}
+
+			claims, err := f.jwt.ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if claims.UserID != user.ID.String() {
+				t.Errorf("token user = %s, want %s", claims.UserID, user.ID.String())
+			}
+			if got := len(alerts) == 1; got != tt.wantNewDevice {
+				t.Errorf("new-device alerts = %d, want alert %v", len(alerts), tt.wantNewDevice)
+			}
+		})
+	}
+}
+
+func TestAuthUsecase_RefreshTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, f *authFixture, user *userDomain.User, sessionID string)
+		token   func(refreshToken string) string
+		wantErr error
+	}{
+		{name: "valid"},
+		{
+			name:    "malformed token",
+			token:   func(string) string { return "not-a-jwt" },
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "revoked session",
+			prepare: func(t *testing.T, f *authFixture, _ *userDomain.User, sessionID string) {
+				if err := f.sessions.RevokeSession(context.Background(), mustObjectID(t, sessionID), "logout"); err != nil {
+					t.Fatalf("RevokeSession: %v", err)
+				}
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "deactivated user",
+			prepare: func(t *testing.T, f *authFixture, user *userDomain.User, _ string) {
+				if _, err := f.users.SetActive(context.Background(), user.ID, false, "admin", "test"); err != nil {
+					t.Fatalf("SetActive: %v", err)
+				}
+			},
+			wantErr: ErrAccountInactive,
+		},
+		{
+			name: "deleted user",
+			prepare: func(t *testing.T, f *authFixture, user *userDomain.User, _ string) {
+				if err := f.users.DeleteUser(context.Background(), user.ID); err != nil {
+					t.Fatalf("DeleteUser: %v", err)
+				}
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			user := f.register(t, "owner@example.com")
+			login, err := f.usecase.Login(deviceContext("Browser A", "203.0.113.10"),
+				&authModel.LoginRequest{Email: "owner@example.com", Password: testPassword}, "device-a")
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			claims, err := f.jwt.ParseRefreshToken(login.RefreshToken)
+			if err != nil {
+				t.Fatalf("ParseRefreshToken: %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, f, user, claims.SessionID)
+			}
+			token := login.RefreshToken
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			resp, err := f.usecase.RefreshTokens(context.Background(), &authModel.RefreshRequest{RefreshToken: token})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshTokens error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			refreshed, err := f.jwt.ParseAccessToken(resp.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if refreshed.SessionID != claims.SessionID {
+				t.Errorf("refreshed session = %s, want %s", refreshed.SessionID, claims.SessionID)
+			}
+		})
+	}
+}
+
+func TestAuthUsecase_GroupRoles(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	user := f.register(t, "owner@example.com")
+
+	// The user is in Support, which is nested in Operations.
+	support, err := f.groups.CreateGroup(ctx, &groupDomain.Group{Name: "Support", Roles: []string{userDomain.RoleSupport}})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	operations, err := f.groups.CreateGroup(ctx, &groupDomain.Group{Name: "Operations", Roles: []string{userDomain.RoleAdmin}})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if _, err := f.groups.AddMember(ctx, support.ID, groupDomain.MemberTypeUser, user.ID.String()); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if _, err := f.groups.AddMember(ctx, operations.ID, groupDomain.MemberTypeGroup, support.ID.Hex()); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	login, err := f.usecase.Login(deviceContext("Browser A", "203.0.113.10"),
+		&authModel.LoginRequest{Email: "owner@example.com", Password: testPassword}, "device-a")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims, err := f.jwt.ParseAccessToken(login.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if want := []string{userDomain.RoleAdmin, userDomain.RoleSupport, userDomain.RoleUser}; !slices.Equal(claims.Roles, want) {
+		t.Errorf("login roles = %v, want %v", claims.Roles, want)
+	}
+
+	// Un-nesting Support takes the admin role away at once for role checks, and from
+	// the tokens at the next refresh.
+	if err := f.groups.RemoveMember(ctx, operations.ID, groupDomain.MemberTypeGroup, support.ID.Hex()); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	current, err := f.usecase.CurrentRoles(ctx, user.ID.String())
+	if err != nil {
+		t.Fatalf("CurrentRoles: %v", err)
+	}
+	if want := []string{userDomain.RoleSupport, userDomain.RoleUser}; !slices.Equal(current, want) {
+		t.Errorf("current roles = %v, want %v", current, want)
+	}
+	refresh, err := f.usecase.RefreshTokens(ctx, &authModel.RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	claims, err = f.jwt.ParseAccessToken(refresh.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if want := []string{userDomain.RoleSupport, userDomain.RoleUser}; !slices.Equal(claims.Roles, want) {
+		t.Errorf("refreshed roles = %v, want %v", claims.Roles, want)
+	}
+
+	// A deactivated user holds no roles at all.
+	if _, err := f.users.SetActive(ctx, user.ID, false, "admin", "test"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	if current, err := f.usecase.CurrentRoles(ctx, user.ID.String()); err != nil || len(current) != 0 {
+		t.Errorf("current roles of a deactivated user = %v, %v; want none", current, err)
+	}
+}
+
+func TestAuthUsecase_Reauthenticate(t *testing.T) {
+	const totpSecret = "JBSWY3DPEHPK3PXP"
+	replayedCode := totpCode(t, totpSecret, time.Now())
+	tests := []struct {
+		name     string
+		attempts func(t *testing.T, f *authFixture, userID, sessionID string)
+		req      func() *authModel.ReauthenticateRequest
+		wantErr  error
+	}{
+		{
+			name: "password",
+			req: func() *authModel.ReauthenticateRequest {
+				return &authModel.ReauthenticateRequest{Password: testPassword}
+			},
+		},
+		{
+			name: "totp code",
+			req: func() *authModel.ReauthenticateRequest {
+				return &authModel.ReauthenticateRequest{TOTPCode: totpCode(t, totpSecret, time.Now())}
+			},
+		},
+		{
+			name: "replayed totp code",
+			attempts: func(t *testing.T, f *authFixture, userID, sessionID string) {
+				req := &authModel.ReauthenticateRequest{TOTPCode: replayedCode}
+				if _, err := f.usecase.Reauthenticate(context.Background(), userID, sessionID, req); err != nil {
+					t.Fatalf("Reauthenticate: %v", err)
+				}
+			},
+			req: func() *authModel.ReauthenticateRequest {
+				return &authModel.ReauthenticateRequest{TOTPCode: replayedCode}
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name: "locked out after failures",
+			attempts: func(t *testing.T, f *authFixture, userID, sessionID string) {
+				for range maxReauthFailures {
+					req := &authModel.ReauthenticateRequest{Password: "wrong"}
+					if _, err := f.usecase.Reauthenticate(context.Background(), userID, sessionID, req); !errors.Is(err, ErrInvalidCredentials) {
+						t.Fatalf("Reauthenticate error = %v, want %v", err, ErrInvalidCredentials)
+					}
+				}
+			},
+			req: func() *authModel.ReauthenticateRequest {
+				return &authModel.ReauthenticateRequest{Password: testPassword}
+			},
+			wantErr: ErrTooManyAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuthFixture(t)
+			user := f.register(t, "owner@example.com")
+			if _, err := f.users.UpdateUser(ctx, user.ID, map[string]interface{}{"totp_secret": totpSecret}, userDomain.AnyVersion); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			login, err := f.usecase.Login(deviceContext("Browser A", "203.0.113.10"),
+				&authModel.LoginRequest{Email: "owner@example.com", Password: testPassword}, "device-a")
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			claims, err := f.jwt.ParseAccessToken(login.AccessToken)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if tt.attempts != nil {
+				tt.attempts(t, f, claims.UserID, claims.SessionID)
+			}
+
+			resp, err := f.usecase.Reauthenticate(ctx, claims.UserID, claims.SessionID, tt.req())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reauthenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && resp.AccessToken == "" {
+				t.Error("Reauthenticate returned no access token")
+			}
+		})
+	}
+}
+
+// totpCode computes the RFC 6238 code of secret at t, as an authenticator app would.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestAuthUsecase_RevokeToken(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	f.register(t, "owner@example.com")
+	login, err := f.usecase.Login(deviceContext("Browser A", "203.0.113.10"),
+		&authModel.LoginRequest{Email: "owner@example.com", Password: testPassword}, "device-a")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	claims, err := f.jwt.ParseAccessToken(login.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+
+	// Another client cannot revoke a token it was not issued.
+	if err := f.usecase.RevokeToken(ctx, login.AccessToken, "access_token", "api-gateway"); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if revoked, _ := f.revocations.IsTokenRevoked(ctx, claims.ID); revoked {
+		t.Fatal("token revoked by another client")
+	}
+
+	if err := f.usecase.RevokeToken(ctx, login.AccessToken, "access_token", authAdapter.FirstPartyClientID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if revoked, _ := f.revocations.IsTokenRevoked(ctx, claims.ID); !revoked {
+		t.Error("token not revoked by the client it was issued to")
+	}
+}
+
+func TestAuthUsecase_ReportNotMe(t *testing.T) {
+	f := newAuthFixture(t)
+	f.register(t, "owner@example.com")
+	if _, err := f.usecase.Login(deviceContext("Browser B", "198.51.100.7"),
+		&authModel.LoginRequest{Email: "owner@example.com", Password: testPassword}, "device-b"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	alerts := f.highPub.MessagesFor(event.NewDeviceLoginNotificationTask)
+	if len(alerts) != 1 {
+		t.Fatalf("new-device alerts = %d, want 1", len(alerts))
+	}
+	alert := alerts[0].Payload.(event.NewDeviceLoginPayload)
+	link, err := url.Parse(alert.NotMeURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", alert.NotMeURL, err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "unknown token", token: "unknown", wantErr: ErrInvalidNotMeToken},
+		{name: "valid token", token: link.Query().Get("token")},
+		// Reporting twice is harmless: the session is already revoked.
+		{name: "repeated", token: link.Query().Get("token")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.usecase.ReportNotMe(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReportNotMe error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			session, err := f.sessions.GetSessionByID(context.Background(), mustObjectID(t, alert.SessionID))
+			if err != nil {
+				t.Fatalf("GetSessionByID: %v", err)
+			}
+			if !session.IsRevoked() {
+				t.Error("reported session is not revoked")
+			}
+			if revoked, _ := f.revocations.IsSessionRevoked(context.Background(), alert.SessionID); !revoked {
+				t.Error("reported session is not in the revocation store")
+			}
+			if device, _ := f.devices.GetKnownDevice(context.Background(), session.UserID, session.Fingerprint); device != nil {
+				t.Error("reported device is still known")
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	authRepository "github.com/iots1/mingkwan-api/internal/auth/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// fakeSessionRepository keeps sessions in memory with the semantics of
+// MongoSessionRepository.
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[primitive.ObjectID]authDomain.Session
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{sessions: make(map[primitive.ObjectID]authDomain.Session)}
+}
+
+func (r *fakeSessionRepository) CreateSession(ctx context.Context, session *authDomain.Session) (*authDomain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	r.sessions[session.ID] = *session
+	return session, nil
+}
+
+func (r *fakeSessionRepository) GetSessionByID(ctx context.Context, id primitive.ObjectID) (*authDomain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, authDomain.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *fakeSessionRepository) GetSessionByNotMeTokenHash(ctx context.Context, tokenHash string) (*authDomain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.NotMeTokenHash != "" && session.NotMeTokenHash == tokenHash {
+			return &session, nil
+		}
+	}
+	return nil, authDomain.ErrSessionNotFound
+}
+
+func (r *fakeSessionRepository) ListSessionsByUser(ctx context.Context, userID userDomain.UserID) ([]authDomain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := []authDomain.Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeSessionRepository) TouchSession(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		session.LastSeenAt = seenAt
+		r.sessions[id] = session
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return authDomain.ErrSessionNotFound
+	}
+	now := time.Now()
+	session.RevokedAt, session.RevokeReason = &now, reason
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeAllSessions(ctx context.Context, userID userDomain.UserID, reason string) ([]primitive.ObjectID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []primitive.ObjectID
+	now := time.Now()
+	for id, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt, session.RevokeReason = &now, reason
+			r.sessions[id] = session
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeSessionRepository) DeleteSessionsByUser(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeKnownDeviceRepository keeps known devices in memory with the semantics of
+// MongoKnownDeviceRepository.
+type fakeKnownDeviceRepository struct {
+	mu      sync.Mutex
+	devices []authDomain.KnownDevice
+}
+
+func (r *fakeKnownDeviceRepository) GetKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) (*authDomain.KnownDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		if device.UserID == userID && device.Fingerprint == fingerprint {
+			return &device, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeKnownDeviceRepository) ListKnownDevices(ctx context.Context, userID userDomain.UserID) ([]authDomain.KnownDevice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	devices := []authDomain.KnownDevice{}
+	for _, device := range r.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (r *fakeKnownDeviceRepository) CountKnownDevices(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	devices, _ := r.ListKnownDevices(ctx, userID)
+	return int64(len(devices)), nil
+}
+
+func (r *fakeKnownDeviceRepository) AddKnownDevice(ctx context.Context, device *authDomain.KnownDevice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if device.ID.IsZero() {
+		device.ID = primitive.NewObjectID()
+	}
+	r.devices = append(r.devices, *device)
+	return nil
+}
+
+func (r *fakeKnownDeviceRepository) TouchKnownDevice(ctx context.Context, id primitive.ObjectID, seenAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.devices {
+		if r.devices[i].ID == id {
+			r.devices[i].LastSeenAt = seenAt
+		}
+	}
+	return nil
+}
+
+func (r *fakeKnownDeviceRepository) RemoveKnownDevice(ctx context.Context, userID userDomain.UserID, fingerprint string) error {
+	_, err := r.remove(func(device authDomain.KnownDevice) bool {
+		return device.UserID == userID && device.Fingerprint == fingerprint
+	})
+	return err
+}
+
+func (r *fakeKnownDeviceRepository) DeleteKnownDevicesByUser(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	return r.remove(func(device authDomain.KnownDevice) bool { return device.UserID == userID })
+}
+
+func (r *fakeKnownDeviceRepository) remove(match func(authDomain.KnownDevice) bool) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.devices[:0]
+	var n int64
+	for _, device := range r.devices {
+		if match(device) {
+			n++
+			continue
+		}
+		kept = append(kept, device)
+	}
+	r.devices = kept
+	return n, nil
+}
+
+// fakeRevocationStore remembers revocations without expiry.
+type fakeRevocationStore struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	tokens   map[string]bool
+}
+
+func newFakeRevocationStore() *fakeRevocationStore {
+	return &fakeRevocationStore{sessions: make(map[string]bool), tokens: make(map[string]bool)}
+}
+
+func (s *fakeRevocationStore) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID] = true
+	return nil
+}
+
+func (s *fakeRevocationStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[sessionID], nil
+}
+
+func (s *fakeRevocationStore) RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenID] = true
+	return nil
+}
+
+func (s *fakeRevocationStore) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[tokenID], nil
+}
+
+// fakeReauthGuard counts failures and remembers TOTP steps without expiry.
+type fakeReauthGuard struct {
+	mu        sync.Mutex
+	failures  map[string]int64
+	lastSteps map[string]int64
+}
+
+func newFakeReauthGuard() *fakeReauthGuard {
+	return &fakeReauthGuard{failures: make(map[string]int64), lastSteps: make(map[string]int64)}
+}
+
+func (g *fakeReauthGuard) FailedAttempts(ctx context.Context, userID string) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failures[userID], nil
+}
+
+func (g *fakeReauthGuard) RecordFailedAttempt(ctx context.Context, userID string, window time.Duration) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures[userID]++
+	return g.failures[userID], nil
+}
+
+func (g *fakeReauthGuard) ResetFailedAttempts(ctx context.Context, userID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, userID)
+	return nil
+}
+
+func (g *fakeReauthGuard) UseTOTPStep(ctx context.Context, userID string, step int64, ttl time.Duration) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if last, ok := g.lastSteps[userID]; ok && step <= last {
+		return false, nil
+	}
+	g.lastSteps[userID] = step
+	return true, nil
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatalf("ObjectIDFromHex(%q): %v", hex, err)
+	}
+	return id
+}
+
+var (
+	_ authRepository.SessionRepository     = (*fakeSessionRepository)(nil)
+	_ authRepository.KnownDeviceRepository = (*fakeKnownDeviceRepository)(nil)
+	_ authRepository.RevocationStore       = (*fakeRevocationStore)(nil)
+)
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	authModel "github.com/iots1/mingkwan-api/internal/auth/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// Values of the token_type_hint parameter (RFC 7009, section 2.1).
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectToken implements RFC 7662. Tokens that are malformed, expired, revoked
+// or not issued by this server (including opaque strings) are reported as inactive
+// rather than as an error; an error means the token state could not be determined.
+func (s *AuthUsecase) IntrospectToken(ctx context.Context, token, tokenTypeHint string) (*authModel.IntrospectionResponse, error) {
+	claims, err := s.parseAnyToken(token, tokenTypeHint)
+	if err != nil {
+		utils.Logger.Debug("AuthUsecase: Introspected token is not valid", zap.Error(err))
+		return &authModel.IntrospectionResponse{Active: false}, nil
+	}
+
+	revoked, err := authAdapter.IsClaimsRevoked(ctx, s.revocations, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return &authModel.IntrospectionResponse{Active: false}, nil
+	}
+
+	resp := &authModel.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Sub:       claims.UserID,
+		TokenType: "Bearer",
+		Jti:       claims.ID,
+	}
+	if claims.TokenType == authAdapter.TokenTypeRefresh {
+		resp.TokenType = "Refresh"
+	}
+	if resp.ClientID == "" {
+		resp.ClientID = authAdapter.FirstPartyClientID
+	}
+	if claims.ExpiresAt != nil {
+		resp.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.Iat = claims.IssuedAt.Unix()
+	}
+	return resp, nil
+}
+
+// RevokeToken implements RFC 7009 on behalf of clientID. Revoking a refresh token
+// revokes its whole session, and with it every access token issued for the session;
+// revoking an access token only blocks that token. Unknown or invalid tokens, and
+// tokens issued to another client, are ignored, as the RFC requires (section 2.1).
+func (s *AuthUsecase) RevokeToken(ctx context.Context, token, tokenTypeHint, clientID string) error {
+	claims, err := s.parseAnyToken(token, tokenTypeHint)
+	if err != nil {
+		utils.Logger.Debug("AuthUsecase: Ignoring revocation of invalid token", zap.String("clientID", clientID), zap.Error(err))
+		return nil
+	}
+	tokenClientID := claims.ClientID
+	if tokenClientID == "" {
+		tokenClientID = authAdapter.FirstPartyClientID
+	}
+	if tokenClientID != clientID {
+		utils.Logger.Debug("AuthUsecase: Ignoring revocation of token issued to another client", zap.String("clientID", clientID), zap.String("tokenClientID", tokenClientID))
+		return nil
+	}
+
+	metadata := map[string]interface{}{
+		"client_id":  clientID,
+		"token_type": claims.TokenType,
+		"jti":        claims.ID,
+	}
+
+	if claims.TokenType == authAdapter.TokenTypeRefresh && claims.SessionID != "" {
+		if err := s.revokeSessionByID(ctx, claims.SessionID); err != nil {
+			s.recordAudit(ctx, auditDomain.ActionAuthTokenRevoke, claims.UserID, err, metadata)
+			return err
+		}
+	} else if claims.ID != "" {
+		ttl := authDomain.SessionLifetime
+		if claims.ExpiresAt != nil {
+			ttl = time.Until(claims.ExpiresAt.Time)
+		}
+		if err := s.revocations.RevokeToken(ctx, claims.ID, ttl); err != nil {
+			s.recordAudit(ctx, auditDomain.ActionAuthTokenRevoke, claims.UserID, err, metadata)
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+	} else {
+		// Issued before tokens carried an ID; it expires on its own shortly.
+		utils.Logger.Warn("AuthUsecase: Token has no ID and cannot be revoked individually", zap.String("userID", claims.UserID))
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionAuthTokenRevoke, claims.UserID, nil, metadata)
+	utils.Logger.Info("Token revoked", zap.String("userID", claims.UserID), zap.String("clientID", clientID), zap.String("tokenType", claims.TokenType))
+	return nil
+}
+
+func (s *AuthUsecase) revokeSessionByID(ctx context.Context, sessionID string) error {
+	oid, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil
+	}
+	session, err := s.sessions.GetSessionByID(ctx, oid)
+	if err != nil {
+		if errors.Is(err, authDomain.ErrSessionNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+	if session.IsRevoked() {
+		return nil
+	}
+	return s.revokeSession(ctx, session, authDomain.RevokeReasonTokenRevoked)
+}
+
+// parseAnyToken parses token as an access or refresh token, trying the hinted type
+// first. Per RFC 7009 the hint is only an optimization, so both are always tried.
+func (s *AuthUsecase) parseAnyToken(token, tokenTypeHint string) (*authAdapter.Claims, error) {
+	parsers := []func(string) (*authAdapter.Claims, error){s.jwtGenerator.ParseAccessToken, s.jwtGenerator.ParseRefreshToken}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		parsers[0], parsers[1] = parsers[1], parsers[0]
+	}
+
+	var lastErr error
+	for _, parse := range parsers {
+		claims, err := parse(token)
+		if err == nil {
+			return claims, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
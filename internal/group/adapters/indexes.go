@@ -0,0 +1,52 @@
+package adapters
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// GroupIndexes declares the indexes of the groups collection. Names are unique
+// regardless of case.
+func GroupIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "name", Value: 1}},
+				Options: options.Index().
+					SetName("groups_name_unique").
+					SetUnique(true).
+					SetCollation(caseInsensitive),
+			},
+		},
+	}
+}
+
+// GroupMembershipIndexes declares the indexes of the memberships collection: one
+// to list a group's members and keep them unique, one to find a member's groups.
+func GroupMembershipIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "member_type", Value: 1}, {Key: "member_id", Value: 1}},
+				Options: options.Index().
+					SetName("group_memberships_member_unique").
+					SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "group_id", Value: 1}, {Key: "added_at", Value: 1}},
+				Options: options.Index().SetName("group_memberships_group_added_at"),
+			},
+			{
+				Keys:    bson.D{{Key: "member_type", Value: 1}, {Key: "member_id", Value: 1}},
+				Options: options.Index().SetName("group_memberships_member"),
+			},
+		},
+	}
+}
@@ -0,0 +1,208 @@
+package adapters
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	"github.com/iots1/mingkwan-api/internal/group/repository"
+)
+
+// MemoryGroupRepository is an in-memory GroupRepository with the semantics of
+// MongoGroupRepository, for tests and local tools.
+type MemoryGroupRepository struct {
+	mu          sync.RWMutex
+	groups      map[primitive.ObjectID]domain.Group
+	memberships []domain.Membership
+}
+
+func NewMemoryGroupRepository() *MemoryGroupRepository {
+	return &MemoryGroupRepository{groups: make(map[primitive.ObjectID]domain.Group)}
+}
+
+func (r *MemoryGroupRepository) CreateGroup(ctx context.Context, group *domain.Group) (*domain.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.nameTaken(group.Name, primitive.NilObjectID) {
+		return nil, domain.ErrGroupAlreadyExists
+	}
+	if group.ID.IsZero() {
+		group.ID = primitive.NewObjectID()
+	}
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	r.groups[group.ID] = cloneGroup(*group)
+	return group, nil
+}
+
+func (r *MemoryGroupRepository) GetGroupByID(ctx context.Context, id primitive.ObjectID) (*domain.Group, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	group, ok := r.groups[id]
+	if !ok {
+		return nil, domain.ErrGroupNotFound
+	}
+	group = cloneGroup(group)
+	return &group, nil
+}
+
+func (r *MemoryGroupRepository) GetGroupsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]domain.Group, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	groups := []domain.Group{}
+	for _, id := range ids {
+		if group, ok := r.groups[id]; ok {
+			groups = append(groups, cloneGroup(group))
+		}
+	}
+	return groups, nil
+}
+
+func (r *MemoryGroupRepository) ListGroups(ctx context.Context, page domain.Page) ([]domain.Group, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	groups := make([]domain.Group, 0, len(r.groups))
+	for _, group := range r.groups {
+		groups = append(groups, cloneGroup(group))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := strings.ToLower(groups[i].Name), strings.ToLower(groups[j].Name)
+		if a != b {
+			return a < b
+		}
+		return groups[i].ID.Hex() < groups[j].ID.Hex()
+	})
+	return paginate(groups, page), int64(len(groups)), nil
+}
+
+func (r *MemoryGroupRepository) UpdateGroup(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) (*domain.Group, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	group, ok := r.groups[id]
+	if !ok {
+		return nil, domain.ErrGroupNotFound
+	}
+	for field, value := range update {
+		switch field {
+		case "name":
+			group.Name = value.(string)
+		case "description":
+			group.Description = value.(string)
+		case "roles":
+			group.Roles = slices.Clone(value.([]string))
+		}
+	}
+	if r.nameTaken(group.Name, id) {
+		return nil, domain.ErrGroupAlreadyExists
+	}
+	group.UpdatedAt = time.Now()
+	r.groups[id] = group
+	group = cloneGroup(group)
+	return &group, nil
+}
+
+func (r *MemoryGroupRepository) DeleteGroup(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.groups[id]; !ok {
+		return domain.ErrGroupNotFound
+	}
+	delete(r.groups, id)
+	r.memberships = slices.DeleteFunc(r.memberships, func(m domain.Membership) bool {
+		return m.GroupID == id || (m.MemberType == domain.MemberTypeGroup && m.MemberID == id.Hex())
+	})
+	return nil
+}
+
+func (r *MemoryGroupRepository) AddMember(ctx context.Context, membership *domain.Membership) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.indexOf(membership.GroupID, membership.MemberType, membership.MemberID) >= 0 {
+		return domain.ErrMemberExists
+	}
+	membership.AddedAt = time.Now()
+	r.memberships = append(r.memberships, *membership)
+	return nil
+}
+
+func (r *MemoryGroupRepository) RemoveMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.indexOf(groupID, memberType, memberID)
+	if i < 0 {
+		return domain.ErrMemberNotFound
+	}
+	r.memberships = slices.Delete(r.memberships, i, i+1)
+	return nil
+}
+
+func (r *MemoryGroupRepository) ListMembers(ctx context.Context, groupID primitive.ObjectID, page domain.Page) ([]domain.Membership, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := []domain.Membership{}
+	for _, m := range r.memberships {
+		if m.GroupID == groupID {
+			members = append(members, m)
+		}
+	}
+	return paginate(members, page), int64(len(members)), nil
+}
+
+func (r *MemoryGroupRepository) ListMemberships(ctx context.Context, memberType string, memberIDs []string) ([]domain.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	memberships := []domain.Membership{}
+	for _, m := range r.memberships {
+		if m.MemberType == memberType && slices.Contains(memberIDs, m.MemberID) {
+			memberships = append(memberships, m)
+		}
+	}
+	return memberships, nil
+}
+
+func (r *MemoryGroupRepository) RemoveMemberships(ctx context.Context, memberType, memberID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := len(r.memberships)
+	r.memberships = slices.DeleteFunc(r.memberships, func(m domain.Membership) bool {
+		return m.MemberType == memberType && m.MemberID == memberID
+	})
+	return int64(before - len(r.memberships)), nil
+}
+
+// nameTaken reports whether another group than except has name, ignoring case. The
+// caller holds the lock.
+func (r *MemoryGroupRepository) nameTaken(name string, except primitive.ObjectID) bool {
+	for id, group := range r.groups {
+		if id != except && strings.EqualFold(group.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf returns the position of a membership, or -1. The caller holds the lock.
+func (r *MemoryGroupRepository) indexOf(groupID primitive.ObjectID, memberType, memberID string) int {
+	return slices.IndexFunc(r.memberships, func(m domain.Membership) bool {
+		return m.GroupID == groupID && m.MemberType == memberType && m.MemberID == memberID
+	})
+}
+
+func cloneGroup(group domain.Group) domain.Group {
+	group.Roles = slices.Clone(group.Roles)
+	return group
+}
+
+func paginate[T any](items []T, page domain.Page) []T {
+	start := min(page.Skip(), len(items))
+	end := min(start+page.Limit, len(items))
+	return items[start:end]
+}
+
+var _ repository.GroupRepository = (*MemoryGroupRepository)(nil)
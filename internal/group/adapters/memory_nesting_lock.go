@@ -0,0 +1,27 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/iots1/mingkwan-api/internal/group/repository"
+)
+
+// MemoryNestingLock is a NestingLock for a single process.
+type MemoryNestingLock struct {
+	held chan struct{}
+}
+
+func NewMemoryNestingLock() *MemoryNestingLock {
+	return &MemoryNestingLock{held: make(chan struct{}, 1)}
+}
+
+func (l *MemoryNestingLock) Lock(ctx context.Context) (func(), error) {
+	select {
+	case l.held <- struct{}{}:
+		return func() { <-l.held }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+var _ repository.NestingLock = (*MemoryNestingLock)(nil)
@@ -0,0 +1,211 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	"github.com/iots1/mingkwan-api/internal/group/repository"
+)
+
+// MongoGroupRepository keeps groups and memberships in separate collections, so
+// that a group's size does not bound the document size and a member's groups are
+// found through an index.
+type MongoGroupRepository struct {
+	groups      *mongo.Collection
+	memberships *mongo.Collection
+}
+
+func NewMongoGroupRepository(db *mongo.Database, groupsCollection, membershipsCollection string) *MongoGroupRepository {
+	return &MongoGroupRepository{
+		groups:      db.Collection(groupsCollection),
+		memberships: db.Collection(membershipsCollection),
+	}
+}
+
+func (r *MongoGroupRepository) CreateGroup(ctx context.Context, group *domain.Group) (*domain.Group, error) {
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	res, err := r.groups.InsertOne(ctx, group)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, domain.ErrGroupAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to insert group: %w", err)
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		group.ID = oid
+	}
+	return group, nil
+}
+
+func (r *MongoGroupRepository) GetGroupByID(ctx context.Context, id primitive.ObjectID) (*domain.Group, error) {
+	var group domain.Group
+	if err := r.groups.FindOne(ctx, bson.M{"_id": id}).Decode(&group); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("failed to find group by ID: %w", err)
+	}
+	return &group, nil
+}
+
+func (r *MongoGroupRepository) GetGroupsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]domain.Group, error) {
+	groups := []domain.Group{}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	cursor, err := r.groups.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (r *MongoGroupRepository) ListGroups(ctx context.Context, page domain.Page) ([]domain.Group, int64, error) {
+	total, err := r.groups.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count groups: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetCollation(caseInsensitive).
+		SetSkip(int64(page.Skip())).
+		SetLimit(int64(page.Limit))
+	cursor, err := r.groups.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get groups cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	groups := []domain.Group{}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode groups: %w", err)
+	}
+	return groups, total, nil
+}
+
+func (r *MongoGroupRepository) UpdateGroup(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) (*domain.Group, error) {
+	set := bson.M{"updated_at": time.Now()}
+	for field, value := range update {
+		set[field] = value
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var group domain.Group
+	if err := r.groups.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": set}, opts).Decode(&group); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrGroupNotFound
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, domain.ErrGroupAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+	return &group, nil
+}
+
+func (r *MongoGroupRepository) DeleteGroup(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.groups.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrGroupNotFound
+	}
+
+	// Left-over memberships are harmless (lookups skip unknown groups), so a failure
+	// here is reported but the group stays deleted.
+	filter := bson.M{"$or": bson.A{
+		bson.M{"group_id": id},
+		bson.M{"member_type": domain.MemberTypeGroup, "member_id": id.Hex()},
+	}}
+	if _, err := r.memberships.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("failed to delete memberships of group: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoGroupRepository) AddMember(ctx context.Context, membership *domain.Membership) error {
+	membership.AddedAt = time.Now()
+	if _, err := r.memberships.InsertOne(ctx, membership); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrMemberExists
+		}
+		return fmt.Errorf("failed to insert membership: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoGroupRepository) RemoveMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) error {
+	res, err := r.memberships.DeleteOne(ctx, bson.M{"group_id": groupID, "member_type": memberType, "member_id": memberID})
+	if err != nil {
+		return fmt.Errorf("failed to delete membership: %w", err)
+	}
+	if res.DeletedCount == 0 {
+		return domain.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *MongoGroupRepository) ListMembers(ctx context.Context, groupID primitive.ObjectID, page domain.Page) ([]domain.Membership, int64, error) {
+	filter := bson.M{"group_id": groupID}
+	total, err := r.memberships.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "added_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(page.Skip())).
+		SetLimit(int64(page.Limit))
+	cursor, err := r.memberships.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get group members cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	members := []domain.Membership{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode group members: %w", err)
+	}
+	return members, total, nil
+}
+
+func (r *MongoGroupRepository) ListMemberships(ctx context.Context, memberType string, memberIDs []string) ([]domain.Membership, error) {
+	memberships := []domain.Membership{}
+	if len(memberIDs) == 0 {
+		return memberships, nil
+	}
+	cursor, err := r.memberships.Find(ctx, bson.M{"member_type": memberType, "member_id": bson.M{"$in": memberIDs}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &memberships); err != nil {
+		return nil, fmt.Errorf("failed to decode memberships: %w", err)
+	}
+	return memberships, nil
+}
+
+func (r *MongoGroupRepository) RemoveMemberships(ctx context.Context, memberType, memberID string) (int64, error) {
+	res, err := r.memberships.DeleteMany(ctx, bson.M{"member_type": memberType, "member_id": memberID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete memberships: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+var _ repository.GroupRepository = (*MongoGroupRepository)(nil)
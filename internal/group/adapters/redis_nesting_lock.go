@@ -0,0 +1,83 @@
+package adapters
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	"github.com/iots1/mingkwan-api/internal/group/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const (
+	nestingLockKey = "groups:nesting_lock"
+	// nestingLockTTL frees the lock of a process that died holding it. A nesting
+	// takes a few queries, far less than this.
+	nestingLockTTL = 10 * time.Second
+	// nestingLockWait is how long Lock waits for another holder.
+	nestingLockWait   = 5 * time.Second
+	nestingLockRetry  = 50 * time.Millisecond
+	nestingTokenBytes = 16
+)
+
+// releaseNestingLockScript deletes the lock only if it still holds the caller's
+// token, so that a holder whose lock expired cannot release the next holder's.
+var releaseNestingLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisNestingLock is a NestingLock shared by every instance through Redis.
+type RedisNestingLock struct {
+	client *redis.Client
+}
+
+func NewRedisNestingLock(client *redis.Client) *RedisNestingLock {
+	return &RedisNestingLock{client: client}
+}
+
+func (l *RedisNestingLock) Lock(ctx context.Context) (func(), error) {
+	raw := make([]byte, nestingTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate nesting lock token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	deadline := time.Now().Add(nestingLockWait)
+	for {
+		ok, err := l.client.SetNX(ctx, nestingLockKey, token, nestingLockTTL).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to take nesting lock: %w", err)
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, domain.ErrGroupsBusy
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(nestingLockRetry):
+		}
+	}
+
+	return func() {
+		// Released even if the request was cancelled meanwhile.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := releaseNestingLockScript.Run(ctx, l.client, []string{nestingLockKey}, token).Err(); err != nil {
+			utils.Logger.Warn("RedisNestingLock: Failed to release nesting lock, it expires by itself", zap.Error(err))
+		}
+	}, nil
+}
+
+var _ repository.NestingLock = (*RedisNestingLock)(nil)
@@ -0,0 +1,294 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	groupModel "github.com/iots1/mingkwan-api/internal/group/models"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type GroupHandler struct {
+	groupUsecase groupUsecase.GroupUsecase
+}
+
+func NewGroupHandler(groupUsecase groupUsecase.GroupUsecase) *GroupHandler {
+	return &GroupHandler{groupUsecase: groupUsecase}
+}
+
+func (h *GroupHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
+	logFields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status_code", statusCode),
+		zap.String("message", message),
+	}
+	if err != nil {
+		logFields = append(logFields, zap.Error(err))
+	}
+	if validationErrors != nil {
+		logFields = append(logFields, zap.Any("validation_errors", validationErrors))
+	}
+	utils.Logger.Error("API Error", logFields...)
+
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Errors:    validationErrors,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
+
+func (h *GroupHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+	})
+}
+
+func (h *GroupHandler) sendPageResponse(c *fiber.Ctx, data interface{}, count int, page domain.Page, total int64) error {
+	return c.Status(fiber.StatusOK).JSON(sharedModel.GenericSuccessResponse{
+		Code:    fiber.StatusOK,
+		Success: true,
+		Data:    data,
+		Count:   count,
+		Meta: &sharedModel.PageMeta{
+			Total:   total,
+			Limit:   page.Limit,
+			Page:    page.Page,
+			HasMore: int64(page.Skip()+count) < total,
+		},
+	})
+}
+
+// sendUsecaseError maps the errors of the group use case to responses.
+func (h *GroupHandler) sendUsecaseError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, domain.ErrGroupNotFound), errors.Is(err, domain.ErrMemberNotFound):
+		return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+	case errors.Is(err, domain.ErrGroupAlreadyExists), errors.Is(err, domain.ErrMemberExists), errors.Is(err, domain.ErrGroupCycle),
+		errors.Is(err, domain.ErrGroupsBusy):
+		return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+	case errors.Is(err, domain.ErrUnknownMember):
+		return h.sendErrorResponse(c, fiber.StatusUnprocessableEntity, err.Error(), nil, nil)
+	}
+	return h.sendErrorResponse(c, fiber.StatusInternalServerError, message, err, nil)
+}
+
+// parseBody reads and validates the JSON body into req and reports a response if
+// it is invalid.
+func (h *GroupHandler) parseBody(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		return false, h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, utils.FormatValidationErrors(err))
+	}
+	return true, nil
+}
+
+func (h *GroupHandler) parsePage(c *fiber.Ctx) (domain.Page, bool, error) {
+	var query groupModel.PageQuery
+	if err := c.QueryParser(&query); err != nil {
+		return domain.Page{}, false, h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		return domain.Page{}, false, h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, utils.FormatValidationErrors(err))
+	}
+	return query.ToPage(), true, nil
+}
+
+func (h *GroupHandler) parseGroupID(c *fiber.Ctx) (primitive.ObjectID, bool, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return primitive.NilObjectID, false, h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid group ID format", err, nil)
+	}
+	return id, true, nil
+}
+
+func (h *GroupHandler) CreateGroup(c *fiber.Ctx) error {
+	var req groupModel.CreateGroupRequest
+	if ok, err := h.parseBody(c, &req); !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	group, err := h.groupUsecase.CreateGroup(ctx, &domain.Group{Name: req.Name, Description: req.Description, Roles: req.Roles})
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to create group")
+	}
+	return h.sendSuccessResponse(c, fiber.StatusCreated, group, 1)
+}
+
+func (h *GroupHandler) ListGroups(c *fiber.Ctx) error {
+	page, ok, err := h.parsePage(c)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	groups, total, err := h.groupUsecase.ListGroups(ctx, page)
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to retrieve groups")
+	}
+	return h.sendPageResponse(c, groups, len(groups), page, total)
+}
+
+func (h *GroupHandler) GetGroup(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	group, err := h.groupUsecase.GetGroup(ctx, id)
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to retrieve group")
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, group, 1)
+}
+
+func (h *GroupHandler) UpdateGroup(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+	var req groupModel.UpdateGroupRequest
+	if ok, err := h.parseBody(c, &req); !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	group, err := h.groupUsecase.UpdateGroup(ctx, id, req.ToUpdate())
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to update group")
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, group, 1)
+}
+
+func (h *GroupHandler) DeleteGroup(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.groupUsecase.DeleteGroup(ctx, id); err != nil {
+		return h.sendUsecaseError(c, err, "Failed to delete group")
+	}
+	utils.Logger.Info("Group deleted", zap.String("group_id", id.Hex()))
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// ListMembers returns the direct members of a group; nested groups are listed as
+// members, not expanded.
+func (h *GroupHandler) ListMembers(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+	page, ok, err := h.parsePage(c)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	members, total, err := h.groupUsecase.ListMembers(ctx, id, page)
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to retrieve group members")
+	}
+	return h.sendPageResponse(c, members, len(members), page, total)
+}
+
+func (h *GroupHandler) AddMember(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+	var req groupModel.AddMemberRequest
+	if ok, err := h.parseBody(c, &req); !ok {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	membership, err := h.groupUsecase.AddMember(ctx, id, req.MemberType, req.MemberID)
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to add member")
+	}
+	utils.Logger.Info("Group member added", zap.String("group_id", id.Hex()), zap.String("member_type", req.MemberType), zap.String("member_id", req.MemberID))
+	return h.sendSuccessResponse(c, fiber.StatusCreated, membership, 1)
+}
+
+func (h *GroupHandler) RemoveMember(c *fiber.Ctx) error {
+	id, ok, err := h.parseGroupID(c)
+	if !ok {
+		return err
+	}
+	memberType := c.Params("memberType")
+	if memberType != domain.MemberTypeUser && memberType != domain.MemberTypeGroup {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Member type must be user or group", nil, nil)
+	}
+	memberID := c.Params("memberId")
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.groupUsecase.RemoveMember(ctx, id, memberType, memberID); err != nil {
+		return h.sendUsecaseError(c, err, "Failed to remove member")
+	}
+	utils.Logger.Info("Group member removed", zap.String("group_id", id.Hex()), zap.String("member_type", memberType), zap.String("member_id", memberID))
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// ListUserGroups returns the groups of a user, including those inherited through
+// nested groups. Users may list their own groups, admins anyone's.
+func (h *GroupHandler) ListUserGroups(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID, err := userDomain.ParseUserID(id)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
+	}
+	meta := middleware.GetRequestMeta(c)
+	if meta.ActorID != id && !slices.Contains(meta.ActorRoles, userDomain.RoleAdmin) {
+		return h.sendErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	groups, err := h.groupUsecase.ListUserGroups(ctx, userID)
+	if err != nil {
+		return h.sendUsecaseError(c, err, "Failed to retrieve user's groups")
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, groups, len(groups))
+}
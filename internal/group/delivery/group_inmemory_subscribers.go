@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// GroupInmemoryEventSubscribers keeps group memberships in line with user
+// lifecycle events.
+type GroupInmemoryEventSubscribers struct {
+	inMemoryBus  *event.InMemPubSub
+	groupUsecase groupUsecase.GroupUsecase
+}
+
+func NewGroupInmemoryEventSubscribers(bus *event.InMemPubSub, groupUsecase groupUsecase.GroupUsecase) *GroupInmemoryEventSubscribers {
+	return &GroupInmemoryEventSubscribers{inMemoryBus: bus, groupUsecase: groupUsecase}
+}
+
+func (s *GroupInmemoryEventSubscribers) StartAllSubscribers(ctx context.Context) {
+	go s.listenToUserErasedEvents(ctx)
+	utils.Logger.Info("GroupFeature/In-Memory Subscribers: All listeners started.")
+}
+
+// listenToUserErasedEvents drops memberships added to an erased user while the erasure
+// job ran.
+func (s *GroupInmemoryEventSubscribers) listenToUserErasedEvents(ctx context.Context) {
+	ch := s.inMemoryBus.SubscribeEvent(event.UserErasedInMemoryEvent)
+	utils.Logger.Info("GroupFeature/In-Memory Subscriber: Listening for 'user.erased.inmemory' events.")
+
+	for {
+		select {
+		case eventData := <-ch:
+			payload, ok := eventData.(event.UserErasedPayload)
+			if !ok {
+				utils.Logger.Warn("GroupFeature/In-Memory Subscriber: Received unexpected payload type for 'user.erased.inmemory' event.",
+					zap.Any("event_data", eventData))
+				continue
+			}
+
+			eraseCtx, cancel := context.WithTimeout(utils.WithRequestMeta(ctx, &utils.RequestMeta{CorrelationID: payload.RequestID}), 10*time.Second)
+			removed, err := s.groupUsecase.RemoveUserFromGroups(eraseCtx, userDomain.UserID(payload.UserID))
+			cancel()
+			if err != nil {
+				utils.Logger.Error("GroupFeature/In-Memory Subscriber: Failed to erase memberships of erased user",
+					zap.String("user_id", payload.UserID), zap.Error(err))
+				continue
+			}
+			utils.Logger.Info("GroupFeature/In-Memory Subscriber: Erased remaining memberships of erased user",
+				zap.String("user_id", payload.UserID), zap.Int64("memberships", removed))
+		case <-ctx.Done():
+			utils.Logger.Info("GroupFeature/In-Memory Subscriber: 'user.erased.inmemory' event listener stopped.", zap.Error(ctx.Err()))
+			return
+		}
+	}
+}
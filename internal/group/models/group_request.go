@@ -0,0 +1,39 @@
+package models
+
+import "github.com/iots1/mingkwan-api/internal/group/domain"
+
+type CreateGroupRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=100"`
+	Description string   `json:"description" validate:"omitempty,max=500"`
+	Roles       []string `json:"roles" validate:"omitempty,max=10,dive,oneof=user admin support"`
+}
+
+// UpdateGroupRequest changes the given fields; omitted fields are left unchanged.
+// An empty roles list removes all roles.
+type UpdateGroupRequest struct {
+	Name        *string   `json:"name" validate:"omitempty,min=2,max=100"`
+	Description *string   `json:"description" validate:"omitempty,max=500"`
+	Roles       *[]string `json:"roles" validate:"omitempty,max=10,dive,oneof=user admin support"`
+}
+
+func (r UpdateGroupRequest) ToUpdate() domain.GroupUpdate {
+	return domain.GroupUpdate{Name: r.Name, Description: r.Description, Roles: r.Roles}
+}
+
+// AddMemberRequest adds a user, or a group to nest, to a group.
+type AddMemberRequest struct {
+	MemberType string `json:"member_type" validate:"required,oneof=user group"`
+	MemberID   string `json:"member_id" validate:"required,max=64"`
+}
+
+// PageQuery is the query string of the paginated group listings.
+type PageQuery struct {
+	Page  int `query:"page" validate:"omitempty,min=1"`
+	Limit int `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+func (q PageQuery) ToPage() domain.Page {
+	page := domain.Page{Page: q.Page, Limit: q.Limit}
+	page.Normalize()
+	return page
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+)
+
+// GroupRepository stores groups and their direct memberships. Nesting is resolved
+// by the use case, which walks memberships level by level.
+type GroupRepository interface {
+	// CreateGroup returns ErrGroupAlreadyExists if the name is taken, ignoring case.
+	CreateGroup(ctx context.Context, group *domain.Group) (*domain.Group, error)
+	GetGroupByID(ctx context.Context, id primitive.ObjectID) (*domain.Group, error)
+	// GetGroupsByIDs returns the groups that exist among ids, in no particular order.
+	GetGroupsByIDs(ctx context.Context, ids []primitive.ObjectID) ([]domain.Group, error)
+	ListGroups(ctx context.Context, page domain.Page) ([]domain.Group, int64, error)
+	UpdateGroup(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) (*domain.Group, error)
+	// DeleteGroup removes the group together with its memberships and the
+	// memberships that nest it in other groups.
+	DeleteGroup(ctx context.Context, id primitive.ObjectID) error
+
+	// AddMember returns ErrMemberExists if the member is already a direct member.
+	AddMember(ctx context.Context, membership *domain.Membership) error
+	RemoveMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) error
+	ListMembers(ctx context.Context, groupID primitive.ObjectID, page domain.Page) ([]domain.Membership, int64, error)
+	// ListMemberships returns the direct memberships of any of memberIDs.
+	ListMemberships(ctx context.Context, memberType string, memberIDs []string) ([]domain.Membership, error)
+	// RemoveMemberships removes a member from every group and returns how many
+	// memberships were removed.
+	RemoveMemberships(ctx context.Context, memberType, memberID string) (int64, error)
+}
+
+// NestingLock serializes nesting groups into groups. The cycle check and the insert
+// run under it, so two concurrent nestings cannot each pass the check and together
+// create a cycle.
+type NestingLock interface {
+	// Lock waits until the lock is taken and returns the function that releases
+	// it. It returns domain.ErrGroupsBusy if the lock stays taken for too long.
+	Lock(ctx context.Context) (unlock func(), err error)
+}
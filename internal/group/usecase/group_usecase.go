@@ -0,0 +1,352 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	"github.com/iots1/mingkwan-api/internal/group/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+type GroupUsecase struct {
+	repo        repository.GroupRepository
+	nesting     repository.NestingLock
+	userUsecase userUsecase.UserUsecase
+	audit       *auditUsecase.AuditUsecase
+}
+
+func NewGroupUsecase(repo repository.GroupRepository, nesting repository.NestingLock, userUsecase userUsecase.UserUsecase, audit *auditUsecase.AuditUsecase) *GroupUsecase {
+	return &GroupUsecase{repo: repo, nesting: nesting, userUsecase: userUsecase, audit: audit}
+}
+
+// recordAudit writes an audit entry for an operation on the group identified by
+// targetID. A non-nil opErr marks the entry as a failure.
+func (s *GroupUsecase) recordAudit(ctx context.Context, action, targetID string, opErr error, metadata map[string]interface{}) {
+	entry := auditDomain.AuditEntry{
+		Action:     action,
+		TargetType: auditDomain.TargetTypeGroup,
+		TargetID:   targetID,
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
+
+func (s *GroupUsecase) CreateGroup(ctx context.Context, group *domain.Group) (*domain.Group, error) {
+	group.Roles = normalizeRoles(group.Roles)
+	group.CreatedBy = utils.RequestMetaFromContext(ctx).ActorID
+
+	created, err := s.repo.CreateGroup(ctx, group)
+	if err != nil {
+		s.recordAudit(ctx, auditDomain.ActionGroupCreate, "", err, map[string]interface{}{"name": group.Name})
+		if errors.Is(err, domain.ErrGroupAlreadyExists) {
+			return nil, err
+		}
+		utils.Logger.Error("GroupUsecase: Failed to create group", zap.String("name", group.Name), zap.Error(err))
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionGroupCreate, created.ID.Hex(), nil, map[string]interface{}{"name": created.Name, "roles": created.Roles})
+	utils.Logger.Info("Group created", zap.String("group_id", created.ID.Hex()), zap.String("name", created.Name))
+	return created, nil
+}
+
+func (s *GroupUsecase) GetGroup(ctx context.Context, id primitive.ObjectID) (*domain.Group, error) {
+	group, err := s.repo.GetGroupByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrGroupNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return group, nil
+}
+
+func (s *GroupUsecase) ListGroups(ctx context.Context, page domain.Page) ([]domain.Group, int64, error) {
+	page.Normalize()
+	groups, total, err := s.repo.ListGroups(ctx, page)
+	if err != nil {
+		utils.Logger.Error("GroupUsecase: Failed to list groups", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list groups: %w", err)
+	}
+	return groups, total, nil
+}
+
+func (s *GroupUsecase) UpdateGroup(ctx context.Context, id primitive.ObjectID, update domain.GroupUpdate) (*domain.Group, error) {
+	fields := map[string]interface{}{}
+	if update.Name != nil {
+		fields["name"] = *update.Name
+	}
+	if update.Description != nil {
+		fields["description"] = *update.Description
+	}
+	if update.Roles != nil {
+		fields["roles"] = normalizeRoles(*update.Roles)
+	}
+	if len(fields) == 0 {
+		return s.GetGroup(ctx, id)
+	}
+
+	group, err := s.repo.UpdateGroup(ctx, id, fields)
+	if err != nil {
+		s.recordAudit(ctx, auditDomain.ActionGroupUpdate, id.Hex(), err, nil)
+		if errors.Is(err, domain.ErrGroupNotFound) || errors.Is(err, domain.ErrGroupAlreadyExists) {
+			return nil, err
+		}
+		utils.Logger.Error("GroupUsecase: Failed to update group", zap.String("group_id", id.Hex()), zap.Error(err))
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionGroupUpdate, id.Hex(), nil, fields)
+	return group, nil
+}
+
+// DeleteGroup removes a group. Its members lose the group's roles; nested groups
+// and users stay, only the memberships go.
+func (s *GroupUsecase) DeleteGroup(ctx context.Context, id primitive.ObjectID) error {
+	if err := s.repo.DeleteGroup(ctx, id); err != nil {
+		s.recordAudit(ctx, auditDomain.ActionGroupDelete, id.Hex(), err, nil)
+		if errors.Is(err, domain.ErrGroupNotFound) {
+			return err
+		}
+		utils.Logger.Error("GroupUsecase: Failed to delete group", zap.String("group_id", id.Hex()), zap.Error(err))
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	s.recordAudit(ctx, auditDomain.ActionGroupDelete, id.Hex(), nil, nil)
+	return nil
+}
+
+// AddMember makes a user or another group a direct member of the group. Nesting a
+// group that already contains the target group, directly or not, is rejected with
+// ErrGroupCycle. Nestings run one at a time under the nesting lock, so concurrent
+// requests cannot together create a cycle.
+func (s *GroupUsecase) AddMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) (*domain.Membership, error) {
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+	if memberType == domain.MemberTypeGroup {
+		unlock, err := s.nesting.Lock(ctx)
+		if err != nil {
+			s.recordAudit(ctx, auditDomain.ActionGroupMemberAdd, groupID.Hex(), err, memberMetadata(memberType, memberID))
+			if errors.Is(err, domain.ErrGroupsBusy) {
+				return nil, err
+			}
+			utils.Logger.Error("GroupUsecase: Failed to lock group nesting", zap.String("group_id", groupID.Hex()), zap.Error(err))
+			return nil, fmt.Errorf("failed to lock group nesting: %w", err)
+		}
+		defer unlock()
+	}
+	if err := s.checkMember(ctx, groupID, memberType, memberID); err != nil {
+		s.recordAudit(ctx, auditDomain.ActionGroupMemberAdd, groupID.Hex(), err, memberMetadata(memberType, memberID))
+		return nil, err
+	}
+
+	membership := &domain.Membership{
+		GroupID:    groupID,
+		MemberType: memberType,
+		MemberID:   memberID,
+		AddedBy:    utils.RequestMetaFromContext(ctx).ActorID,
+	}
+	if err := s.repo.AddMember(ctx, membership); err != nil {
+		if errors.Is(err, domain.ErrMemberExists) {
+			return nil, err
+		}
+		utils.Logger.Error("GroupUsecase: Failed to add member", zap.String("group_id", groupID.Hex()), zap.String("member_id", memberID), zap.Error(err))
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionGroupMemberAdd, groupID.Hex(), nil, memberMetadata(memberType, memberID))
+	return membership, nil
+}
+
+// checkMember verifies that the member exists and, for a group, that nesting it
+// does not create a cycle.
+func (s *GroupUsecase) checkMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) error {
+	if memberType == domain.MemberTypeUser {
+		userID, err := userDomain.ParseUserID(memberID)
+		if err != nil {
+			return domain.ErrUnknownMember
+		}
+		if _, err := s.userUsecase.GetUserByID(ctx, userID); err != nil {
+			if errors.Is(err, userDomain.ErrUserNotFound) {
+				return domain.ErrUnknownMember
+			}
+			return err
+		}
+		return nil
+	}
+
+	childID, err := primitive.ObjectIDFromHex(memberID)
+	if err != nil {
+		return domain.ErrUnknownMember
+	}
+	if childID == groupID {
+		return domain.ErrGroupCycle
+	}
+	if _, err := s.GetGroup(ctx, childID); err != nil {
+		if errors.Is(err, domain.ErrGroupNotFound) {
+			return domain.ErrUnknownMember
+		}
+		return err
+	}
+	// The child must not already contain the group, or the group would end up
+	// inside itself.
+	ancestors, err := s.containingGroups(ctx, domain.MemberTypeGroup, groupID.Hex())
+	if err != nil {
+		return err
+	}
+	if _, ok := ancestors[childID]; ok {
+		return domain.ErrGroupCycle
+	}
+	return nil
+}
+
+func (s *GroupUsecase) RemoveMember(ctx context.Context, groupID primitive.ObjectID, memberType, memberID string) error {
+	if err := s.repo.RemoveMember(ctx, groupID, memberType, memberID); err != nil {
+		if errors.Is(err, domain.ErrMemberNotFound) {
+			return err
+		}
+		utils.Logger.Error("GroupUsecase: Failed to remove member", zap.String("group_id", groupID.Hex()), zap.String("member_id", memberID), zap.Error(err))
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	s.recordAudit(ctx, auditDomain.ActionGroupMemberRemove, groupID.Hex(), nil, memberMetadata(memberType, memberID))
+	return nil
+}
+
+// ListMembers returns the direct members of a group.
+func (s *GroupUsecase) ListMembers(ctx context.Context, groupID primitive.ObjectID, page domain.Page) ([]domain.Membership, int64, error) {
+	if _, err := s.GetGroup(ctx, groupID); err != nil {
+		return nil, 0, err
+	}
+	page.Normalize()
+	members, total, err := s.repo.ListMembers(ctx, groupID, page)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list group members: %w", err)
+	}
+	return members, total, nil
+}
+
+// ListUserGroups returns every group the user belongs to, directly or through
+// nested groups, sorted by name.
+func (s *GroupUsecase) ListUserGroups(ctx context.Context, userID userDomain.UserID) ([]domain.UserGroup, error) {
+	containing, err := s.containingGroups(ctx, domain.MemberTypeUser, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(containing))
+	for id := range containing {
+		ids = append(ids, id)
+	}
+	groups, err := s.repo.GetGroupsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user's groups: %w", err)
+	}
+
+	userGroups := make([]domain.UserGroup, 0, len(groups))
+	for _, group := range groups {
+		userGroups = append(userGroups, domain.UserGroup{Group: group, Direct: containing[group.ID]})
+	}
+	slices.SortFunc(userGroups, func(a, b domain.UserGroup) int {
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.Hex(), b.ID.Hex())
+	})
+	return userGroups, nil
+}
+
+// GroupRoles returns the roles the user holds through their groups, sorted and
+// without duplicates.
+func (s *GroupUsecase) GroupRoles(ctx context.Context, userID userDomain.UserID) ([]string, error) {
+	groups, err := s.ListUserGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var roles []string
+	for _, group := range groups {
+		roles = append(roles, group.Roles...)
+	}
+	return normalizeRoles(roles), nil
+}
+
+// RemoveUserFromGroups drops all of the user's memberships, for erasure requests,
+// and returns how many were removed.
+func (s *GroupUsecase) RemoveUserFromGroups(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	removed, err := s.repo.RemoveMemberships(ctx, domain.MemberTypeUser, userID.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove user from groups: %w", err)
+	}
+	return removed, nil
+}
+
+// MoveUserMemberships moves the direct memberships of a user merged into another
+// to the target, keeping who added them, and returns how many were moved. Groups
+// the target is already a direct member of only lose the source. Replaying it for
+// the same users is a no-op.
+func (s *GroupUsecase) MoveUserMemberships(ctx context.Context, sourceID, targetID userDomain.UserID) (int, error) {
+	memberships, err := s.repo.ListMemberships(ctx, domain.MemberTypeUser, []string{sourceID.String()})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list memberships: %w", err)
+	}
+	for _, m := range memberships {
+		moved := m
+		moved.MemberID = targetID.String()
+		if err := s.repo.AddMember(ctx, &moved); err != nil && !errors.Is(err, domain.ErrMemberExists) {
+			return 0, fmt.Errorf("failed to add member: %w", err)
+		}
+		if err := s.repo.RemoveMember(ctx, m.GroupID, domain.MemberTypeUser, sourceID.String()); err != nil && !errors.Is(err, domain.ErrMemberNotFound) {
+			return 0, fmt.Errorf("failed to remove member: %w", err)
+		}
+	}
+	return len(memberships), nil
+}
+
+// containingGroups walks memberships upwards from a member and returns every group
+// that contains it, mapped to whether the membership is direct. It costs one query
+// per nesting level. Groups already seen are not expanded again, so it cannot loop
+// even on a cycle written around the use case.
+func (s *GroupUsecase) containingGroups(ctx context.Context, memberType, memberID string) (map[primitive.ObjectID]bool, error) {
+	found := map[primitive.ObjectID]bool{}
+	frontier := []string{memberID}
+	for direct := true; len(frontier) > 0; direct = false {
+		memberships, err := s.repo.ListMemberships(ctx, memberType, frontier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve group memberships: %w", err)
+		}
+		memberType, frontier = domain.MemberTypeGroup, nil
+		for _, m := range memberships {
+			if _, seen := found[m.GroupID]; seen {
+				continue
+			}
+			found[m.GroupID] = direct
+			frontier = append(frontier, m.GroupID.Hex())
+		}
+	}
+	return found, nil
+}
+
+// normalizeRoles sorts roles and drops duplicates.
+func normalizeRoles(roles []string) []string {
+	roles = slices.Clone(roles)
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+func memberMetadata(memberType, memberID string) map[string]interface{} {
+	return map[string]interface{}{"member_type": memberType, "member_id": memberID}
+}
@@ -0,0 +1,275 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/group/adapters"
+	"github.com/iots1/mingkwan-api/internal/group/domain"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase/usecasetest"
+)
+
+type groupFixture struct {
+	usecase *GroupUsecase
+	users   *usecasetest.Fixture
+}
+
+func newGroupFixture(t *testing.T) *groupFixture {
+	t.Helper()
+	f := &groupFixture{users: usecasetest.NewFixture()}
+	f.usecase = NewGroupUsecase(adapters.NewMemoryGroupRepository(), adapters.NewMemoryNestingLock(), *f.users.Users, nil)
+	return f
+}
+
+func (f *groupFixture) user(t *testing.T, email string) *userDomain.User {
+	t.Helper()
+	return f.users.Seed(t, &userDomain.User{Name: "Member", Email: email})
+}
+
+func (f *groupFixture) group(t *testing.T, name string, roles ...string) *domain.Group {
+	t.Helper()
+	group, err := f.usecase.CreateGroup(context.Background(), &domain.Group{Name: name, Roles: roles})
+	if err != nil {
+		t.Fatalf("CreateGroup %s: %v", name, err)
+	}
+	return group
+}
+
+// nest makes child a member of parent.
+func (f *groupFixture) nest(t *testing.T, parent, child *domain.Group) {
+	t.Helper()
+	if _, err := f.usecase.AddMember(context.Background(), parent.ID, domain.MemberTypeGroup, child.ID.Hex()); err != nil {
+		t.Fatalf("nest %s in %s: %v", child.Name, parent.Name, err)
+	}
+}
+
+func TestGroupUsecase_CreateGroup(t *testing.T) {
+	f := newGroupFixture(t)
+	f.group(t, "Engineering")
+
+	_, err := f.usecase.CreateGroup(context.Background(), &domain.Group{Name: "ENGINEERING"})
+	if !errors.Is(err, domain.ErrGroupAlreadyExists) {
+		t.Errorf("CreateGroup with taken name error = %v, want %v", err, domain.ErrGroupAlreadyExists)
+	}
+
+	group, err := f.usecase.CreateGroup(context.Background(), &domain.Group{Name: "Ops", Roles: []string{"support", "admin", "support"}})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if want := []string{"admin", "support"}; !slices.Equal(group.Roles, want) {
+		t.Errorf("roles = %v, want %v", group.Roles, want)
+	}
+}
+
+func TestGroupUsecase_AddMember(t *testing.T) {
+	tests := []struct {
+		name string
+		// member returns the member to add to group A, given a chain C in B in A.
+		member  func(t *testing.T, f *groupFixture, a, b, c *domain.Group) (string, string)
+		wantErr error
+	}{
+		{
+			name: "user",
+			member: func(t *testing.T, f *groupFixture, _, _, _ *domain.Group) (string, string) {
+				return domain.MemberTypeUser, f.user(t, "ann@example.com").ID.String()
+			},
+		},
+		{
+			name: "unknown user",
+			member: func(t *testing.T, _ *groupFixture, _, _, _ *domain.Group) (string, string) {
+				return domain.MemberTypeUser, primitive.NewObjectID().Hex()
+			},
+			wantErr: domain.ErrUnknownMember,
+		},
+		{
+			name: "unknown group",
+			member: func(t *testing.T, _ *groupFixture, _, _, _ *domain.Group) (string, string) {
+				return domain.MemberTypeGroup, primitive.NewObjectID().Hex()
+			},
+			wantErr: domain.ErrUnknownMember,
+		},
+		{
+			name: "unrelated group",
+			member: func(t *testing.T, f *groupFixture, _, _, _ *domain.Group) (string, string) {
+				return domain.MemberTypeGroup, f.group(t, "D").ID.Hex()
+			},
+		},
+		{
+			name: "existing member",
+			member: func(t *testing.T, _ *groupFixture, _, b, _ *domain.Group) (string, string) {
+				return domain.MemberTypeGroup, b.ID.Hex()
+			},
+			wantErr: domain.ErrMemberExists,
+		},
+		{
+			name: "itself",
+			member: func(t *testing.T, _ *groupFixture, a, _, _ *domain.Group) (string, string) {
+				return domain.MemberTypeGroup, a.ID.Hex()
+			},
+			wantErr: domain.ErrGroupCycle,
+		},
+		{
+			name: "nested group again, one level down",
+			member: func(t *testing.T, _ *groupFixture, _, _, c *domain.Group) (string, string) {
+				return domain.MemberTypeGroup, c.ID.Hex()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newGroupFixture(t)
+			a, b, c := f.group(t, "A"), f.group(t, "B"), f.group(t, "C")
+			f.nest(t, a, b)
+			f.nest(t, b, c)
+
+			memberType, memberID := tt.member(t, f, a, b, c)
+			_, err := f.usecase.AddMember(context.Background(), a.ID, memberType, memberID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddMember error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupUsecase_CycleDetection(t *testing.T) {
+	ctx := context.Background()
+	f := newGroupFixture(t)
+	a, b, c := f.group(t, "A"), f.group(t, "B"), f.group(t, "C")
+	f.nest(t, a, b)
+	f.nest(t, b, c)
+
+	for _, tt := range []struct{ parent, child *domain.Group }{{c, a}, {c, b}, {b, a}} {
+		_, err := f.usecase.AddMember(ctx, tt.parent.ID, domain.MemberTypeGroup, tt.child.ID.Hex())
+		if !errors.Is(err, domain.ErrGroupCycle) {
+			t.Errorf("nest %s in %s error = %v, want %v", tt.child.Name, tt.parent.Name, err, domain.ErrGroupCycle)
+		}
+	}
+
+	// Once B leaves A, A may go below C.
+	if err := f.usecase.RemoveMember(ctx, a.ID, domain.MemberTypeGroup, b.ID.Hex()); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	f.nest(t, c, a)
+}
+
+func TestGroupUsecase_ConcurrentNesting(t *testing.T) {
+	ctx := context.Background()
+	f := newGroupFixture(t)
+	for i := range 20 {
+		a, b := f.group(t, fmt.Sprintf("A%d", i)), f.group(t, fmt.Sprintf("B%d", i))
+
+		// Nesting A in B and B in A at once: exactly one may succeed.
+		errs := make(chan error, 2)
+		for _, pair := range [][2]*domain.Group{{a, b}, {b, a}} {
+			go func() {
+				_, err := f.usecase.AddMember(ctx, pair[0].ID, domain.MemberTypeGroup, pair[1].ID.Hex())
+				errs <- err
+			}()
+		}
+		var cycles int
+		for range 2 {
+			if err := <-errs; errors.Is(err, domain.ErrGroupCycle) {
+				cycles++
+			} else if err != nil {
+				t.Fatalf("AddMember: %v", err)
+			}
+		}
+		if cycles != 1 {
+			t.Fatalf("%d of 2 concurrent nestings were rejected, want 1", cycles)
+		}
+	}
+}
+
+func TestGroupUsecase_ListUserGroups(t *testing.T) {
+	ctx := context.Background()
+	f := newGroupFixture(t)
+	user := f.user(t, "ann@example.com")
+	staff, support, admins := f.group(t, "Staff", userDomain.RoleUser), f.group(t, "Support", userDomain.RoleSupport), f.group(t, "Admins", userDomain.RoleAdmin)
+	f.group(t, "Unrelated", userDomain.RoleAdmin)
+	f.nest(t, staff, support)
+	if _, err := f.usecase.AddMember(ctx, support.ID, domain.MemberTypeUser, user.ID.String()); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if _, err := f.usecase.AddMember(ctx, admins.ID, domain.MemberTypeUser, user.ID.String()); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+
+	groups, err := f.usecase.ListUserGroups(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListUserGroups: %v", err)
+	}
+	var got []string
+	for _, group := range groups {
+		got = append(got, group.Name)
+		if wantDirect := group.ID != staff.ID; group.Direct != wantDirect {
+			t.Errorf("%s direct = %v, want %v", group.Name, group.Direct, wantDirect)
+		}
+	}
+	if want := []string{"Admins", "Staff", "Support"}; !slices.Equal(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+
+	roles, err := f.usecase.GroupRoles(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GroupRoles: %v", err)
+	}
+	if want := []string{userDomain.RoleAdmin, userDomain.RoleSupport, userDomain.RoleUser}; !slices.Equal(roles, want) {
+		t.Errorf("roles = %v, want %v", roles, want)
+	}
+
+	// Deleting a group drops its memberships, so its roles go with it.
+	if err := f.usecase.DeleteGroup(ctx, admins.ID); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	roles, err = f.usecase.GroupRoles(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GroupRoles: %v", err)
+	}
+	if want := []string{userDomain.RoleSupport, userDomain.RoleUser}; !slices.Equal(roles, want) {
+		t.Errorf("roles after delete = %v, want %v", roles, want)
+	}
+}
+
+func TestGroupUsecase_MoveUserMemberships(t *testing.T) {
+	f := newGroupFixture(t)
+	ctx := context.Background()
+	source, target := f.user(t, "social@example.com"), f.user(t, "password@example.com")
+	shared, only := f.group(t, "shared"), f.group(t, "only-source")
+	for _, add := range []struct {
+		group *domain.Group
+		user  *userDomain.User
+	}{{shared, source}, {shared, target}, {only, source}} {
+		if _, err := f.usecase.AddMember(ctx, add.group.ID, domain.MemberTypeUser, add.user.ID.String()); err != nil {
+			t.Fatalf("AddMember: %v", err)
+		}
+	}
+
+	for range 2 { // a replayed merge event changes nothing
+		if _, err := f.usecase.MoveUserMemberships(ctx, source.ID, target.ID); err != nil {
+			t.Fatalf("MoveUserMemberships: %v", err)
+		}
+	}
+
+	sourceGroups, err := f.usecase.ListUserGroups(ctx, source.ID)
+	if err != nil || len(sourceGroups) != 0 {
+		t.Errorf("source groups = %+v, %v, want none", sourceGroups, err)
+	}
+	targetGroups, err := f.usecase.ListUserGroups(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("ListUserGroups: %v", err)
+	}
+	var names []string
+	for _, g := range targetGroups {
+		names = append(names, g.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"only-source", "shared"}) {
+		t.Errorf("target groups = %v, want [only-source shared]", names)
+	}
+}
@@ -0,0 +1,40 @@
+package modules
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/iots1/mingkwan-api/internal/audit/adapters"
+	"github.com/iots1/mingkwan-api/internal/audit/delivery"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
+	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// SetupAuditModule initializes the security audit log and registers its admin routes.
+func SetupAuditModule(
+	router fiber.Router,
+	deps infrastructure.AppDependencies,
+	authMiddleware *authDelivery.AuthMiddleware,
+) *auditUsecase.AuditUsecase {
+	utils.Logger.Info("========== Setup Audit Module ==========")
+
+	repo := adapters.NewMongoAuditRepository(deps.DB, auditLogsCollection)
+	auditUsecase := auditUsecase.NewAuditUsecase(repo)
+	auditHandler := delivery.NewAuditHandler(*auditUsecase)
+
+	setupAuditRoutes(router, auditHandler, authMiddleware)
+	utils.Logger.Info("========== Audit module setup complete. ==========")
+
+	return auditUsecase
+}
+
+func setupAuditRoutes(router fiber.Router, handler *delivery.AuditHandler, authMiddleware *authDelivery.AuthMiddleware) {
+	auditRoutes := router.Group("/admin/audit-logs",
+		authMiddleware.RequireAuth(),
+		authMiddleware.RequireRole(userDomain.RoleAdmin),
+	)
+	auditRoutes.Get("/", handler.ListAuditLogs)
+	auditRoutes.Get("/export", handler.ExportAuditLogs)
+}
@@ -3,31 +3,57 @@ package modules
 import (
 	"github.com/gofiber/fiber/v2"
 
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
 	authAdapter "github.com/iots1/mingkwan-api/internal/auth/adapters"
 	"github.com/iots1/mingkwan-api/internal/auth/delivery"
 	authHandler "github.com/iots1/mingkwan-api/internal/auth/delivery"
 	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 	"github.com/iots1/mingkwan-api/internal/user/usecase"
 )
 
+// NewAuthMiddleware builds the middleware other modules use to protect their routes.
+// It is created before the modules are set up because the auth module itself
+// depends on the user module.
+func NewAuthMiddleware(deps infrastructure.AppDependencies) *delivery.AuthMiddleware {
+	jwtGenerator := authAdapter.NewJWTTokenGenerator(deps.AppConfig.SecretKey)
+	revocationStore := authAdapter.NewRedisRevocationStore(deps.RedisClient)
+	return delivery.NewAuthMiddleware(jwtGenerator, revocationStore, deps.AuthCookieConfig.Enabled)
+}
+
 // SetupAuthModule initializes authentication dependencies and registers routes.
+// The returned use case is shared with modules that act on a user's sessions.
 func SetupAuthModule(
 	router fiber.Router,
 	deps infrastructure.AppDependencies,
 	userUsecase usecase.UserUsecase,
-) {
+	groupUsecase *groupUsecase.GroupUsecase,
+	auditUsecase *auditUsecase.AuditUsecase,
+	authMiddleware *delivery.AuthMiddleware,
+) *authUsecase.AuthUsecase {
 	// Initialize JWT Token Generator
 
 	jwtGenerator := authAdapter.NewJWTTokenGenerator(deps.AppConfig.SecretKey)
+	sessionRepo := authAdapter.NewMongoSessionRepository(deps.DB, sessionsCollection)
+	knownDeviceRepo := authAdapter.NewMongoKnownDeviceRepository(deps.DB, knownDevicesCollection)
+	revocationStore := authAdapter.NewRedisRevocationStore(deps.RedisClient)
 
 	authUsecase := authUsecase.NewAuthUsecase(
 		userUsecase,
+		groupUsecase,
 		jwtGenerator,
 		deps.PasswordHasher,
 		deps.LowPub,
 		deps.HighPub,
+		auditUsecase,
+		sessionRepo,
+		knownDeviceRepo,
+		revocationStore,
+		authAdapter.NewRedisReauthGuard(deps.RedisClient),
+		deps.AppConfig.PublicURL,
 	)
 
 	if authUsecase == nil {
@@ -35,13 +61,37 @@ func SetupAuthModule(
 		panic("AuthUsecase is nil, check your dependencies")
 	}
 
-	authHandler := authHandler.NewAuthHandler(*authUsecase, userUsecase, jwtGenerator, deps.PasswordHasher)
-	setupAuthRoutes(router, authHandler)
+	// Role checks confirm that token roles are still held.
+	authMiddleware.SetRoleResolver(authUsecase)
+
+	deps.TaskWorker.HandleFunc(event.NewDeviceLoginNotificationTask, event.SendNewDeviceLoginEmailHandler)
+
+	authSubscribers := authHandler.NewAuthInmemoryEventSubscribers(deps.InMemPubSub, *authUsecase)
+	authSubscribers.StartAllSubscribers(deps.AppCtx)
+
+	authHandler := authHandler.NewAuthHandler(*authUsecase, userUsecase, jwtGenerator, deps.PasswordHasher, deps.AuthCookieConfig)
+	setupAuthRoutes(router, authHandler, authMiddleware)
+
+	if len(deps.OAuthConfig.Clients) == 0 {
+		utils.Logger.Warn("AuthModule: OAUTH_CLIENTS is empty, token introspection and revocation will reject every client")
+	}
+	oauthHandler := delivery.NewOAuthHandler(*authUsecase, deps.OAuthConfig.Clients)
+	setupOAuthRoutes(router, oauthHandler)
+
+	return authUsecase
+}
+
+// setupOAuthRoutes registers the RFC 7662 and RFC 7009 endpoints used by the API
+// gateway. Both require client credentials.
+func setupOAuthRoutes(router fiber.Router, oauthHandler *delivery.OAuthHandler) {
+	oauth := router.Group("/oauth", oauthHandler.RequireClientCredentials())
+	oauth.Post("/introspect", oauthHandler.Introspect)
+	oauth.Post("/revoke", oauthHandler.Revoke)
 }
 
 // RegisterAuthRoutes registers authentication routes with a Fiber group.
 // This function assumes authHandler has its annotations in delivery layer.
-func setupAuthRoutes(router fiber.Router, authHandler *delivery.AuthHandler) {
+func setupAuthRoutes(router fiber.Router, authHandler *delivery.AuthHandler, authMiddleware *delivery.AuthMiddleware) {
 	auth := router.Group("/auth")
 	// @Summary Register a new user
 	// @Description Register a new user with name, email, and password
@@ -67,7 +117,7 @@ func setupAuthRoutes(router fiber.Router, authHandler *delivery.AuthHandler) {
 	// @Failure 401 {object} models.CommonErrorResponse "Invalid credentials"
 	// @Failure 500 {object} models.CommonErrorResponse "Internal server error"
 	// @Router /api/v1/auth/login [post]
-	// auth.Post("/login", authHandler.Login)
+	auth.Post("/login", authHandler.Login)
 
 	// @Summary Refresh access token
 	// @Description Use refresh token to get a new access token
@@ -80,7 +130,7 @@ func setupAuthRoutes(router fiber.Router, authHandler *delivery.AuthHandler) {
 	// @Failure 401 {object} models.CommonErrorResponse "Unauthorized or expired refresh token"
 	// @Failure 500 {object} models.CommonErrorResponse "Internal server error"
 	// @Router /api/v1/auth/refresh [post]
-	// auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/refresh", authHandler.Refresh)
 
 	// @Summary Get user profile
 	// @Description Get authenticated user's profile
@@ -92,5 +142,16 @@ func setupAuthRoutes(router fiber.Router, authHandler *delivery.AuthHandler) {
 	// @Failure 401 {object} models.CommonErrorResponse "Unauthorized"
 	// @Failure 500 {object} models.CommonErrorResponse "Internal server error"
 	// @Router /api/v1/auth/profile [get]
-	// auth.Get("/profile", authHandler.GetProfile) // Uncomment and add actual middleware/logic later
+	auth.Get("/profile", authMiddleware.RequireAuth(), authHandler.GetProfile)
+
+	// Step-up: exchange a password or TOTP code for a short-lived token with a fresh
+	// auth_time, required by routes guarded with RequireRecentAuth
+	auth.Post("/reauthenticate", authMiddleware.RequireAuth(), authHandler.Reauthenticate)
+
+	// Sessions and known devices of the authenticated user
+	auth.Get("/sessions", authMiddleware.RequireAuth(), authHandler.ListSessions)
+	auth.Get("/devices", authMiddleware.RequireAuth(), authHandler.ListKnownDevices)
+
+	// Target of the "this wasn't me" link in new-device login alerts
+	auth.Post("/login-alerts/not-me", authHandler.ReportNotMe)
 }
@@ -0,0 +1,57 @@
+package modules
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
+	"github.com/iots1/mingkwan-api/internal/group/adapters"
+	"github.com/iots1/mingkwan-api/internal/group/delivery"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// SetupGroupModule initializes user groups and registers their routes. The
+// returned use case resolves the roles users hold through their groups.
+func SetupGroupModule(
+	router fiber.Router,
+	deps infrastructure.AppDependencies,
+	userUsecase userUsecase.UserUsecase,
+	auditUsecase *auditUsecase.AuditUsecase,
+	authMiddleware *authDelivery.AuthMiddleware,
+) *groupUsecase.GroupUsecase {
+	utils.Logger.Info("========== Setup Group Module ==========")
+
+	repo := adapters.NewMongoGroupRepository(deps.DB, groupsCollection, groupMembershipsCollection)
+	groupUsecase := groupUsecase.NewGroupUsecase(repo, adapters.NewRedisNestingLock(deps.RedisClient), userUsecase, auditUsecase)
+	groupHandler := delivery.NewGroupHandler(*groupUsecase)
+
+	groupSubscribers := delivery.NewGroupInmemoryEventSubscribers(deps.InMemPubSub, *groupUsecase)
+	groupSubscribers.StartAllSubscribers(deps.AppCtx)
+
+	setupGroupRoutes(router, groupHandler, authMiddleware)
+	utils.Logger.Info("========== Group module setup complete. ==========")
+
+	return groupUsecase
+}
+
+func setupGroupRoutes(router fiber.Router, handler *delivery.GroupHandler, authMiddleware *authDelivery.AuthMiddleware) {
+	groupRoutes := router.Group("/groups",
+		authMiddleware.RequireAuth(),
+		authMiddleware.RequireRole(userDomain.RoleAdmin),
+	)
+	groupRoutes.Post("/", handler.CreateGroup)
+	groupRoutes.Get("/", handler.ListGroups)
+	groupRoutes.Get("/:id", handler.GetGroup)
+	groupRoutes.Patch("/:id", handler.UpdateGroup)
+	groupRoutes.Delete("/:id", handler.DeleteGroup)
+	groupRoutes.Get("/:id/members", handler.ListMembers)
+	groupRoutes.Post("/:id/members", handler.AddMember)
+	groupRoutes.Delete("/:id/members/:memberType/:memberId", handler.RemoveMember)
+
+	// Self or admin, checked in the handler
+	router.Get("/users/:id/groups", authMiddleware.RequireAuth(), handler.ListUserGroups)
+}
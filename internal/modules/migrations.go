@@ -0,0 +1,63 @@
+package modules
+
+import (
+	auditAdapters "github.com/iots1/mingkwan-api/internal/audit/adapters"
+	authAdapters "github.com/iots1/mingkwan-api/internal/auth/adapters"
+	groupAdapters "github.com/iots1/mingkwan-api/internal/group/adapters"
+	preferenceAdapters "github.com/iots1/mingkwan-api/internal/preference/adapters"
+	privacyAdapters "github.com/iots1/mingkwan-api/internal/privacy/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+	userAdapters "github.com/iots1/mingkwan-api/internal/user/adapters"
+)
+
+// Collections shared by the module setup and the schema declarations below.
+const (
+	usersCollection            = "users"
+	sessionsCollection         = "auth_sessions"
+	knownDevicesCollection     = "known_devices"
+	auditLogsCollection        = "audit_logs"
+	privacyRequestsCollection  = "privacy_requests"
+	erasureRecordsCollection   = "erasure_records"
+	erasureAnchorsCollection   = "erasure_anchors"
+	privacyExportsBucket       = "privacy_exports"
+	groupsCollection           = "groups"
+	groupMembershipsCollection = "group_memberships"
+	userPreferencesCollection  = "user_preferences"
+	userHistoryCollection      = "user_history"
+	userMergesCollection       = "user_merges"
+
+	// MigrationsCollection holds the applied migration versions and the migration lock.
+	MigrationsCollection = "migrations"
+	// SQLMigrationsTable holds the applied versions of the PostgreSQL migrations.
+	SQLMigrationsTable = "schema_migrations"
+)
+
+// Migrations returns the data migrations of all modules.
+func Migrations() *migration.Registry {
+	registry := migration.NewRegistry()
+	registry.Register(userAdapters.UserMigrations(usersCollection)...)
+	return registry
+}
+
+// SQLMigrations returns the PostgreSQL migrations of all modules. They apply only
+// when users are stored in PostgreSQL.
+func SQLMigrations() ([]migration.SQLMigration, error) {
+	return userAdapters.UserSQLMigrations()
+}
+
+// Indexes returns the indexes of all modules. They are ensured at every startup.
+func Indexes() []migration.IndexSpec {
+	return []migration.IndexSpec{
+		userAdapters.UserIndexes(usersCollection),
+		userAdapters.UserHistoryIndexes(userHistoryCollection),
+		userAdapters.UserMergeIndexes(userMergesCollection),
+		authAdapters.SessionIndexes(sessionsCollection),
+		authAdapters.KnownDeviceIndexes(knownDevicesCollection),
+		auditAdapters.AuditIndexes(auditLogsCollection),
+		privacyAdapters.PrivacyRequestIndexes(privacyRequestsCollection),
+		privacyAdapters.ErasureRecordIndexes(erasureRecordsCollection),
+		groupAdapters.GroupIndexes(groupsCollection),
+		groupAdapters.GroupMembershipIndexes(groupMembershipsCollection),
+		preferenceAdapters.PreferenceIndexes(userPreferencesCollection),
+	}
+}
@@ -0,0 +1,54 @@
+package modules
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
+	"github.com/iots1/mingkwan-api/internal/preference/adapters"
+	"github.com/iots1/mingkwan-api/internal/preference/delivery"
+	"github.com/iots1/mingkwan-api/internal/preference/domain"
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// Preferences returns the preference declarations of all modules. A module that
+// lets users configure it declares its keys here.
+func Preferences() []domain.Definition {
+	return userDomain.Preferences()
+}
+
+// SetupPreferenceModule initializes per-user preferences and registers their routes.
+func SetupPreferenceModule(
+	router fiber.Router,
+	deps infrastructure.AppDependencies,
+	authMiddleware *authDelivery.AuthMiddleware,
+) *preferenceUsecase.PreferenceUsecase {
+	utils.Logger.Info("========== Setup Preference Module ==========")
+
+	registry := domain.NewRegistry()
+	if err := registry.Register(Preferences()...); err != nil {
+		utils.Logger.Error("Preference module: Invalid preference declaration", zap.Error(err))
+		panic(err)
+	}
+
+	repo := adapters.NewMongoPreferenceRepository(deps.DB, userPreferencesCollection)
+	preferenceUsecase := preferenceUsecase.NewPreferenceUsecase(registry, repo, deps.LowPub)
+	preferenceHandler := delivery.NewPreferenceHandler(*preferenceUsecase)
+
+	preferenceSubscribers := delivery.NewPreferenceInmemoryEventSubscribers(deps.InMemPubSub, *preferenceUsecase)
+	preferenceSubscribers.StartAllSubscribers(deps.AppCtx)
+
+	setupPreferenceRoutes(router, preferenceHandler, authMiddleware)
+	utils.Logger.Info("========== Preference module setup complete. ==========", zap.Int("preferences", len(registry.Keys())))
+
+	return preferenceUsecase
+}
+
+func setupPreferenceRoutes(router fiber.Router, handler *delivery.PreferenceHandler, authMiddleware *authDelivery.AuthMiddleware) {
+	router.Get("/me/preferences", authMiddleware.RequireAuth(), handler.GetPreferences)
+	router.Put("/me/preferences", authMiddleware.RequireAuth(), handler.UpdatePreferences)
+	router.Get("/preferences/schema", authMiddleware.RequireAuth(), handler.GetSchema)
+}
@@ -0,0 +1,105 @@
+package modules
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
+	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/privacy/adapters"
+	"github.com/iots1/mingkwan-api/internal/privacy/delivery"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+	privacyUsecase "github.com/iots1/mingkwan-api/internal/privacy/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// SetupPrivacyModule initializes personal data exports and erasure (GDPR / PDPA)
+// and registers their routes.
+func SetupPrivacyModule(
+	router fiber.Router,
+	deps infrastructure.AppDependencies,
+	userUsecase userUsecase.UserUsecase,
+	userHistoryUsecase *userUsecase.UserHistoryUsecase,
+	authUsecase authUsecase.AuthUsecase,
+	groupUsecase *groupUsecase.GroupUsecase,
+	preferenceUsecase *preferenceUsecase.PreferenceUsecase,
+	auditUsecase *auditUsecase.AuditUsecase,
+	authMiddleware *authDelivery.AuthMiddleware,
+) *privacyUsecase.PrivacyUsecase {
+	utils.Logger.Info("========== Setup Privacy Module ==========")
+
+	requestRepo := adapters.NewMongoPrivacyRequestRepository(deps.DB, privacyRequestsCollection)
+	recordRepo := adapters.NewMongoErasureRecordRepository(deps.DB, erasureRecordsCollection)
+	anchorStore := adapters.NewMongoErasureChainAnchorStore(deps.DB, erasureAnchorsCollection)
+
+	archiveStore, err := adapters.NewGridFSExportArchiveStore(deps.DB, privacyExportsBucket)
+	if err != nil {
+		utils.Logger.Error("Privacy module: Failed to open export archive store", zap.Error(err))
+		panic(err)
+	}
+
+	// Erasure runs in this order. The user record goes first so that no new session
+	// can start, and user history follows it because its erasure records an entry.
+	// Audit comes last because erasing the other sources writes audit entries of its
+	// own. Modules also sweep their data again on UserErasedInMemoryEvent.
+	sources := []repository.PersonalDataSource{
+		adapters.NewUserDataSource(userUsecase),
+		adapters.NewUserHistoryDataSource(userHistoryUsecase),
+		adapters.NewAuthDataSource(authUsecase),
+		adapters.NewGroupDataSource(groupUsecase),
+		adapters.NewPreferenceDataSource(preferenceUsecase),
+		adapters.NewAuditDataSource(auditUsecase),
+	}
+
+	privacyUsecase := privacyUsecase.NewPrivacyUsecase(
+		requestRepo,
+		recordRepo,
+		anchorStore,
+		archiveStore,
+		sources,
+		userUsecase,
+		deps.LowPub,
+		deps.HighPub,
+		auditUsecase,
+	)
+
+	taskHandlers := delivery.NewPrivacyTaskHandlers(*privacyUsecase)
+	deps.TaskWorker.HandleFunc(event.ExportUserDataTask, taskHandlers.ExportUserData)
+	deps.TaskWorker.HandleFunc(event.EraseUserTask, taskHandlers.EraseUser)
+	deps.TaskWorker.HandleFunc(event.CleanupDataExportsTask, taskHandlers.CleanupDataExports)
+	if err := deps.TaskScheduler.Register("@hourly", event.CleanupDataExportsTask, 30*time.Minute); err != nil {
+		utils.Logger.Error("Privacy module: Failed to schedule cleanup of data exports", zap.Error(err))
+	}
+
+	privacyHandler := delivery.NewPrivacyHandler(*privacyUsecase)
+	setupPrivacyRoutes(router, privacyHandler, authMiddleware)
+	utils.Logger.Info("========== Privacy module setup complete. ==========")
+
+	return privacyUsecase
+}
+
+func setupPrivacyRoutes(router fiber.Router, handler *delivery.PrivacyHandler, authMiddleware *authDelivery.AuthMiddleware) {
+	me := router.Group("/me", authMiddleware.RequireAuth())
+	me.Post("/data-export", handler.RequestDataExport)
+	me.Get("/data-export/:id", handler.GetDataExport)
+	me.Get("/data-export/:id/download", handler.DownloadDataExport)
+	// Erasure cannot be undone, so it needs a recent login on top of the confirmation
+	me.Post("/erasure", authMiddleware.RequireRecentAuth(middleware.StepUpMaxAge), handler.RequestErasure)
+	me.Get("/erasure/:id", handler.GetErasure)
+
+	router.Get("/privacy/erasure-records/verify",
+		authMiddleware.RequireAuth(),
+		authMiddleware.RequireRole(userDomain.RoleAdmin),
+		handler.VerifyErasureRecords,
+	)
+}
@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	authDomain "github.com/iots1/mingkwan-api/internal/auth/domain"
+	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// SetupUserMergeRepointers registers what every module does when a user is merged
+// into another. The user module is set up before the modules that depend on it, so
+// this runs once they all are.
+func SetupUserMergeRepointers(
+	mergeUsecase *userUsecase.UserMergeUsecase,
+	authUsecase *authUsecase.AuthUsecase,
+	groupUsecase *groupUsecase.GroupUsecase,
+	preferenceUsecase *preferenceUsecase.PreferenceUsecase,
+) {
+	// Sessions of the merged user go first: its tokens would otherwise act as a
+	// deleted account until they expire.
+	mergeUsecase.AddRepointer("auth", func(ctx context.Context, sourceID, targetID userDomain.UserID) error {
+		revoked, err := authUsecase.RevokeAllSessions(ctx, sourceID, authDomain.RevokeReasonUserMerged)
+		if err == nil {
+			utils.Logger.Info("User merge: Revoked sessions of merged user", zap.String("source_id", sourceID.String()), zap.Int("sessions", revoked))
+		}
+		return err
+	})
+	mergeUsecase.AddRepointer("group", func(ctx context.Context, sourceID, targetID userDomain.UserID) error {
+		moved, err := groupUsecase.MoveUserMemberships(ctx, sourceID, targetID)
+		if err == nil {
+			utils.Logger.Info("User merge: Moved memberships of merged user",
+				zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()), zap.Int("memberships", moved))
+		}
+		return err
+	})
+	mergeUsecase.AddRepointer("preference", func(ctx context.Context, sourceID, targetID userDomain.UserID) error {
+		taken, err := preferenceUsecase.MergePreferences(ctx, sourceID, targetID)
+		if err == nil {
+			utils.Logger.Info("User merge: Merged preferences of merged user",
+				zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()), zap.Int("preferences", taken))
+		}
+		return err
+	})
+}
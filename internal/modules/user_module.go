@@ -1,52 +1,133 @@
 package modules
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/config"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	authDelivery "github.com/iots1/mingkwan-api/internal/auth/delivery"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/infrastructure"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 	"github.com/iots1/mingkwan-api/internal/user/adapters"
 	"github.com/iots1/mingkwan-api/internal/user/delivery"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
 	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
 )
 
 func SetupUserModule(
 	router fiber.Router,
 	deps infrastructure.AppDependencies,
-) *userUsecase.UserUsecase {
+	auditUsecase *auditUsecase.AuditUsecase,
+	authMiddleware *authDelivery.AuthMiddleware,
+) (*userUsecase.UserUsecase, *userUsecase.UserHistoryUsecase, *userUsecase.UserMergeUsecase) {
 	utils.Logger.Info("========== Setup User Module ==========")
 
-	repo := adapters.NewMongoUserRepository(deps.DB, "users")
-	utils.Logger.Debug("User module: User repository initialized.")
+	var (
+		repo        repository.UserRepository
+		searcher    repository.UserSearcher
+		historyRepo repository.UserHistoryRepository
+	)
+	if deps.UserStorage.Backend == config.UserStoragePostgres {
+		repo = adapters.NewPostgresUserRepository(deps.Postgres)
+		searcher = adapters.NewPostgresUserSearcher(deps.Postgres)
+		historyRepo = adapters.NewPostgresUserHistoryRepository(deps.Postgres)
+	} else {
+		repo = adapters.NewMongoUserRepository(deps.DB, usersCollection)
+		searcher = adapters.NewMongoUserSearcher(deps.DB, usersCollection)
+		historyRepo = adapters.NewMongoUserHistoryRepository(deps.DB, userHistoryCollection)
+	}
+	// History records writes below the cache, so that it reads the stored state.
+	repo = adapters.NewHistoryUserRepository(repo, historyRepo, deps.HighPub)
+	if deps.UserCache.TTL > 0 {
+		cached := adapters.NewCachedUserRepository(repo, deps.CacheManager, deps.UserCache.TTL, deps.UserCache.LocalTTL,
+			[]byte(deps.AppConfig.SecretKey))
+		if err := cached.ListenForInvalidations(deps.AppCtx); err != nil {
+			// Without invalidations, other instances serve stale users for up to the local TTL.
+			utils.Logger.Error("User module: Failed to subscribe to user cache invalidations", zap.Error(err))
+		}
+		repo = cached
+	}
+	utils.Logger.Debug("User module: User repository initialized.", zap.String("backend", deps.UserStorage.Backend),
+		zap.Duration("cache_ttl", deps.UserCache.TTL))
+
+	importStore := adapters.NewRedisUserImportStore(deps.RedisClient)
+	importUsecase := userUsecase.NewUserImportUsecase(repo, importStore, deps.PasswordHasher, deps.HighPub, auditUsecase)
+	historyUsecase := userUsecase.NewUserHistoryUsecase(repo, historyRepo)
+	mergeRepo := adapters.NewMongoUserMergeRepository(deps.DB, userMergesCollection)
+	mergeUsecase := userUsecase.NewUserMergeUsecase(repo, mergeRepo, auditUsecase)
 
 	userUsecase := userUsecase.NewUserUsecase(
 		repo,
+		searcher,
 		deps.LowPub,
 		deps.HighPub,
+		auditUsecase,
+		deps.AppConfig.PublicURL,
 	)
 	utils.Logger.Debug("User module: User use case initialized.")
 
-	userInMemorySubscribers := delivery.NewUserInmemoryEventSubscribers(deps.InMemPubSub)
+	userInMemorySubscribers := delivery.NewUserInmemoryEventSubscribers(deps.InMemPubSub, historyUsecase)
 	userInMemorySubscribers.StartAllSubscribers(deps.AppCtx)
 	utils.Logger.Debug("User module: User in-memory event subscribers started.")
 
 	if userUsecase == nil {
-		utils.Logger.Error("AuthModule: authUsecase is nil, check your dependencies")
-		panic("AuthUsecase is nil, check your dependencies")
+		utils.Logger.Error("UserModule: userUsecase is nil, check your dependencies")
+		panic("UserUsecase is nil, check your dependencies")
 	}
 
-	userHandler := delivery.NewUserHandler(*userUsecase, deps.PasswordHasher)
+	userHandler := delivery.NewUserHandler(*userUsecase, *historyUsecase, mergeUsecase, deps.PasswordHasher)
+
+	importHandler := delivery.NewUserImportHandler(*importUsecase)
+
+	taskHandlers := delivery.NewUserTaskHandlers(*userUsecase, *importUsecase, *historyUsecase, mergeUsecase, deps.UserRetention.PurgeAfterDays)
+	deps.TaskWorker.HandleFunc(event.ImportUsersTask, taskHandlers.ImportUsers)
+	deps.TaskWorker.HandleFunc(event.RecordUserHistoryTask, taskHandlers.RecordHistory)
+	deps.TaskWorker.HandleFunc(event.RepointUserMergesTask, taskHandlers.RepointMerges)
+	deps.TaskWorker.HandleFunc(event.PurgeDeletedUsersTask, taskHandlers.PurgeDeletedUsers)
+	deps.TaskWorker.HandleFunc(event.UserDeletedHighImportance, event.UserDeletedHandler)
+	deps.TaskWorker.HandleFunc(event.EmailChangeConfirmationTask, event.SendEmailChangeConfirmationHandler)
+	deps.TaskWorker.HandleFunc(event.EmailChangeNoticeTask, event.SendEmailChangeNoticeHandler)
+	if err := deps.TaskScheduler.Register(deps.UserRetention.PurgeSchedule, event.PurgeDeletedUsersTask, time.Hour); err != nil {
+		utils.Logger.Error("User module: Failed to schedule purge of deleted users", zap.Error(err))
+	}
+	if err := deps.TaskScheduler.Register("@every 5m", event.RepointUserMergesTask, 5*time.Minute); err != nil {
+		utils.Logger.Error("User module: Failed to schedule retries of user merges", zap.Error(err))
+	}
 
-	setupRouters(router, userHandler)
+	setupRouters(router, userHandler, importHandler, authMiddleware)
 	utils.Logger.Info("========== User module setup complete. ==========")
 
-	return userUsecase
+	return userUsecase, historyUsecase, mergeUsecase
 }
 
-func setupRouters(router fiber.Router, handler *delivery.UserHandler) {
+func setupRouters(router fiber.Router, handler *delivery.UserHandler, importHandler *delivery.UserImportHandler, authMiddleware *authDelivery.AuthMiddleware) {
 	userRoutes := router.Group("/users")
 	userRoutes.Post("/", handler.CreateUser)
-	userRoutes.Get("/:id", handler.GetUserByID)
-	userRoutes.Get("/", handler.GetAllUsers)
-	userRoutes.Put("/:id", handler.UpdateUser)
-	userRoutes.Delete("/:id", handler.DeleteUser)
+	userRoutes.Post("/import", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), importHandler.ImportUsers)
+	userRoutes.Get("/import/:jobId", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), importHandler.GetImportJob)
+	// Targets of the links in email change messages; the token authenticates the call
+	userRoutes.Post("/email-change/confirm", handler.ConfirmEmailChange)
+	userRoutes.Post("/email-change/cancel", handler.CancelEmailChange)
+	userRoutes.Get("/search", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin, userDomain.RoleSupport), handler.SearchUsers)
+	userRoutes.Get("/export", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.ExportUsers)
+	userRoutes.Get("/duplicates", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.FindDuplicateUsers)
+	userRoutes.Post("/merges/preview", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.PreviewMerge)
+	userRoutes.Post("/merges", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), authMiddleware.RequireRecentAuth(middleware.StepUpMaxAge), handler.MergeUsers)
+	userRoutes.Get("/:id/history", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin, userDomain.RoleSupport), handler.GetUserHistory)
+	// Public profile for anonymous callers; the handler shows more to the user, admins and support
+	userRoutes.Get("/:id", authMiddleware.OptionalAuth(), handler.GetUserByID)
+	userRoutes.Get("/", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin, userDomain.RoleSupport), handler.ListUsers)
+	// Email changes additionally require a recent authentication, checked in the handler
+	userRoutes.Put("/:id", authMiddleware.RequireAuth(), handler.UpdateUser)
+	userRoutes.Patch("/:id", authMiddleware.RequireAuth(), handler.PatchUser)
+	userRoutes.Delete("/:id", authMiddleware.RequireAuth(), authMiddleware.RequireRecentAuth(middleware.StepUpMaxAge), handler.DeleteUser)
+	userRoutes.Post("/:id/restore", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.RestoreUser)
+	userRoutes.Post("/:id/deactivate", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.DeactivateUser)
+	userRoutes.Post("/:id/reactivate", authMiddleware.RequireAuth(), authMiddleware.RequireRole(userDomain.RoleAdmin), handler.ReactivateUser)
 }
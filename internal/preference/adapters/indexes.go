@@ -0,0 +1,12 @@
+package adapters
+
+import (
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+// PreferenceIndexes declares the indexes of the preferences collection. Documents
+// are keyed by user ID and only ever read by it, so the _id index covers every
+// query; the collection is declared so that indexes added later are ensured too.
+func PreferenceIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{Collection: collectionName}
+}
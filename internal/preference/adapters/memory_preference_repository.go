@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iots1/mingkwan-api/internal/preference/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// MemoryPreferenceRepository is an in-memory PreferenceRepository, for tests and
+// local tools.
+type MemoryPreferenceRepository struct {
+	mu     sync.Mutex
+	values map[userDomain.UserID]map[string]interface{}
+}
+
+func NewMemoryPreferenceRepository() *MemoryPreferenceRepository {
+	return &MemoryPreferenceRepository{values: make(map[userDomain.UserID]map[string]interface{})}
+}
+
+func (r *MemoryPreferenceRepository) GetPreferences(ctx context.Context, userID userDomain.UserID, keys []string) (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pick(userID, keys), nil
+}
+
+func (r *MemoryPreferenceRepository) UpdatePreferences(ctx context.Context, userID userDomain.UserID, set map[string]interface{}, unset []string) (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := append([]string{}, unset...)
+	for key := range set {
+		keys = append(keys, key)
+	}
+	before := r.pick(userID, keys)
+
+	stored, ok := r.values[userID]
+	if !ok {
+		stored = make(map[string]interface{})
+		r.values[userID] = stored
+	}
+	for key, value := range set {
+		stored[key] = value
+	}
+	for _, key := range unset {
+		delete(stored, key)
+	}
+	return before, nil
+}
+
+func (r *MemoryPreferenceRepository) DeletePreferences(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.values[userID]; !ok {
+		return 0, nil
+	}
+	delete(r.values, userID)
+	return 1, nil
+}
+
+// pick copies the stored values among keys. The caller holds the lock.
+func (r *MemoryPreferenceRepository) pick(userID userDomain.UserID, keys []string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, key := range keys {
+		if value, ok := r.values[userID][key]; ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+var _ repository.PreferenceRepository = (*MemoryPreferenceRepository)(nil)
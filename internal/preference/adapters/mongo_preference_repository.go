@@ -0,0 +1,119 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/preference/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// MongoPreferenceRepository keeps one document per user, keyed by the user ID.
+// Values are nested by key segment ("ui.theme" is stored at values.ui.theme), so
+// each key is set or removed on its own and concurrent updates of different keys
+// do not overwrite each other.
+type MongoPreferenceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPreferenceRepository(db *mongo.Database, collectionName string) *MongoPreferenceRepository {
+	return &MongoPreferenceRepository{collection: db.Collection(collectionName)}
+}
+
+func (r *MongoPreferenceRepository) GetPreferences(ctx context.Context, userID userDomain.UserID, keys []string) (map[string]interface{}, error) {
+	var doc bson.M
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID}, options.FindOne().SetProjection(valueProjection(keys))).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("failed to find preferences: %w", err)
+	}
+	return extractValues(doc, keys), nil
+}
+
+func (r *MongoPreferenceRepository) UpdatePreferences(ctx context.Context, userID userDomain.UserID, set map[string]interface{}, unset []string) (map[string]interface{}, error) {
+	setDoc := bson.M{"updated_at": time.Now()}
+	keys := make([]string, 0, len(set)+len(unset))
+	for key, value := range set {
+		setDoc["values."+key] = value
+		keys = append(keys, key)
+	}
+	update := bson.M{"$set": setDoc}
+	if len(unset) > 0 {
+		unsetDoc := bson.M{}
+		for _, key := range unset {
+			unsetDoc["values."+key] = ""
+			keys = append(keys, key)
+		}
+		update["$unset"] = unsetDoc
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.Before).
+		SetProjection(valueProjection(keys))
+	var before bson.M
+	if err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update, opts).Decode(&before); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// The document was just created.
+			return map[string]interface{}{}, nil
+		}
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+	return extractValues(before, keys), nil
+}
+
+func (r *MongoPreferenceRepository) DeletePreferences(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+func valueProjection(keys []string) bson.M {
+	projection := bson.M{"_id": 0}
+	for _, key := range keys {
+		projection["values."+key] = 1
+	}
+	return projection
+}
+
+// extractValues reads keys from the nested values of a preferences document.
+func extractValues(doc bson.M, keys []string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, key := range keys {
+		var current interface{} = doc["values"]
+		for _, segment := range strings.Split(key, ".") {
+			current = lookup(current, segment)
+		}
+		if current != nil {
+			values[key] = current
+		}
+	}
+	return values
+}
+
+func lookup(doc interface{}, field string) interface{} {
+	switch d := doc.(type) {
+	case bson.M:
+		return d[field]
+	case bson.D:
+		for _, e := range d {
+			if e.Key == field {
+				return e.Value
+			}
+		}
+	}
+	return nil
+}
+
+var _ repository.PreferenceRepository = (*MongoPreferenceRepository)(nil)
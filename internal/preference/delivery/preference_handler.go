@@ -0,0 +1,111 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/preference/domain"
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type PreferenceHandler struct {
+	preferenceUsecase preferenceUsecase.PreferenceUsecase
+}
+
+func NewPreferenceHandler(preferenceUsecase preferenceUsecase.PreferenceUsecase) *PreferenceHandler {
+	return &PreferenceHandler{preferenceUsecase: preferenceUsecase}
+}
+
+func (h *PreferenceHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
+	logFields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status_code", statusCode),
+		zap.String("message", message),
+	}
+	if err != nil {
+		logFields = append(logFields, zap.Error(err))
+	}
+	if validationErrors != nil {
+		logFields = append(logFields, zap.Any("validation_errors", validationErrors))
+	}
+	utils.Logger.Error("API Error", logFields...)
+
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Errors:    validationErrors,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
+
+func (h *PreferenceHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+	})
+}
+
+// GetPreferences returns every declared preference of the caller, defaults
+// included.
+func (h *PreferenceHandler) GetPreferences(c *fiber.Ctx) error {
+	userID, err := userDomain.ParseUserID(middleware.GetRequestMeta(c).ActorID)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Invalid token subject", err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	values, err := h.preferenceUsecase.GetPreferences(ctx, userID)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve preferences", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, values, len(values))
+}
+
+// UpdatePreferences sets the preferences in the body, an object of key to value.
+// null restores a preference's default; preferences left out are unchanged.
+func (h *PreferenceHandler) UpdatePreferences(c *fiber.Ctx) error {
+	userID, err := userDomain.ParseUserID(middleware.GetRequestMeta(c).ActorID)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Invalid token subject", err, nil)
+	}
+	var changes map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &changes); err != nil || changes == nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Request body must be a JSON object", err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	values, err := h.preferenceUsecase.UpdatePreferences(ctx, userID, changes)
+	if err != nil {
+		var invalid *domain.InvalidPreferencesError
+		if errors.As(err, &invalid) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, invalid.Fields)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to update preferences", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, values, len(values))
+}
+
+// GetSchema describes the declared preferences: type, default and constraints.
+func (h *PreferenceHandler) GetSchema(c *fiber.Ctx) error {
+	definitions := h.preferenceUsecase.Definitions()
+	return h.sendSuccessResponse(c, fiber.StatusOK, definitions, len(definitions))
+}
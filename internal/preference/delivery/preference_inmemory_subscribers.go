@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// PreferenceInmemoryEventSubscribers keeps stored preferences in line with user
+// lifecycle events.
+type PreferenceInmemoryEventSubscribers struct {
+	inMemoryBus       *event.InMemPubSub
+	preferenceUsecase preferenceUsecase.PreferenceUsecase
+}
+
+func NewPreferenceInmemoryEventSubscribers(bus *event.InMemPubSub, preferenceUsecase preferenceUsecase.PreferenceUsecase) *PreferenceInmemoryEventSubscribers {
+	return &PreferenceInmemoryEventSubscribers{inMemoryBus: bus, preferenceUsecase: preferenceUsecase}
+}
+
+func (s *PreferenceInmemoryEventSubscribers) StartAllSubscribers(ctx context.Context) {
+	go s.listenToUserErasedEvents(ctx)
+	utils.Logger.Info("PreferenceFeature/In-Memory Subscribers: All listeners started.")
+}
+
+// listenToUserErasedEvents deletes preferences stored for an erased user while the
+// erasure job ran.
+func (s *PreferenceInmemoryEventSubscribers) listenToUserErasedEvents(ctx context.Context) {
+	ch := s.inMemoryBus.SubscribeEvent(event.UserErasedInMemoryEvent)
+	utils.Logger.Info("PreferenceFeature/In-Memory Subscriber: Listening for 'user.erased.inmemory' events.")
+
+	for {
+		select {
+		case eventData := <-ch:
+			payload, ok := eventData.(event.UserErasedPayload)
+			if !ok {
+				utils.Logger.Warn("PreferenceFeature/In-Memory Subscriber: Received unexpected payload type for 'user.erased.inmemory' event.",
+					zap.Any("event_data", eventData))
+				continue
+			}
+
+			eraseCtx, cancel := context.WithTimeout(utils.WithRequestMeta(ctx, &utils.RequestMeta{CorrelationID: payload.RequestID}), 10*time.Second)
+			deleted, err := s.preferenceUsecase.DeletePreferences(eraseCtx, userDomain.UserID(payload.UserID))
+			cancel()
+			if err != nil {
+				utils.Logger.Error("PreferenceFeature/In-Memory Subscriber: Failed to erase preferences of erased user",
+					zap.String("user_id", payload.UserID), zap.Error(err))
+				continue
+			}
+			utils.Logger.Info("PreferenceFeature/In-Memory Subscriber: Erased remaining preferences of erased user",
+				zap.String("user_id", payload.UserID), zap.Int64("preferences", deleted))
+		case <-ctx.Done():
+			utils.Logger.Info("PreferenceFeature/In-Memory Subscriber: 'user.erased.inmemory' event listener stopped.", zap.Error(ctx.Err()))
+			return
+		}
+	}
+}
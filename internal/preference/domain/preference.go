@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Type is the type of a preference value. Values are stored and returned as bool,
+// int64 or string.
+type Type string
+
+const (
+	TypeBool   Type = "bool"
+	TypeInt    Type = "int"
+	TypeString Type = "string"
+)
+
+// DefaultMaxLength bounds string values of definitions without a MaxLength.
+const DefaultMaxLength = 256
+
+// Definition declares a preference key. Modules declare the keys they own; clients
+// may only store declared keys.
+type Definition struct {
+	// Key is dot-separated lowercase words, e.g. "ui.theme". The first word names
+	// the owning area.
+	Key         string      `json:"key"`
+	Type        Type        `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description,omitempty"`
+	// Allowed restricts string values to a fixed set.
+	Allowed []string `json:"allowed,omitempty"`
+	// Min and Max bound int values when set.
+	Min *int64 `json:"min,omitempty"`
+	Max *int64 `json:"max,omitempty"`
+	// MaxLength bounds string values; 0 means DefaultMaxLength.
+	MaxLength int `json:"max_length,omitempty"`
+	// Validate checks a value of the right type further, if set.
+	Validate func(value interface{}) error `json:"-"`
+}
+
+var (
+	// ErrUnknownPreference is returned for keys no module declared.
+	ErrUnknownPreference = errors.New("unknown preference")
+	ErrInvalidPreference = errors.New("invalid preference value")
+)
+
+// InvalidPreferencesError lists the rejected keys of an update with the reasons.
+type InvalidPreferencesError struct {
+	Fields map[string][]string
+}
+
+func (e *InvalidPreferencesError) Error() string {
+	return fmt.Sprintf("%d invalid preferences", len(e.Fields))
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)+$`)
+
+// Registry holds the declared preferences. It is filled at startup and read-only
+// afterwards.
+type Registry struct {
+	definitions map[string]Definition
+}
+
+func NewRegistry() *Registry {
+	return &Registry{definitions: make(map[string]Definition)}
+}
+
+// Register declares preferences. It rejects malformed keys, duplicates, keys
+// nested under another key (stored values are nested by key segment) and
+// defaults that do not pass their own validation.
+func (r *Registry) Register(definitions ...Definition) error {
+	for _, def := range definitions {
+		if !keyPattern.MatchString(def.Key) {
+			return fmt.Errorf("preference %q: key must be dot-separated lowercase words", def.Key)
+		}
+		if def.Type != TypeBool && def.Type != TypeInt && def.Type != TypeString {
+			return fmt.Errorf("preference %q: unsupported type %q", def.Key, def.Type)
+		}
+		for key := range r.definitions {
+			if key == def.Key {
+				return fmt.Errorf("preference %q: already registered", def.Key)
+			}
+			if strings.HasPrefix(key, def.Key+".") || strings.HasPrefix(def.Key, key+".") {
+				return fmt.Errorf("preference %q: conflicts with %q", def.Key, key)
+			}
+		}
+		value, err := def.normalize(def.Default)
+		if err != nil {
+			return fmt.Errorf("preference %q: invalid default: %w", def.Key, err)
+		}
+		def.Default = value
+		def.Allowed = slices.Clone(def.Allowed)
+		r.definitions[def.Key] = def
+	}
+	return nil
+}
+
+// Lookup returns the definition of key.
+func (r *Registry) Lookup(key string) (Definition, bool) {
+	def, ok := r.definitions[key]
+	return def, ok
+}
+
+// Definitions returns the declared preferences sorted by key.
+func (r *Registry) Definitions() []Definition {
+	definitions := make([]Definition, 0, len(r.definitions))
+	for _, def := range r.definitions {
+		definitions = append(definitions, def)
+	}
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Key < definitions[j].Key })
+	return definitions
+}
+
+// Keys returns the declared keys sorted.
+func (r *Registry) Keys() []string {
+	keys := make([]string, 0, len(r.definitions))
+	for key := range r.definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Defaults returns the default value of every declared preference.
+func (r *Registry) Defaults() map[string]interface{} {
+	values := make(map[string]interface{}, len(r.definitions))
+	for key, def := range r.definitions {
+		values[key] = def.Default
+	}
+	return values
+}
+
+// Normalize checks value against the definition of key and converts it to the
+// stored representation: JSON numbers and BSON integers become int64.
+func (r *Registry) Normalize(key string, value interface{}) (interface{}, error) {
+	def, ok := r.definitions[key]
+	if !ok {
+		return nil, ErrUnknownPreference
+	}
+	return def.normalize(value)
+}
+
+func (d Definition) normalize(value interface{}) (interface{}, error) {
+	var normalized interface{}
+	switch d.Type {
+	case TypeBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w: must be a boolean", ErrInvalidPreference)
+		}
+		normalized = b
+	case TypeInt:
+		n, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Errorf("%w: must be an integer", ErrInvalidPreference)
+		}
+		if d.Min != nil && n < *d.Min {
+			return nil, fmt.Errorf("%w: must be at least %d", ErrInvalidPreference, *d.Min)
+		}
+		if d.Max != nil && n > *d.Max {
+			return nil, fmt.Errorf("%w: must be at most %d", ErrInvalidPreference, *d.Max)
+		}
+		normalized = n
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: must be a string", ErrInvalidPreference)
+		}
+		maxLength := d.MaxLength
+		if maxLength == 0 {
+			maxLength = DefaultMaxLength
+		}
+		if utf8.RuneCountInString(s) > maxLength {
+			return nil, fmt.Errorf("%w: must be at most %d characters", ErrInvalidPreference, maxLength)
+		}
+		if len(d.Allowed) > 0 && !slices.Contains(d.Allowed, s) {
+			return nil, fmt.Errorf("%w: must be one of %s", ErrInvalidPreference, strings.Join(d.Allowed, ", "))
+		}
+		normalized = s
+	}
+	if d.Validate != nil {
+		if err := d.Validate(normalized); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPreference, err)
+		}
+	}
+	return normalized, nil
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// PreferenceRepository stores the preferences users changed from their defaults,
+// as values already normalized by the registry. Keys are dot-separated, see
+// domain.Definition.
+type PreferenceRepository interface {
+	// GetPreferences returns the stored values among keys. Keys without a stored
+	// value are absent from the result.
+	GetPreferences(ctx context.Context, userID userDomain.UserID, keys []string) (map[string]interface{}, error)
+	// UpdatePreferences stores set and removes unset in one write, and returns what
+	// was stored before for those keys. set and unset must not share keys.
+	UpdatePreferences(ctx context.Context, userID userDomain.UserID, set map[string]interface{}, unset []string) (map[string]interface{}, error)
+	// DeletePreferences removes every stored preference of the user and returns
+	// how many documents were removed.
+	DeletePreferences(ctx context.Context, userID userDomain.UserID) (int64, error)
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/preference/domain"
+	"github.com/iots1/mingkwan-api/internal/preference/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type PreferenceUsecase struct {
+	registry *domain.Registry
+	repo     repository.PreferenceRepository
+	lowPub   event.Publisher
+}
+
+func NewPreferenceUsecase(registry *domain.Registry, repo repository.PreferenceRepository, lowPub event.Publisher) *PreferenceUsecase {
+	return &PreferenceUsecase{registry: registry, repo: repo, lowPub: lowPub}
+}
+
+// Definitions returns the declared preferences, for clients to build forms from.
+func (s *PreferenceUsecase) Definitions() []domain.Definition {
+	return s.registry.Definitions()
+}
+
+// GetPreferences returns the value of every declared preference for the user: the
+// stored value, or the default. A stored value that no longer passes validation,
+// e.g. after a module narrowed the allowed values, reads as the default.
+func (s *PreferenceUsecase) GetPreferences(ctx context.Context, userID userDomain.UserID) (map[string]interface{}, error) {
+	stored, err := s.repo.GetPreferences(ctx, userID, s.registry.Keys())
+	if err != nil {
+		utils.Logger.Error("PreferenceUsecase: Failed to get preferences", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	values := s.registry.Defaults()
+	for key, value := range stored {
+		if normalized, err := s.registry.Normalize(key, value); err == nil {
+			values[key] = normalized
+		}
+	}
+	return values, nil
+}
+
+// UpdatePreferences sets the given preferences; a nil value restores the default.
+// Other preferences are left unchanged. The update is rejected as a whole with an
+// *InvalidPreferencesError if any key is unknown or any value invalid. Keys whose
+// effective value changed are published as a PreferencesChangedInMemoryEvent.
+func (s *PreferenceUsecase) UpdatePreferences(ctx context.Context, userID userDomain.UserID, changes map[string]interface{}) (map[string]interface{}, error) {
+	set := map[string]interface{}{}
+	var unset []string
+	invalid := map[string][]string{}
+	for key, value := range changes {
+		if _, ok := s.registry.Lookup(key); !ok {
+			invalid[key] = []string{domain.ErrUnknownPreference.Error()}
+			continue
+		}
+		if value == nil {
+			unset = append(unset, key)
+			continue
+		}
+		normalized, err := s.registry.Normalize(key, value)
+		if err != nil {
+			invalid[key] = []string{err.Error()}
+			continue
+		}
+		set[key] = normalized
+	}
+	if len(invalid) > 0 {
+		return nil, &domain.InvalidPreferencesError{Fields: invalid}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return s.GetPreferences(ctx, userID)
+	}
+
+	previous, err := s.repo.UpdatePreferences(ctx, userID, set, unset)
+	if err != nil {
+		utils.Logger.Error("PreferenceUsecase: Failed to update preferences", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+
+	values, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishChanges(ctx, userID, previous, set, changes)
+	return values, nil
+}
+
+// publishChanges announces the keys of an update whose effective value differs
+// from before. Keys of changes missing from set were reset to their default.
+func (s *PreferenceUsecase) publishChanges(ctx context.Context, userID userDomain.UserID, previous, set, changes map[string]interface{}) {
+	defaults := s.registry.Defaults()
+	changed := map[string]interface{}{}
+	for key := range changes {
+		before := defaults[key]
+		if value, ok := previous[key]; ok {
+			if normalized, err := s.registry.Normalize(key, value); err == nil {
+				before = normalized
+			}
+		}
+		after, ok := set[key]
+		if !ok {
+			after = defaults[key]
+		}
+		if after != before {
+			changed[key] = after
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	payload := event.PreferencesChangedPayload{UserID: userID.String(), Changes: changed, ChangedAt: time.Now().UTC()}
+	if err := s.lowPub.Publish(ctx, string(event.PreferencesChangedInMemoryEvent), payload); err != nil {
+		utils.Logger.Error("PreferenceUsecase: Failed to publish preferences changed event", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+}
+
+// MergePreferences moves the stored preferences of a user merged into another to
+// the target. Values the target set itself win; values no longer valid are dropped.
+// It returns how many values the target took, and is a no-op when replayed.
+func (s *PreferenceUsecase) MergePreferences(ctx context.Context, sourceID, targetID userDomain.UserID) (int, error) {
+	keys := s.registry.Keys()
+	source, err := s.repo.GetPreferences(ctx, sourceID, keys)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	target, err := s.repo.GetPreferences(ctx, targetID, keys)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	set := map[string]interface{}{}
+	for key, value := range source {
+		if _, ok := target[key]; ok {
+			continue
+		}
+		if normalized, err := s.registry.Normalize(key, value); err == nil {
+			set[key] = normalized
+		}
+	}
+	if len(set) > 0 {
+		previous, err := s.repo.UpdatePreferences(ctx, targetID, set, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update preferences: %w", err)
+		}
+		s.publishChanges(ctx, targetID, previous, set, set)
+	}
+	if _, err := s.repo.DeletePreferences(ctx, sourceID); err != nil {
+		return 0, fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return len(set), nil
+}
+
+// DeletePreferences removes the stored preferences of a user, for erasure requests.
+func (s *PreferenceUsecase) DeletePreferences(ctx context.Context, userID userDomain.UserID) (int64, error) {
+	n, err := s.repo.DeletePreferences(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return n, nil
+}
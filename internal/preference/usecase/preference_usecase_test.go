@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/iots1/mingkwan-api/internal/preference/adapters"
+	"github.com/iots1/mingkwan-api/internal/preference/domain"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+const testUserID = userDomain.UserID("64b000000000000000000001")
+
+func newTestUsecase(t *testing.T) (*PreferenceUsecase, *eventtest.RecordingPublisher) {
+	t.Helper()
+	registry := domain.NewRegistry()
+	if err := registry.Register(userDomain.Preferences()...); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	lowPub := eventtest.NewRecordingPublisher()
+	return NewPreferenceUsecase(registry, adapters.NewMemoryPreferenceRepository(), lowPub), lowPub
+}
+
+func TestRegistry_Register(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     domain.Definition
+		wantErr bool
+	}{
+		{name: "valid", def: domain.Definition{Key: "ui.font", Type: domain.TypeString, Default: "sans"}},
+		{name: "no area", def: domain.Definition{Key: "font", Type: domain.TypeString, Default: "sans"}, wantErr: true},
+		{name: "upper case", def: domain.Definition{Key: "ui.Font", Type: domain.TypeString, Default: "sans"}, wantErr: true},
+		{name: "duplicate", def: domain.Definition{Key: "ui.theme", Type: domain.TypeString, Default: "dark"}, wantErr: true},
+		{name: "nested under a key", def: domain.Definition{Key: "ui.theme.accent", Type: domain.TypeString, Default: "blue"}, wantErr: true},
+		{name: "default of wrong type", def: domain.Definition{Key: "ui.font", Type: domain.TypeBool, Default: "sans"}, wantErr: true},
+		{name: "default not allowed", def: domain.Definition{Key: "ui.font", Type: domain.TypeString, Default: "serif", Allowed: []string{"sans"}}, wantErr: true},
+		{name: "unsupported type", def: domain.Definition{Key: "ui.font", Type: "float", Default: 1.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := domain.NewRegistry()
+			if err := registry.Register(userDomain.Preferences()...); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if err := registry.Register(tt.def); (err != nil) != tt.wantErr {
+				t.Errorf("Register error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreferenceUsecase_UpdatePreferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		first       map[string]interface{}
+		update      map[string]interface{}
+		wantErr     []string // keys reported invalid
+		wantValues  map[string]interface{}
+		wantChanges map[string]interface{} // nil if no event is expected
+	}{
+		{
+			name:        "set values",
+			update:      map[string]interface{}{"ui.theme": "dark", "ui.page_size": float64(50)},
+			wantValues:  map[string]interface{}{"ui.theme": "dark", "ui.page_size": int64(50), "ui.density": "comfortable"},
+			wantChanges: map[string]interface{}{"ui.theme": "dark", "ui.page_size": int64(50)},
+		},
+		{
+			name:        "null restores the default",
+			first:       map[string]interface{}{"ui.theme": "dark", "ui.density": "compact"},
+			update:      map[string]interface{}{"ui.theme": nil},
+			wantValues:  map[string]interface{}{"ui.theme": "system", "ui.density": "compact"},
+			wantChanges: map[string]interface{}{"ui.theme": "system"},
+		},
+		{
+			name:       "same value publishes nothing",
+			first:      map[string]interface{}{"ui.theme": "dark"},
+			update:     map[string]interface{}{"ui.theme": "dark", "notifications.product_updates": false},
+			wantValues: map[string]interface{}{"ui.theme": "dark", "notifications.product_updates": false},
+		},
+		{
+			name:       "invalid values reject the whole update",
+			update:     map[string]interface{}{"ui.theme": "neon", "ui.page_size": float64(5), "ui.density": "compact", "ui.unknown": true, "notifications.product_updates": "yes"},
+			wantErr:    []string{"ui.theme", "ui.page_size", "ui.unknown", "notifications.product_updates"},
+			wantValues: map[string]interface{}{"ui.density": "comfortable"},
+		},
+		{
+			name:       "fractional int",
+			update:     map[string]interface{}{"ui.page_size": 25.5},
+			wantErr:    []string{"ui.page_size"},
+			wantValues: map[string]interface{}{"ui.page_size": int64(25)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			usecase, lowPub := newTestUsecase(t)
+			if tt.first != nil {
+				if _, err := usecase.UpdatePreferences(ctx, testUserID, tt.first); err != nil {
+					t.Fatalf("UpdatePreferences: %v", err)
+				}
+			}
+			published := len(lowPub.MessagesFor(string(event.PreferencesChangedInMemoryEvent)))
+
+			_, err := usecase.UpdatePreferences(ctx, testUserID, tt.update)
+			var invalid *domain.InvalidPreferencesError
+			if len(tt.wantErr) > 0 {
+				if !errors.As(err, &invalid) {
+					t.Fatalf("UpdatePreferences error = %v, want invalid preferences", err)
+				}
+				for _, key := range tt.wantErr {
+					if _, ok := invalid.Fields[key]; !ok {
+						t.Errorf("%s not reported invalid: %v", key, invalid.Fields)
+					}
+				}
+				if len(invalid.Fields) != len(tt.wantErr) {
+					t.Errorf("invalid keys = %v, want %v", invalid.Fields, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("UpdatePreferences: %v", err)
+			}
+
+			values, err := usecase.GetPreferences(ctx, testUserID)
+			if err != nil {
+				t.Fatalf("GetPreferences: %v", err)
+			}
+			for key, want := range tt.wantValues {
+				if values[key] != want {
+					t.Errorf("%s = %#v, want %#v", key, values[key], want)
+				}
+			}
+
+			messages := lowPub.MessagesFor(string(event.PreferencesChangedInMemoryEvent))[published:]
+			if tt.wantChanges == nil {
+				if len(messages) != 0 {
+					t.Errorf("published %d events, want none", len(messages))
+				}
+				return
+			}
+			if len(messages) != 1 {
+				t.Fatalf("published %d events, want 1", len(messages))
+			}
+			payload := messages[0].Payload.(event.PreferencesChangedPayload)
+			if payload.UserID != testUserID.String() || !maps.Equal(payload.Changes, tt.wantChanges) {
+				t.Errorf("event = %+v, want changes %v for %s", payload, tt.wantChanges, testUserID)
+			}
+		})
+	}
+}
+
+func TestPreferenceUsecase_GetPreferencesDefaults(t *testing.T) {
+	usecase, _ := newTestUsecase(t)
+	values, err := usecase.GetPreferences(context.Background(), testUserID)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	want := map[string]interface{}{
+		"ui.theme":                      "system",
+		"ui.density":                    "comfortable",
+		"ui.page_size":                  int64(25),
+		"notifications.email_digest":    "weekly",
+		"notifications.product_updates": false,
+	}
+	if !maps.Equal(values, want) {
+		t.Errorf("GetPreferences = %v, want %v", values, want)
+	}
+}
+
+func TestPreferenceUsecase_MergePreferences(t *testing.T) {
+	uc, lowPub := newTestUsecase(t)
+	ctx := context.Background()
+	const targetID = userDomain.UserID("64b000000000000000000002")
+	if _, err := uc.UpdatePreferences(ctx, testUserID, map[string]interface{}{"ui.theme": "dark", "ui.page_size": 50}); err != nil {
+		t.Fatalf("UpdatePreferences source: %v", err)
+	}
+	if _, err := uc.UpdatePreferences(ctx, targetID, map[string]interface{}{"ui.theme": "light"}); err != nil {
+		t.Fatalf("UpdatePreferences target: %v", err)
+	}
+	lowPub.Reset()
+
+	for range 2 { // a replayed merge event changes nothing
+		if _, err := uc.MergePreferences(ctx, testUserID, targetID); err != nil {
+			t.Fatalf("MergePreferences: %v", err)
+		}
+	}
+
+	got, err := uc.GetPreferences(ctx, targetID)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if got["ui.theme"] != "light" || got["ui.page_size"] != int64(50) {
+		t.Errorf("target preferences = %v, want its own theme and the source's page size", got)
+	}
+	source, err := uc.GetPreferences(ctx, testUserID)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if want := uc.registry.Defaults(); !maps.Equal(source, want) {
+		t.Errorf("source preferences = %v, want the defaults", source)
+	}
+	if messages := lowPub.MessagesFor(string(event.PreferencesChangedInMemoryEvent)); len(messages) != 1 {
+		t.Errorf("published %d change events, want 1", len(messages))
+	}
+}
@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// GridFSExportArchiveStore keeps export archives in a GridFS bucket, so they are
+// available to every API instance without extra infrastructure.
+type GridFSExportArchiveStore struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFSExportArchiveStore(db *mongo.Database, bucketName string) (*GridFSExportArchiveStore, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GridFS bucket %s: %w", bucketName, err)
+	}
+	return &GridFSExportArchiveStore{bucket: bucket}, nil
+}
+
+func (s *GridFSExportArchiveStore) Save(ctx context.Context, filename string, userID userDomain.UserID, write func(w io.Writer) error) (primitive.ObjectID, int64, error) {
+	opts := options.GridFSUpload().SetMetadata(bson.M{"user_id": userID})
+	upload, err := s.bucket.OpenUploadStream(filename, opts)
+	if err != nil {
+		return primitive.NilObjectID, 0, fmt.Errorf("failed to open export upload: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := upload.SetWriteDeadline(deadline); err != nil {
+			_ = upload.Abort()
+			return primitive.NilObjectID, 0, fmt.Errorf("failed to set export upload deadline: %w", err)
+		}
+	}
+
+	counter := &countingWriter{w: upload}
+	if err := write(counter); err != nil {
+		// Abort removes the chunks written so far.
+		_ = upload.Abort()
+		return primitive.NilObjectID, 0, err
+	}
+	if err := upload.Close(); err != nil {
+		return primitive.NilObjectID, 0, fmt.Errorf("failed to finish export upload: %w", err)
+	}
+
+	fileID, ok := upload.FileID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, 0, fmt.Errorf("unexpected export file ID type %T", upload.FileID)
+	}
+	return fileID, counter.n, nil
+}
+
+func (s *GridFSExportArchiveStore) Open(ctx context.Context, fileID primitive.ObjectID) (io.ReadCloser, int64, error) {
+	download, err := s.bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, 0, domain.ErrArchiveNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to open export archive: %w", err)
+	}
+	return download, download.GetFile().Length, nil
+}
+
+// Delete removes an archive. Deleting an archive that no longer exists succeeds.
+func (s *GridFSExportArchiveStore) Delete(ctx context.Context, fileID primitive.ObjectID) error {
+	if err := s.bucket.DeleteContext(ctx, fileID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return fmt.Errorf("failed to delete export archive: %w", err)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+var _ repository.ExportArchiveStore = (*GridFSExportArchiveStore)(nil)
@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+// PrivacyRequestIndexes declares the indexes of the privacy requests collection.
+func PrivacyRequestIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "status", Value: 1}},
+				Options: options.Index().SetName("privacy_requests_user_type_status"),
+			},
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+				Options: options.Index().SetName("privacy_requests_status_expires_at"),
+			},
+		},
+	}
+}
+
+// ErasureRecordIndexes declares the unique indexes that keep the erasure record chain
+// linear: one record per position and one record per erasure request.
+func ErasureRecordIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "seq", Value: 1}},
+				Options: options.Index().SetName("erasure_records_seq").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "request_id", Value: 1}},
+				Options: options.Index().SetName("erasure_records_request_id").SetUnique(true),
+			},
+		},
+	}
+}
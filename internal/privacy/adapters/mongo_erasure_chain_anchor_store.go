@@ -0,0 +1,53 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+)
+
+// erasureChainAnchorID is the _id of the single anchor document.
+const erasureChainAnchorID = "erasure_records"
+
+// MongoErasureChainAnchorStore keeps the chain anchor in its own collection, so that
+// cutting records from the end of the chain also requires rewriting the anchor.
+type MongoErasureChainAnchorStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoErasureChainAnchorStore(db *mongo.Database, collectionName string) *MongoErasureChainAnchorStore {
+	return &MongoErasureChainAnchorStore{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (s *MongoErasureChainAnchorStore) GetAnchor(ctx context.Context) (*domain.ErasureChainAnchor, error) {
+	var anchor domain.ErasureChainAnchor
+	if err := s.collection.FindOne(ctx, bson.M{"_id": erasureChainAnchorID}).Decode(&anchor); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find erasure chain anchor: %w", err)
+	}
+	return &anchor, nil
+}
+
+func (s *MongoErasureChainAnchorStore) AdvanceAnchor(ctx context.Context, anchor *domain.ErasureChainAnchor) error {
+	filter := bson.M{"_id": erasureChainAnchorID, "seq": bson.M{"$lt": anchor.Seq}}
+	update := bson.M{"$set": bson.M{"seq": anchor.Seq, "hash": anchor.Hash, "updated_at": anchor.UpdatedAt}}
+	_, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	// The upsert collides with the existing anchor when it is already at or past seq.
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("failed to advance erasure chain anchor: %w", err)
+	}
+	return nil
+}
+
+var _ repository.ErasureChainAnchorStore = (*MongoErasureChainAnchorStore)(nil)
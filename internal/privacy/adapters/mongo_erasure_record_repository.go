@@ -0,0 +1,81 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+)
+
+type MongoErasureRecordRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoErasureRecordRepository(db *mongo.Database, collectionName string) *MongoErasureRecordRepository {
+	return &MongoErasureRecordRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoErasureRecordRepository) Last(ctx context.Context) (*domain.ErasureRecord, error) {
+	var record domain.ErasureRecord
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})
+	if err := r.collection.FindOne(ctx, bson.M{}, opts).Decode(&record); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find last erasure record: %w", err)
+	}
+	return &record, nil
+}
+
+func (r *MongoErasureRecordRepository) Insert(ctx context.Context, record *domain.ErasureRecord) error {
+	if _, err := r.collection.InsertOne(ctx, record); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateSeq
+		}
+		return fmt.Errorf("failed to insert erasure record: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoErasureRecordRepository) GetByRequestID(ctx context.Context, requestID string) (*domain.ErasureRecord, error) {
+	var record domain.ErasureRecord
+	if err := r.collection.FindOne(ctx, bson.M{"request_id": requestID}).Decode(&record); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find erasure record: %w", err)
+	}
+	return &record, nil
+}
+
+func (r *MongoErasureRecordRepository) Stream(ctx context.Context, fn func(record *domain.ErasureRecord) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to query erasure records: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record domain.ErasureRecord
+		if err := cursor.Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode erasure record: %w", err)
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to iterate erasure records: %w", err)
+	}
+	return nil
+}
+
+var _ repository.ErasureRecordRepository = (*MongoErasureRecordRepository)(nil)
@@ -0,0 +1,125 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+type MongoPrivacyRequestRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPrivacyRequestRepository(db *mongo.Database, collectionName string) *MongoPrivacyRequestRepository {
+	return &MongoPrivacyRequestRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoPrivacyRequestRepository) Create(ctx context.Context, request *domain.PrivacyRequest) error {
+	res, err := r.collection.InsertOne(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to insert privacy request: %w", err)
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		request.ID = oid
+	}
+	return nil
+}
+
+func (r *MongoPrivacyRequestRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.PrivacyRequest, error) {
+	var request domain.PrivacyRequest
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&request); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to find privacy request: %w", err)
+	}
+	return &request, nil
+}
+
+func (r *MongoPrivacyRequestRepository) Save(ctx context.Context, request *domain.PrivacyRequest) error {
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": request.ID}, request)
+	if err != nil {
+		return fmt.Errorf("failed to save privacy request: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrRequestNotFound
+	}
+	return nil
+}
+
+func (r *MongoPrivacyRequestRepository) FindActive(ctx context.Context, userID userDomain.UserID, requestType string) (*domain.PrivacyRequest, error) {
+	filter := bson.M{
+		"user_id": userID,
+		"type":    requestType,
+		"status":  bson.M{"$in": []string{domain.RequestStatusPending, domain.RequestStatusRunning}},
+	}
+	var request domain.PrivacyRequest
+	if err := r.collection.FindOne(ctx, filter).Decode(&request); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrRequestNotFound
+		}
+		return nil, fmt.Errorf("failed to find active privacy request: %w", err)
+	}
+	return &request, nil
+}
+
+func (r *MongoPrivacyRequestRepository) FindExpiredExports(ctx context.Context, now time.Time, limit int) ([]domain.PrivacyRequest, error) {
+	filter := bson.M{
+		"type":       domain.RequestTypeDataExport,
+		"status":     domain.RequestStatusCompleted,
+		"expires_at": bson.M{"$lt": now},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired exports: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var requests []domain.PrivacyRequest
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, fmt.Errorf("failed to decode expired exports: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *MongoPrivacyRequestRepository) ExportFileIDs(ctx context.Context, userID userDomain.UserID) ([]primitive.ObjectID, error) {
+	filter := bson.M{
+		"user_id": userID,
+		"type":    domain.RequestTypeDataExport,
+		"file_id": bson.M{"$exists": true},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"file_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find export files: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var doc struct {
+			FileID primitive.ObjectID `bson:"file_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode export file: %w", err)
+		}
+		ids = append(ids, doc.FileID)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate export files: %w", err)
+	}
+	return ids, nil
+}
+
+var _ repository.PrivacyRequestRepository = (*MongoPrivacyRequestRepository)(nil)
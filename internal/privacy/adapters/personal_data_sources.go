@@ -0,0 +1,236 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	authUsecase "github.com/iots1/mingkwan-api/internal/auth/usecase"
+	groupUsecase "github.com/iots1/mingkwan-api/internal/group/usecase"
+	preferenceUsecase "github.com/iots1/mingkwan-api/internal/preference/usecase"
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// UserDataSource covers the user record and its profile.
+type UserDataSource struct {
+	userUsecase userUsecase.UserUsecase
+}
+
+func NewUserDataSource(userUsecase userUsecase.UserUsecase) *UserDataSource {
+	return &UserDataSource{userUsecase: userUsecase}
+}
+
+func (s *UserDataSource) Name() string { return "user" }
+
+func (s *UserDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	user, err := s.userUsecase.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(w, "user/profile.json", user)
+}
+
+func (s *UserDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	if err := s.userUsecase.AnonymizeUser(ctx, userID); err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return map[string]int64{"users_anonymized": 0}, nil
+		}
+		return nil, err
+	}
+	return map[string]int64{"users_anonymized": 1}, nil
+}
+
+// UserHistoryDataSource covers the recorded history of the user record, which holds
+// every previous value of the profile.
+type UserHistoryDataSource struct {
+	historyUsecase *userUsecase.UserHistoryUsecase
+}
+
+func NewUserHistoryDataSource(historyUsecase *userUsecase.UserHistoryUsecase) *UserHistoryDataSource {
+	return &UserHistoryDataSource{historyUsecase: historyUsecase}
+}
+
+func (s *UserHistoryDataSource) Name() string { return "user_history" }
+
+func (s *UserHistoryDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	file, err := w.Create("user/history.ndjson")
+	if err != nil {
+		return fmt.Errorf("failed to create user history export file: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+	return s.historyUsecase.ExportHistory(ctx, userID, func(entry *userDomain.UserHistoryEntry) error {
+		return encoder.Encode(entry)
+	})
+}
+
+// ErasePersonalData deletes the history, including the entry recorded by the erasure
+// of the user record; the erasure itself stays in the audit log.
+func (s *UserHistoryDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	n, err := s.historyUsecase.DeleteHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"history_entries_deleted": n}, nil
+}
+
+// AuthDataSource covers sessions and known devices, which hold IP addresses and
+// user agents.
+type AuthDataSource struct {
+	authUsecase authUsecase.AuthUsecase
+}
+
+func NewAuthDataSource(authUsecase authUsecase.AuthUsecase) *AuthDataSource {
+	return &AuthDataSource{authUsecase: authUsecase}
+}
+
+func (s *AuthDataSource) Name() string { return "auth" }
+
+func (s *AuthDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	sessions, err := s.authUsecase.ListSessions(ctx, userID.String())
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(w, "auth/sessions.json", sessions); err != nil {
+		return err
+	}
+	devices, err := s.authUsecase.ListKnownDevices(ctx, userID.String())
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(w, "auth/known_devices.json", devices)
+}
+
+func (s *AuthDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	sessions, devices, err := s.authUsecase.EraseUserData(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"sessions_deleted": sessions, "devices_deleted": devices}, nil
+}
+
+// GroupDataSource covers the user's group memberships.
+type GroupDataSource struct {
+	groupUsecase *groupUsecase.GroupUsecase
+}
+
+func NewGroupDataSource(groupUsecase *groupUsecase.GroupUsecase) *GroupDataSource {
+	return &GroupDataSource{groupUsecase: groupUsecase}
+}
+
+func (s *GroupDataSource) Name() string { return "group" }
+
+func (s *GroupDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	groups, err := s.groupUsecase.ListUserGroups(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(w, "group/groups.json", groups)
+}
+
+func (s *GroupDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	n, err := s.groupUsecase.RemoveUserFromGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"memberships_deleted": n}, nil
+}
+
+// PreferenceDataSource covers the preferences the user stored.
+type PreferenceDataSource struct {
+	preferenceUsecase *preferenceUsecase.PreferenceUsecase
+}
+
+func NewPreferenceDataSource(preferenceUsecase *preferenceUsecase.PreferenceUsecase) *PreferenceDataSource {
+	return &PreferenceDataSource{preferenceUsecase: preferenceUsecase}
+}
+
+func (s *PreferenceDataSource) Name() string { return "preference" }
+
+func (s *PreferenceDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	values, err := s.preferenceUsecase.GetPreferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(w, "preference/preferences.json", values)
+}
+
+func (s *PreferenceDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	n, err := s.preferenceUsecase.DeletePreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"preferences_deleted": n}, nil
+}
+
+// AuditDataSource covers audit entries where the user is the actor or the target.
+// Erasure pseudonymizes them rather than deleting them, so it must run after the
+// other sources, whose erasure writes audit entries of its own.
+type AuditDataSource struct {
+	auditUsecase *auditUsecase.AuditUsecase
+}
+
+func NewAuditDataSource(auditUsecase *auditUsecase.AuditUsecase) *AuditDataSource {
+	return &AuditDataSource{auditUsecase: auditUsecase}
+}
+
+func (s *AuditDataSource) Name() string { return "audit" }
+
+func (s *AuditDataSource) ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error {
+	file, err := w.Create("audit/entries.ndjson")
+	if err != nil {
+		return fmt.Errorf("failed to create audit export file: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+
+	// Entries where the user acted on themselves match both filters.
+	seen := make(map[primitive.ObjectID]struct{})
+	write := func(entry *auditDomain.AuditEntry) error {
+		if _, ok := seen[entry.ID]; ok {
+			return nil
+		}
+		seen[entry.ID] = struct{}{}
+		return encoder.Encode(entry)
+	}
+	if err := s.auditUsecase.ExportEntries(ctx, auditDomain.AuditFilter{ActorID: userID.String()}, write); err != nil {
+		return err
+	}
+	return s.auditUsecase.ExportEntries(ctx, auditDomain.AuditFilter{TargetID: userID.String()}, write)
+}
+
+func (s *AuditDataSource) ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error) {
+	n, err := s.auditUsecase.PseudonymizeSubject(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"entries_pseudonymized": n}, nil
+}
+
+func writeJSONFile(w domain.ExportWriter, name string, v interface{}) error {
+	file, err := w.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create export file %s: %w", name, err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write export file %s: %w", name, err)
+	}
+	return nil
+}
+
+var (
+	_ repository.PersonalDataSource = (*UserDataSource)(nil)
+	_ repository.PersonalDataSource = (*UserHistoryDataSource)(nil)
+	_ repository.PersonalDataSource = (*AuthDataSource)(nil)
+	_ repository.PersonalDataSource = (*GroupDataSource)(nil)
+	_ repository.PersonalDataSource = (*PreferenceDataSource)(nil)
+	_ repository.PersonalDataSource = (*AuditDataSource)(nil)
+)
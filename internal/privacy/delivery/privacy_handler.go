@@ -0,0 +1,200 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	privacyDomain "github.com/iots1/mingkwan-api/internal/privacy/domain"
+	privacyModel "github.com/iots1/mingkwan-api/internal/privacy/models"
+	privacyUsecase "github.com/iots1/mingkwan-api/internal/privacy/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// verifyTimeout covers reading and hashing the whole erasure record chain.
+const verifyTimeout = time.Minute
+
+type PrivacyHandler struct {
+	privacyUsecase privacyUsecase.PrivacyUsecase
+}
+
+func NewPrivacyHandler(privacyUsecase privacyUsecase.PrivacyUsecase) *PrivacyHandler {
+	return &PrivacyHandler{privacyUsecase: privacyUsecase}
+}
+
+func (h *PrivacyHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
+	logFields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status_code", statusCode),
+		zap.String("message", message),
+	}
+	if err != nil {
+		logFields = append(logFields, zap.Error(err))
+	}
+	if validationErrors != nil {
+		logFields = append(logFields, zap.Any("validation_errors", validationErrors))
+	}
+	utils.Logger.Error("API Error", logFields...)
+
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Errors:    validationErrors,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
+
+func (h *PrivacyHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+	})
+}
+
+// callerID returns the ID of the authenticated user.
+func (h *PrivacyHandler) callerID(c *fiber.Ctx) (userDomain.UserID, bool) {
+	id, err := userDomain.ParseUserID(middleware.GetRequestMeta(c).ActorID)
+	return id, err == nil
+}
+
+// RequestDataExport queues a ZIP archive of all personal data of the caller. The
+// returned request can be polled until it is completed, then downloaded.
+func (h *PrivacyHandler) RequestDataExport(c *fiber.Ctx) error {
+	userID, ok := h.callerID(c)
+	if !ok {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	request, err := h.privacyUsecase.RequestDataExport(ctx, userID)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to request data export", err, nil)
+	}
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + request.ID.Hex())
+	return h.sendSuccessResponse(c, fiber.StatusAccepted, request, 1)
+}
+
+// GetDataExport reports the status of a data export of the caller.
+func (h *PrivacyHandler) GetDataExport(c *fiber.Ctx) error {
+	return h.getRequest(c, privacyDomain.RequestTypeDataExport)
+}
+
+// DownloadDataExport streams the ZIP archive of a completed data export.
+func (h *PrivacyHandler) DownloadDataExport(c *fiber.Ctx) error {
+	userID, ok := h.callerID(c)
+	if !ok {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	reader, size, filename, err := h.privacyUsecase.OpenExport(ctx, userID, c.Params("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, privacyDomain.ErrRequestNotFound):
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case errors.Is(err, privacyDomain.ErrExportNotReady):
+			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		case errors.Is(err, privacyDomain.ErrExportExpired):
+			return h.sendErrorResponse(c, fiber.StatusGone, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to download data export", err, nil)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	// The reader is closed once the body was sent.
+	return c.SendStream(reader, int(size))
+}
+
+// RequestErasure queues the erasure of all personal data of the caller. The
+// account is anonymized and can no longer be used once the erasure completed.
+func (h *PrivacyHandler) RequestErasure(c *fiber.Ctx) error {
+	userID, ok := h.callerID(c)
+	if !ok {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+
+	var req privacyModel.ErasureRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("RequestErasure: Invalid request body", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("RequestErasure: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	request, err := h.privacyUsecase.RequestErasure(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, userDomain.ErrUserNotFound):
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case errors.Is(err, privacyDomain.ErrAlreadyErased):
+			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to request erasure", err, nil)
+	}
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + request.ID.Hex())
+	return h.sendSuccessResponse(c, fiber.StatusAccepted, request, 1)
+}
+
+// GetErasure reports the status of an erasure request of the caller.
+func (h *PrivacyHandler) GetErasure(c *fiber.Ctx) error {
+	return h.getRequest(c, privacyDomain.RequestTypeErasure)
+}
+
+func (h *PrivacyHandler) getRequest(c *fiber.Ctx, requestType string) error {
+	userID, ok := h.callerID(c)
+	if !ok {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	request, err := h.privacyUsecase.GetRequest(ctx, userID, c.Params("id"), requestType)
+	if err != nil {
+		if errors.Is(err, privacyDomain.ErrRequestNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve privacy request", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, request, 1)
+}
+
+// VerifyErasureRecords checks the erasure record chain for tampering.
+func (h *PrivacyHandler) VerifyErasureRecords(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), verifyTimeout)
+	defer cancel()
+
+	result, err := h.privacyUsecase.VerifyErasureChain(ctx)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to verify erasure records", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, result, int(result.Records))
+}
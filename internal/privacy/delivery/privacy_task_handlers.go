@@ -0,0 +1,72 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	privacyUsecase "github.com/iots1/mingkwan-api/internal/privacy/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// privacyActorID identifies the privacy jobs as the actor in audit entries.
+const privacyActorID = "system:privacy"
+
+// PrivacyTaskHandlers processes the privacy module's Asynq tasks.
+type PrivacyTaskHandlers struct {
+	privacyUsecase privacyUsecase.PrivacyUsecase
+}
+
+func NewPrivacyTaskHandlers(privacyUsecase privacyUsecase.PrivacyUsecase) *PrivacyTaskHandlers {
+	return &PrivacyTaskHandlers{privacyUsecase: privacyUsecase}
+}
+
+// ExportUserData handles the 'privacy:export_user_data' task queued by
+// POST /me/data-export.
+func (h *PrivacyTaskHandlers) ExportUserData(ctx context.Context, t *asynq.Task) error {
+	return h.runRequest(ctx, t, "data export", h.privacyUsecase.RunDataExport)
+}
+
+// EraseUser handles the 'privacy:erase_user' task queued by POST /me/erasure.
+func (h *PrivacyTaskHandlers) EraseUser(ctx context.Context, t *asynq.Task) error {
+	return h.runRequest(ctx, t, "erasure", h.privacyUsecase.RunErasure)
+}
+
+func (h *PrivacyTaskHandlers) runRequest(ctx context.Context, t *asynq.Task, kind string, run func(ctx context.Context, requestID string) error) error {
+	var payload event.PrivacyRequestPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode privacy request payload: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: privacyActorID})
+
+	if err := run(ctx, payload.RequestID); err != nil {
+		utils.Logger.Error("PrivacyTaskHandlers: Job failed", zap.String("kind", kind), zap.String("request_id", payload.RequestID), zap.Error(err))
+		// Leave the request pending for the next attempt unless this was the last one.
+		retried, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if retried >= maxRetry {
+			h.privacyUsecase.FailRequest(ctx, payload.RequestID, err)
+		}
+		return fmt.Errorf("run %s: %w", kind, err)
+	}
+	return nil
+}
+
+// CleanupDataExports handles the periodic 'privacy:cleanup_exports' task.
+func (h *PrivacyTaskHandlers) CleanupDataExports(ctx context.Context, t *asynq.Task) error {
+	ctx = utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: privacyActorID})
+
+	removed, err := h.privacyUsecase.CleanupExpiredExports(ctx)
+	if err != nil {
+		utils.Logger.Error("PrivacyTaskHandlers: Cleanup of data exports failed", zap.Int("removed", removed), zap.Error(err))
+		return fmt.Errorf("cleanup data exports: %w", err)
+	}
+	if removed > 0 {
+		utils.Logger.Info("PrivacyTaskHandlers: Removed expired data exports", zap.Int("removed", removed))
+	}
+	return nil
+}
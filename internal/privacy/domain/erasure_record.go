@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ErasureRecord is the proof that an erasure request was carried out. Records form
+// a hash chain: each one hashes its own content together with the hash of the
+// previous record, so editing or removing any record breaks every hash after it.
+type ErasureRecord struct {
+	Seq         int64        `bson:"seq" json:"seq"`
+	RequestID   string       `bson:"request_id" json:"request_id"`
+	SubjectID   string       `bson:"subject_id" json:"subject_id"`
+	Steps       []StepResult `bson:"steps" json:"steps"`
+	CompletedAt time.Time    `bson:"completed_at" json:"completed_at"`
+	PrevHash    string       `bson:"prev_hash" json:"prev_hash"`
+	Hash        string       `bson:"hash" json:"hash"`
+}
+
+// ComputeHash returns the hex SHA-256 of the record without its Hash field. Times
+// are hashed at millisecond precision in UTC, which is what MongoDB stores, so that
+// a record read back hashes to the same value.
+func (r ErasureRecord) ComputeHash() (string, error) {
+	r.Hash = ""
+	r.CompletedAt = r.CompletedAt.UTC().Truncate(time.Millisecond)
+	steps := make([]StepResult, len(r.Steps))
+	for i, step := range r.Steps {
+		step.CompletedAt = step.CompletedAt.UTC().Truncate(time.Millisecond)
+		steps[i] = step
+	}
+	r.Steps = steps
+
+	// encoding/json writes struct fields in declaration order and map keys sorted,
+	// which makes the encoding canonical.
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ErasureChainAnchor is the sequence number and hash of the newest erasure record,
+// kept apart from the chain. The chain alone cannot show that records were cut from
+// its end; the anchor can, since sequence numbers start at 1 without gaps and Seq is
+// thus also the number of records.
+type ErasureChainAnchor struct {
+	Seq       int64     `bson:"seq" json:"seq"`
+	Hash      string    `bson:"hash" json:"hash"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ChainVerification is the result of checking the erasure record chain.
+type ChainVerification struct {
+	Valid    bool   `json:"valid"`
+	Records  int64  `json:"records"`
+	LastHash string `json:"last_hash,omitempty"`
+	// AnchoredSeq is the sequence number of the chain anchor, 0 if there is none.
+	AnchoredSeq int64 `json:"anchored_seq"`
+	// BrokenAt is the sequence number of the first record that does not verify.
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
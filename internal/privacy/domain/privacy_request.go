@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// Privacy request types: the data subject rights we serve under the GDPR and the
+// Thai PDPA.
+const (
+	RequestTypeDataExport = "data_export"
+	RequestTypeErasure    = "erasure"
+)
+
+// PrivacyRequest statuses.
+const (
+	RequestStatusPending   = "pending"
+	RequestStatusRunning   = "running"
+	RequestStatusCompleted = "completed"
+	RequestStatusFailed    = "failed"
+	// RequestStatusExpired marks a data export whose archive was deleted.
+	RequestStatusExpired = "expired"
+)
+
+// ExportRetention is how long a finished data export can be downloaded.
+const ExportRetention = 7 * 24 * time.Hour
+
+var (
+	ErrRequestNotFound = errors.New("privacy request not found")
+	ErrExportNotReady  = errors.New("data export is not ready yet")
+	ErrExportExpired   = errors.New("data export has expired")
+	ErrAlreadyErased   = errors.New("personal data has already been erased")
+	ErrArchiveNotFound = errors.New("export archive not found")
+	// ErrDuplicateSeq is returned when two erasure records race for the same
+	// position in the chain.
+	ErrDuplicateSeq = errors.New("erasure record sequence number already taken")
+)
+
+// StepResult records that one data source finished exporting or erasing the data
+// of a user. Details holds per-source counters, e.g. how many sessions were deleted.
+type StepResult struct {
+	Source      string           `bson:"source" json:"source"`
+	Details     map[string]int64 `bson:"details,omitempty" json:"details,omitempty"`
+	CompletedAt time.Time        `bson:"completed_at" json:"completed_at"`
+}
+
+// PrivacyRequest tracks a data export or erasure of one user. Steps lets a retried
+// job skip the sources it already handled.
+type PrivacyRequest struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Type        string              `bson:"type" json:"type"`
+	UserID      userDomain.UserID   `bson:"user_id" json:"user_id"`
+	Status      string              `bson:"status" json:"status"`
+	Steps       []StepResult        `bson:"steps,omitempty" json:"steps,omitempty"`
+	FileID      *primitive.ObjectID `bson:"file_id,omitempty" json:"-"`
+	FileSize    int64               `bson:"file_size,omitempty" json:"file_size,omitempty"`
+	Error       string              `bson:"error,omitempty" json:"error,omitempty"`
+	RequestedAt time.Time           `bson:"requested_at" json:"requested_at"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time          `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+}
+
+// StepDone reports whether source already completed its step.
+func (r *PrivacyRequest) StepDone(source string) bool {
+	for _, step := range r.Steps {
+		if step.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the request is still waiting for or being processed by
+// its job.
+func (r *PrivacyRequest) IsActive() bool {
+	return r.Status == RequestStatusPending || r.Status == RequestStatusRunning
+}
+
+// ExportWriter receives the files of a data export. *zip.Writer implements it.
+type ExportWriter interface {
+	Create(name string) (io.Writer, error)
+}
+
+// ExportManifest is written as manifest.json at the root of every export archive.
+type ExportManifest struct {
+	RequestID   string    `json:"request_id"`
+	UserID      string    `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Sources     []string  `json:"sources"`
+	Files       []string  `json:"files"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// ErasureRequest is the body of POST /me/erasure. Erasure cannot be undone, so the
+// client must confirm it explicitly.
+type ErasureRequest struct {
+	Confirm bool `json:"confirm" validate:"required"`
+}
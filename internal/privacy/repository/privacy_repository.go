@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// PersonalDataSource is implemented once per module that stores personal data.
+// A module that starts storing personal data (e.g. media uploads) plugs into
+// exports and erasures by adding a source.
+type PersonalDataSource interface {
+	// Name identifies the source in manifests, steps and erasure records.
+	Name() string
+	// ExportPersonalData writes the data held about userID as one or more files.
+	ExportPersonalData(ctx context.Context, userID userDomain.UserID, w domain.ExportWriter) error
+	// ErasePersonalData deletes or anonymizes the data held about userID and returns
+	// per-kind counters. It must be safe to run again after a partial failure.
+	ErasePersonalData(ctx context.Context, userID userDomain.UserID) (map[string]int64, error)
+}
+
+type PrivacyRequestRepository interface {
+	Create(ctx context.Context, request *domain.PrivacyRequest) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.PrivacyRequest, error)
+	Save(ctx context.Context, request *domain.PrivacyRequest) error
+	// FindActive returns the pending or running request of the given type of a
+	// user, or domain.ErrRequestNotFound.
+	FindActive(ctx context.Context, userID userDomain.UserID, requestType string) (*domain.PrivacyRequest, error)
+	// FindExpiredExports returns completed data exports that expired before now.
+	FindExpiredExports(ctx context.Context, now time.Time, limit int) ([]domain.PrivacyRequest, error)
+	// ExportFileIDs returns the archive IDs of every data export of a user.
+	ExportFileIDs(ctx context.Context, userID userDomain.UserID) ([]primitive.ObjectID, error)
+}
+
+// ErasureRecordRepository stores the erasure record chain. Records are only ever
+// appended.
+type ErasureRecordRepository interface {
+	// Last returns the record with the highest sequence number, or nil if the chain
+	// is empty.
+	Last(ctx context.Context) (*domain.ErasureRecord, error)
+	// Insert appends record. It fails with domain.ErrDuplicateSeq if another record
+	// took the sequence number first.
+	Insert(ctx context.Context, record *domain.ErasureRecord) error
+	// GetByRequestID returns the record of an erasure request, or nil if there is none.
+	GetByRequestID(ctx context.Context, requestID string) (*domain.ErasureRecord, error)
+	// Stream calls fn for every record in sequence order.
+	Stream(ctx context.Context, fn func(record *domain.ErasureRecord) error) error
+}
+
+// ErasureChainAnchorStore keeps the anchor of the erasure record chain.
+type ErasureChainAnchorStore interface {
+	// GetAnchor returns the anchor, or nil if no record was anchored yet.
+	GetAnchor(ctx context.Context) (*domain.ErasureChainAnchor, error)
+	// AdvanceAnchor moves the anchor to anchor, unless it already points at the same
+	// or a later record.
+	AdvanceAnchor(ctx context.Context, anchor *domain.ErasureChainAnchor) error
+}
+
+// ExportArchiveStore holds the ZIP archives of data exports.
+type ExportArchiveStore interface {
+	// Save stores the archive written by write and returns its ID and size.
+	Save(ctx context.Context, filename string, userID userDomain.UserID, write func(w io.Writer) error) (primitive.ObjectID, int64, error)
+	Open(ctx context.Context, fileID primitive.ObjectID) (io.ReadCloser, int64, error)
+	Delete(ctx context.Context, fileID primitive.ObjectID) error
+}
@@ -0,0 +1,560 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	"github.com/iots1/mingkwan-api/internal/privacy/domain"
+	"github.com/iots1/mingkwan-api/internal/privacy/repository"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+const (
+	// chainAppendAttempts bounds the retries of an erasure record that lost the race
+	// for its position in the chain to a concurrent erasure.
+	chainAppendAttempts = 5
+	// cleanupBatchSize is how many expired exports one cleanup run deletes.
+	cleanupBatchSize = 100
+)
+
+// PrivacyUsecase serves the data subject rights of the GDPR and the Thai PDPA: a
+// copy of all personal data (data export) and its erasure. Both run as background
+// jobs over every registered PersonalDataSource.
+type PrivacyUsecase struct {
+	requests    repository.PrivacyRequestRepository
+	records     repository.ErasureRecordRepository
+	anchors     repository.ErasureChainAnchorStore
+	archives    repository.ExportArchiveStore
+	sources     []repository.PersonalDataSource
+	userUsecase userUsecase.UserUsecase
+	lowPub      event.Publisher
+	highPub     event.Publisher
+	audit       *auditUsecase.AuditUsecase
+}
+
+// NewPrivacyUsecase takes the data sources in the order their data is erased.
+func NewPrivacyUsecase(
+	requests repository.PrivacyRequestRepository,
+	records repository.ErasureRecordRepository,
+	anchors repository.ErasureChainAnchorStore,
+	archives repository.ExportArchiveStore,
+	sources []repository.PersonalDataSource,
+	userUsecase userUsecase.UserUsecase,
+	lowPub event.Publisher,
+	highPub event.Publisher,
+	audit *auditUsecase.AuditUsecase,
+) *PrivacyUsecase {
+	return &PrivacyUsecase{
+		requests:    requests,
+		records:     records,
+		anchors:     anchors,
+		archives:    archives,
+		sources:     sources,
+		userUsecase: userUsecase,
+		lowPub:      lowPub,
+		highPub:     highPub,
+		audit:       audit,
+	}
+}
+
+// RequestDataExport queues a data export of the user. While an export is pending
+// or running, the existing request is returned instead of starting another one.
+func (s *PrivacyUsecase) RequestDataExport(ctx context.Context, userID userDomain.UserID) (*domain.PrivacyRequest, error) {
+	if _, err := s.userUsecase.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.startRequest(ctx, userID, domain.RequestTypeDataExport, event.ExportUserDataTask, auditDomain.ActionPrivacyExport)
+}
+
+// RequestErasure queues the erasure of the user's personal data. While an erasure
+// is pending or running, the existing request is returned.
+func (s *PrivacyUsecase) RequestErasure(ctx context.Context, userID userDomain.UserID) (*domain.PrivacyRequest, error) {
+	user, err := s.userUsecase.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ErasedAt != nil {
+		return nil, domain.ErrAlreadyErased
+	}
+	return s.startRequest(ctx, userID, domain.RequestTypeErasure, event.EraseUserTask, auditDomain.ActionPrivacyErasure)
+}
+
+func (s *PrivacyUsecase) startRequest(ctx context.Context, userID userDomain.UserID, requestType, taskName, action string) (*domain.PrivacyRequest, error) {
+	active, err := s.requests.FindActive(ctx, userID, requestType)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, domain.ErrRequestNotFound) {
+		utils.Logger.Error("PrivacyUsecase: Failed to look up active request", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, fmt.Errorf("failed to look up active privacy request: %w", err)
+	}
+
+	now := time.Now()
+	request := &domain.PrivacyRequest{
+		Type:        requestType,
+		UserID:      userID,
+		Status:      domain.RequestStatusPending,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	if err := s.requests.Create(ctx, request); err != nil {
+		utils.Logger.Error("PrivacyUsecase: Failed to create request", zap.String("type", requestType), zap.Error(err))
+		return nil, fmt.Errorf("failed to create privacy request: %w", err)
+	}
+
+	if err := s.highPub.Publish(ctx, taskName, event.PrivacyRequestPayload{RequestID: request.ID.Hex()}); err != nil {
+		utils.Logger.Error("PrivacyUsecase: Failed to enqueue request job", zap.String("request_id", request.ID.Hex()), zap.Error(err))
+		s.failRequest(ctx, request, err)
+		s.recordAudit(ctx, action, request, err, map[string]interface{}{"stage": "requested"})
+		return nil, fmt.Errorf("failed to enqueue privacy request: %w", err)
+	}
+
+	s.recordAudit(ctx, action, request, nil, map[string]interface{}{"stage": "requested"})
+	utils.Logger.Info("PrivacyUsecase: Request queued", zap.String("type", requestType),
+		zap.String("request_id", request.ID.Hex()), zap.String("user_id", userID.String()))
+	return request, nil
+}
+
+// GetRequest returns a request of the given type owned by userID. Requests of other
+// users are reported as not found.
+func (s *PrivacyUsecase) GetRequest(ctx context.Context, userID userDomain.UserID, idStr, requestType string) (*domain.PrivacyRequest, error) {
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		return nil, domain.ErrRequestNotFound
+	}
+	request, err := s.requests.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrRequestNotFound) {
+			return nil, err
+		}
+		utils.Logger.Error("GetRequest: Failed to load privacy request", zap.String("request_id", idStr), zap.Error(err))
+		return nil, fmt.Errorf("failed to load privacy request: %w", err)
+	}
+	if request.UserID != userID || request.Type != requestType {
+		return nil, domain.ErrRequestNotFound
+	}
+	return request, nil
+}
+
+// RunDataExport builds the ZIP archive of a queued data export: a manifest plus the
+// files of every data source.
+func (s *PrivacyUsecase) RunDataExport(ctx context.Context, requestID string) error {
+	request, err := s.loadForJob(ctx, requestID, domain.RequestTypeDataExport)
+	if err != nil || request == nil {
+		return err
+	}
+
+	request.Status = domain.RequestStatusRunning
+	if err := s.saveRequest(ctx, request); err != nil {
+		return fmt.Errorf("failed to save privacy request: %w", err)
+	}
+
+	// Steps are rebuilt on every attempt: the archive is written in one go.
+	request.Steps = nil
+	generatedAt := time.Now().UTC()
+	filename := fmt.Sprintf("personal-data-%s-%s.zip", request.UserID.String(), generatedAt.Format("20060102T150405Z"))
+	fileID, size, err := s.archives.Save(ctx, filename, request.UserID, func(w io.Writer) error {
+		return s.writeArchive(ctx, w, request, generatedAt)
+	})
+	if err != nil {
+		request.Error = err.Error()
+		if saveErr := s.saveRequest(ctx, request); saveErr != nil {
+			utils.Logger.Error("PrivacyUsecase: Failed to save export progress", zap.String("request_id", requestID), zap.Error(saveErr))
+		}
+		return fmt.Errorf("failed to build data export: %w", err)
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(domain.ExportRetention)
+	request.Status = domain.RequestStatusCompleted
+	request.FileID = &fileID
+	request.FileSize = size
+	request.Error = ""
+	request.CompletedAt = &completedAt
+	request.ExpiresAt = &expiresAt
+	if err := s.saveRequest(ctx, request); err != nil {
+		if delErr := s.archives.Delete(ctx, fileID); delErr != nil {
+			utils.Logger.Error("PrivacyUsecase: Failed to delete orphaned export archive", zap.String("file_id", fileID.Hex()), zap.Error(delErr))
+		}
+		return fmt.Errorf("failed to save completed data export: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionPrivacyExport, request, nil, map[string]interface{}{"stage": "completed", "size": size})
+	utils.Logger.Info("PrivacyUsecase: Data export completed", zap.String("request_id", requestID), zap.Int64("size", size))
+	return nil
+}
+
+func (s *PrivacyUsecase) writeArchive(ctx context.Context, w io.Writer, request *domain.PrivacyRequest, generatedAt time.Time) error {
+	archive := zip.NewWriter(w)
+	files := &fileRecorder{zip: archive}
+
+	manifest := domain.ExportManifest{
+		RequestID:   request.ID.Hex(),
+		UserID:      request.UserID.String(),
+		GeneratedAt: generatedAt,
+	}
+	for _, source := range s.sources {
+		if err := source.ExportPersonalData(ctx, request.UserID, files); err != nil {
+			return fmt.Errorf("failed to export %s data: %w", source.Name(), err)
+		}
+		manifest.Sources = append(manifest.Sources, source.Name())
+		request.Steps = append(request.Steps, domain.StepResult{Source: source.Name(), CompletedAt: time.Now()})
+	}
+	manifest.Files = files.names
+
+	file, err := archive.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// fileRecorder remembers the names of the files written to an archive for the
+// manifest.
+type fileRecorder struct {
+	zip   *zip.Writer
+	names []string
+}
+
+func (f *fileRecorder) Create(name string) (io.Writer, error) {
+	f.names = append(f.names, name)
+	return f.zip.Create(name)
+}
+
+// OpenExport opens the archive of a completed, unexpired data export of userID.
+func (s *PrivacyUsecase) OpenExport(ctx context.Context, userID userDomain.UserID, idStr string) (io.ReadCloser, int64, string, error) {
+	request, err := s.GetRequest(ctx, userID, idStr, domain.RequestTypeDataExport)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	switch {
+	case request.Status == domain.RequestStatusExpired,
+		request.ExpiresAt != nil && time.Now().After(*request.ExpiresAt):
+		return nil, 0, "", domain.ErrExportExpired
+	case request.Status != domain.RequestStatusCompleted || request.FileID == nil:
+		return nil, 0, "", domain.ErrExportNotReady
+	}
+
+	reader, size, err := s.archives.Open(ctx, *request.FileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrArchiveNotFound) {
+			return nil, 0, "", domain.ErrExportExpired
+		}
+		utils.Logger.Error("OpenExport: Failed to open export archive", zap.String("request_id", idStr), zap.Error(err))
+		return nil, 0, "", fmt.Errorf("failed to open data export: %w", err)
+	}
+	filename := fmt.Sprintf("personal-data-%s.zip", request.ID.Hex())
+	return reader, size, filename, nil
+}
+
+// RunErasure erases the user's data source by source, saving the request after
+// each one so that a retried job resumes where it stopped. It then deletes the
+// user's data exports, appends the erasure record to the chain, advances the chain
+// anchor and tells every module through UserErasedInMemoryEvent.
+func (s *PrivacyUsecase) RunErasure(ctx context.Context, requestID string) error {
+	request, err := s.loadForJob(ctx, requestID, domain.RequestTypeErasure)
+	if err != nil || request == nil {
+		return err
+	}
+
+	request.Status = domain.RequestStatusRunning
+	for _, source := range s.sources {
+		if request.StepDone(source.Name()) {
+			continue
+		}
+		details, err := source.ErasePersonalData(ctx, request.UserID)
+		if err != nil {
+			request.Error = fmt.Sprintf("%s: %v", source.Name(), err)
+			if saveErr := s.saveRequest(ctx, request); saveErr != nil {
+				utils.Logger.Error("PrivacyUsecase: Failed to save erasure progress", zap.String("request_id", requestID), zap.Error(saveErr))
+			}
+			return fmt.Errorf("failed to erase %s data: %w", source.Name(), err)
+		}
+		request.Steps = append(request.Steps, domain.StepResult{Source: source.Name(), Details: details, CompletedAt: time.Now()})
+		request.Error = ""
+		if err := s.saveRequest(ctx, request); err != nil {
+			return fmt.Errorf("failed to save erasure progress: %w", err)
+		}
+	}
+
+	if err := s.deleteExports(ctx, request.UserID); err != nil {
+		return err
+	}
+
+	completedAt := time.Now()
+	record, err := s.appendErasureRecord(ctx, request, completedAt)
+	if err != nil {
+		return err
+	}
+	// A retried job advances the anchor again; it never moves back.
+	if err := s.anchors.AdvanceAnchor(ctx, &domain.ErasureChainAnchor{Seq: record.Seq, Hash: record.Hash, UpdatedAt: time.Now()}); err != nil {
+		return fmt.Errorf("failed to anchor erasure record: %w", err)
+	}
+
+	request.Status = domain.RequestStatusCompleted
+	request.CompletedAt = &completedAt
+	if err := s.saveRequest(ctx, request); err != nil {
+		return fmt.Errorf("failed to save completed erasure: %w", err)
+	}
+
+	payload := event.UserErasedPayload{UserID: request.UserID.String(), RequestID: requestID, ErasedAt: completedAt}
+	if err := s.lowPub.Publish(ctx, string(event.UserErasedInMemoryEvent), payload); err != nil {
+		utils.Logger.Error("PrivacyUsecase: Failed to publish user erased event", zap.String("request_id", requestID), zap.Error(err))
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionPrivacyErasure, request, nil, map[string]interface{}{"stage": "completed", "record_seq": record.Seq, "record_hash": record.Hash})
+	utils.Logger.Info("PrivacyUsecase: Erasure completed", zap.String("request_id", requestID),
+		zap.String("user_id", request.UserID.String()), zap.Int64("record_seq", record.Seq))
+	return nil
+}
+
+func (s *PrivacyUsecase) deleteExports(ctx context.Context, userID userDomain.UserID) error {
+	fileIDs, err := s.requests.ExportFileIDs(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to find data exports: %w", err)
+	}
+	for _, fileID := range fileIDs {
+		if err := s.archives.Delete(ctx, fileID); err != nil {
+			return fmt.Errorf("failed to delete data export: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendErasureRecord links the record of a finished erasure to the end of the
+// chain. A retried job finds the record it already appended.
+func (s *PrivacyUsecase) appendErasureRecord(ctx context.Context, request *domain.PrivacyRequest, completedAt time.Time) (*domain.ErasureRecord, error) {
+	requestID := request.ID.Hex()
+	existing, err := s.records.GetByRequestID(ctx, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up erasure record: %w", err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	for attempt := 0; attempt < chainAppendAttempts; attempt++ {
+		last, err := s.records.Last(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load last erasure record: %w", err)
+		}
+		record := &domain.ErasureRecord{
+			Seq:         1,
+			RequestID:   requestID,
+			SubjectID:   request.UserID.String(),
+			Steps:       request.Steps,
+			CompletedAt: completedAt.UTC().Truncate(time.Millisecond),
+		}
+		if last != nil {
+			record.Seq = last.Seq + 1
+			record.PrevHash = last.Hash
+		}
+		if record.Hash, err = record.ComputeHash(); err != nil {
+			return nil, fmt.Errorf("failed to hash erasure record: %w", err)
+		}
+
+		err = s.records.Insert(ctx, record)
+		if err == nil {
+			return record, nil
+		}
+		if !errors.Is(err, domain.ErrDuplicateSeq) {
+			return nil, fmt.Errorf("failed to append erasure record: %w", err)
+		}
+		// The duplicate may be our own record, written by an attempt whose reply got lost.
+		if existing, getErr := s.records.GetByRequestID(ctx, requestID); getErr == nil && existing != nil {
+			return existing, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to append erasure record after %d attempts: %w", chainAppendAttempts, domain.ErrDuplicateSeq)
+}
+
+// VerifyErasureChain recomputes every erasure record hash and checks that each
+// record links to its predecessor, and that the chain reaches its anchor with the
+// anchored hash. Records appended after the anchor, whose job has yet to advance it,
+// only need to link up.
+func (s *PrivacyUsecase) VerifyErasureChain(ctx context.Context) (*domain.ChainVerification, error) {
+	anchor, err := s.anchors.GetAnchor(ctx)
+	if err != nil {
+		utils.Logger.Error("VerifyErasureChain: Failed to read erasure chain anchor", zap.Error(err))
+		return nil, fmt.Errorf("failed to verify erasure records: %w", err)
+	}
+	result := &domain.ChainVerification{Valid: true}
+	if anchor != nil {
+		result.AnchoredSeq = anchor.Seq
+	}
+	var prev *domain.ErasureRecord
+	err = s.records.Stream(ctx, func(record *domain.ErasureRecord) error {
+		result.Records++
+		reason := ""
+		switch {
+		case prev == nil && (record.Seq != 1 || record.PrevHash != ""):
+			reason = "chain does not start at sequence 1"
+		case prev != nil && record.Seq != prev.Seq+1:
+			reason = fmt.Sprintf("sequence gap after %d", prev.Seq)
+		case prev != nil && record.PrevHash != prev.Hash:
+			reason = "previous hash does not match"
+		}
+		if reason == "" {
+			hash, err := record.ComputeHash()
+			if err != nil {
+				return fmt.Errorf("failed to hash erasure record %d: %w", record.Seq, err)
+			}
+			switch {
+			case hash != record.Hash:
+				reason = "record hash does not match its content"
+			case anchor != nil && record.Seq == anchor.Seq && record.Hash != anchor.Hash:
+				reason = "record hash does not match the chain anchor"
+			}
+		}
+		if reason != "" {
+			seq := record.Seq
+			result.Valid = false
+			result.BrokenAt = &seq
+			result.Reason = reason
+			return errChainBroken
+		}
+		result.LastHash = record.Hash
+		prev = record
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		utils.Logger.Error("VerifyErasureChain: Failed to read erasure records", zap.Error(err))
+		return nil, fmt.Errorf("failed to verify erasure records: %w", err)
+	}
+	if result.Valid && result.Records < result.AnchoredSeq {
+		missing := result.Records + 1
+		result.Valid = false
+		result.BrokenAt = &missing
+		result.Reason = fmt.Sprintf("records after sequence %d are missing, the chain is anchored at %d", result.Records, result.AnchoredSeq)
+	}
+	if !result.Valid {
+		utils.Logger.Error("VerifyErasureChain: Erasure record chain is broken",
+			zap.Int64("broken_at", *result.BrokenAt), zap.String("reason", result.Reason))
+	}
+	return result, nil
+}
+
+// errChainBroken stops streaming the chain at the first record that fails.
+var errChainBroken = errors.New("erasure record chain is broken")
+
+// CleanupExpiredExports deletes the archives of expired data exports and returns
+// how many were removed.
+func (s *PrivacyUsecase) CleanupExpiredExports(ctx context.Context) (int, error) {
+	expired, err := s.requests.FindExpiredExports(ctx, time.Now(), cleanupBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired data exports: %w", err)
+	}
+	removed := 0
+	for i := range expired {
+		request := &expired[i]
+		if request.FileID != nil {
+			if err := s.archives.Delete(ctx, *request.FileID); err != nil {
+				return removed, fmt.Errorf("failed to delete expired data export: %w", err)
+			}
+		}
+		request.Status = domain.RequestStatusExpired
+		request.FileID = nil
+		if err := s.saveRequest(ctx, request); err != nil {
+			return removed, fmt.Errorf("failed to save expired data export: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// FailRequest marks a request as failed once its job gave up retrying.
+func (s *PrivacyUsecase) FailRequest(ctx context.Context, requestID string, cause error) {
+	id, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return
+	}
+	request, err := s.requests.GetByID(ctx, id)
+	if err != nil {
+		utils.Logger.Error("PrivacyUsecase: Failed to load request to fail", zap.String("request_id", requestID), zap.Error(err))
+		return
+	}
+	s.failRequest(ctx, request, cause)
+
+	action := auditDomain.ActionPrivacyExport
+	if request.Type == domain.RequestTypeErasure {
+		action = auditDomain.ActionPrivacyErasure
+	}
+	s.recordAudit(ctx, action, request, cause, map[string]interface{}{"stage": "failed"})
+}
+
+// loadForJob loads the request a job works on. It returns nil without an error when
+// there is nothing left to do, so that the job is not retried.
+func (s *PrivacyUsecase) loadForJob(ctx context.Context, requestID, requestType string) (*domain.PrivacyRequest, error) {
+	id, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		utils.Logger.Error("PrivacyUsecase: Invalid request ID in job", zap.String("request_id", requestID))
+		return nil, nil
+	}
+	request, err := s.requests.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrRequestNotFound) {
+			utils.Logger.Error("PrivacyUsecase: Request of job not found", zap.String("request_id", requestID))
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load privacy request: %w", err)
+	}
+	if request.Type != requestType || !request.IsActive() {
+		return nil, nil
+	}
+	return request, nil
+}
+
+func (s *PrivacyUsecase) saveRequest(ctx context.Context, request *domain.PrivacyRequest) error {
+	request.UpdatedAt = time.Now()
+	return s.requests.Save(ctx, request)
+}
+
+func (s *PrivacyUsecase) failRequest(ctx context.Context, request *domain.PrivacyRequest, cause error) {
+	now := time.Now()
+	request.Status = domain.RequestStatusFailed
+	request.Error = cause.Error()
+	request.CompletedAt = &now
+	if err := s.saveRequest(ctx, request); err != nil {
+		utils.Logger.Error("PrivacyUsecase: Failed to save failed request", zap.String("request_id", request.ID.Hex()), zap.Error(err))
+	}
+}
+
+func (s *PrivacyUsecase) recordAudit(ctx context.Context, action string, request *domain.PrivacyRequest, opErr error, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["request_id"] = request.ID.Hex()
+	entry := auditDomain.AuditEntry{
+		Action:     action,
+		TargetType: auditDomain.TargetTypeUser,
+		TargetID:   request.UserID.String(),
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
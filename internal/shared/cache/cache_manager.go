@@ -12,6 +12,9 @@ import (
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 )
 
+// ErrCacheMiss is returned by Get for a key that is not cached.
+var ErrCacheMiss = errors.New("cache key not found")
+
 type CacheManager struct {
 	client *redis.Client
 }
@@ -26,7 +29,6 @@ func (cm *CacheManager) Set(ctx context.Context, key string, value interface{},
 		utils.Logger.Error(
 			"CacheManager: Failed to set cache key",
 			zap.String("key", key),
-			zap.Any("value", value),
 			zap.Duration("expiration", expiration),
 			zap.Error(err),
 		)
@@ -36,11 +38,38 @@ func (cm *CacheManager) Set(ctx context.Context, key string, value interface{},
 	return nil
 }
 
+// setUnlessScript sets KEYS[1] to ARGV[1] for ARGV[2] milliseconds unless KEYS[2]
+// exists, and returns whether it did.
+var setUnlessScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// SetUnless sets key like Set unless guardKey exists, atomically, and reports
+// whether the value was set.
+func (cm *CacheManager) SetUnless(ctx context.Context, key string, value interface{}, expiration time.Duration, guardKey string) (bool, error) {
+	set, err := setUnlessScript.Run(ctx, cm.client, []string{key, guardKey}, value, expiration.Milliseconds()).Int()
+	if err != nil {
+		utils.Logger.Error(
+			"CacheManager: Failed to set cache key",
+			zap.String("key", key),
+			zap.Duration("expiration", expiration),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to set cache key '%s': %w", key, err)
+	}
+	utils.Logger.Debug("CacheManager: Conditional cache set", zap.String("key", key), zap.Bool("set", set == 1))
+	return set == 1, nil
+}
+
 func (cm *CacheManager) Get(ctx context.Context, key string) (string, error) {
 	val, err := cm.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		utils.Logger.Info("CacheManager: Cache key not found", zap.String("key", key))
-		return "", errors.New("cache key not found") // สามารถใช้ errors.New แทน fmt.Errorf ได้ถ้าไม่ต้องการ format
+		utils.Logger.Debug("CacheManager: Cache key not found", zap.String("key", key))
+		return "", ErrCacheMiss
 	}
 	if err != nil {
 		utils.Logger.Error(
@@ -67,3 +96,43 @@ func (cm *CacheManager) Delete(ctx context.Context, key string) error {
 	utils.Logger.Debug("CacheManager: Cache key deleted successfully", zap.String("key", key))
 	return nil
 }
+
+// Publish sends message to every subscriber of channel, on any instance.
+func (cm *CacheManager) Publish(ctx context.Context, channel, message string) error {
+	if err := cm.client.Publish(ctx, channel, message).Err(); err != nil {
+		utils.Logger.Error("CacheManager: Failed to publish message", zap.String("channel", channel), zap.Error(err))
+		return fmt.Errorf("failed to publish to '%s': %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe calls handle for every message published to channel until ctx is done.
+// It returns once the subscription is confirmed, so no message published after it
+// returns is missed. The client reconnects by itself; messages published while it
+// is disconnected are lost.
+func (cm *CacheManager) Subscribe(ctx context.Context, channel string, handle func(message string)) error {
+	pubsub := cm.client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to '%s': %w", channel, err)
+	}
+	utils.Logger.Info("CacheManager: Subscribed to channel", zap.String("channel", channel))
+
+	go func() {
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				utils.Logger.Info("CacheManager: Unsubscribed from channel", zap.String("channel", channel))
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				handle(msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
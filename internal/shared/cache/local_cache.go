@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// LocalCache is a small in-process cache in front of Redis. It holds serialized
+// values, so callers always decode a private copy. Entries expire after the TTL;
+// when the cache is full, expired entries are dropped first and then arbitrary ones.
+type LocalCache struct {
+	mu         sync.Mutex
+	entries    map[string]localEntry
+	ttl        time.Duration
+	maxEntries int
+}
+
+type localEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewLocalCache(ttl time.Duration, maxEntries int) *LocalCache {
+	return &LocalCache{
+		entries:    make(map[string]localEntry),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *LocalCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+func (c *LocalCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = localEntry{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *LocalCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// evict makes room for one entry. The caller holds the lock.
+func (c *LocalCache) evict() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			return
+		}
+		delete(c.entries, key)
+	}
+}
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/hibiken/asynq"
@@ -19,7 +20,12 @@ func NewAsynqClient(redisOpt asynq.RedisClientOpt) *AsynqClientImpl {
 }
 
 func (a *AsynqClientImpl) EnqueueTask(taskType string, payload interface{}) error {
-	task := asynq.NewTask(taskType, []byte(fmt.Sprintf("%v", payload)),
+	// Task handlers decode payloads with json.Unmarshal, so encode them the same way.
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("could not encode payload for task %s: %w", taskType, err)
+	}
+	task := asynq.NewTask(taskType, data,
 		asynq.Queue("critical"), asynq.MaxRetry(3))
 
 	info, err := a.Client.Enqueue(task)
@@ -0,0 +1,50 @@
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// AsynqScheduler enqueues periodic tasks for the AsynqWorker. Every API instance
+// runs one, so periodic tasks are registered as unique: only the first instance to
+// enqueue a task within the uniqueness window succeeds.
+type AsynqScheduler struct {
+	scheduler *asynq.Scheduler
+}
+
+func NewAsynqScheduler(redisOpt asynq.RedisClientOpt) *AsynqScheduler {
+	return &AsynqScheduler{
+		scheduler: asynq.NewScheduler(redisOpt, &asynq.SchedulerOpts{Location: time.UTC}),
+	}
+}
+
+// Register enqueues taskType on the cron schedule cronspec (e.g. "@daily" or
+// "0 3 * * *"). uniqueFor should be shorter than the schedule interval.
+func (s *AsynqScheduler) Register(cronspec, taskType string, uniqueFor time.Duration) error {
+	entryID, err := s.scheduler.Register(cronspec, asynq.NewTask(taskType, nil),
+		asynq.Queue("low"), asynq.Unique(uniqueFor), asynq.MaxRetry(3))
+	if err != nil {
+		return fmt.Errorf("failed to schedule task %s: %w", taskType, err)
+	}
+	utils.Logger.Info("Asynq scheduler: Registered periodic task",
+		zap.String("type", taskType), zap.String("cronspec", cronspec), zap.String("entry_id", entryID))
+	return nil
+}
+
+func (s *AsynqScheduler) Start() error {
+	if err := s.scheduler.Start(); err != nil {
+		return fmt.Errorf("failed to start Asynq scheduler: %w", err)
+	}
+	utils.Logger.Info("Asynq scheduler started.")
+	return nil
+}
+
+func (s *AsynqScheduler) Shutdown() {
+	s.scheduler.Shutdown()
+	utils.Logger.Info("Asynq scheduler stopped.")
+}
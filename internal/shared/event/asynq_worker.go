@@ -0,0 +1,52 @@
+package event
+
+import (
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// AsynqWorker processes the tasks enqueued through the HighImportancePublisher.
+// Modules register their handlers during setup; Start is called once all modules
+// are wired.
+type AsynqWorker struct {
+	server *asynq.Server
+	mux    *asynq.ServeMux
+}
+
+func NewAsynqWorker(redisOpt asynq.RedisClientOpt, concurrency int) *AsynqWorker {
+	server := asynq.NewServer(redisOpt, asynq.Config{
+		Concurrency: concurrency,
+		Queues: map[string]int{
+			"critical": 6,
+			"default":  3,
+			"low":      1,
+		},
+	})
+	return &AsynqWorker{
+		server: server,
+		mux:    asynq.NewServeMux(),
+	}
+}
+
+// HandleFunc registers handler for taskType.
+func (w *AsynqWorker) HandleFunc(taskType string, handler asynq.HandlerFunc) {
+	w.mux.HandleFunc(taskType, handler)
+	utils.Logger.Debug("Asynq worker: Registered task handler", zap.String("type", taskType))
+}
+
+func (w *AsynqWorker) Start() error {
+	if err := w.server.Start(w.mux); err != nil {
+		return fmt.Errorf("failed to start Asynq worker: %w", err)
+	}
+	utils.Logger.Info("Asynq worker started.")
+	return nil
+}
+
+func (w *AsynqWorker) Shutdown() {
+	w.server.Shutdown()
+	utils.Logger.Info("Asynq worker stopped.")
+}
@@ -3,8 +3,7 @@ package event
 
 import (
 	"context"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 // Topic represents an event topic/name
@@ -12,32 +11,104 @@ type Topic string
 
 // Define your in-memory event topics
 const (
-	UserCreatedInMemoryEvent = Topic("user.created.inmemory")
+	UserCreatedInMemoryEvent     = Topic("user.created.inmemory")
+	UserDeactivatedInMemoryEvent = Topic("user.deactivated.inmemory")
+	UserReactivatedInMemoryEvent = Topic("user.reactivated.inmemory")
+	UserErasedInMemoryEvent      = Topic("user.erased.inmemory")
+	// PreferencesChangedInMemoryEvent lets modules react to preference changes, e.g.
+	// notifications honouring a newly disabled channel.
+	PreferencesChangedInMemoryEvent = Topic("user.preferences_changed.inmemory")
 )
 
 // --- NEW --- Define Asynq Task Names
 const (
-	SendWelcomeEmailTaskName         = "user:send_welcome_email" // Define this task name
-	UserDeletedHighImportance string = "user:deleted_high_importance"
+	SendWelcomeEmailTaskName              = "user:send_welcome_email" // Define this task name
+	UserDeletedHighImportance      string = "user:deleted_high_importance"
+	NewDeviceLoginNotificationTask        = "auth:notify_new_device_login"
+	PurgeDeletedUsersTask                 = "user:purge_deleted"
+	ImportUsersTask                       = "user:import"
+	RecordUserHistoryTask                 = "user:record_history"
+	RepointUserMergesTask                 = "user:repoint_merges"
+	EmailChangeConfirmationTask           = "user:send_email_change_confirmation"
+	EmailChangeNoticeTask                 = "user:send_email_change_notice"
+	ExportUserDataTask                    = "privacy:export_user_data"
+	EraseUserTask                         = "privacy:erase_user"
+	CleanupDataExportsTask                = "privacy:cleanup_exports"
 )
 
 // --- END NEW ---
 
 // Define payloads for your in-memory events
 type UserCreatedPayload struct {
-	UserID primitive.ObjectID `json:"userId"`
-	Name   string             `json:"name"`
-	Email  string             `json:"email"`
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
 }
 
 type UserUpdatedPayload struct {
-	UserID primitive.ObjectID `json:"userId"`
-	Name   string             `json:"name,omitempty"`
-	Email  string             `json:"email,omitempty"`
+	UserID string `json:"userId"`
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
 }
 
 type UserDeletedPayload struct {
-	UserID primitive.ObjectID `json:"userId"`
+	UserID string `json:"userId"`
+}
+
+// UserActivationPayload is published when an admin deactivates or reactivates a user.
+type UserActivationPayload struct {
+	UserID    string    `json:"userId"`
+	ActorID   string    `json:"actorId"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// ImportUsersPayload starts a bulk import. The rows themselves stay in the import
+// store so that passwords never end up in task payloads or logs.
+type ImportUsersPayload struct {
+	JobID string `json:"job_id"`
+}
+
+// EmailChangeConfirmationPayload is sent to the new address of a pending email
+// change. ConfirmURL applies the change.
+type EmailChangeConfirmationPayload struct {
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	NewEmail   string    `json:"new_email"`
+	ConfirmURL string    `json:"confirm_url"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// EmailChangeNoticePayload warns the current address of a pending email change.
+// CancelURL lets the owner stop a change they did not ask for.
+type EmailChangeNoticePayload struct {
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	OldEmail  string `json:"old_email"`
+	NewEmail  string `json:"new_email"`
+	CancelURL string `json:"cancel_url"`
+}
+
+// UserErasedPayload is published once the personal data of a user was erased on
+// their request. Every module that keeps data about users outside of a privacy data
+// source (e.g. in caches) must drop it.
+type UserErasedPayload struct {
+	UserID    string    `json:"userId"`
+	RequestID string    `json:"requestId"`
+	ErasedAt  time.Time `json:"erasedAt"`
+}
+
+// PreferencesChangedPayload carries the new values of the preferences whose
+// effective value changed. A key reset to its default carries the default.
+type PreferencesChangedPayload struct {
+	UserID    string                 `json:"userId"`
+	Changes   map[string]interface{} `json:"changes"`
+	ChangedAt time.Time              `json:"changedAt"`
+}
+
+// PrivacyRequestPayload starts the job of a data export or erasure request.
+type PrivacyRequestPayload struct {
+	RequestID string `json:"request_id"`
 }
 
 // --- NEW --- Define Payload for SendWelcomeEmailTaskName
@@ -49,6 +120,20 @@ type SendWelcomeEmailPayload struct {
 
 // --- END NEW ---
 
+// NewDeviceLoginPayload is sent to the account owner when a login comes from a
+// device or network they have not used before. NotMeURL lets them revoke it.
+type NewDeviceLoginPayload struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	SessionID string    `json:"session_id"`
+	IP        string    `json:"ip"`
+	IPSubnet  string    `json:"ip_subnet"`
+	UserAgent string    `json:"user_agent"`
+	LoginAt   time.Time `json:"login_at"`
+	NotMeURL  string    `json:"not_me_url"`
+}
+
 // Unified Publisher interface: All publishers (in-memory, Asynq) will implement this.
 type Publisher interface {
 	Publish(ctx context.Context, topicOrTaskName string, payload interface{}) error
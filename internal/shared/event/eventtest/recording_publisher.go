@@ -0,0 +1,67 @@
+// Package eventtest provides test doubles for the event publishers.
+package eventtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+)
+
+// PublishedMessage is a message captured by a RecordingPublisher.
+type PublishedMessage struct {
+	Topic   string
+	Payload interface{}
+}
+
+// RecordingPublisher is an event.Publisher that records what is published instead
+// of delivering it. Setting Err makes every Publish fail with it; failed
+// messages are not recorded.
+type RecordingPublisher struct {
+	mu       sync.Mutex
+	messages []PublishedMessage
+	Err      error
+}
+
+func NewRecordingPublisher() *RecordingPublisher {
+	return &RecordingPublisher{}
+}
+
+func (p *RecordingPublisher) Publish(ctx context.Context, topicOrTaskName string, payload interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Err != nil {
+		return p.Err
+	}
+	p.messages = append(p.messages, PublishedMessage{Topic: topicOrTaskName, Payload: payload})
+	return nil
+}
+
+// Messages returns the recorded messages in publishing order.
+func (p *RecordingPublisher) Messages() []PublishedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PublishedMessage(nil), p.messages...)
+}
+
+// MessagesFor returns the recorded messages of one topic or task name.
+func (p *RecordingPublisher) MessagesFor(topicOrTaskName string) []PublishedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var matches []PublishedMessage
+	for _, m := range p.messages {
+		if m.Topic == topicOrTaskName {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// Reset forgets the recorded messages.
+func (p *RecordingPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = nil
+}
+
+var _ event.Publisher = (*RecordingPublisher)(nil)
@@ -26,6 +26,18 @@ func (p *LowImportancePublisher) Publish(ctx context.Context, topic string, payl
 		if _, ok := payload.(UserCreatedPayload); !ok {
 			return fmt.Errorf("invalid payload type for %s: %T", topic, payload)
 		}
+	case string(UserDeactivatedInMemoryEvent), string(UserReactivatedInMemoryEvent):
+		if _, ok := payload.(UserActivationPayload); !ok {
+			return fmt.Errorf("invalid payload type for %s: %T", topic, payload)
+		}
+	case string(UserErasedInMemoryEvent):
+		if _, ok := payload.(UserErasedPayload); !ok {
+			return fmt.Errorf("invalid payload type for %s: %T", topic, payload)
+		}
+	case string(PreferencesChangedInMemoryEvent):
+		if _, ok := payload.(PreferencesChangedPayload); !ok {
+			return fmt.Errorf("invalid payload type for %s: %T", topic, payload)
+		}
 	default:
 		return fmt.Errorf("unsupported in-memory event topic: %s", topic)
 	}
@@ -58,9 +70,7 @@ func (p *HighImportancePublisher) Publish(ctx context.Context, taskType string,
 	if err := p.asynqClient.EnqueueTask(taskType, payload); err != nil {
 		return fmt.Errorf("failed to enqueue Asynq task %s: %w", taskType, err)
 	}
-	utils.Logger.Info("Enqueued Asynq task",
-		zap.String("type", taskType),
-		zap.Any("payload", payload),
-	)
+	// Payloads are not logged: some carry links with single-use tokens.
+	utils.Logger.Info("Enqueued Asynq task", zap.String("type", taskType))
 	return nil
 }
@@ -29,5 +29,75 @@ func SendWelcomeEmailHandler(ctx context.Context, t *asynq.Task) error {
 	return nil
 }
 
+// SendNewDeviceLoginEmailHandler handles the 'auth:notify_new_device_login' task.
+func SendNewDeviceLoginEmailHandler(ctx context.Context, t *asynq.Task) error {
+	var payload NewDeviceLoginPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		log.Printf("ERROR: Failed to unmarshal NewDeviceLoginPayload: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %w", asynq.SkipRetry)
+	}
+
+	// The not-me link carries a session revocation token and is never logged.
+	log.Printf("Asynq Worker: Sending new-device login alert to %s for User ID: %s (ip=%s, agent=%q)\n",
+		payload.Email, payload.UserID, payload.IP, payload.UserAgent)
+
+	// Simulate email sending delay
+	time.Sleep(1 * time.Second)
+
+	log.Printf("Asynq Worker: New-device login alert sent successfully to %s.\n", payload.Email)
+	return nil
+}
+
+// SendEmailChangeConfirmationHandler handles the 'user:send_email_change_confirmation' task.
+func SendEmailChangeConfirmationHandler(ctx context.Context, t *asynq.Task) error {
+	var payload EmailChangeConfirmationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		log.Printf("ERROR: Failed to unmarshal EmailChangeConfirmationPayload: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %w", asynq.SkipRetry)
+	}
+
+	// The confirm link carries the token that applies the change and is never logged.
+	log.Printf("Asynq Worker: Sending email change confirmation to %s for User ID: %s (expires=%s)\n",
+		payload.NewEmail, payload.UserID, payload.ExpiresAt.Format(time.RFC3339))
+
+	// Simulate email sending delay
+	time.Sleep(1 * time.Second)
+
+	log.Printf("Asynq Worker: Email change confirmation sent successfully to %s.\n", payload.NewEmail)
+	return nil
+}
+
+// SendEmailChangeNoticeHandler handles the 'user:send_email_change_notice' task.
+func SendEmailChangeNoticeHandler(ctx context.Context, t *asynq.Task) error {
+	var payload EmailChangeNoticePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		log.Printf("ERROR: Failed to unmarshal EmailChangeNoticePayload: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %w", asynq.SkipRetry)
+	}
+
+	// Like the confirm link, the cancel link carries a token and is never logged.
+	log.Printf("Asynq Worker: Sending email change notice to %s for User ID: %s\n",
+		payload.OldEmail, payload.UserID)
+
+	// Simulate email sending delay
+	time.Sleep(1 * time.Second)
+
+	log.Printf("Asynq Worker: Email change notice sent successfully to %s.\n", payload.OldEmail)
+	return nil
+}
+
+// UserDeletedHandler handles the 'user:deleted_high_importance' task, published
+// once a user has been removed for good. Downstream cleanup hooks in here.
+func UserDeletedHandler(ctx context.Context, t *asynq.Task) error {
+	var payload UserDeletedPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		log.Printf("ERROR: Failed to unmarshal UserDeletedPayload: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %w", asynq.SkipRetry)
+	}
+
+	log.Printf("Asynq Worker: User %s was permanently deleted.\n", payload.UserID)
+	return nil
+}
+
 // You can add more Asynq task handlers here.
 // func ProcessPaymentHandler(ctx context.Context, t *asynq.Task) error { ... }
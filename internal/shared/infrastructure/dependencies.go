@@ -5,40 +5,69 @@ import (
 
 	"github.com/iots1/mingkwan-api/config"
 	"github.com/iots1/mingkwan-api/internal/shared/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/cache"
 	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type AppDependencies struct {
-	AppCtx         context.Context
-	DB             *mongo.Database
-	RedisClient    *redis.Client
-	LowPub         event.Publisher
-	HighPub        event.Publisher
-	InMemPubSub    *event.InMemPubSub
-	AppConfig      config.AppConfig
-	PasswordHasher adapters.PasswordHasher
+	AppCtx           context.Context
+	DB               *mongo.Database
+	Postgres         *pgxpool.Pool // Set only if users are stored in PostgreSQL
+	RedisClient      *redis.Client
+	CacheManager     *cache.CacheManager
+	LowPub           event.Publisher
+	HighPub          event.Publisher
+	InMemPubSub      *event.InMemPubSub
+	AppConfig        config.AppConfig
+	AuthCookieConfig config.AuthCookieConfig
+	OAuthConfig      config.OAuthConfig
+	UserRetention    config.UserRetentionConfig
+	UserStorage      config.UserStorageConfig
+	UserCache        config.UserCacheConfig
+	PasswordHasher   adapters.PasswordHasher
+	TaskWorker       *event.AsynqWorker
+	TaskScheduler    *event.AsynqScheduler
 }
 
 func NewAppDependencies(
 	ctx context.Context,
 	db *mongo.Database,
+	pg *pgxpool.Pool,
 	rdb *redis.Client,
+	cacheManager *cache.CacheManager,
 	lowPub event.Publisher,
 	highPub event.Publisher,
 	inMemPubSub *event.InMemPubSub,
 	appConfig config.AppConfig,
+	authCookieConfig config.AuthCookieConfig,
+	oauthConfig config.OAuthConfig,
+	userRetention config.UserRetentionConfig,
+	userStorage config.UserStorageConfig,
+	userCache config.UserCacheConfig,
 	passwordHasher adapters.PasswordHasher,
+	taskWorker *event.AsynqWorker,
+	taskScheduler *event.AsynqScheduler,
 ) AppDependencies {
 	return AppDependencies{
-		AppCtx:         ctx,
-		DB:             db,
-		RedisClient:    rdb,
-		LowPub:         lowPub,
-		HighPub:        highPub,
-		InMemPubSub:    inMemPubSub,
-		AppConfig:      appConfig,
-		PasswordHasher: passwordHasher,
+		AppCtx:           ctx,
+		DB:               db,
+		Postgres:         pg,
+		RedisClient:      rdb,
+		CacheManager:     cacheManager,
+		LowPub:           lowPub,
+		HighPub:          highPub,
+		InMemPubSub:      inMemPubSub,
+		AppConfig:        appConfig,
+		AuthCookieConfig: authCookieConfig,
+		OAuthConfig:      oauthConfig,
+		UserRetention:    userRetention,
+		UserStorage:      userStorage,
+		UserCache:        userCache,
+		PasswordHasher:   passwordHasher,
+		TaskWorker:       taskWorker,
+		TaskScheduler:    taskScheduler,
 	}
 }
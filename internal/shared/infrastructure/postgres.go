@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+type PostgresClient struct {
+	pool *pgxpool.Pool
+	url  string
+}
+
+func NewPostgresClient(url string) *PostgresClient {
+	return &PostgresClient{url: url}
+}
+
+func (pc *PostgresClient) Connect(ctx context.Context) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, pc.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Ping(pingCtx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+
+	pc.pool = pool
+	utils.Logger.Info("Successfully connected to PostgreSQL!")
+	return pool, nil
+}
+
+func (pc *PostgresClient) Disconnect() {
+	if pc.pool != nil {
+		pc.pool.Close()
+		utils.Logger.Info("Disconnected from PostgreSQL.")
+	}
+}
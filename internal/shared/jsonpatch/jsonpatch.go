@@ -0,0 +1,408 @@
+// Package jsonpatch applies RFC 7396 JSON Merge Patch and RFC 6902 JSON Patch
+// documents to JSON values.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+	// ErrInvalidPatch means the patch document itself is malformed.
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// ErrConflict means the patch is well-formed but cannot be applied to the
+	// document, e.g. a "test" operation failed or a path does not exist.
+	ErrConflict = errors.New("patch cannot be applied to the current document")
+)
+
+// IsSupported reports whether contentType (without parameters) is a patch format
+// this package applies.
+func IsSupported(contentType string) bool {
+	return contentType == MergePatchContentType || contentType == JSONPatchContentType
+}
+
+// Apply applies a patch in the given format to doc and returns the patched document.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("failed to decode document: %w", err)
+	}
+
+	var (
+		patched interface{}
+		err     error
+	)
+	switch contentType {
+	case MergePatchContentType:
+		var p interface{}
+		if err := json.Unmarshal(patch, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		patched = mergePatch(target, p)
+	case JSONPatchContentType:
+		ops, decodeErr := decodeOperations(patch)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		patched, err = applyOperations(target, ops)
+	default:
+		return nil, ErrUnsupportedMediaType
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(patched)
+}
+
+// TopLevelFields returns the names of the top-level members a patch may change, so
+// that callers can check them against an allowlist before applying it. A patch that
+// replaces the whole document reports the empty name "".
+func TopLevelFields(contentType string, patch []byte) ([]string, error) {
+	switch contentType {
+	case MergePatchContentType:
+		var p interface{}
+		if err := json.Unmarshal(patch, &p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		obj, ok := p.(map[string]interface{})
+		if !ok {
+			return []string{""}, nil
+		}
+		fields := make([]string, 0, len(obj))
+		for name := range obj {
+			fields = append(fields, name)
+		}
+		return fields, nil
+	case JSONPatchContentType:
+		ops, err := decodeOperations(patch)
+		if err != nil {
+			return nil, err
+		}
+		var fields []string
+		for _, op := range ops {
+			// test only reads the document
+			if op.Op == "test" {
+				continue
+			}
+			fields = append(fields, topLevel(op.Path))
+			// move removes its source, so the source must be modifiable too
+			if op.Op == "move" {
+				fields = append(fields, topLevel(op.From))
+			}
+		}
+		return fields, nil
+	default:
+		return nil, ErrUnsupportedMediaType
+	}
+}
+
+func topLevel(pointer string) string {
+	tokens, err := parsePointer(pointer)
+	if err != nil || len(tokens) == 0 {
+		return ""
+	}
+	return tokens[0]
+}
+
+// mergePatch implements the MergePatch algorithm of RFC 7396 section 2.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+	return t
+}
+
+type operation struct {
+	Op   string
+	Path string
+	From string
+	// Value is nil if the operation has no "value" member; a JSON null is kept as
+	// the raw "null".
+	Value json.RawMessage
+}
+
+func decodeOperations(patch []byte) ([]operation, error) {
+	// Members are decoded raw first: a *json.RawMessage field would read an explicit
+	// "value": null as a missing value.
+	var members []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	ops := make([]operation, len(members))
+	for i, m := range members {
+		for name, dst := range map[string]*string{"op": &ops[i].Op, "path": &ops[i].Path, "from": &ops[i].From} {
+			if raw, ok := m[name]; ok {
+				if err := json.Unmarshal(raw, dst); err != nil {
+					return nil, fmt.Errorf("%w: operation %d: %q must be a string", ErrInvalidPatch, i, name)
+				}
+			}
+		}
+		ops[i].Value = m["value"]
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d (%s) has no value", ErrInvalidPatch, i, op.Op)
+			}
+		case "remove":
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return ops, nil
+}
+
+// applyOperations applies the operations in order. The patch is atomic: an error
+// leaves the caller's document untouched because the result is simply discarded.
+func applyOperations(doc interface{}, ops []operation) (interface{}, error) {
+	var err error
+	for i, op := range ops {
+		path, _ := parsePointer(op.Path)
+		switch op.Op {
+		case "add":
+			doc, err = add(doc, path, decodeValue(op.Value))
+		case "remove":
+			doc, _, err = remove(doc, path)
+		case "replace":
+			if _, err = get(doc, path); err == nil {
+				doc, _, err = remove(doc, path)
+			}
+			if err == nil {
+				doc, err = add(doc, path, decodeValue(op.Value))
+			}
+		case "move":
+			from, _ := parsePointer(op.From)
+			if isProperPrefix(from, path) {
+				return nil, fmt.Errorf("%w: operation %d moves %s into itself", ErrConflict, i, op.From)
+			}
+			var value interface{}
+			if doc, value, err = remove(doc, from); err == nil {
+				doc, err = add(doc, path, value)
+			}
+		case "copy":
+			from, _ := parsePointer(op.From)
+			var value interface{}
+			if value, err = get(doc, from); err == nil {
+				doc, err = add(doc, path, deepCopy(value))
+			}
+		case "test":
+			var value interface{}
+			if value, err = get(doc, path); err == nil && !reflect.DeepEqual(value, decodeValue(op.Value)) {
+				err = fmt.Errorf("%w: test failed at %s", ErrConflict, op.Path)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func decodeValue(raw json.RawMessage) interface{} {
+	var v interface{}
+	_ = json.Unmarshal(raw, &v) // already validated as JSON by decodeOperations
+	return v
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses an array index token; max is the largest valid index.
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrConflict, token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx > max {
+		return 0, fmt.Errorf("%w: array index %q out of range", ErrConflict, token)
+	}
+	return idx, nil
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrConflict, token)
+			}
+			node = child
+		case []interface{}:
+			idx, err := arrayIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("%w: cannot descend into a scalar at %q", ErrConflict, token)
+		}
+	}
+	return node, nil
+}
+
+// add returns node with value added at path, following RFC 6902 section 4.1.
+func add(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q does not exist", ErrConflict, token)
+		}
+		updated, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = updated
+		return n, nil
+	case []interface{}:
+		if len(rest) == 0 {
+			idx := len(n)
+			if token != "-" {
+				var err error
+				if idx, err = arrayIndex(token, len(n)); err != nil {
+					return nil, err
+				}
+			}
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, nil
+		}
+		idx, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := add(n[idx], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot add below a scalar at %q", ErrConflict, token)
+	}
+}
+
+// remove returns node without the value at path, and the removed value.
+func remove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrConflict)
+	}
+	token, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %q does not exist", ErrConflict, token)
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, child, nil
+		}
+		updated, removed, err := remove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = updated
+		return n, removed, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[idx]
+			return append(n[:idx], n[idx+1:]...), removed, nil
+		}
+		updated, removed, err := remove(n[idx], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[idx] = updated
+		return n, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: cannot remove below a scalar at %q", ErrConflict, token)
+	}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(n))
+		for k, child := range n {
+			c[k] = deepCopy(child)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(n))
+		for i, child := range n {
+			c[i] = deepCopy(child)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,142 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestApply_MergePatch(t *testing.T) {
+	// Cases from RFC 7396 appendix A.
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "null removes member", doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{name: "null removes only its member", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "array replaced whole", doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "nested null", doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{name: "null in array kept", doc: `{"e":null}`, patch: `{"a":[null]}`, want: `{"e":null,"a":[null]}`},
+		{name: "null of missing member", doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+		{name: "non-object patch replaces document", doc: `{"a":"foo"}`, patch: `["c"]`, want: `["c"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(MergePatchContentType, []byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestApply_JSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{name: "add member", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, want: `{"foo":"bar","baz":"qux"}`},
+		{name: "add null value", doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":null}]`, want: `{"foo":"bar","baz":null}`},
+		{name: "replace with null", doc: `{"foo":"bar"}`, patch: `[{"op":"replace","path":"/foo","value":null}]`, want: `{"foo":null}`},
+		{name: "add array element", doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, want: `{"foo":["bar","qux","baz"]}`},
+		{name: "append to array", doc: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/-","value":"qux"}]`, want: `{"foo":["bar","qux"]}`},
+		{name: "remove member", doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, want: `{"foo":"bar"}`},
+		{name: "move member", doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "move array element", doc: `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`},
+		{name: "move into itself", doc: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a","path":"/a/c"}]`, wantErr: ErrConflict},
+		{name: "copy is deep", doc: `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			want:  `{"a":{"b":1},"c":{"b":2}}`},
+		{name: "test passes", doc: `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`},
+		{name: "test null passes", doc: `{"a":null}`, patch: `[{"op":"test","path":"/a","value":null}]`, want: `{"a":null}`},
+		{name: "test fails", doc: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`, wantErr: ErrConflict},
+		{name: "failed test discards earlier operations", doc: `{"a":1}`,
+			patch:   `[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":1}]`,
+			wantErr: ErrConflict},
+		{name: "escaped paths", doc: `{"a/b":1,"m~n":2}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"a/b":3}`},
+		{name: "escape decoded in order", doc: `{"~1":1}`, patch: `[{"op":"test","path":"/~01","value":1}]`, want: `{"~1":1}`},
+		{name: "missing value", doc: `{}`, patch: `[{"op":"add","path":"/a"}]`, wantErr: ErrInvalidPatch},
+		{name: "unknown op", doc: `{}`, patch: `[{"op":"frobnicate","path":"/a"}]`, wantErr: ErrInvalidPatch},
+		{name: "invalid pointer", doc: `{}`, patch: `[{"op":"add","path":"a","value":1}]`, wantErr: ErrInvalidPatch},
+		{name: "remove missing member", doc: `{}`, patch: `[{"op":"remove","path":"/a"}]`, wantErr: ErrConflict},
+		{name: "leading zero index", doc: `{"a":[1,2]}`, patch: `[{"op":"remove","path":"/a/01"}]`, wantErr: ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(JSONPatchContentType, []byte(tt.doc), []byte(tt.patch))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				assertJSONEqual(t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopLevelFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		want        []string
+	}{
+		{name: "merge patch members", contentType: MergePatchContentType, patch: `{"name":"x","metadata":{"a":null}}`, want: []string{"metadata", "name"}},
+		{name: "merge patch replacing document", contentType: MergePatchContentType, patch: `"x"`, want: []string{""}},
+		{name: "json patch paths", contentType: JSONPatchContentType, patch: `[{"op":"replace","path":"/name","value":null}]`, want: []string{"name"}},
+		{name: "test ops skipped", contentType: JSONPatchContentType,
+			patch: `[{"op":"test","path":"/roles","value":["user"]},{"op":"replace","path":"/name","value":"x"}]`,
+			want:  []string{"name"}},
+		{name: "move source included", contentType: JSONPatchContentType, patch: `[{"op":"move","from":"/phone","path":"/name"}]`, want: []string{"name", "phone"}},
+		{name: "copy source excluded", contentType: JSONPatchContentType, patch: `[{"op":"copy","from":"/phone","path":"/name"}]`, want: []string{"name"}},
+		{name: "escaped path", contentType: JSONPatchContentType, patch: `[{"op":"add","path":"/metadata~1x/y","value":1}]`, want: []string{"metadata/x"}},
+		{name: "whole document", contentType: JSONPatchContentType, patch: `[{"op":"replace","path":"","value":{}}]`, want: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TopLevelFields(tt.contentType, []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("TopLevelFields: %v", err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("TopLevelFields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want is not JSON: %v", err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("result = %s, want %s", got, want)
+	}
+}
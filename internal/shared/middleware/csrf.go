@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const (
+	CSRFCookieName = "mk_csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRFProtection implements the double-submit cookie pattern for cookie-authenticated
+// requests: unsafe methods must echo the CSRF cookie in the X-CSRF-Token header.
+// Requests that carry none of sessionCookies (e.g. bearer-token API clients) are not
+// subject to CSRF and pass through untouched.
+func CSRFProtection(sessionCookies ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+			return c.Next()
+		}
+
+		usesCookieSession := false
+		for _, name := range sessionCookies {
+			if c.Cookies(name) != "" {
+				usesCookieSession = true
+				break
+			}
+		}
+		if !usesCookieSession {
+			return c.Next()
+		}
+
+		cookieToken := c.Cookies(CSRFCookieName)
+		headerToken := c.Get(CSRFHeaderName)
+		if cookieToken == "" || headerToken == "" ||
+			subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 {
+			utils.Logger.Warn("CSRF: Token missing or mismatched",
+				zap.String("method", c.Method()), zap.String("path", c.Path()), zap.String("ip", c.IP()))
+			return c.Status(fiber.StatusForbidden).JSON(sharedModel.CommonErrorResponse{
+				Success:   false,
+				Timestamp: time.Now().UTC(),
+				Message:   "Missing or invalid CSRF token",
+				Code:      fiber.StatusForbidden * 1000,
+				Method:    c.Method(),
+				Path:      c.Path(),
+			})
+		}
+		return c.Next()
+	}
+}
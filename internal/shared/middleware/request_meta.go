@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const CorrelationIDHeader = "X-Request-ID"
+
+// RequestMeta attaches a utils.RequestMeta to every request so that use cases can
+// record the caller's IP, user agent and correlation ID. An incoming X-Request-ID
+// header is reused, otherwise a new ID is generated and echoed back.
+func RequestMeta() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		correlationID := c.Get(CorrelationIDHeader)
+		if correlationID == "" {
+			correlationID = uuid.NewString()
+		}
+		c.Set(CorrelationIDHeader, correlationID)
+
+		c.Locals(utils.RequestMetaKey, &utils.RequestMeta{
+			IP:            c.IP(),
+			UserAgent:     c.Get(fiber.HeaderUserAgent),
+			CorrelationID: correlationID,
+		})
+		return c.Next()
+	}
+}
+
+// GetRequestMeta returns the mutable request metadata for c, creating it if the
+// RequestMeta middleware has not run.
+func GetRequestMeta(c *fiber.Ctx) *utils.RequestMeta {
+	if meta, ok := c.Locals(utils.RequestMetaKey).(*utils.RequestMeta); ok && meta != nil {
+		return meta
+	}
+	meta := &utils.RequestMeta{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+	c.Locals(utils.RequestMetaKey, meta)
+	return meta
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// StepUpMaxAge is how long a login or re-authentication counts as recent for
+// sensitive operations such as deleting an account or changing an email address.
+const StepUpMaxAge = 5 * time.Minute
+
+// HasRecentAuth reports whether the authenticated user proved their identity within
+// maxAge. It must be used after the auth middleware has populated meta.
+func HasRecentAuth(meta *utils.RequestMeta, maxAge time.Duration) bool {
+	return !meta.AuthTime.IsZero() && time.Since(meta.AuthTime) <= maxAge
+}
+
+// SendStepUpRequired answers 401 with the RFC 9470 step-up challenge, telling the
+// client to call POST /auth/reauthenticate and retry with the elevated token.
+func SendStepUpRequired(c *fiber.Ctx, maxAge time.Duration) error {
+	c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(
+		`Bearer error="insufficient_user_authentication", error_description="A recent authentication is required", max_age=%d`,
+		int(maxAge.Seconds())))
+	return c.Status(fiber.StatusUnauthorized).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   "Recent authentication required",
+		Code:      fiber.StatusUnauthorized * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
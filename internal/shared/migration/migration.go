@@ -0,0 +1,88 @@
+// Package migration evolves the MongoDB schema: versioned data migrations that run
+// on demand (the migrate subcommand) and index declarations that are ensured at
+// every startup.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration changes documents from one schema version to the next. Versions are
+// global across modules and ordered numerically; use the date and a sequence number,
+// e.g. 2025101801. MongoDB cannot run them in a transaction on every deployment, so
+// Up and Down must be safe to run again after a partial failure.
+type Migration struct {
+	Version     int64
+	Module      string
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	// Down reverts Up. A nil Down makes the migration irreversible.
+	Down func(ctx context.Context, db *mongo.Database) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%d %s: %s", m.Version, m.Module, m.Description)
+}
+
+// Registry collects the migrations of all modules.
+type Registry struct {
+	migrations map[int64]Migration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{migrations: make(map[int64]Migration)}
+}
+
+// Register adds migrations. Two migrations with the same version are a programming
+// error and panic at startup rather than running in an undefined order.
+func (r *Registry) Register(migrations ...Migration) {
+	for _, m := range migrations {
+		if m.Version <= 0 || m.Up == nil {
+			panic(fmt.Sprintf("migration: %s migration %d needs a positive version and an Up function", m.Module, m.Version))
+		}
+		if existing, ok := r.migrations[m.Version]; ok {
+			panic(fmt.Sprintf("migration: version %d is registered by both %s and %s", m.Version, existing.Module, m.Module))
+		}
+		r.migrations[m.Version] = m
+	}
+}
+
+// Migrations returns the registered migrations in version order.
+func (r *Registry) Migrations() []Migration {
+	list := make([]Migration, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// IndexSpec declares the indexes a module needs on one collection.
+type IndexSpec struct {
+	Collection string
+	Indexes    []mongo.IndexModel
+}
+
+// EnsureIndexes creates the declared indexes. Creating an index that already exists
+// with the same definition is a no-op, so it runs at every startup. It tries every
+// collection and returns the errors together.
+func EnsureIndexes(ctx context.Context, db *mongo.Database, specs []IndexSpec) error {
+	var failed []error
+	for _, spec := range specs {
+		if len(spec.Indexes) == 0 {
+			continue
+		}
+		if _, err := db.Collection(spec.Collection).Indexes().CreateMany(ctx, spec.Indexes); err != nil {
+			failed = append(failed, fmt.Errorf("collection %s: %w", spec.Collection, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to ensure indexes: %w", errors.Join(failed...))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+const (
+	// lockID is the _id of the lock document; applied migrations use their version.
+	lockID = "lock"
+	// lockTTL lets another process take over the lock of a migrator that died. The
+	// lock is renewed before every migration, so it bounds a single migration.
+	lockTTL = 15 * time.Minute
+)
+
+var (
+	ErrLocked       = errors.New("migrations are locked by another process")
+	ErrIrreversible = errors.New("migration cannot be reverted")
+)
+
+// appliedMigration is the record of an applied migration.
+type appliedMigration struct {
+	Version     int64     `bson:"_id"`
+	Module      string    `bson:"module"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+	DurationMS  int64     `bson:"duration_ms"`
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Version     int64      `json:"version"`
+	Module      string     `json:"module"`
+	Description string     `json:"description"`
+	Applied     bool       `json:"applied"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Reversible  bool       `json:"reversible"`
+	// Unknown marks an applied migration that is no longer registered.
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// Migrator applies and reverts the registered migrations. Applied versions and the
+// lock that keeps two processes from migrating at once live in one collection.
+type Migrator struct {
+	db       *mongo.Database
+	store    store
+	registry *Registry
+	owner    string
+}
+
+func NewMigrator(db *mongo.Database, registry *Registry, collectionName string) *Migrator {
+	host, _ := os.Hostname()
+	return newMigrator(db, registry, &mongoStore{collection: db.Collection(collectionName)}, fmt.Sprintf("%s/%d", host, os.Getpid()))
+}
+
+func newMigrator(db *mongo.Database, registry *Registry, store store, owner string) *Migrator {
+	return &Migrator{db: db, store: store, registry: registry, owner: owner}
+}
+
+// Status lists every registered or applied migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.registry.Migrations() {
+		status := Status{
+			Version:     migration.Version,
+			Module:      migration.Module,
+			Description: migration.Description,
+			Reversible:  migration.Down != nil,
+		}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{
+			Version:     record.Version,
+			Module:      record.Module,
+			Description: record.Description,
+			Applied:     true,
+			AppliedAt:   &appliedAt,
+			Unknown:     true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending returns the registered migrations that have not been applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.registry.Migrations() {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations up to and including target, or all of them if
+// target is 0. It stops at the first failure and returns what was applied before it.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		if target > 0 && migration.Version > target {
+			break
+		}
+		if err := m.lock(ctx); err != nil {
+			return done, err
+		}
+
+		utils.Logger.Info("Migrator: Applying migration", zap.Int64("version", migration.Version),
+			zap.String("module", migration.Module), zap.String("description", migration.Description))
+		started := time.Now()
+		if err := migration.Up(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		record := appliedMigration{
+			Version:     migration.Version,
+			Module:      migration.Module,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+			DurationMS:  time.Since(started).Milliseconds(),
+		}
+		if err := m.store.Record(ctx, record); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	registered := make(map[int64]Migration)
+	for _, migration := range m.registry.Migrations() {
+		registered[migration.Version] = migration
+	}
+
+	var done []Migration
+	for _, version := range versions {
+		if len(done) == steps {
+			break
+		}
+		migration, ok := registered[version]
+		if !ok || migration.Down == nil {
+			return done, fmt.Errorf("migration %d: %w", version, ErrIrreversible)
+		}
+		if err := m.lock(ctx); err != nil {
+			return done, err
+		}
+
+		utils.Logger.Info("Migrator: Reverting migration", zap.Int64("version", migration.Version),
+			zap.String("module", migration.Module), zap.String("description", migration.Description))
+		if err := migration.Down(ctx, m.db); err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		if err := m.store.Remove(ctx, version); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	return m.store.Applied(ctx)
+}
+
+func (m *Migrator) lock(ctx context.Context) error {
+	return m.store.Lock(ctx, m.owner)
+}
+
+func (m *Migrator) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.store.Unlock(ctx, m.owner); err != nil {
+		utils.Logger.Error("Migrator: Failed to release migration lock", zap.Error(err))
+	}
+}
@@ -0,0 +1,330 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// memoryStore is a store kept in memory, so the migrator can be tested without a
+// database.
+type memoryStore struct {
+	mu          sync.Mutex
+	applied     map[int64]appliedMigration
+	lockOwner   string
+	lockExpires time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{applied: make(map[int64]appliedMigration)}
+}
+
+func (s *memoryStore) Applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	applied := make(map[int64]appliedMigration, len(s.applied))
+	for version, record := range s.applied {
+		applied[version] = record
+	}
+	return applied, nil
+}
+
+func (s *memoryStore) Record(ctx context.Context, record appliedMigration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.applied[record.Version]; ok {
+		return fmt.Errorf("migration %d is already recorded", record.Version)
+	}
+	s.applied[record.Version] = record
+	return nil
+}
+
+func (s *memoryStore) Remove(ctx context.Context, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.applied, version)
+	return nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.lockOwner != "" && s.lockOwner != owner && s.lockExpires.After(now) {
+		return fmt.Errorf("%w: held by %s", ErrLocked, s.lockOwner)
+	}
+	s.lockOwner, s.lockExpires = owner, now.Add(lockTTL)
+	return nil
+}
+
+func (s *memoryStore) Unlock(ctx context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lockOwner == owner {
+		s.lockOwner = ""
+	}
+	return nil
+}
+
+var _ store = (*memoryStore)(nil)
+
+// migratorBackend creates a migrator for owner over registry. Migrators created by
+// one backend within a test share their store.
+type migratorBackend func(t *testing.T) func(registry *Registry, owner string) *Migrator
+
+func TestMigrator_Memory(t *testing.T) {
+	testMigrator(t, func(t *testing.T) func(*Registry, string) *Migrator {
+		s := newMemoryStore()
+		return func(registry *Registry, owner string) *Migrator {
+			return newMigrator(nil, registry, s, owner)
+		}
+	})
+}
+
+// TestMigrator_Mongo runs the same tests with the state kept in MongoDB. Set
+// MONGO_TEST_URI to run it; every subtest uses a throwaway database.
+func TestMigrator_Mongo(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	n := 0
+	testMigrator(t, func(t *testing.T) func(*Registry, string) *Migrator {
+		n++
+		db := client.Database(fmt.Sprintf("mingkwan_migration_test_%d_%d", os.Getpid(), n))
+		t.Cleanup(func() { db.Drop(context.Background()) })
+		s := &mongoStore{collection: db.Collection("migrations")}
+		return func(registry *Registry, owner string) *Migrator {
+			return newMigrator(db, registry, s, owner)
+		}
+	})
+}
+
+// migrationLog records the order in which test migrations run.
+type migrationLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *migrationLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+func (l *migrationLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	steps := l.steps
+	l.steps = nil
+	return steps
+}
+
+// testMigration returns a reversible migration that logs its runs. Up fails while
+// *failUp is true.
+func testMigration(log *migrationLog, version int64, failUp *bool) Migration {
+	return Migration{
+		Version:     version,
+		Module:      "test",
+		Description: fmt.Sprintf("step %d", version),
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if failUp != nil && *failUp {
+				return errors.New("boom")
+			}
+			log.add(fmt.Sprintf("up %d", version))
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			log.add(fmt.Sprintf("down %d", version))
+			return nil
+		},
+	}
+}
+
+func versions(migrations []Migration) []int64 {
+	list := make([]int64, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, m.Version)
+	}
+	return list
+}
+
+func testMigrator(t *testing.T, backend migratorBackend) {
+	ctx := context.Background()
+
+	t.Run("up applies in version order up to the target", func(t *testing.T) {
+		newM := backend(t)
+		log := &migrationLog{}
+		registry := NewRegistry()
+		// Registered out of order on purpose.
+		registry.Register(testMigration(log, 3, nil), testMigration(log, 1, nil), testMigration(log, 2, nil))
+		m := newM(registry, "a")
+
+		applied, err := m.Up(ctx, 2)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		if got := versions(applied); !slices.Equal(got, []int64{1, 2}) {
+			t.Errorf("applied = %v, want [1 2]", got)
+		}
+		if got := log.take(); !slices.Equal(got, []string{"up 1", "up 2"}) {
+			t.Errorf("ran %v", got)
+		}
+
+		applied, err = m.Up(ctx, 0)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		if got := versions(applied); !slices.Equal(got, []int64{3}) {
+			t.Errorf("applied = %v, want [3]", got)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		for _, s := range statuses {
+			if !s.Applied {
+				t.Errorf("migration %d not applied", s.Version)
+			}
+		}
+	})
+
+	t.Run("down reverts newest first", func(t *testing.T) {
+		newM := backend(t)
+		log := &migrationLog{}
+		registry := NewRegistry()
+		registry.Register(testMigration(log, 1, nil), testMigration(log, 2, nil), testMigration(log, 3, nil))
+		m := newM(registry, "a")
+		if _, err := m.Up(ctx, 0); err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		log.take()
+
+		reverted, err := m.Down(ctx, 2)
+		if err != nil {
+			t.Fatalf("Down: %v", err)
+		}
+		if got := versions(reverted); !slices.Equal(got, []int64{3, 2}) {
+			t.Errorf("reverted = %v, want [3 2]", got)
+		}
+		if got := log.take(); !slices.Equal(got, []string{"down 3", "down 2"}) {
+			t.Errorf("ran %v", got)
+		}
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			t.Fatalf("Pending: %v", err)
+		}
+		if got := versions(pending); !slices.Equal(got, []int64{2, 3}) {
+			t.Errorf("pending = %v, want [2 3]", got)
+		}
+	})
+
+	t.Run("down stops at an irreversible migration", func(t *testing.T) {
+		newM := backend(t)
+		log := &migrationLog{}
+		irreversible := testMigration(log, 1, nil)
+		irreversible.Down = nil
+		registry := NewRegistry()
+		registry.Register(irreversible, testMigration(log, 2, nil))
+		m := newM(registry, "a")
+		if _, err := m.Up(ctx, 0); err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+
+		reverted, err := m.Down(ctx, 2)
+		if !errors.Is(err, ErrIrreversible) {
+			t.Fatalf("Down error = %v, want ErrIrreversible", err)
+		}
+		if got := versions(reverted); !slices.Equal(got, []int64{2}) {
+			t.Errorf("reverted = %v, want [2]", got)
+		}
+	})
+
+	t.Run("up resumes after a failed step", func(t *testing.T) {
+		newM := backend(t)
+		log := &migrationLog{}
+		fail := true
+		registry := NewRegistry()
+		registry.Register(testMigration(log, 1, nil), testMigration(log, 2, &fail), testMigration(log, 3, nil))
+		m := newM(registry, "a")
+
+		applied, err := m.Up(ctx, 0)
+		if err == nil {
+			t.Fatal("Up succeeded despite a failing migration")
+		}
+		if got := versions(applied); !slices.Equal(got, []int64{1}) {
+			t.Errorf("applied = %v, want [1]", got)
+		}
+		if got := log.take(); !slices.Equal(got, []string{"up 1"}) {
+			t.Errorf("ran %v", got)
+		}
+
+		fail = false
+		applied, err = m.Up(ctx, 0)
+		if err != nil {
+			t.Fatalf("Up: %v", err)
+		}
+		if got := versions(applied); !slices.Equal(got, []int64{2, 3}) {
+			t.Errorf("applied = %v, want [2 3]", got)
+		}
+		if got := log.take(); !slices.Equal(got, []string{"up 2", "up 3"}) {
+			t.Errorf("ran %v", got)
+		}
+	})
+
+	t.Run("lock keeps a second migrator out until released", func(t *testing.T) {
+		newM := backend(t)
+		log := &migrationLog{}
+		registry := NewRegistry()
+		registry.Register(testMigration(log, 1, nil))
+		holder := newM(registry, "holder")
+		other := newM(registry, "other")
+
+		if err := holder.lock(ctx); err != nil {
+			t.Fatalf("lock: %v", err)
+		}
+		// The holder renews its own lock.
+		if err := holder.lock(ctx); err != nil {
+			t.Fatalf("renewing lock: %v", err)
+		}
+		if _, err := other.Up(ctx, 0); !errors.Is(err, ErrLocked) {
+			t.Fatalf("Up while locked error = %v, want ErrLocked", err)
+		}
+		if _, err := other.Down(ctx, 1); !errors.Is(err, ErrLocked) {
+			t.Fatalf("Down while locked error = %v, want ErrLocked", err)
+		}
+		if got := log.take(); len(got) != 0 {
+			t.Errorf("ran %v while locked", got)
+		}
+
+		holder.unlock()
+		applied, err := other.Up(ctx, 0)
+		if err != nil {
+			t.Fatalf("Up after unlock: %v", err)
+		}
+		if got := versions(applied); !slices.Equal(got, []int64{1}) {
+			t.Errorf("applied = %v, want [1]", got)
+		}
+		// Up releases the lock when it is done.
+		if err := holder.lock(ctx); err != nil {
+			t.Errorf("lock after Up: %v", err)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// SQLMigration changes the PostgreSQL schema. Versions follow the same convention as
+// Migration. Up and Down each run in one transaction together with the record of the
+// migration, so a failed migration leaves nothing behind.
+type SQLMigration struct {
+	Version     int64
+	Module      string
+	Description string
+	Up          string
+	// Down reverts Up. An empty Down makes the migration irreversible.
+	Down string
+}
+
+func (m SQLMigration) String() string {
+	return fmt.Sprintf("%d %s: %s", m.Version, m.Module, m.Description)
+}
+
+// sqlFileName matches migration files, e.g. 2025102001_create_users.up.sql.
+var sqlFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// LoadSQLMigrations reads the migrations of module from the .sql files in the root
+// of fsys. Every version needs an up file; the down file is optional.
+func LoadSQLMigrations(fsys fs.FS, module string) ([]SQLMigration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s migrations: %w", module, err)
+	}
+	byVersion := make(map[int64]*SQLMigration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := sqlFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s migration %s: name must be <version>_<description>.(up|down).sql", module, entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s migration %s: invalid version", module, entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s migration %s: %w", module, entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			description := strings.ReplaceAll(match[2], "_", " ")
+			m = &SQLMigration{Version: version, Module: module, Description: strings.ToUpper(description[:1]) + description[1:]}
+			byVersion[version] = m
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]SQLMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("%s migration %d has no up file", module, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// SQLMigrator applies and reverts SQL migrations. Applied versions are recorded in
+// a table; a session-level advisory lock keeps two processes from migrating at once.
+type SQLMigrator struct {
+	pool       *pgxpool.Pool
+	migrations []SQLMigration
+	table      string
+	lockKey    int64
+}
+
+// NewSQLMigrator panics on duplicate versions, like Registry.Register.
+func NewSQLMigrator(pool *pgxpool.Pool, migrations []SQLMigration, table string) *SQLMigrator {
+	sorted := make([]SQLMigration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			panic(fmt.Sprintf("migration: SQL version %d is registered by both %s and %s", sorted[i].Version, sorted[i-1].Module, sorted[i].Module))
+		}
+	}
+	h := fnv.New64a()
+	h.Write([]byte("migrations:" + table))
+	return &SQLMigrator{pool: pool, migrations: sorted, table: table, lockKey: int64(h.Sum64())}
+}
+
+// Status lists every known or applied migration in version order.
+func (m *SQLMigrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.pool)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{
+			Version:     migration.Version,
+			Module:      migration.Module,
+			Description: migration.Description,
+			Reversible:  strings.TrimSpace(migration.Down) != "",
+		}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{
+			Version:     record.Version,
+			Module:      record.Module,
+			Description: record.Description,
+			Applied:     true,
+			AppliedAt:   &appliedAt,
+			Unknown:     true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied.
+func (m *SQLMigrator) Pending(ctx context.Context) ([]SQLMigration, error) {
+	applied, err := m.applied(ctx, m.pool)
+	if err != nil {
+		return nil, err
+	}
+	var pending []SQLMigration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the pending migrations up to and including target, or all of them if
+// target is 0. It stops at the first failure and returns what was applied before it.
+func (m *SQLMigrator) Up(ctx context.Context, target int64) ([]SQLMigration, error) {
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.unlock(conn)
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []SQLMigration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if target > 0 && migration.Version > target {
+			break
+		}
+		utils.Logger.Info("SQLMigrator: Applying migration", zap.Int64("version", migration.Version),
+			zap.String("module", migration.Module), zap.String("description", migration.Description))
+		started := time.Now()
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO "+m.tableName()+" (version, module, description, applied_at, duration_ms) VALUES ($1, $2, $3, $4, $5)",
+				migration.Version, migration.Module, migration.Description, time.Now().UTC(), time.Since(started).Milliseconds())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *SQLMigrator) Down(ctx context.Context, steps int) ([]SQLMigration, error) {
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.unlock(conn)
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	known := make(map[int64]SQLMigration)
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	var done []SQLMigration
+	for _, version := range versions {
+		if len(done) == steps {
+			break
+		}
+		migration, ok := known[version]
+		if !ok || strings.TrimSpace(migration.Down) == "" {
+			return done, fmt.Errorf("migration %d: %w", version, ErrIrreversible)
+		}
+		utils.Logger.Info("SQLMigrator: Reverting migration", zap.Int64("version", migration.Version),
+			zap.String("module", migration.Module), zap.String("description", migration.Description))
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, migration.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM "+m.tableName()+" WHERE version = $1", version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+type appliedSQLMigration struct {
+	Version     int64
+	Module      string
+	Description string
+	AppliedAt   time.Time
+}
+
+// dbtx is satisfied by both the pool and a single acquired connection.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (m *SQLMigrator) tableName() string {
+	return pgx.Identifier{m.table}.Sanitize()
+}
+
+// applied reads the applied migrations, creating the table on first use.
+func (m *SQLMigrator) applied(ctx context.Context, db dbtx) (map[int64]appliedSQLMigration, error) {
+	_, err := db.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+m.tableName()+` (
+		version     BIGINT PRIMARY KEY,
+		module      TEXT NOT NULL,
+		description TEXT NOT NULL,
+		applied_at  TIMESTAMPTZ NOT NULL,
+		duration_ms BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	rows, err := db.Query(ctx, "SELECT version, module, description, applied_at FROM "+m.tableName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	records, err := pgx.CollectRows(rows, pgx.RowToStructByPos[appliedSQLMigration])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	applied := make(map[int64]appliedSQLMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// lock takes the advisory lock on a dedicated connection, which the caller must
+// release with unlock. It does not wait for another process to finish.
+func (m *SQLMigrator) lock(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", m.lockKey).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, ErrLocked
+	}
+	return conn, nil
+}
+
+func (m *SQLMigrator) unlock(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", m.lockKey); err != nil {
+		// Closing the connection ends the session and so releases the lock.
+		utils.Logger.Error("SQLMigrator: Failed to release migration lock", zap.Error(err))
+		if err := conn.Conn().Close(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			utils.Logger.Warn("SQLMigrator: Failed to close connection", zap.Error(err))
+		}
+	}
+	conn.Release()
+}
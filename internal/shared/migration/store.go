@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// store keeps the applied migrations and the migration lock.
+type store interface {
+	Applied(ctx context.Context) (map[int64]appliedMigration, error)
+	Record(ctx context.Context, record appliedMigration) error
+	Remove(ctx context.Context, version int64) error
+	// Lock takes the lock for owner, or renews it if owner already holds it. An
+	// expired lock of another owner is taken over.
+	Lock(ctx context.Context, owner string) error
+	Unlock(ctx context.Context, owner string) error
+}
+
+// mongoStore keeps applied versions and the lock in one collection. Applied
+// migrations use their version as _id and the lock uses lockID.
+type mongoStore struct {
+	collection *mongo.Collection
+}
+
+func (s *mongoStore) Applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"_id": bson.M{"$type": "long"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int64]appliedMigration)
+	for cursor.Next(ctx) {
+		var record appliedMigration
+		if err := cursor.Decode(&record); err != nil {
+			return nil, fmt.Errorf("failed to decode applied migration: %w", err)
+		}
+		applied[record.Version] = record
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+func (s *mongoStore) Record(ctx context.Context, record appliedMigration) error {
+	if _, err := s.collection.InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", record.Version, err)
+	}
+	return nil
+}
+
+func (s *mongoStore) Remove(ctx context.Context, version int64) error {
+	if _, err := s.collection.DeleteOne(ctx, bson.M{"_id": version}); err != nil {
+		return fmt.Errorf("failed to remove record of migration %d: %w", version, err)
+	}
+	return nil
+}
+
+func (s *mongoStore) Lock(ctx context.Context, owner string) error {
+	now := time.Now()
+	lockDoc := bson.M{"owner": owner, "locked_at": now, "expires_at": now.Add(lockTTL)}
+
+	filter := bson.M{"_id": lockID, "$or": bson.A{
+		bson.M{"owner": owner},
+		bson.M{"expires_at": bson.M{"$lt": now}},
+	}}
+	res, err := s.collection.UpdateOne(ctx, filter, bson.M{"$set": lockDoc})
+	if err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	lockDoc["_id"] = lockID
+	if _, err := s.collection.InsertOne(ctx, lockDoc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			var holder struct {
+				Owner     string    `bson:"owner"`
+				ExpiresAt time.Time `bson:"expires_at"`
+			}
+			if findErr := s.collection.FindOne(ctx, bson.M{"_id": lockID}).Decode(&holder); findErr == nil {
+				return fmt.Errorf("%w: held by %s until %s", ErrLocked, holder.Owner, holder.ExpiresAt.Format(time.RFC3339))
+			}
+			return ErrLocked
+		}
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	return nil
+}
+
+func (s *mongoStore) Unlock(ctx context.Context, owner string) error {
+	if _, err := s.collection.DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner}); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	return nil
+}
+
+var _ store = (*mongoStore)(nil)
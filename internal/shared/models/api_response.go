@@ -11,6 +11,7 @@ type CommonErrorResponse struct {
 	Code      int                 `json:"code"`
 	Method    string              `json:"method,omitempty"`
 	Path      string              `json:"path,omitempty"`
+	Data      interface{}         `json:"data,omitempty"` // Optional: current state of the resource, e.g. on 412 Precondition Failed
 }
 
 // GenericSuccessResponse provides a flexible structure for all successful API responses.
@@ -20,4 +21,15 @@ type GenericSuccessResponse struct {
 	Success bool        `json:"success"`         // Always true for success
 	Data    interface{} `json:"data"`            // This is the flexible part! It can be any struct.
 	Count   int         `json:"count,omitempty"` // Optional: useful for list responses
+	Meta    *PageMeta   `json:"meta,omitempty"`  // Optional: pagination info for paged list responses
+}
+
+// PageMeta describes where a page sits in a paginated listing. Page is only set for
+// offset pagination; NextCursor is empty on the last page.
+type PageMeta struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
 }
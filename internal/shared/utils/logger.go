@@ -9,7 +9,9 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var Logger *zap.Logger
+// Logger discards everything until InitLogger is called, so that packages can be
+// used without a configured logger, e.g. in tests.
+var Logger = zap.NewNop()
 
 func InitLogger(env string, logLevel string) {
 	var config zap.Config
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+type requestMetaKey struct{}
+
+// RequestMetaKey is the key under which the per-request metadata is stored in
+// fiber Locals. Because fasthttp.RequestCtx resolves Value() through its user
+// values, any context derived from c.Context() can read it back.
+var RequestMetaKey = requestMetaKey{}
+
+// RequestMeta carries request-scoped information (who, from where, which request)
+// down to the use case layer without widening every method signature.
+type RequestMeta struct {
+	ActorID       string
+	ActorRoles    []string
+	SessionID     string
+	AuthTime      time.Time
+	IP            string
+	UserAgent     string
+	CorrelationID string
+}
+
+// WithRequestMeta returns a copy of ctx carrying meta. Useful outside of HTTP
+// handlers, e.g. in Asynq workers.
+func WithRequestMeta(ctx context.Context, meta *RequestMeta) context.Context {
+	return context.WithValue(ctx, RequestMetaKey, meta)
+}
+
+// RequestMetaFromContext returns the request metadata stored in ctx, or an empty
+// value if none is present.
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	if ctx == nil {
+		return RequestMeta{}
+	}
+	if meta, ok := ctx.Value(RequestMetaKey).(*RequestMeta); ok && meta != nil {
+		return *meta
+	}
+	return RequestMeta{}
+}
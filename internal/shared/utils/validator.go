@@ -130,6 +130,26 @@ func getErrorMessage(err validator.FieldError) string {
 		return fmt.Sprintf("%s must contain only alphanumeric characters", fieldName)
 	case "hexcolor":
 		return fmt.Sprintf("%s must be a valid hex color code", fieldName)
+	case "datetime":
+		return fmt.Sprintf("%s must be a timestamp in the format %s", fieldName, param)
+	case "ip":
+		return fmt.Sprintf("%s must be a valid IP address", fieldName)
+	case "fqdn":
+		return fmt.Sprintf("%s must be a valid domain name", fieldName)
+	case "e164":
+		return fmt.Sprintf("%s must be a phone number in E.164 format, e.g. +66812345678", fieldName)
+	case "timezone":
+		return fmt.Sprintf("%s must be an IANA time zone, e.g. Asia/Bangkok", fieldName)
+	case "bcp47_language_tag":
+		return fmt.Sprintf("%s must be a BCP 47 language tag, e.g. th-TH", fieldName)
+	case "http_url":
+		return fmt.Sprintf("%s must be a valid HTTP or HTTPS URL", fieldName)
+	case "printascii":
+		return fmt.Sprintf("%s must contain only printable ASCII characters", fieldName)
+	case "required_without":
+		return fmt.Sprintf("%s is required when %s is not provided", fieldName, toSnakeCase(param))
+	case "excluded_with":
+		return fmt.Sprintf("%s must not be provided together with %s", fieldName, toSnakeCase(param))
 	// Add more custom messages as needed for other tags
 	default:
 		// Fallback for tags not explicitly handled
@@ -0,0 +1,293 @@
+package adapters
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/cache"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+const (
+	userCacheKeyPrefix = "user:"
+	// userInvalidatedKeyPrefix marks a user that was just written. While the marker
+	// exists, reads do not cache the user, so a read that started before the write
+	// cannot put the old version back.
+	userInvalidatedKeyPrefix = "user-cache:invalidated:"
+	// userInvalidatedTTL must outlast any read that started before the write.
+	userInvalidatedTTL = 30 * time.Second
+	// UserCacheInvalidationChannel carries the IDs of users whose cache entries are
+	// stale, so that every instance drops its local copy.
+	UserCacheInvalidationChannel = "user-cache:invalidate"
+	userLocalCacheMaxEntries     = 10000
+)
+
+// CachedUserRepository caches GetUserByID in front of another UserRepository: in
+// process for localTTL, then in Redis for ttl. Every write through it drops the
+// user from both tiers, marks it as invalidated for a short while and tells the
+// other instances to drop their local copy. Other reads and writes go straight to
+// the wrapped repository.
+//
+// Callers of GetUserByID verify the password hash and TOTP secret, so cached users
+// keep them, encrypted with a key derived from secret.
+type CachedUserRepository struct {
+	repository.UserRepository
+	cache *cache.CacheManager
+	local *cache.LocalCache // nil if localTTL is 0
+	ttl   time.Duration
+	aead  cipher.AEAD
+}
+
+func NewCachedUserRepository(next repository.UserRepository, cacheManager *cache.CacheManager, ttl, localTTL time.Duration, secret []byte) *CachedUserRepository {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("user cache credentials"))
+	// A 32-byte key always makes a valid AES cipher, and AES always supports GCM.
+	block, _ := aes.NewCipher(mac.Sum(nil))
+	aead, _ := cipher.NewGCM(block)
+
+	r := &CachedUserRepository{UserRepository: next, cache: cacheManager, ttl: ttl, aead: aead}
+	if localTTL > 0 {
+		r.local = cache.NewLocalCache(localTTL, userLocalCacheMaxEntries)
+	}
+	return r
+}
+
+// ListenForInvalidations drops the local copies of users invalidated by any
+// instance until ctx is done.
+func (r *CachedUserRepository) ListenForInvalidations(ctx context.Context) error {
+	if r.local == nil {
+		return nil
+	}
+	return r.cache.Subscribe(ctx, UserCacheInvalidationChannel, func(id string) {
+		r.local.Delete(userCacheKey(domain.UserID(id)))
+	})
+}
+
+func (r *CachedUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	key := userCacheKey(id)
+	if r.local != nil {
+		if raw, ok := r.local.Get(key); ok {
+			if user, err := r.decode(raw); err == nil {
+				return user, nil
+			}
+			r.local.Delete(key)
+		}
+	}
+
+	raw, err := r.cache.Get(ctx, key)
+	if err == nil {
+		if user, err := r.decode([]byte(raw)); err == nil {
+			if r.local != nil {
+				r.local.Set(key, []byte(raw))
+			}
+			return user, nil
+		}
+		utils.Logger.Warn("CachedUserRepository: Dropping undecodable cache entry", zap.String("user_id", id.String()))
+	} else if !errors.Is(err, cache.ErrCacheMiss) {
+		// Redis being down must not take user lookups down with it.
+		utils.Logger.Warn("CachedUserRepository: Cache unavailable, reading from the repository", zap.Error(err))
+	}
+
+	user, err := r.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.store(ctx, key, user)
+	return user, nil
+}
+
+func (r *CachedUserRepository) UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	user, err := r.UserRepository.UpdateUser(ctx, id, update, expectedVersion)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+func (r *CachedUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	err := r.UserRepository.DeleteUser(ctx, id)
+	r.invalidate(ctx, id)
+	return err
+}
+
+func (r *CachedUserRepository) SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error) {
+	user, err := r.UserRepository.SetActive(ctx, id, active, actorID, reason)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+func (r *CachedUserRepository) RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	user, err := r.UserRepository.RestoreUser(ctx, id)
+	r.invalidate(ctx, id)
+	return user, err
+}
+
+func (r *CachedUserRepository) PurgeUser(ctx context.Context, id domain.UserID) error {
+	err := r.UserRepository.PurgeUser(ctx, id)
+	r.invalidate(ctx, id)
+	return err
+}
+
+func (r *CachedUserRepository) AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error {
+	err := r.UserRepository.AnonymizeUser(ctx, id, erasedAt)
+	r.invalidate(ctx, id)
+	return err
+}
+
+// store caches user unless it was invalidated since the read began.
+func (r *CachedUserRepository) store(ctx context.Context, key string, user *domain.User) {
+	raw, err := r.encode(user)
+	if err != nil {
+		utils.Logger.Error("CachedUserRepository: Failed to encode user", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return
+	}
+	stored, err := r.cache.SetUnless(ctx, key, raw, r.ttl, userInvalidatedKey(user.ID))
+	if err == nil && !stored {
+		return
+	}
+	// CacheManager logs failures; the local tier still saves repository reads.
+	if r.local != nil {
+		r.local.Set(key, raw)
+	}
+}
+
+// invalidate drops the cached user after a write. It runs even if the write failed,
+// because a failure such as a version conflict shows that the cached copy may be
+// stale, and dropping an entry is always safe. The marker is set before the entry
+// is dropped, so that a read in between does not cache it again.
+func (r *CachedUserRepository) invalidate(ctx context.Context, id domain.UserID) {
+	key := userCacheKey(id)
+	if r.local != nil {
+		r.local.Delete(key)
+	}
+	if err := r.cache.Set(ctx, userInvalidatedKey(id), "1", userInvalidatedTTL); err != nil {
+		utils.Logger.Warn("CachedUserRepository: Failed to mark user as invalidated", zap.String("user_id", id.String()), zap.Error(err))
+	}
+	if err := r.cache.Delete(ctx, key); err != nil {
+		// The entry expires with its TTL; until then other instances may read it.
+		utils.Logger.Warn("CachedUserRepository: Failed to invalidate cached user", zap.String("user_id", id.String()), zap.Error(err))
+	}
+	if r.local != nil {
+		if err := r.cache.Publish(ctx, UserCacheInvalidationChannel, id.String()); err != nil {
+			utils.Logger.Warn("CachedUserRepository: Failed to broadcast invalidation", zap.String("user_id", id.String()), zap.Error(err))
+		}
+	}
+}
+
+func userCacheKey(id domain.UserID) string {
+	return userCacheKeyPrefix + id.String()
+}
+
+func userInvalidatedKey(id domain.UserID) string {
+	return userInvalidatedKeyPrefix + id.String()
+}
+
+// cachedUser is the JSON form of a cached user. Unlike the API representation of
+// domain.User it keeps every field; the credentials are sealed in Credentials.
+type cachedUser struct {
+	ID                 domain.UserID             `json:"id"`
+	Name               string                    `json:"name"`
+	Email              string                    `json:"email"`
+	EmailCanonical     string                    `json:"email_canonical,omitempty"`
+	CreatedAt          time.Time                 `json:"created_at"`
+	UpdatedAt          time.Time                 `json:"updated_at"`
+	IsActive           bool                      `json:"is_active"`
+	Roles              []string                  `json:"roles,omitempty"`
+	Profile            domain.UserProfile        `json:"profile"`
+	DeletedAt          *time.Time                `json:"deleted_at,omitempty"`
+	DeactivatedAt      *time.Time                `json:"deactivated_at,omitempty"`
+	DeactivatedBy      string                    `json:"deactivated_by,omitempty"`
+	DeactivationReason string                    `json:"deactivation_reason,omitempty"`
+	PendingEmail       *cachedPendingEmailChange `json:"pending_email,omitempty"`
+	ErasedAt           *time.Time                `json:"erased_at,omitempty"`
+	Version            int64                     `json:"version"`
+	// Credentials is the cachedCredentials JSON encrypted with AES-GCM, nonce first,
+	// and bound to the user ID.
+	Credentials []byte `json:"credentials"`
+}
+
+type cachedCredentials struct {
+	Password   string `json:"password"`
+	TOTPSecret string `json:"totp_secret,omitempty"`
+}
+
+type cachedPendingEmailChange struct {
+	Email            string    `json:"email"`
+	ConfirmTokenHash string    `json:"confirm_token_hash"`
+	CancelTokenHash  string    `json:"cancel_token_hash"`
+	RequestedAt      time.Time `json:"requested_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+func (r *CachedUserRepository) encode(user *domain.User) ([]byte, error) {
+	credentials, err := json.Marshal(cachedCredentials{Password: user.Password, TOTPSecret: user.TOTPSecret})
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	cached := cachedUser{
+		ID: user.ID, Name: user.Name, Email: user.Email, EmailCanonical: user.EmailCanonical,
+		CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt, IsActive: user.IsActive,
+		Roles: user.Roles, Profile: user.UserProfile, DeletedAt: user.DeletedAt,
+		DeactivatedAt: user.DeactivatedAt, DeactivatedBy: user.DeactivatedBy, DeactivationReason: user.DeactivationReason,
+		ErasedAt: user.ErasedAt, Version: user.Version,
+		Credentials: r.aead.Seal(nonce, nonce, credentials, []byte(user.ID.String())),
+	}
+	if change := user.PendingEmail; change != nil {
+		cached.PendingEmail = &cachedPendingEmailChange{
+			Email: change.Email, ConfirmTokenHash: change.ConfirmTokenHash, CancelTokenHash: change.CancelTokenHash,
+			RequestedAt: change.RequestedAt, ExpiresAt: change.ExpiresAt,
+		}
+	}
+	return json.Marshal(cached)
+}
+
+func (r *CachedUserRepository) decode(raw []byte) (*domain.User, error) {
+	var cached cachedUser
+	if err := json.Unmarshal(raw, &cached); err != nil {
+		return nil, err
+	}
+	nonceSize := r.aead.NonceSize()
+	if len(cached.Credentials) < nonceSize {
+		return nil, errors.New("cached user has no credentials")
+	}
+	plain, err := r.aead.Open(nil, cached.Credentials[:nonceSize], cached.Credentials[nonceSize:], []byte(cached.ID.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cached credentials: %w", err)
+	}
+	var credentials cachedCredentials
+	if err := json.Unmarshal(plain, &credentials); err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		ID: cached.ID, Name: cached.Name, Email: cached.Email, EmailCanonical: cached.EmailCanonical,
+		Password: credentials.Password, CreatedAt: cached.CreatedAt, UpdatedAt: cached.UpdatedAt, IsActive: cached.IsActive,
+		Roles: cached.Roles, UserProfile: cached.Profile, TOTPSecret: credentials.TOTPSecret, DeletedAt: cached.DeletedAt,
+		DeactivatedAt: cached.DeactivatedAt, DeactivatedBy: cached.DeactivatedBy, DeactivationReason: cached.DeactivationReason,
+		ErasedAt: cached.ErasedAt, Version: cached.Version,
+	}
+	if change := cached.PendingEmail; change != nil {
+		user.PendingEmail = &domain.PendingEmailChange{
+			Email: change.Email, ConfirmTokenHash: change.ConfirmTokenHash, CancelTokenHash: change.CancelTokenHash,
+			RequestedAt: change.RequestedAt, ExpiresAt: change.ExpiresAt,
+		}
+	}
+	return user, nil
+}
+
+var _ repository.UserRepository = (*CachedUserRepository)(nil)
@@ -0,0 +1,239 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iots1/mingkwan-api/internal/shared/cache"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/repository/repositorytest"
+)
+
+var testCacheSecret = []byte("test-secret")
+
+func newTestCacheManager(t *testing.T) (*cache.CacheManager, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return cache.NewCacheManager(client), server
+}
+
+func TestCachedUserRepository_Conformance(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		cacheManager, _ := newTestCacheManager(t)
+		return NewCachedUserRepository(NewMemoryUserRepository(), cacheManager, time.Minute, time.Minute, testCacheSecret)
+	})
+}
+
+func TestCachedUserRepository_GetUserByID(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// write changes the user behind the cache, through the cache or around it.
+		write     func(t *testing.T, cached *CachedUserRepository, inner *MemoryUserRepository, id domain.UserID)
+		wantName  string
+		wantError error
+	}{
+		{
+			name: "served from cache",
+			write: func(t *testing.T, _ *CachedUserRepository, inner *MemoryUserRepository, id domain.UserID) {
+				if _, err := inner.UpdateUser(ctx, id, map[string]interface{}{"name": "Bypassed"}, domain.AnyVersion); err != nil {
+					t.Fatalf("UpdateUser: %v", err)
+				}
+			},
+			wantName: "Nina",
+		},
+		{
+			name: "invalidated by update",
+			write: func(t *testing.T, cached *CachedUserRepository, _ *MemoryUserRepository, id domain.UserID) {
+				if _, err := cached.UpdateUser(ctx, id, map[string]interface{}{"name": "Nora"}, domain.AnyVersion); err != nil {
+					t.Fatalf("UpdateUser: %v", err)
+				}
+			},
+			wantName: "Nora",
+		},
+		{
+			name: "invalidated by deactivation",
+			write: func(t *testing.T, cached *CachedUserRepository, _ *MemoryUserRepository, id domain.UserID) {
+				if _, err := cached.SetActive(ctx, id, false, "admin", "test"); err != nil {
+					t.Fatalf("SetActive: %v", err)
+				}
+				user, err := cached.GetUserByID(ctx, id)
+				if err != nil || user.IsActive {
+					t.Fatalf("GetUserByID after deactivation = %+v, %v; want an inactive user", user, err)
+				}
+			},
+			wantName: "Nina",
+		},
+		{
+			name: "invalidated by delete",
+			write: func(t *testing.T, cached *CachedUserRepository, _ *MemoryUserRepository, id domain.UserID) {
+				if err := cached.DeleteUser(ctx, id); err != nil {
+					t.Fatalf("DeleteUser: %v", err)
+				}
+			},
+			wantError: domain.ErrUserNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheManager, _ := newTestCacheManager(t)
+			inner := NewMemoryUserRepository()
+			cached := NewCachedUserRepository(inner, cacheManager, time.Minute, time.Minute, testCacheSecret)
+			created, err := inner.CreateUser(ctx, &domain.User{Name: "Nina", Email: "nina@example.com", Password: "hash", IsActive: true})
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			if _, err := cached.GetUserByID(ctx, created.ID); err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+
+			tt.write(t, cached, inner, created.ID)
+
+			user, err := cached.GetUserByID(ctx, created.ID)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("GetUserByID error = %v, want %v", err, tt.wantError)
+			}
+			if tt.wantError == nil && user.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", user.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCachedUserRepository_EncryptsCredentials(t *testing.T) {
+	ctx := context.Background()
+	cacheManager, server := newTestCacheManager(t)
+	inner := NewMemoryUserRepository()
+	created, err := inner.CreateUser(ctx, &domain.User{Name: "Owen", Email: "owen@example.com", Password: "bcrypt-hash", TOTPSecret: "TOTPSECRET", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Without a local tier the second read decodes the Redis entry.
+	cached := NewCachedUserRepository(inner, cacheManager, time.Minute, 0, testCacheSecret)
+	for range 2 {
+		user, err := cached.GetUserByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.Password != "bcrypt-hash" || user.TOTPSecret != "TOTPSECRET" {
+			t.Errorf("cached user lost credentials: password %q, TOTP secret %q", user.Password, user.TOTPSecret)
+		}
+	}
+
+	raw, err := server.Get(userCacheKey(created.ID))
+	if err != nil {
+		t.Fatalf("cache entry: %v", err)
+	}
+	if strings.Contains(raw, "bcrypt-hash") || strings.Contains(raw, "TOTPSECRET") {
+		t.Errorf("cache entry holds plain-text credentials: %s", raw)
+	}
+
+	// An entry sealed with another secret is a miss, not a user without credentials.
+	other := NewCachedUserRepository(inner, cacheManager, time.Minute, 0, []byte("other-secret"))
+	user, err := other.GetUserByID(ctx, created.ID)
+	if err != nil || user.Password != "bcrypt-hash" {
+		t.Errorf("GetUserByID with another secret = %+v, %v; want the stored user", user, err)
+	}
+}
+
+func TestCachedUserRepository_StaleReadAfterWrite(t *testing.T) {
+	ctx := context.Background()
+	cacheManager, _ := newTestCacheManager(t)
+	inner := NewMemoryUserRepository()
+	created, err := inner.CreateUser(ctx, &domain.User{Name: "Rita", Email: "rita@example.com", Password: "hash", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	cached := NewCachedUserRepository(inner, cacheManager, time.Minute, time.Minute, testCacheSecret)
+
+	// A read loads the user, a write invalidates it, then the read stores what it
+	// loaded before the write.
+	stale, err := inner.GetUserByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if _, err := cached.SetActive(ctx, created.ID, false, "admin", "test"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	cached.store(ctx, userCacheKey(created.ID), stale)
+
+	user, err := cached.GetUserByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.IsActive {
+		t.Error("stale read cached the user as active after the deactivation")
+	}
+}
+
+func TestCachedUserRepository_BroadcastsInvalidations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cacheManager, _ := newTestCacheManager(t)
+	inner := NewMemoryUserRepository()
+	created, err := inner.CreateUser(ctx, &domain.User{Name: "Paul", Email: "paul@example.com", Password: "hash", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Two instances sharing Redis, each with its own local tier.
+	reader := NewCachedUserRepository(inner, cacheManager, time.Minute, time.Minute, testCacheSecret)
+	writer := NewCachedUserRepository(inner, cacheManager, time.Minute, time.Minute, testCacheSecret)
+	if err := reader.ListenForInvalidations(ctx); err != nil {
+		t.Fatalf("ListenForInvalidations: %v", err)
+	}
+	if _, err := reader.GetUserByID(ctx, created.ID); err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if _, err := writer.UpdateUser(ctx, created.ID, map[string]interface{}{"name": "Pia"}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		user, err := reader.GetUserByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.Name == "Pia" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reader still serves %q after the invalidation", user.Name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCachedUserRepository_RedisDown(t *testing.T) {
+	ctx := context.Background()
+	cacheManager, server := newTestCacheManager(t)
+	inner := NewMemoryUserRepository()
+	created, err := inner.CreateUser(ctx, &domain.User{Name: "Quinn", Email: "quinn@example.com", Password: "hash", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	cached := NewCachedUserRepository(inner, cacheManager, time.Minute, 0, testCacheSecret)
+	server.Close()
+
+	user, err := cached.GetUserByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID with Redis down: %v", err)
+	}
+	if user.Name != "Quinn" {
+		t.Errorf("name = %q, want Quinn", user.Name)
+	}
+	if _, err := cached.UpdateUser(ctx, created.ID, map[string]interface{}{"name": "Quincy"}, domain.AnyVersion); err != nil {
+		t.Errorf("UpdateUser with Redis down: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// historyUpdateAttempts bounds how often an unversioned update is retried when
+// another write lands between reading the user and updating it.
+const historyUpdateAttempts = 3
+
+// HistoryUserRepository records every write through it in a UserHistoryRepository:
+// the changed fields, the user before and after, and the actor and request ID of
+// ctx. Reads go straight to the wrapped repository.
+//
+// Unversioned updates are pinned to the version read before them, so that the
+// recorded diff is exactly the update's; they fail with domain.ErrVersionConflict
+// only if other writes win historyUpdateAttempts times in a row. Other writes have
+// no version to pin, and a write racing them can make their diff include its
+// changes.
+//
+// Entries that cannot be stored are queued on outbox as event.RecordUserHistoryTask,
+// whose handler stores them later. If outbox is nil or queuing fails as well, the
+// write returns the error although it happened, so that no write goes unrecorded
+// silently.
+type HistoryUserRepository struct {
+	repository.UserRepository
+	history repository.UserHistoryRepository
+	outbox  event.Publisher
+}
+
+func NewHistoryUserRepository(next repository.UserRepository, history repository.UserHistoryRepository, outbox event.Publisher) *HistoryUserRepository {
+	return &HistoryUserRepository{UserRepository: next, history: history, outbox: outbox}
+}
+
+func (r *HistoryUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	created, err := r.UserRepository.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, newHistoryEntry(ctx, domain.HistoryCreate, created.ID, nil, created)); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *HistoryUserRepository) CreateUsers(ctx context.Context, users []*domain.User) ([]error, error) {
+	errs, err := r.UserRepository.CreateUsers(ctx, users)
+	if err != nil {
+		return errs, err
+	}
+	entries := make([]domain.UserHistoryEntry, 0, len(users))
+	for i, user := range users {
+		if errs[i] == nil {
+			entries = append(entries, newHistoryEntry(ctx, domain.HistoryCreate, user.ID, nil, user))
+		}
+	}
+	if err := r.record(ctx, entries...); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+func (r *HistoryUserRepository) UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	for attempt := 1; ; attempt++ {
+		before, err := r.UserRepository.GetUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		version := expectedVersion
+		if version == domain.AnyVersion {
+			version = before.Version
+		}
+
+		after, err := r.UserRepository.UpdateUser(ctx, id, update, version)
+		if errors.Is(err, domain.ErrVersionConflict) && expectedVersion == domain.AnyVersion && attempt < historyUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := r.record(ctx, newHistoryEntry(ctx, domain.HistoryUpdate, id, before, after)); err != nil {
+			return nil, err
+		}
+		return after, nil
+	}
+}
+
+func (r *HistoryUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	before, err := r.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.UserRepository.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	// Deleted users are not readable; the deletion changes nothing else.
+	after := *before
+	deletedAt := time.Now()
+	after.DeletedAt, after.UpdatedAt, after.Version = &deletedAt, deletedAt, before.Version+1
+	return r.record(ctx, newHistoryEntry(ctx, domain.HistoryDelete, id, before, &after))
+}
+
+func (r *HistoryUserRepository) SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error) {
+	before, err := r.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	after, err := r.UserRepository.SetActive(ctx, id, active, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+	operation := domain.HistoryDeactivate
+	if active {
+		operation = domain.HistoryReactivate
+	}
+	if err := r.record(ctx, newHistoryEntry(ctx, operation, id, before, after)); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// RestoreUser records only the state after the restore: deleted users are not
+// readable, and the deletion entry already holds the state before it.
+func (r *HistoryUserRepository) RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	after, err := r.UserRepository.RestoreUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entry := newHistoryEntry(ctx, domain.HistoryRestore, id, nil, after)
+	entry.Fields = []string{"deleted_at"}
+	if err := r.record(ctx, entry); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// AnonymizeUser records which fields the erasure replaced but not their previous
+// values, which would defeat it.
+func (r *HistoryUserRepository) AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error {
+	before, err := r.UserRepository.GetUserByID(ctx, id)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return err
+	}
+	if err := r.UserRepository.AnonymizeUser(ctx, id, erasedAt); err != nil {
+		return err
+	}
+	// Deleted users are erased too but stay unreadable.
+	after, err := r.UserRepository.GetUserByID(ctx, id)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		utils.Logger.Warn("HistoryUserRepository: Failed to read erased user", zap.String("user_id", id.String()), zap.Error(err))
+	}
+	entry := newHistoryEntry(ctx, domain.HistoryErase, id, nil, nil)
+	if before != nil && after != nil {
+		entry.Fields = domain.ChangedFields(before, after)
+		entry.After = domain.NewUserSnapshot(after)
+	}
+	return r.record(ctx, entry)
+}
+
+// PurgeUser removes the user's history with the user.
+func (r *HistoryUserRepository) PurgeUser(ctx context.Context, id domain.UserID) error {
+	if err := r.UserRepository.PurgeUser(ctx, id); err != nil {
+		return err
+	}
+	if _, err := r.history.DeleteEntries(ctx, id); err != nil {
+		utils.Logger.Error("HistoryUserRepository: Failed to delete history of purged user", zap.String("user_id", id.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// newHistoryEntry describes the write that turned before into after; either may be
+// nil if unknown.
+func newHistoryEntry(ctx context.Context, operation string, id domain.UserID, before, after *domain.User) domain.UserHistoryEntry {
+	meta := utils.RequestMetaFromContext(ctx)
+	entry := domain.UserHistoryEntry{
+		UserID:    id,
+		Operation: operation,
+		ActorID:   meta.ActorID,
+		RequestID: meta.CorrelationID,
+		At:        time.Now(),
+	}
+	if before != nil {
+		entry.Before = domain.NewUserSnapshot(before)
+	}
+	if after != nil {
+		entry.After = domain.NewUserSnapshot(after)
+		entry.Fields = domain.ChangedFields(before, after)
+	}
+	return entry
+}
+
+// record stores entries, or queues them on the outbox if that fails. Entries get
+// their IDs first, so that the task does not store twice what the failed call
+// stored partially.
+func (r *HistoryUserRepository) record(ctx context.Context, entries ...domain.UserHistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i := range entries {
+		if entries[i].ID == "" {
+			entries[i].ID = primitive.NewObjectID().Hex()
+		}
+	}
+	err := r.history.AddEntries(ctx, entries)
+	if err == nil {
+		return nil
+	}
+	fields := []zap.Field{zap.String("user_id", entries[0].UserID.String()), zap.String("operation", entries[0].Operation),
+		zap.Int("entries", len(entries)), zap.Error(err)}
+	if r.outbox == nil {
+		utils.Logger.Error("HistoryUserRepository: Failed to record user history", fields...)
+		return fmt.Errorf("failed to record user history: %w", err)
+	}
+	if queueErr := r.outbox.Publish(ctx, event.RecordUserHistoryTask, domain.RecordHistoryPayload{Entries: entries}); queueErr != nil {
+		utils.Logger.Error("HistoryUserRepository: Failed to record or queue user history", append(fields, zap.NamedError("queue_error", queueErr))...)
+		return fmt.Errorf("failed to record user history: %w", errors.Join(err, queueErr))
+	}
+	utils.Logger.Warn("HistoryUserRepository: Queued user history that failed to record", fields...)
+	return nil
+}
+
+var _ repository.UserRepository = (*HistoryUserRepository)(nil)
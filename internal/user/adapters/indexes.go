@@ -0,0 +1,93 @@
+package adapters
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+)
+
+// UserIndexes declares the indexes of the users collection.
+func UserIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			// Soft-deleted users keep their email until they are purged, so that
+			// restoring them cannot collide with a newer account. Users without a
+			// canonical email (unresolved collisions, see BackfillEmailCanonical) are
+			// left out.
+			{
+				Keys: bson.D{{Key: "email_canonical", Value: 1}},
+				Options: options.Index().
+					SetName("users_email_canonical_unique").
+					SetUnique(true).
+					SetCollation(emailCollation).
+					SetPartialFilterExpression(bson.M{"email_canonical": bson.M{"$exists": true}}),
+			},
+			// Word matches of MongoUserSearcher.
+			{
+				Keys: bson.D{{Key: "name", Value: "text"}, {Key: "email", Value: "text"}},
+				Options: options.Index().
+					SetName("users_search_text").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "email", Value: 5}}),
+			},
+			// Prefix matches of MongoUserSearcher.
+			{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("users_email_ci").SetCollation(caseInsensitive),
+			},
+			{
+				Keys:    bson.D{{Key: "name", Value: 1}},
+				Options: options.Index().SetName("users_name_ci").SetCollation(caseInsensitive),
+			},
+			// Links in email change messages.
+			{
+				Keys:    bson.D{{Key: "pending_email.confirm_token_hash", Value: 1}},
+				Options: options.Index().SetName("users_pending_email_confirm_token").SetSparse(true),
+			},
+			{
+				Keys:    bson.D{{Key: "pending_email.cancel_token_hash", Value: 1}},
+				Options: options.Index().SetName("users_pending_email_cancel_token").SetSparse(true),
+			},
+			// The purge of soft-deleted users.
+			{
+				Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+				Options: options.Index().SetName("users_deleted_at").SetSparse(true),
+			},
+		},
+	}
+}
+
+// UserHistoryIndexes declares the indexes of the user history collection, which is
+// only ever read per user and in time order.
+func UserHistoryIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "at", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("user_history_user_at"),
+			},
+		},
+	}
+}
+
+// UserMergeIndexes declares the indexes of the user merges collection. Merges are
+// keyed by the source user; the index on the target finds the accounts merged into
+// a user, and the one on repointed_at the merges still to be re-pointed.
+func UserMergeIndexes(collectionName string) migration.IndexSpec {
+	return migration.IndexSpec{
+		Collection: collectionName,
+		Indexes: []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "merged_at", Value: 1}},
+				Options: options.Index().SetName("user_merges_target_merged_at"),
+			},
+			{
+				Keys:    bson.D{{Key: "repointed_at", Value: 1}, {Key: "merged_at", Value: 1}},
+				Options: options.Index().SetName("user_merges_repointed_at_merged_at"),
+			},
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// MemoryUserHistoryRepository is an in-memory UserHistoryRepository, for tests and
+// local tools. Like MemoryUserRepository it stores entries BSON-encoded.
+type MemoryUserHistoryRepository struct {
+	mu sync.RWMutex
+	// entries holds the entries of each user in insertion order.
+	entries map[domain.UserID][][]byte
+	ids     map[string]bool
+}
+
+func NewMemoryUserHistoryRepository() *MemoryUserHistoryRepository {
+	return &MemoryUserHistoryRepository{entries: make(map[domain.UserID][][]byte), ids: make(map[string]bool)}
+}
+
+func (r *MemoryUserHistoryRepository) AddEntries(ctx context.Context, entries []domain.UserHistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID == "" {
+			entry.ID = primitive.NewObjectID().Hex()
+		}
+		if r.ids[entry.ID] {
+			continue
+		}
+		raw, err := bson.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to encode user history entry: %w", err)
+		}
+		r.entries[entry.UserID] = append(r.entries[entry.UserID], raw)
+		r.ids[entry.ID] = true
+	}
+	return nil
+}
+
+// newestFirst decodes the entries of a user, newest first.
+func (r *MemoryUserHistoryRepository) newestFirst(userID domain.UserID) ([]domain.UserHistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stored := r.entries[userID]
+	entries := make([]domain.UserHistoryEntry, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		var entry domain.UserHistoryEntry
+		if err := bson.Unmarshal(stored[i], &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode user history entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	// Entries are appended in time order except for writes racing each other.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.After(entries[j].At) })
+	return entries, nil
+}
+
+func (r *MemoryUserHistoryRepository) ListEntries(ctx context.Context, userID domain.UserID, page domain.HistoryPage) ([]domain.UserHistoryEntry, int64, error) {
+	entries, err := r.newestFirst(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := min(page.Skip(), len(entries))
+	end := min(start+page.Limit, len(entries))
+	return entries[start:end], int64(len(entries)), nil
+}
+
+func (r *MemoryUserHistoryRepository) LatestEntryAt(ctx context.Context, userID domain.UserID, t time.Time) (*domain.UserHistoryEntry, error) {
+	entries, err := r.newestFirst(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.At.After(t) {
+			return &entry, nil
+		}
+	}
+	return nil, domain.ErrHistoryEntryNotFound
+}
+
+func (r *MemoryUserHistoryRepository) EarliestEntry(ctx context.Context, userID domain.UserID) (*domain.UserHistoryEntry, error) {
+	entries, err := r.newestFirst(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, domain.ErrHistoryEntryNotFound
+	}
+	return &entries[len(entries)-1], nil
+}
+
+func (r *MemoryUserHistoryRepository) DeleteEntries(ctx context.Context, userID domain.UserID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := int64(len(r.entries[userID]))
+	for _, raw := range r.entries[userID] {
+		if id, ok := bson.Raw(raw).Lookup("_id").StringValueOK(); ok {
+			delete(r.ids, id)
+		}
+	}
+	delete(r.entries, userID)
+	return n, nil
+}
+
+var _ repository.UserHistoryRepository = (*MemoryUserHistoryRepository)(nil)
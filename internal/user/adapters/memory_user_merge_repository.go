@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// MemoryUserMergeRepository is an in-memory UserMergeRepository, for tests and local
+// tools.
+type MemoryUserMergeRepository struct {
+	mu     sync.RWMutex
+	merges map[domain.UserID]domain.UserMerge
+}
+
+func NewMemoryUserMergeRepository() *MemoryUserMergeRepository {
+	return &MemoryUserMergeRepository{merges: make(map[domain.UserID]domain.UserMerge)}
+}
+
+func (r *MemoryUserMergeRepository) CreateMerge(ctx context.Context, merge *domain.UserMerge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.merges[merge.SourceID]; ok {
+		return domain.ErrUserMerged
+	}
+	stored := *merge
+	stored.Fields = append([]string(nil), merge.Fields...)
+	r.merges[merge.SourceID] = stored
+	return nil
+}
+
+func (r *MemoryUserMergeRepository) GetMerge(ctx context.Context, sourceID domain.UserID) (*domain.UserMerge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	merge, ok := r.merges[sourceID]
+	if !ok {
+		return nil, domain.ErrUserMergeNotFound
+	}
+	return &merge, nil
+}
+
+func (r *MemoryUserMergeRepository) DeleteMerge(ctx context.Context, sourceID domain.UserID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.merges, sourceID)
+	return nil
+}
+
+func (r *MemoryUserMergeRepository) MarkRepointed(ctx context.Context, sourceID domain.UserID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if merge, ok := r.merges[sourceID]; ok {
+		merge.RepointedAt = &at
+		r.merges[sourceID] = merge
+	}
+	return nil
+}
+
+func (r *MemoryUserMergeRepository) PendingRepoints(ctx context.Context, mergedBefore time.Time, limit int) ([]domain.UserMerge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	merges := []domain.UserMerge{}
+	for _, merge := range r.merges {
+		if merge.RepointedAt == nil && merge.MergedAt.Before(mergedBefore) {
+			merges = append(merges, merge)
+		}
+	}
+	slices.SortFunc(merges, func(a, b domain.UserMerge) int { return a.MergedAt.Compare(b.MergedAt) })
+	if len(merges) > limit {
+		merges = merges[:limit]
+	}
+	return merges, nil
+}
+
+var _ repository.UserMergeRepository = (*MemoryUserMergeRepository)(nil)
@@ -0,0 +1,524 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// MemoryUserRepository is an in-memory UserRepository with the semantics of
+// MongoUserRepository, for tests and local tools. Users are stored BSON-encoded, so
+// callers never share memory with the store and times are truncated to milliseconds
+// as in MongoDB.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[domain.UserID][]byte
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[domain.UserID][]byte)}
+}
+
+func (r *MemoryUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	user.Version = 1
+	user.EmailCanonical = domain.CanonicalEmail(user.Email)
+	if user.ID.IsZero() {
+		user.ID = domain.UserID(primitive.NewObjectID().Hex())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.insert(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *MemoryUserRepository) CreateUsers(ctx context.Context, users []*domain.User) ([]error, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := make([]error, len(users))
+	for i, user := range users {
+		if user.ID.IsZero() {
+			user.ID = domain.UserID(primitive.NewObjectID().Hex())
+		}
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.Version = 1
+		user.EmailCanonical = domain.CanonicalEmail(user.Email)
+		errs[i] = r.insert(user)
+	}
+	return errs, nil
+}
+
+// insert stores a new user, enforcing the unique ID and canonical email. The caller
+// holds the write lock.
+func (r *MemoryUserRepository) insert(user *domain.User) error {
+	if _, ok := r.users[user.ID]; ok {
+		return domain.ErrUserAlreadyExists
+	}
+	if r.canonicalTaken(user.EmailCanonical, user.ID) {
+		return domain.ErrUserAlreadyExists
+	}
+	raw, err := bson.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to encode user: %w", err)
+	}
+	r.users[user.ID] = raw
+	return nil
+}
+
+// canonicalTaken reports whether a user other than id has the canonical email,
+// mirroring the unique index: soft-deleted users count, users without a canonical
+// email do not.
+func (r *MemoryUserRepository) canonicalTaken(canonical string, id domain.UserID) bool {
+	if canonical == "" {
+		return false
+	}
+	for _, user := range r.all() {
+		if user.ID != id && user.EmailCanonical != "" && strings.EqualFold(user.EmailCanonical, canonical) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryUserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	wanted := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		wanted[domain.CanonicalEmail(email)] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.all() {
+		if user.EmailCanonical != "" && wanted[user.EmailCanonical] {
+			existing[user.EmailCanonical] = true
+		}
+	}
+	return existing, nil
+}
+
+func (r *MemoryUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.get(id)
+	if !ok || user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *MemoryUserRepository) GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.all() {
+		change := user.PendingEmail
+		if user.IsDeleted() || change == nil {
+			continue
+		}
+		if change.ConfirmTokenHash == tokenHash || change.CancelTokenHash == tokenHash {
+			return user, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	canonical := domain.CanonicalEmail(email)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.all() {
+		if user.IsDeleted() {
+			continue
+		}
+		// Users left without a canonical email by a collision are still found by
+		// their exact email.
+		if user.EmailCanonical != "" && strings.EqualFold(user.EmailCanonical, canonical) ||
+			user.EmailCanonical == "" && strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *MemoryUserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error) {
+	r.mu.RLock()
+	matches := r.filter(filter)
+	r.mu.RUnlock()
+
+	page := &domain.UserPage{Users: []domain.User{}, Total: int64(len(matches))}
+	if filter.Cursor != "" {
+		value, id, err := decodeUserCursor(filter.Cursor, filter.SortBy, filter.SortDesc)
+		if err != nil {
+			return nil, err
+		}
+		after := 0
+		for after < len(matches) && !isAfterCursor(matches[after], filter.SortBy, filter.SortDesc, value, id) {
+			after++
+		}
+		matches = matches[after:]
+	} else if skip := (filter.Page - 1) * filter.Limit; skip > 0 {
+		if skip > len(matches) {
+			skip = len(matches)
+		}
+		matches = matches[skip:]
+	}
+
+	for i, user := range matches {
+		if i == filter.Limit {
+			page.NextCursor = encodeUserCursor(&page.Users[filter.Limit-1], filter.SortBy, filter.SortDesc)
+			break
+		}
+		page.Users = append(page.Users, *user)
+	}
+	return page, nil
+}
+
+func (r *MemoryUserRepository) StreamUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	r.mu.RLock()
+	matches := r.filter(filter)
+	r.mu.RUnlock()
+
+	for _, user := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		user.Password = ""
+		user.TOTPSecret = ""
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns the users matching filter in its sort order, like buildUserQuery
+// and the sort of ListUsers. The caller holds the read lock.
+func (r *MemoryUserRepository) filter(filter domain.UserFilter) []*domain.User {
+	var matches []*domain.User
+	for _, user := range r.all() {
+		if user.IsDeleted() {
+			continue
+		}
+		if filter.IsActive != nil && user.IsActive != *filter.IsActive {
+			continue
+		}
+		if !filter.CreatedFrom.IsZero() && user.CreatedAt.Before(filter.CreatedFrom) {
+			continue
+		}
+		if !filter.CreatedTo.IsZero() && !user.CreatedAt.Before(filter.CreatedTo) {
+			continue
+		}
+		if filter.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+strings.ToLower(filter.EmailDomain)) {
+			continue
+		}
+		matches = append(matches, user)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		c := compareUsers(matches[i], matches[j], filter.SortBy)
+		if filter.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return matches
+}
+
+// compareUsers orders users by the sort field, then by ID.
+func compareUsers(a, b *domain.User, sortBy string) int {
+	if c := compareSortValue(a, sortBy, sortValue(b, sortBy)); c != 0 {
+		return c
+	}
+	return strings.Compare(string(a.ID), string(b.ID))
+}
+
+// isAfterCursor reports whether user comes after the cursor position (value, id).
+func isAfterCursor(user *domain.User, sortBy string, sortDesc bool, value interface{}, id domain.UserID) bool {
+	c := compareSortValue(user, sortBy, value)
+	if c == 0 {
+		c = strings.Compare(string(user.ID), string(id))
+	}
+	if sortDesc {
+		return c < 0
+	}
+	return c > 0
+}
+
+func sortValue(user *domain.User, sortBy string) interface{} {
+	switch sortBy {
+	case domain.SortByCreatedAt:
+		return user.CreatedAt
+	case domain.SortByUpdatedAt:
+		return user.UpdatedAt
+	case domain.SortByName:
+		return user.Name
+	case domain.SortByEmail:
+		return user.Email
+	}
+	return nil
+}
+
+func compareSortValue(user *domain.User, sortBy string, value interface{}) int {
+	switch own := sortValue(user, sortBy).(type) {
+	case time.Time:
+		return own.Compare(value.(time.Time))
+	case string:
+		return strings.Compare(own, value.(string))
+	}
+	return 0
+}
+
+func (r *MemoryUserRepository) UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.get(id)
+	if !ok || user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	if expectedVersion != domain.AnyVersion && user.Version != expectedVersion {
+		return nil, domain.ErrVersionConflict
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(r.users[id], &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode user: %w", err)
+	}
+	if email, ok := update["email"].(string); ok {
+		canonical := domain.CanonicalEmail(email)
+		if r.canonicalTaken(canonical, id) {
+			return nil, domain.ErrUserAlreadyExists
+		}
+		doc["email_canonical"] = canonical
+	}
+	for field, value := range update {
+		if value == nil {
+			unsetPath(doc, field)
+		} else {
+			setPath(doc, field, value)
+		}
+	}
+	doc["updated_at"] = time.Now()
+	doc["version"] = user.Version + 1
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	var updated domain.User
+	if err := bson.Unmarshal(raw, &updated); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	r.users[id] = raw
+	return &updated, nil
+}
+
+// setPath sets a possibly dotted field of doc, creating embedded documents as $set does.
+func setPath(doc bson.M, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		doc = embedded(doc, part, true)
+	}
+	doc[parts[len(parts)-1]] = value
+}
+
+// unsetPath removes a possibly dotted field of doc as $unset does.
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		if doc = embedded(doc, part, false); doc == nil {
+			return
+		}
+	}
+	delete(doc, parts[len(parts)-1])
+}
+
+func embedded(doc bson.M, key string, create bool) bson.M {
+	switch child := doc[key].(type) {
+	case bson.M:
+		return child
+	case primitive.D:
+		m := child.Map()
+		doc[key] = m
+		return m
+	}
+	if !create {
+		return nil
+	}
+	m := bson.M{}
+	doc[key] = m
+	return m
+}
+
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.get(id)
+	if !ok || user.IsDeleted() {
+		return domain.ErrUserNotFound
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	user.UpdatedAt = now
+	user.Version++
+	return r.save(user)
+}
+
+func (r *MemoryUserRepository) SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.get(id)
+	if !ok || user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	now := time.Now()
+	user.IsActive = active
+	if active {
+		user.DeactivatedAt, user.DeactivatedBy, user.DeactivationReason = nil, "", ""
+	} else {
+		user.DeactivatedAt, user.DeactivatedBy, user.DeactivationReason = &now, actorID, reason
+	}
+	user.UpdatedAt = now
+	user.Version++
+	if err := r.save(user); err != nil {
+		return nil, err
+	}
+	return r.reload(id)
+}
+
+func (r *MemoryUserRepository) RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.get(id)
+	if !ok || !user.IsDeleted() {
+		return nil, domain.ErrUserNotFound
+	}
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	user.Version++
+	if err := r.save(user); err != nil {
+		return nil, err
+	}
+	return r.reload(id)
+}
+
+func (r *MemoryUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var users []domain.User
+	for _, user := range r.all() {
+		if user.IsDeleted() && user.DeletedAt.Before(cutoff) {
+			users = append(users, *user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].DeletedAt.Before(*users[j].DeletedAt) })
+	if limit > 0 && len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) PurgeUser(ctx context.Context, id domain.UserID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.get(id)
+	if !ok || !user.IsDeleted() {
+		return domain.ErrUserNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *MemoryUserRepository) AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.get(id)
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	placeholder := "erased-" + id.String() + "@erased.invalid"
+	user.Name = "Erased user"
+	user.Email = placeholder
+	user.EmailCanonical = placeholder
+	user.Password = ""
+	user.IsActive = false
+	user.ErasedAt = &erasedAt
+	user.UpdatedAt = erasedAt
+	user.TOTPSecret = ""
+	user.UserProfile = domain.UserProfile{}
+	user.DeactivatedBy, user.DeactivationReason = "", ""
+	user.PendingEmail = nil
+	user.Version++
+	return r.save(user)
+}
+
+// get decodes the stored user. The caller holds a lock.
+func (r *MemoryUserRepository) get(id domain.UserID) (*domain.User, bool) {
+	raw, ok := r.users[id]
+	if !ok {
+		return nil, false
+	}
+	var user domain.User
+	if err := bson.Unmarshal(raw, &user); err != nil {
+		// Only documents this repository encoded itself are stored.
+		panic(fmt.Sprintf("memory user repository: corrupt user %s: %v", id.String(), err))
+	}
+	return &user, true
+}
+
+func (r *MemoryUserRepository) reload(id domain.UserID) (*domain.User, error) {
+	user, ok := r.get(id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// all decodes every stored user in ID order, which is creation order for generated
+// IDs. The caller holds a lock.
+func (r *MemoryUserRepository) all() []*domain.User {
+	ids := make([]domain.UserID, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	users := make([]*domain.User, len(ids))
+	for i, id := range ids {
+		users[i], _ = r.get(id)
+	}
+	return users
+}
+
+// save replaces the stored user. The caller holds the write lock.
+func (r *MemoryUserRepository) save(user *domain.User) error {
+	raw, err := bson.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to encode user: %w", err)
+	}
+	r.users[user.ID] = raw
+	return nil
+}
+
+var _ repository.UserRepository = (*MemoryUserRepository)(nil)
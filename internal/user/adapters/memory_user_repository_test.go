@@ -0,0 +1,20 @@
+package adapters
+
+import (
+	"testing"
+
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/repository/repositorytest"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		return NewMemoryUserRepository()
+	})
+}
+
+func TestMemoryUserHistoryRepository(t *testing.T) {
+	repositorytest.TestUserHistoryRepository(t, func(t *testing.T) repository.UserHistoryRepository {
+		return NewMemoryUserHistoryRepository()
+	})
+}
@@ -0,0 +1,61 @@
+package adapters
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+)
+
+// UserMigrations lists the migrations of the users collection.
+func UserMigrations(collectionName string) []migration.Migration {
+	return []migration.Migration{
+		{
+			Version:     2025101801,
+			Module:      "user",
+			Description: "Backfill canonical emails",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				backfilled, collisions, err := NewMongoUserRepository(db, collectionName).BackfillEmailCanonical(ctx)
+				if err != nil {
+					return err
+				}
+				utils.Logger.Info("User migration: Backfilled canonical emails", zap.Int("users", backfilled))
+				for _, collision := range collisions {
+					// These users can only log in with their exact email until an admin resolves the collision.
+					utils.Logger.Warn("User migration: Users share a canonical email",
+						zap.String("canonical_email", collision.CanonicalEmail), zap.Strings("user_ids", collision.UserIDs))
+				}
+				return nil
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(collectionName).UpdateMany(ctx,
+					bson.M{"email_canonical": bson.M{"$exists": true}},
+					bson.M{"$unset": bson.M{"email_canonical": ""}})
+				if err != nil {
+					return fmt.Errorf("failed to remove canonical emails: %w", err)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// UserSQLMigrations lists the migrations of the PostgreSQL users and user_history
+// tables.
+func UserSQLMigrations() ([]migration.SQLMigration, error) {
+	fsys, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	return migration.LoadSQLMigrations(fsys, "user")
+}
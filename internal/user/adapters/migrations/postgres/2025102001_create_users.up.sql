@@ -0,0 +1,45 @@
+CREATE TABLE users (
+    id                              UUID PRIMARY KEY,
+    name                            TEXT NOT NULL,
+    email                           TEXT NOT NULL,
+    -- NULL only for legacy users whose email collides with another account.
+    email_canonical                 TEXT UNIQUE,
+    password                        TEXT NOT NULL,
+    created_at                      TIMESTAMPTZ NOT NULL,
+    updated_at                      TIMESTAMPTZ NOT NULL,
+    is_active                       BOOLEAN NOT NULL DEFAULT TRUE,
+    roles                           TEXT[] NOT NULL DEFAULT '{}',
+    display_name                    TEXT NOT NULL DEFAULT '',
+    avatar_url                      TEXT NOT NULL DEFAULT '',
+    locale                          TEXT NOT NULL DEFAULT '',
+    timezone                        TEXT NOT NULL DEFAULT '',
+    phone                           TEXT NOT NULL DEFAULT '',
+    metadata                        JSONB,
+    totp_secret                     TEXT NOT NULL DEFAULT '',
+    deleted_at                      TIMESTAMPTZ,
+    deactivated_at                  TIMESTAMPTZ,
+    deactivated_by                  TEXT NOT NULL DEFAULT '',
+    deactivation_reason             TEXT NOT NULL DEFAULT '',
+    pending_email                   TEXT,
+    pending_email_confirm_token_hash TEXT,
+    pending_email_cancel_token_hash TEXT,
+    pending_email_requested_at      TIMESTAMPTZ,
+    pending_email_expires_at        TIMESTAMPTZ,
+    erased_at                       TIMESTAMPTZ,
+    version                         BIGINT NOT NULL DEFAULT 1
+);
+
+-- Keyset pagination over the live users, one index per sort field.
+CREATE INDEX users_created_at_id_idx ON users (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX users_updated_at_id_idx ON users (updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX users_name_id_idx ON users (name, id) WHERE deleted_at IS NULL;
+CREATE INDEX users_email_id_idx ON users (email, id) WHERE deleted_at IS NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX users_pending_email_confirm_idx ON users (pending_email_confirm_token_hash) WHERE pending_email_confirm_token_hash IS NOT NULL;
+CREATE INDEX users_pending_email_cancel_idx ON users (pending_email_cancel_token_hash) WHERE pending_email_cancel_token_hash IS NOT NULL;
+
+-- Search: case-insensitive prefix matches and word matches on name and email.
+CREATE INDEX users_lower_email_prefix_idx ON users (lower(email) text_pattern_ops);
+CREATE INDEX users_lower_name_prefix_idx ON users (lower(name) text_pattern_ops);
+CREATE INDEX users_search_idx ON users USING GIN (to_tsvector('simple', name || ' ' || email));
@@ -0,0 +1,16 @@
+CREATE TABLE user_history (
+    -- seq breaks ties between entries recorded at the same time, in insertion order.
+    seq        BIGSERIAL PRIMARY KEY,
+    id         TEXT NOT NULL UNIQUE,
+    -- Not a foreign key: purging a user deletes its history separately.
+    user_id    UUID NOT NULL,
+    operation  TEXT NOT NULL,
+    fields     TEXT[] NOT NULL DEFAULT '{}',
+    before     JSONB,
+    after      JSONB,
+    actor_id   TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    at         TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX user_history_user_id_at_idx ON user_history (user_id, at, seq);
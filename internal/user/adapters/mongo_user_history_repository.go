@@ -0,0 +1,110 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// MongoUserHistoryRepository keeps one document per history entry. It is used
+// with MongoUserRepository.
+type MongoUserHistoryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserHistoryRepository(db *mongo.Database, collectionName string) *MongoUserHistoryRepository {
+	return &MongoUserHistoryRepository{collection: db.Collection(collectionName)}
+}
+
+// newestFirst orders entries by time; entry IDs break ties in insertion order.
+var newestFirst = bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}
+
+func (r *MongoUserHistoryRepository) AddEntries(ctx context.Context, entries []domain.UserHistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(entries))
+	for i := range entries {
+		if entries[i].ID == "" {
+			entries[i].ID = primitive.NewObjectID().Hex()
+		}
+		docs[i] = entries[i]
+	}
+	// Unordered, so that entries stored by an earlier attempt do not stop the others.
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return fmt.Errorf("failed to insert user history entries: %w", err)
+			}
+		}
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert user history entries: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoUserHistoryRepository) ListEntries(ctx context.Context, userID domain.UserID, page domain.HistoryPage) ([]domain.UserHistoryEntry, int64, error) {
+	filter := bson.M{"user_id": userID}
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count user history entries: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(newestFirst).
+		SetSkip(int64(page.Skip())).
+		SetLimit(int64(page.Limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get user history cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	entries := []domain.UserHistoryEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode user history entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+func (r *MongoUserHistoryRepository) LatestEntryAt(ctx context.Context, userID domain.UserID, t time.Time) (*domain.UserHistoryEntry, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID, "at": bson.M{"$lte": t}}, newestFirst)
+}
+
+func (r *MongoUserHistoryRepository) EarliestEntry(ctx context.Context, userID domain.UserID) (*domain.UserHistoryEntry, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID}, bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}})
+}
+
+func (r *MongoUserHistoryRepository) findOne(ctx context.Context, filter bson.M, sort bson.D) (*domain.UserHistoryEntry, error) {
+	var entry domain.UserHistoryEntry
+	if err := r.collection.FindOne(ctx, filter, options.FindOne().SetSort(sort)).Decode(&entry); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrHistoryEntryNotFound
+		}
+		return nil, fmt.Errorf("failed to find user history entry: %w", err)
+	}
+	return &entry, nil
+}
+
+func (r *MongoUserHistoryRepository) DeleteEntries(ctx context.Context, userID domain.UserID) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user history entries: %w", err)
+	}
+	return res.DeletedCount, nil
+}
+
+var _ repository.UserHistoryRepository = (*MongoUserHistoryRepository)(nil)
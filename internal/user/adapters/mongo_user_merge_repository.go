@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// MongoUserMergeRepository keeps one document per merged user, with the source ID as
+// _id so that a user cannot be merged twice. Like the user history it is used
+// whichever backend stores the users themselves.
+type MongoUserMergeRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserMergeRepository(db *mongo.Database, collectionName string) *MongoUserMergeRepository {
+	return &MongoUserMergeRepository{collection: db.Collection(collectionName)}
+}
+
+func (r *MongoUserMergeRepository) CreateMerge(ctx context.Context, merge *domain.UserMerge) error {
+	if _, err := r.collection.InsertOne(ctx, merge); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrUserMerged
+		}
+		return fmt.Errorf("failed to insert user merge: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoUserMergeRepository) GetMerge(ctx context.Context, sourceID domain.UserID) (*domain.UserMerge, error) {
+	var merge domain.UserMerge
+	if err := r.collection.FindOne(ctx, bson.M{"_id": sourceID}).Decode(&merge); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserMergeNotFound
+		}
+		return nil, fmt.Errorf("failed to find user merge: %w", err)
+	}
+	return &merge, nil
+}
+
+func (r *MongoUserMergeRepository) DeleteMerge(ctx context.Context, sourceID domain.UserID) error {
+	if _, err := r.collection.DeleteOne(ctx, bson.M{"_id": sourceID}); err != nil {
+		return fmt.Errorf("failed to delete user merge: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoUserMergeRepository) MarkRepointed(ctx context.Context, sourceID domain.UserID, at time.Time) error {
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": sourceID}, bson.M{"$set": bson.M{"repointed_at": at}}); err != nil {
+		return fmt.Errorf("failed to mark user merge repointed: %w", err)
+	}
+	return nil
+}
+
+func (r *MongoUserMergeRepository) PendingRepoints(ctx context.Context, mergedBefore time.Time, limit int) ([]domain.UserMerge, error) {
+	// repointed_at: null also matches merges without the field.
+	filter := bson.M{"repointed_at": nil, "merged_at": bson.M{"$lt": mergedBefore}}
+	opts := options.Find().SetSort(bson.D{{Key: "merged_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending user merges: %w", err)
+	}
+	defer cursor.Close(ctx)
+	merges := []domain.UserMerge{}
+	if err := cursor.All(ctx, &merges); err != nil {
+		return nil, fmt.Errorf("failed to decode pending user merges: %w", err)
+	}
+	return merges, nil
+}
+
+var _ repository.UserMergeRepository = (*MongoUserMergeRepository)(nil)
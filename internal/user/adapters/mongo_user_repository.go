@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,6 +18,25 @@ import (
 	"github.com/iots1/mingkwan-api/internal/user/repository"
 )
 
+// notDeleted restricts a query to users that have not been soft-deleted. It matches
+// both a missing and a null deleted_at. A fresh map is returned each time so callers
+// can extend the filter without affecting other queries.
+func notDeleted() bson.M {
+	return bson.M{"deleted_at": nil}
+}
+
+// withNotDeleted returns filter restricted to users that have not been soft-deleted.
+func withNotDeleted(filter bson.M) bson.M {
+	if len(filter) == 0 {
+		return notDeleted()
+	}
+	return bson.M{"$and": bson.A{filter, notDeleted()}}
+}
+
+// emailCollation compares emails case-insensitively. The unique index on
+// email_canonical uses it, and queries must too in order to use that index.
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
 type MongoUserRepository struct {
 	collection *mongo.Collection
 }
@@ -27,38 +47,139 @@ func NewMongoUserRepository(db *mongo.Database, collectionName string) *MongoUse
 	}
 }
 
-func (r *MongoUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
+// backfillBatchSize bounds how many canonical email updates are held in memory
+// before they are written.
+const backfillBatchSize = 1000
+
+// BackfillEmailCanonical sets the canonical email of users created before it was
+// stored. Users whose emails share a canonical form are left unchanged and reported,
+// since merging or renaming accounts needs a human decision.
+//
+// Users are streamed in email order under the email collation, so case variants of
+// an email arrive next to each other and only one group is held at a time. Emails
+// that only collide after trimming or IDN conversion are not adjacent; the unique
+// index on email_canonical rejects their update and they are reported too.
+func (r *MongoUserRepository) BackfillEmailCanonical(ctx context.Context) (int, []domain.EmailCollision, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"email": 1, "email_canonical": 1}).
+		SetSort(bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}).
+		SetCollation(emailCollation).
+		SetAllowDiskUse(true).
+		SetBatchSize(backfillBatchSize)
+	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to scan user emails: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	type emailDoc struct {
+		ID             domain.UserID `bson:"_id"`
+		Email          string        `bson:"email"`
+		EmailCanonical string        `bson:"email_canonical"`
+	}
+	var (
+		modified   int
+		collisions []domain.EmailCollision
+		models     []mongo.WriteModel
+		pending    []emailDoc
+		group      []emailDoc
+		groupKey   string
+	)
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if res != nil {
+			modified += int(res.ModifiedCount)
+		}
+		var bulkErr mongo.BulkWriteException
+		if err != nil && errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+			for _, writeErr := range bulkErr.WriteErrors {
+				if !mongo.IsDuplicateKeyError(writeErr) {
+					return fmt.Errorf("failed to backfill canonical emails: %w", err)
+				}
+				doc := pending[writeErr.Index]
+				collisions = append(collisions, domain.EmailCollision{
+					CanonicalEmail: domain.CanonicalEmail(doc.Email),
+					UserIDs:        []string{doc.ID.String()},
+				})
+			}
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to backfill canonical emails: %w", err)
+		}
+		models, pending = models[:0], pending[:0]
+		return nil
+	}
+	closeGroup := func() error {
+		switch {
+		case len(group) > 1:
+			collision := domain.EmailCollision{CanonicalEmail: groupKey}
+			for _, doc := range group {
+				collision.UserIDs = append(collision.UserIDs, doc.ID.String())
+			}
+			collisions = append(collisions, collision)
+		case len(group) == 1 && group[0].EmailCanonical != groupKey:
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": group[0].ID}).
+				SetUpdate(bson.M{"$set": bson.M{"email_canonical": groupKey}}))
+			pending = append(pending, group[0])
+		}
+		group = group[:0]
+		if len(models) >= backfillBatchSize {
+			return flush()
+		}
+		return nil
+	}
 
-	existingUser, err := r.GetUserByEmail(ctx, user.Email)
-	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
-		utils.Logger.Error("MongoUserRepository: Error checking for existing user by email during creation",
-			zap.String("email", user.Email), zap.Error(err))
-		return nil, fmt.Errorf("failed to check for existing user: %w", err)
+	for cursor.Next(ctx) {
+		var doc emailDoc
+		if err := cursor.Decode(&doc); err != nil {
+			return modified, collisions, fmt.Errorf("failed to decode user email: %w", err)
+		}
+		canonical := domain.CanonicalEmail(doc.Email)
+		if len(group) > 0 && canonical != groupKey {
+			if err := closeGroup(); err != nil {
+				return modified, collisions, err
+			}
+		}
+		groupKey = canonical
+		group = append(group, doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return modified, collisions, fmt.Errorf("failed to scan user emails: %w", err)
 	}
-	if existingUser != nil {
-		utils.Logger.Info("MongoUserRepository: User with this email already exists", zap.String("email", user.Email))
-		return nil, domain.ErrUserAlreadyExists
+	if err := closeGroup(); err != nil {
+		return modified, collisions, err
 	}
+	if err := flush(); err != nil {
+		return modified, collisions, err
+	}
+	return modified, collisions, nil
+}
+
+func (r *MongoUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	user.Version = 1
+	user.EmailCanonical = domain.CanonicalEmail(user.Email)
 
+	// The unique index on email_canonical rejects duplicates, also between
+	// concurrent requests.
 	res, err := r.collection.InsertOne(ctx, user)
 	if err != nil {
-		var writeException mongo.WriteException
-		if errors.As(err, &writeException) {
-			for _, we := range writeException.WriteErrors {
-				if we.Code == 11000 {
-					utils.Logger.Warn("MongoUserRepository: Duplicate email found during insert", zap.String("email", user.Email))
-					return nil, domain.ErrUserAlreadyExists
-				}
-			}
+		if mongo.IsDuplicateKeyError(err) {
+			utils.Logger.Info("MongoUserRepository: User with this email already exists", zap.String("email", user.Email))
+			return nil, domain.ErrUserAlreadyExists
 		}
 		utils.Logger.Error("MongoUserRepository: Failed to insert new user", zap.Error(err))
 		return nil, fmt.Errorf("failed to insert user: %w", err)
 	}
 
 	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
-		user.ID = oid
+		user.ID = domain.UserID(oid.Hex())
 	} else {
 		utils.Logger.Warn("MongoUserRepository: Could not convert InsertedID to ObjectID", zap.Any("inserted_id", res.InsertedID))
 		return nil, fmt.Errorf("failed to retrieve inserted ID")
@@ -67,9 +188,70 @@ func (r *MongoUserRepository) CreateUser(ctx context.Context, user *domain.User)
 	return user, nil
 }
 
-func (r *MongoUserRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*domain.User, error) {
+func (r *MongoUserRepository) CreateUsers(ctx context.Context, users []*domain.User) ([]error, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	docs := make([]interface{}, len(users))
+	for i, user := range users {
+		if user.ID.IsZero() {
+			user.ID = domain.UserID(primitive.NewObjectID().Hex())
+		}
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.Version = 1
+		user.EmailCanonical = domain.CanonicalEmail(user.Email)
+		docs[i] = user
+	}
+
+	errs := make([]error, len(users))
+	// Unordered, so that one duplicate does not stop the rest of the batch.
+	_, err := r.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return nil, fmt.Errorf("failed to insert users: %w", err)
+		}
+		for _, we := range bulkErr.WriteErrors {
+			if we.Index < 0 || we.Index >= len(errs) {
+				continue
+			}
+			if we.Code == 11000 {
+				errs[we.Index] = domain.ErrUserAlreadyExists
+			} else {
+				errs[we.Index] = fmt.Errorf("failed to insert user: %s", we.Message)
+			}
+		}
+	}
+	return errs, nil
+}
+
+func (r *MongoUserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	canonical := make([]string, len(emails))
+	for i, email := range emails {
+		canonical[i] = domain.CanonicalEmail(email)
+	}
+	opts := options.Distinct().SetCollation(emailCollation)
+	values, err := r.collection.Distinct(ctx, "email_canonical", bson.M{"email_canonical": bson.M{"$in": canonical}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing emails: %w", err)
+	}
+	for _, v := range values {
+		if email, ok := v.(string); ok {
+			existing[email] = true
+		}
+	}
+	return existing, nil
+}
+
+func (r *MongoUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	err := r.collection.FindOne(ctx, withNotDeleted(bson.M{"_id": id})).Decode(&user)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, domain.ErrUserNotFound
@@ -79,9 +261,32 @@ func (r *MongoUserRepository) GetUserByID(ctx context.Context, id primitive.Obje
 	return &user, nil
 }
 
+func (r *MongoUserRepository) GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*domain.User, error) {
+	filter := withNotDeleted(bson.M{"$or": bson.A{
+		bson.M{"pending_email.confirm_token_hash": tokenHash},
+		bson.M{"pending_email.cancel_token_hash": tokenHash},
+	}})
+	var user domain.User
+	if err := r.collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user by email change token: %w", err)
+	}
+	return &user, nil
+}
+
 func (r *MongoUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	filter := bson.M{"email_canonical": domain.CanonicalEmail(email)}
+	err := r.collection.FindOne(ctx, withNotDeleted(filter), options.FindOne().SetCollation(emailCollation)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Users left without a canonical email by a collision are still found by
+		// their exact email. This lookup is deliberately case-sensitive: a
+		// case-insensitive match would pick one of the colliding accounts arbitrarily.
+		legacy := bson.M{"email_canonical": bson.M{"$exists": false}, "email": email}
+		err = r.collection.FindOne(ctx, withNotDeleted(legacy)).Decode(&user)
+	}
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, domain.ErrUserNotFound
@@ -91,46 +296,273 @@ func (r *MongoUserRepository) GetUserByEmail(ctx context.Context, email string)
 	return &user, nil
 }
 
-func (r *MongoUserRepository) GetAllUsers(ctx context.Context) ([]domain.User, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{})
+// ListUsers returns one page of users. Offset pagination (Page) is convenient for
+// small result sets; cursor pagination keeps deep pages cheap because it seeks on
+// the sort key instead of skipping documents.
+func (r *MongoUserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error) {
+	query := buildUserQuery(filter)
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	direction := 1
+	if filter.SortDesc {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: filter.SortBy, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(filter.Limit) + 1) // One extra document tells whether there is a next page
+
+	if filter.Cursor != "" {
+		condition, err := cursorCondition(filter.Cursor, filter.SortBy, filter.SortDesc)
+		if err != nil {
+			return nil, err
+		}
+		query = bson.M{"$and": bson.A{query, condition}}
+	} else {
+		opts.SetSkip(int64((filter.Page - 1) * filter.Limit))
+	}
+
+	cursor, err := r.collection.Find(ctx, query, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users cursor: %w", err)
 	}
 	defer cursor.Close(ctx)
 
-	var users []domain.User
+	users := make([]domain.User, 0, filter.Limit)
 	if err = cursor.All(ctx, &users); err != nil {
 		return nil, fmt.Errorf("failed to decode users: %w", err)
 	}
-	return users, nil
+
+	page := &domain.UserPage{Users: users, Total: total}
+	if len(users) > filter.Limit {
+		page.Users = users[:filter.Limit]
+		page.NextCursor = encodeUserCursor(&page.Users[filter.Limit-1], filter.SortBy, filter.SortDesc)
+	}
+	return page, nil
 }
 
-func (r *MongoUserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) (*domain.User, error) {
-	filter := bson.M{"_id": id}
-	update["updated_at"] = time.Now()
-	updateDoc := bson.M{"$set": update}
+func (r *MongoUserRepository) StreamUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	direction := 1
+	if filter.SortDesc {
+		direction = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: filter.SortBy, Value: direction}, {Key: "_id", Value: direction}}).
+		SetProjection(bson.M{"password": 0, "totp_secret": 0}).
+		SetBatchSize(500)
+
+	cursor, err := r.collection.Find(ctx, buildUserQuery(filter), opts)
+	if err != nil {
+		return fmt.Errorf("failed to get users cursor: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user domain.User
+		if err := cursor.Decode(&user); err != nil {
+			return fmt.Errorf("failed to decode user: %w", err)
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("users cursor error: %w", err)
+	}
+	return nil
+}
+
+func buildUserQuery(filter domain.UserFilter) bson.M {
+	query := bson.M{"deleted_at": nil}
+	if filter.IsActive != nil {
+		query["is_active"] = *filter.IsActive
+	}
+	createdAt := bson.M{}
+	if !filter.CreatedFrom.IsZero() {
+		createdAt["$gte"] = filter.CreatedFrom
+	}
+	if !filter.CreatedTo.IsZero() {
+		createdAt["$lt"] = filter.CreatedTo
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	if filter.EmailDomain != "" {
+		query["email"] = primitive.Regex{Pattern: "@" + regexp.QuoteMeta(filter.EmailDomain) + "$", Options: "i"}
+	}
+	return query
+}
+
+func (r *MongoUserRepository) UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	filter := withNotDeleted(bson.M{"_id": id})
+	if expectedVersion != domain.AnyVersion {
+		filter["version"] = versionMatch(expectedVersion)
+	}
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	if email, ok := update["email"].(string); ok {
+		set["email_canonical"] = domain.CanonicalEmail(email)
+	}
+	for field, value := range update {
+		if value == nil {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	updateDoc := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		updateDoc["$unset"] = unset
+	}
 
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedUser domain.User
 	err := r.collection.FindOneAndUpdate(ctx, filter, updateDoc, opts).Decode(&updatedUser)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, domain.ErrUserNotFound
+			if expectedVersion == domain.AnyVersion {
+				return nil, domain.ErrUserNotFound
+			}
+			// The filter also failed if the version moved on; tell the two apart.
+			if _, getErr := r.GetUserByID(ctx, id); getErr != nil {
+				return nil, getErr
+			}
+			return nil, domain.ErrVersionConflict
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, domain.ErrUserAlreadyExists
 		}
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 	return &updatedUser, nil
 }
 
-func (r *MongoUserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
-	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+// versionMatch matches the given version. Documents written before versioning have
+// no version field and count as version 0.
+func versionMatch(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+func (r *MongoUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	now := time.Now()
+	res, err := r.collection.UpdateOne(ctx, withNotDeleted(bson.M{"_id": id}),
+		bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}, "$inc": bson.M{"version": 1}})
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
+	if res.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *MongoUserRepository) SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error) {
+	now := time.Now()
+	var update bson.M
+	if active {
+		update = bson.M{
+			"$set":   bson.M{"is_active": true, "updated_at": now},
+			"$unset": bson.M{"deactivated_at": "", "deactivated_by": "", "deactivation_reason": ""},
+			"$inc":   bson.M{"version": 1},
+		}
+	} else {
+		update = bson.M{"$set": bson.M{
+			"is_active":           false,
+			"deactivated_at":      now,
+			"deactivated_by":      actorID,
+			"deactivation_reason": reason,
+			"updated_at":          now,
+		}, "$inc": bson.M{"version": 1}}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated domain.User
+	if err := r.collection.FindOneAndUpdate(ctx, withNotDeleted(bson.M{"_id": id}), update, opts).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to change user activation: %w", err)
+	}
+	return &updated, nil
+}
+
+// RestoreUser clears the deletion mark of a soft-deleted user. It returns
+// domain.ErrUserNotFound if no soft-deleted user has the given ID.
+func (r *MongoUserRepository) RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	filter := bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}}
+	update := bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": time.Now()}, "$inc": bson.M{"version": 1}}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var restored domain.User
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&restored); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+	return &restored, nil
+}
+
+// ListDeletedBefore returns up to limit users soft-deleted before cutoff, oldest first.
+func (r *MongoUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.User, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": cutoff}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted users: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var users []domain.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode deleted users: %w", err)
+	}
+	return users, nil
+}
+
+// PurgeUser permanently removes a soft-deleted user. Users that are not
+// soft-deleted (e.g. restored in the meantime) are left alone.
+func (r *MongoUserRepository) PurgeUser(ctx context.Context, id domain.UserID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
 	if res.DeletedCount == 0 {
 		return domain.ErrUserNotFound
 	}
 	return nil
 }
 
+func (r *MongoUserRepository) AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"name":            "Erased user",
+			"email":           "erased-" + id.String() + "@erased.invalid", // keeps the email unique
+			"email_canonical": "erased-" + id.String() + "@erased.invalid",
+			"password":        "", // matches no password
+			"is_active":       false,
+			"erased_at":       erasedAt,
+			"updated_at":      erasedAt,
+		},
+		"$unset": bson.M{
+			"totp_secret": "", "display_name": "", "avatar_url": "", "locale": "", "timezone": "",
+			"phone": "", "metadata": "", "deactivated_by": "", "deactivation_reason": "", "pending_email": "",
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
 var _ repository.UserRepository = (*MongoUserRepository)(nil)
@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/repository/repositorytest"
+)
+
+// TestMongoUserRepository runs the conformance suite against a real MongoDB. Set
+// MONGO_TEST_URI to run it; every subtest uses a throwaway database.
+func TestMongoUserRepository(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	n := 0
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		n++
+		db := client.Database(fmt.Sprintf("mingkwan_test_%d_%d", os.Getpid(), n))
+		t.Cleanup(func() { db.Drop(context.Background()) })
+		if err := migration.EnsureIndexes(context.Background(), db, []migration.IndexSpec{UserIndexes("users")}); err != nil {
+			t.Fatalf("EnsureIndexes: %v", err)
+		}
+		return NewMongoUserRepository(db, "users")
+	})
+}
+
+// TestMongoUserHistoryRepository runs the history conformance suite against a real
+// MongoDB, like TestMongoUserRepository.
+func TestMongoUserHistoryRepository(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	n := 0
+	repositorytest.TestUserHistoryRepository(t, func(t *testing.T) repository.UserHistoryRepository {
+		n++
+		db := client.Database(fmt.Sprintf("mingkwan_history_test_%d_%d", os.Getpid(), n))
+		t.Cleanup(func() { db.Drop(context.Background()) })
+		if err := migration.EnsureIndexes(context.Background(), db, []migration.IndexSpec{UserHistoryIndexes("user_history")}); err != nil {
+			t.Fatalf("EnsureIndexes: %v", err)
+		}
+		return NewMongoUserHistoryRepository(db, "user_history")
+	})
+}
@@ -0,0 +1,164 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+const (
+	// searchCandidateLimit caps how many documents each strategy contributes before
+	// ranking, which bounds the cost of very broad queries such as "a".
+	searchCandidateLimit = 500
+
+	// Prefix matches rank above text matches: support staff usually type the start
+	// of an email address or name they already know.
+	scoreExactEmail  = 100.0
+	scoreEmailPrefix = 50.0
+	scoreNamePrefix  = 30.0
+	textScoreWeight  = 10.0
+)
+
+// caseInsensitive is a strength 2 collation: comparisons ignore case but not
+// diacritics. Prefix queries must use the same collation as the index to use it.
+var caseInsensitive = &options.Collation{Locale: "en", Strength: 2}
+
+// MongoUserSearcher implements UserSearcher with a text index on name and email for
+// word matches, and case-insensitive indexes on email and name for prefix matches.
+type MongoUserSearcher struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserSearcher(db *mongo.Database, collectionName string) *MongoUserSearcher {
+	return &MongoUserSearcher{collection: db.Collection(collectionName)}
+}
+
+func (s *MongoUserSearcher) SearchUsers(ctx context.Context, query domain.UserSearchQuery) (*domain.UserSearchResult, error) {
+	text := strings.TrimSpace(query.Text)
+	prefixMatches, err := s.findByPrefix(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	textMatches, err := s.findByText(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	scored := make([]domain.UserSearchHit, len(textMatches))
+	for i, match := range textMatches {
+		scored[i] = domain.UserSearchHit{User: match.User, Score: match.Score}
+	}
+	return rankSearchHits(text, prefixMatches, scored, query), nil
+}
+
+// findByPrefix matches emails and names starting with prefix, ignoring case. The
+// range query uses the collated indexes; U+FFFF sorts after every other character
+// in the collation, so it closes the range.
+func (s *MongoUserSearcher) findByPrefix(ctx context.Context, prefix string) ([]domain.User, error) {
+	upper := prefix + "\uffff"
+	filter := bson.M{
+		"deleted_at": nil,
+		"$or": bson.A{
+			bson.M{"email": bson.M{"$gte": prefix, "$lt": upper}},
+			bson.M{"name": bson.M{"$gte": prefix, "$lt": upper}},
+		},
+	}
+	opts := options.Find().SetCollation(caseInsensitive).SetLimit(searchCandidateLimit)
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run prefix search: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var users []domain.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to decode prefix search results: %w", err)
+	}
+	return users, nil
+}
+
+type textMatch struct {
+	domain.User `bson:",inline"`
+	Score       float64 `bson:"score"`
+}
+
+func (s *MongoUserSearcher) findByText(ctx context.Context, text string) ([]textMatch, error) {
+	filter := bson.M{"$text": bson.M{"$search": text}, "deleted_at": nil}
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(searchCandidateLimit)
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run text search: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var matches []textMatch
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, fmt.Errorf("failed to decode text search results: %w", err)
+	}
+	return matches, nil
+}
+
+// rankSearchHits merges the prefix and text matches of a search, adding up the
+// scores of users found by both, and returns the requested page, best first.
+func rankSearchHits(text string, prefixMatches []domain.User, textMatches []domain.UserSearchHit, query domain.UserSearchQuery) *domain.UserSearchResult {
+	hits := make(map[string]*domain.UserSearchHit)
+	merge := func(user domain.User, score float64) {
+		id := user.ID.String()
+		if existing, ok := hits[id]; ok {
+			existing.Score += score
+			return
+		}
+		hits[id] = &domain.UserSearchHit{User: user, Score: score}
+	}
+	for _, user := range prefixMatches {
+		merge(user, prefixScore(user, text))
+	}
+	for _, match := range textMatches {
+		merge(match.User, match.Score*textScoreWeight)
+	}
+
+	ranked := make([]domain.UserSearchHit, 0, len(hits))
+	for _, hit := range hits {
+		ranked = append(ranked, *hit)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].User.ID.String() < ranked[j].User.ID.String()
+	})
+
+	result := &domain.UserSearchResult{Total: int64(len(ranked))}
+	start := (query.Page - 1) * query.Limit
+	if start < len(ranked) {
+		end := min(start+query.Limit, len(ranked))
+		result.Hits = ranked[start:end]
+	}
+	return result
+}
+
+func prefixScore(user domain.User, prefix string) float64 {
+	switch {
+	case strings.EqualFold(user.Email, prefix):
+		return scoreExactEmail
+	case strings.HasPrefix(strings.ToLower(user.Email), strings.ToLower(prefix)):
+		return scoreEmailPrefix
+	default:
+		return scoreNamePrefix
+	}
+}
+
+var _ repository.UserSearcher = (*MongoUserSearcher)(nil)
@@ -0,0 +1,180 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+const selectHistoryColumns = "id, user_id::text, operation, fields, before, after, actor_id, request_id, at"
+
+// PostgresUserHistoryRepository implements UserHistoryRepository on the
+// user_history table created by UserSQLMigrations. It is used with
+// PostgresUserRepository; snapshots are stored as JSON.
+type PostgresUserHistoryRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserHistoryRepository(pool *pgxpool.Pool) *PostgresUserHistoryRepository {
+	return &PostgresUserHistoryRepository{pool: pool}
+}
+
+func (r *PostgresUserHistoryRepository) AddEntries(ctx context.Context, entries []domain.UserHistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID == "" {
+			entry.ID = primitive.NewObjectID().Hex()
+		}
+		uid, ok := postgresUserID(entry.UserID)
+		if !ok {
+			return fmt.Errorf("failed to insert user history entries: invalid user ID %q", entry.UserID)
+		}
+		before, err := snapshotValue(entry.Before)
+		if err != nil {
+			return err
+		}
+		after, err := snapshotValue(entry.After)
+		if err != nil {
+			return err
+		}
+		fields := entry.Fields
+		if fields == nil {
+			fields = []string{}
+		}
+		batch.Queue(`INSERT INTO user_history (id, user_id, operation, fields, before, after, actor_id, request_id, at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (id) DO NOTHING`,
+			entry.ID, uid, entry.Operation, fields, before, after, entry.ActorID, entry.RequestID, pgTime(entry.At))
+	}
+	// A batch runs in one implicit transaction: either all entries are stored or none.
+	if err := r.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert user history entries: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresUserHistoryRepository) ListEntries(ctx context.Context, userID domain.UserID, page domain.HistoryPage) ([]domain.UserHistoryEntry, int64, error) {
+	entries := []domain.UserHistoryEntry{}
+	uid, ok := postgresUserID(userID)
+	if !ok {
+		return entries, 0, nil
+	}
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM user_history WHERE user_id = $1", uid).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count user history entries: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, "SELECT "+selectHistoryColumns+" FROM user_history WHERE user_id = $1 ORDER BY at DESC, seq DESC OFFSET $2 LIMIT $3",
+		uid, page.Skip(), page.Limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query user history entries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode user history entries: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query user history entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+func (r *PostgresUserHistoryRepository) LatestEntryAt(ctx context.Context, userID domain.UserID, t time.Time) (*domain.UserHistoryEntry, error) {
+	return r.findOne(ctx, userID, "AND at <= $2 ORDER BY at DESC, seq DESC", t)
+}
+
+func (r *PostgresUserHistoryRepository) EarliestEntry(ctx context.Context, userID domain.UserID) (*domain.UserHistoryEntry, error) {
+	return r.findOne(ctx, userID, "ORDER BY at, seq")
+}
+
+// findOne returns the first entry of the user in the order of clause, which may
+// refer to the arguments after the user ID from $2 on.
+func (r *PostgresUserHistoryRepository) findOne(ctx context.Context, userID domain.UserID, clause string, args ...any) (*domain.UserHistoryEntry, error) {
+	uid, ok := postgresUserID(userID)
+	if !ok {
+		return nil, domain.ErrHistoryEntryNotFound
+	}
+	row := r.pool.QueryRow(ctx, "SELECT "+selectHistoryColumns+" FROM user_history WHERE user_id = $1 "+clause+" LIMIT 1",
+		append([]any{uid}, args...)...)
+	entry, err := scanHistoryEntry(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrHistoryEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user history entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (r *PostgresUserHistoryRepository) DeleteEntries(ctx context.Context, userID domain.UserID) (int64, error) {
+	uid, ok := postgresUserID(userID)
+	if !ok {
+		return 0, nil
+	}
+	tag, err := r.pool.Exec(ctx, "DELETE FROM user_history WHERE user_id = $1", uid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user history entries: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanHistoryEntry(row pgx.Row) (*domain.UserHistoryEntry, error) {
+	var (
+		entry         domain.UserHistoryEntry
+		userID        string
+		before, after []byte
+	)
+	if err := row.Scan(&entry.ID, &userID, &entry.Operation, &entry.Fields, &before, &after,
+		&entry.ActorID, &entry.RequestID, &entry.At); err != nil {
+		return nil, err
+	}
+	entry.UserID = domain.UserID(userID)
+	var err error
+	if entry.Before, err = decodeSnapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = decodeSnapshot(after); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func snapshotValue(snapshot *domain.UserSnapshot) (any, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user snapshot: %w", err)
+	}
+	return raw, nil
+}
+
+func decodeSnapshot(raw []byte) (*domain.UserSnapshot, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var snapshot domain.UserSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode user snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+var _ repository.UserHistoryRepository = (*PostgresUserHistoryRepository)(nil)
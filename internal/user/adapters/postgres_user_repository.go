@@ -0,0 +1,670 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// pendingEmailColumns store domain.PendingEmailChange, all NULL if there is none.
+var pendingEmailColumns = []string{
+	"pending_email", "pending_email_confirm_token_hash", "pending_email_cancel_token_hash",
+	"pending_email_requested_at", "pending_email_expires_at",
+}
+
+// userColumns are the columns of the users table in the order of userValues.
+var userColumns = append(append([]string{
+	"id", "name", "email", "email_canonical", "password", "created_at", "updated_at", "is_active", "roles",
+	"display_name", "avatar_url", "locale", "timezone", "phone", "metadata", "totp_secret",
+	"deleted_at", "deactivated_at", "deactivated_by", "deactivation_reason",
+}, pendingEmailColumns...), "erased_at", "version")
+
+// selectUserColumns reads userColumns in the order scanUser expects.
+var selectUserColumns = "id::text, " + strings.Join(userColumns[1:], ", ")
+
+// streamUserColumns is selectUserColumns without credentials.
+var streamUserColumns = strings.NewReplacer("password,", "'' AS password,", "totp_secret,", "'' AS totp_secret,").
+	Replace(selectUserColumns)
+
+// userSortColumns maps the sort fields of domain.UserFilter to columns. Only these
+// may be interpolated into queries.
+var userSortColumns = map[string]string{
+	domain.SortByCreatedAt: "created_at",
+	domain.SortByUpdatedAt: "updated_at",
+	domain.SortByName:      "name",
+	domain.SortByEmail:     "email",
+}
+
+// textUpdateColumns are the update keys stored in a plain text column. A nil value
+// stores the empty string, since the columns are not nullable.
+var textUpdateColumns = map[string]bool{
+	"name": true, "password": true, "display_name": true, "avatar_url": true,
+	"locale": true, "timezone": true, "phone": true, "totp_secret": true,
+}
+
+// PostgresUserRepository implements UserRepository on the users table created by
+// UserSQLMigrations. IDs are UUIDs; an ID of any other form matches no user.
+type PostgresUserRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserRepository(pool *pgxpool.Pool) *PostgresUserRepository {
+	return &PostgresUserRepository{pool: pool}
+}
+
+func (r *PostgresUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	prepareNewUser(user, pgNow())
+	values, err := userValues(user)
+	if err != nil {
+		return nil, err
+	}
+
+	// The unique index on email_canonical rejects duplicates, also between
+	// concurrent requests.
+	if _, err := r.pool.Exec(ctx, insertUserSQL(""), values...); err != nil {
+		if isUniqueViolation(err) {
+			utils.Logger.Info("PostgresUserRepository: User with this email already exists", zap.String("email", user.Email))
+			return nil, domain.ErrUserAlreadyExists
+		}
+		utils.Logger.Error("PostgresUserRepository: Failed to insert new user", zap.Error(err))
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *PostgresUserRepository) CreateUsers(ctx context.Context, users []*domain.User) ([]error, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	createdAt := pgNow()
+	errs := make([]error, len(users))
+	batch := &pgx.Batch{}
+	queued := make([]int, 0, len(users))
+	for i, user := range users {
+		prepareNewUser(user, createdAt)
+		values, err := userValues(user)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		// ON CONFLICT skips duplicates instead of aborting the whole batch.
+		batch.Queue(insertUserSQL("ON CONFLICT DO NOTHING RETURNING id::text"), values...)
+		queued = append(queued, i)
+	}
+
+	results := r.pool.SendBatch(ctx, batch)
+	defer results.Close()
+	for _, i := range queued {
+		var id string
+		err := results.QueryRow().Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			errs[i] = domain.ErrUserAlreadyExists
+		} else if err != nil {
+			// A batch runs in one implicit transaction, so any other error rolled back
+			// the users inserted before it, too.
+			return nil, fmt.Errorf("failed to insert users: %w", err)
+		}
+	}
+	return errs, nil
+}
+
+func (r *PostgresUserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	canonical := make([]string, len(emails))
+	for i, email := range emails {
+		canonical[i] = domain.CanonicalEmail(email)
+	}
+	rows, err := r.pool.Query(ctx, "SELECT email_canonical FROM users WHERE email_canonical = ANY($1)", canonical)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing emails: %w", err)
+	}
+	found, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing emails: %w", err)
+	}
+	for _, email := range found {
+		existing[email] = true
+	}
+	return existing, nil
+}
+
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	user, err := r.findOne(ctx, "id = $1 AND deleted_at IS NULL", uid)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to find user by ID: %w", err)
+	}
+	return user, err
+}
+
+func (r *PostgresUserRepository) GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*domain.User, error) {
+	user, err := r.findOne(ctx, "(pending_email_confirm_token_hash = $1 OR pending_email_cancel_token_hash = $1) AND deleted_at IS NULL", tokenHash)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to find user by email change token: %w", err)
+	}
+	return user, err
+}
+
+func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	// Users left without a canonical email by a collision are still found by their
+	// exact email.
+	user, err := r.findOne(ctx, "(email_canonical = $1 OR (email_canonical IS NULL AND lower(email) = lower($2))) AND deleted_at IS NULL",
+		domain.CanonicalEmail(email), email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to find user by email: %w", err)
+	}
+	return user, err
+}
+
+// findOne returns the first user matching the where clause, or domain.ErrUserNotFound.
+func (r *PostgresUserRepository) findOne(ctx context.Context, where string, args ...any) (*domain.User, error) {
+	row := r.pool.QueryRow(ctx, "SELECT "+selectUserColumns+" FROM users WHERE "+where+" LIMIT 1", args...)
+	user, err := scanUser(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, err
+}
+
+// ListUsers returns one page of users, see MongoUserRepository.ListUsers. The cursor
+// condition compares (sort column, id) as a row, which the sort indexes serve.
+func (r *PostgresUserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error) {
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", filter.SortBy)
+	}
+	where, args := buildUserWhere(filter)
+
+	var total int64
+	if err := r.pool.QueryRow(ctx, "SELECT count(*) FROM users WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	direction, op := "ASC", ">"
+	if filter.SortDesc {
+		direction, op = "DESC", "<"
+	}
+	if filter.Cursor != "" {
+		value, id, err := decodeUserCursor(filter.Cursor, filter.SortBy, filter.SortDesc)
+		if err != nil {
+			return nil, err
+		}
+		uid, ok := postgresUserID(id)
+		if !ok {
+			return nil, domain.ErrInvalidCursor
+		}
+		args = append(args, value, uid)
+		where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d::uuid)", column, op, len(args)-1, len(args))
+	}
+	query := fmt.Sprintf("SELECT %s FROM users WHERE %s ORDER BY %s %s, id %s LIMIT %d",
+		selectUserColumns, where, column, direction, direction, filter.Limit+1) // One extra row tells whether there is a next page
+	if filter.Cursor == "" {
+		query += " OFFSET " + strconv.Itoa((filter.Page-1)*filter.Limit)
+	}
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	users, err := collectUsers(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+
+	page := &domain.UserPage{Users: users, Total: total}
+	if len(users) > filter.Limit {
+		page.Users = users[:filter.Limit]
+		page.NextCursor = encodeUserCursor(&page.Users[filter.Limit-1], filter.SortBy, filter.SortDesc)
+	}
+	return page, nil
+}
+
+func (r *PostgresUserRepository) StreamUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		return fmt.Errorf("unsupported sort field %q", filter.SortBy)
+	}
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+	where, args := buildUserWhere(filter)
+	rows, err := r.pool.Query(ctx, fmt.Sprintf("SELECT %s FROM users WHERE %s ORDER BY %s %s, id %s",
+		streamUserColumns, where, column, direction, direction), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return fmt.Errorf("failed to decode user: %w", err)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("users query error: %w", err)
+	}
+	return nil
+}
+
+// buildUserWhere returns the where clause selecting the live users matching filter,
+// with its arguments.
+func buildUserWhere(filter domain.UserFilter) (string, []any) {
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.IsActive != nil {
+		add("is_active = $%d", *filter.IsActive)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		add("created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		add("created_at < $%d", filter.CreatedTo)
+	}
+	if filter.EmailDomain != "" {
+		add(`lower(email) LIKE $%d ESCAPE '\'`, "%@"+escapeLike(strings.ToLower(filter.EmailDomain)))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (r *PostgresUserRepository) UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	set, args, err := updateAssignments(update)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, pgNow(), uid)
+	set = append(set, fmt.Sprintf("updated_at = $%d", len(args)-1), "version = version + 1")
+	where := fmt.Sprintf("id = $%d AND deleted_at IS NULL", len(args))
+	if expectedVersion != domain.AnyVersion {
+		args = append(args, expectedVersion)
+		where += fmt.Sprintf(" AND version = $%d", len(args))
+	}
+
+	row := r.pool.QueryRow(ctx, "UPDATE users SET "+strings.Join(set, ", ")+" WHERE "+where+" RETURNING "+selectUserColumns, args...)
+	updated, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if expectedVersion == domain.AnyVersion {
+				return nil, domain.ErrUserNotFound
+			}
+			// The update also matched nothing if the version moved on; tell the two apart.
+			if _, getErr := r.GetUserByID(ctx, id); getErr != nil {
+				return nil, getErr
+			}
+			return nil, domain.ErrVersionConflict
+		}
+		if isUniqueViolation(err) {
+			return nil, domain.ErrUserAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return updated, nil
+}
+
+// updateAssignments translates the field names of an UpdateUser map, which follow
+// the BSON names of domain.User, into column assignments.
+func updateAssignments(update map[string]interface{}) ([]string, []any, error) {
+	var (
+		set  []string
+		args []any
+	)
+	assign := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	for field, value := range update {
+		switch {
+		case textUpdateColumns[field]:
+			text, ok := value.(string)
+			if value != nil && !ok {
+				return nil, nil, fmt.Errorf("field %s must be a string, got %T", field, value)
+			}
+			assign(field, text)
+		case field == "email":
+			email, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("field email must be a string, got %T", value)
+			}
+			assign("email", email)
+			assign("email_canonical", domain.CanonicalEmail(email))
+		case field == "metadata":
+			metadata, ok := value.(map[string]string)
+			if value != nil && !ok {
+				return nil, nil, fmt.Errorf("field metadata must be a map[string]string, got %T", value)
+			}
+			encoded, err := metadataValue(metadata)
+			if err != nil {
+				return nil, nil, err
+			}
+			assign("metadata", encoded)
+		case field == "pending_email":
+			var change *domain.PendingEmailChange
+			switch v := value.(type) {
+			case nil:
+			case *domain.PendingEmailChange:
+				change = v
+			case domain.PendingEmailChange:
+				change = &v
+			default:
+				return nil, nil, fmt.Errorf("field pending_email must be a PendingEmailChange, got %T", value)
+			}
+			for i, v := range pendingEmailValues(change) {
+				assign(pendingEmailColumns[i], v)
+			}
+		default:
+			return nil, nil, fmt.Errorf("field %s cannot be updated", field)
+		}
+	}
+	return set, args, nil
+}
+
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	tag, err := r.pool.Exec(ctx, "UPDATE users SET deleted_at = $1, updated_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL", pgNow(), uid)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresUserRepository) SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error) {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	at := pgNow()
+	var query string
+	var args []any
+	if active {
+		query = `UPDATE users SET is_active = TRUE, deactivated_at = NULL, deactivated_by = '', deactivation_reason = '',
+			updated_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL RETURNING ` + selectUserColumns
+		args = []any{at, uid}
+	} else {
+		query = `UPDATE users SET is_active = FALSE, deactivated_at = $1, deactivated_by = $3, deactivation_reason = $4,
+			updated_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL RETURNING ` + selectUserColumns
+		args = []any{at, uid, actorID, reason}
+	}
+	updated, err := scanUser(r.pool.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to change user activation: %w", err)
+	}
+	return updated, nil
+}
+
+// RestoreUser clears the deletion mark of a soft-deleted user. It returns
+// domain.ErrUserNotFound if no soft-deleted user has the given ID.
+func (r *PostgresUserRepository) RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	restored, err := scanUser(r.pool.QueryRow(ctx, `UPDATE users SET deleted_at = NULL, updated_at = $1, version = version + 1
+		WHERE id = $2 AND deleted_at IS NOT NULL RETURNING `+selectUserColumns, pgNow(), uid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+	return restored, nil
+}
+
+// ListDeletedBefore returns up to limit users soft-deleted before cutoff, oldest first.
+func (r *PostgresUserRepository) ListDeletedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.User, error) {
+	rows, err := r.pool.Query(ctx, "SELECT "+selectUserColumns+" FROM users WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2", cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find deleted users: %w", err)
+	}
+	users, err := collectUsers(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode deleted users: %w", err)
+	}
+	return users, nil
+}
+
+// PurgeUser permanently removes a soft-deleted user. Users that are not
+// soft-deleted (e.g. restored in the meantime) are left alone.
+func (r *PostgresUserRepository) PurgeUser(ctx context.Context, id domain.UserID) error {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	tag, err := r.pool.Exec(ctx, "DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL", uid)
+	if err != nil {
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresUserRepository) AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error {
+	uid, ok := postgresUserID(id)
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	erasedEmail := "erased-" + uid + "@erased.invalid" // keeps the email unique
+	tag, err := r.pool.Exec(ctx, `UPDATE users SET
+		name = 'Erased user', email = $1, email_canonical = $1, password = '', is_active = FALSE,
+		totp_secret = '', display_name = '', avatar_url = '', locale = '', timezone = '', phone = '', metadata = NULL,
+		deactivated_by = '', deactivation_reason = '',
+		pending_email = NULL, pending_email_confirm_token_hash = NULL, pending_email_cancel_token_hash = NULL,
+		pending_email_requested_at = NULL, pending_email_expires_at = NULL,
+		erased_at = $2, updated_at = $2, version = version + 1
+		WHERE id = $3`, erasedEmail, pgTime(erasedAt), uid)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// prepareNewUser assigns the ID, timestamps, version and canonical email of a user
+// about to be inserted.
+func prepareNewUser(user *domain.User, createdAt time.Time) {
+	if user.ID.IsZero() {
+		user.ID = domain.UserID(uuid.NewString())
+	}
+	user.CreatedAt = createdAt
+	user.UpdatedAt = createdAt
+	user.Version = 1
+	user.EmailCanonical = domain.CanonicalEmail(user.Email)
+}
+
+func insertUserSQL(suffix string) string {
+	placeholders := make([]string, len(userColumns))
+	for i := range placeholders {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	return "INSERT INTO users (" + strings.Join(userColumns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ") " + suffix
+}
+
+// userValues returns the column values of user in the order of userColumns.
+func userValues(user *domain.User) ([]any, error) {
+	uid, ok := postgresUserID(user.ID)
+	if !ok {
+		return nil, fmt.Errorf("user ID %q is not a UUID: %w", user.ID, domain.ErrInvalidUserID)
+	}
+	metadata, err := metadataValue(user.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	values := []any{
+		uid, user.Name, user.Email, nullIfEmpty(user.EmailCanonical), user.Password,
+		pgTime(user.CreatedAt), pgTime(user.UpdatedAt), user.IsActive, roles,
+		user.DisplayName, user.AvatarURL, user.Locale, user.Timezone, user.Phone, metadata, user.TOTPSecret,
+		pgTimePtr(user.DeletedAt), pgTimePtr(user.DeactivatedAt), user.DeactivatedBy, user.DeactivationReason,
+	}
+	values = append(values, pendingEmailValues(user.PendingEmail)...)
+	return append(values, pgTimePtr(user.ErasedAt), user.Version), nil
+}
+
+// pendingEmailValues returns the values of the five pending_email columns.
+func pendingEmailValues(change *domain.PendingEmailChange) []any {
+	if change == nil {
+		return []any{nil, nil, nil, nil, nil}
+	}
+	return []any{change.Email, change.ConfirmTokenHash, change.CancelTokenHash,
+		pgTime(change.RequestedAt), pgTime(change.ExpiresAt)}
+}
+
+func scanUser(row pgx.Row) (*domain.User, error) {
+	var (
+		user                           domain.User
+		id                             string
+		emailCanonical                 *string
+		metadata                       []byte
+		pendingEmail, confirm, cancel  *string
+		pendingRequested, pendingUntil *time.Time
+	)
+	err := row.Scan(&id, &user.Name, &user.Email, &emailCanonical, &user.Password, &user.CreatedAt, &user.UpdatedAt,
+		&user.IsActive, &user.Roles, &user.DisplayName, &user.AvatarURL, &user.Locale, &user.Timezone, &user.Phone,
+		&metadata, &user.TOTPSecret, &user.DeletedAt, &user.DeactivatedAt, &user.DeactivatedBy, &user.DeactivationReason,
+		&pendingEmail, &confirm, &cancel, &pendingRequested, &pendingUntil, &user.ErasedAt, &user.Version)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = domain.UserID(id)
+	if emailCanonical != nil {
+		user.EmailCanonical = *emailCanonical
+	}
+	if len(user.Roles) == 0 {
+		user.Roles = nil
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &user.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode user metadata: %w", err)
+		}
+	}
+	if pendingEmail != nil {
+		user.PendingEmail = &domain.PendingEmailChange{Email: *pendingEmail}
+		if confirm != nil {
+			user.PendingEmail.ConfirmTokenHash = *confirm
+		}
+		if cancel != nil {
+			user.PendingEmail.CancelTokenHash = *cancel
+		}
+		if pendingRequested != nil {
+			user.PendingEmail.RequestedAt = *pendingRequested
+		}
+		if pendingUntil != nil {
+			user.PendingEmail.ExpiresAt = *pendingUntil
+		}
+	}
+	return &user, nil
+}
+
+func collectUsers(rows pgx.Rows) ([]domain.User, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.User, error) {
+		user, err := scanUser(row)
+		if err != nil {
+			return domain.User{}, err
+		}
+		return *user, nil
+	})
+}
+
+// postgresUserID returns id in the text form of a UUID, or false if it is not one.
+func postgresUserID(id domain.UserID) (string, bool) {
+	parsed, err := uuid.Parse(id.String())
+	if err != nil || len(id) != 36 {
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+func metadataValue(metadata map[string]string) (any, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user metadata: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// escapeLike escapes the LIKE wildcards in s, using \ as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// pgNow returns the current time at the precision PostgreSQL stores, so that the
+// times of a returned user equal those read back later.
+func pgNow() time.Time {
+	return pgTime(time.Now())
+}
+
+func pgTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+func pgTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := pgTime(*t)
+	return &truncated
+}
+
+var _ repository.UserRepository = (*PostgresUserRepository)(nil)
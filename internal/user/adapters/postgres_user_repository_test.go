@@ -0,0 +1,82 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/iots1/mingkwan-api/internal/shared/migration"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/repository/repositorytest"
+)
+
+// TestPostgresUserRepository runs the conformance suite against a real PostgreSQL.
+// Set POSTGRES_TEST_URL to run it; every subtest uses a throwaway schema.
+func TestPostgresUserRepository(t *testing.T) {
+	newPool := postgresTestPools(t)
+	repositorytest.TestUserRepository(t, func(t *testing.T) repository.UserRepository {
+		return NewPostgresUserRepository(newPool(t))
+	})
+}
+
+// TestPostgresUserHistoryRepository runs the history conformance suite like
+// TestPostgresUserRepository.
+func TestPostgresUserHistoryRepository(t *testing.T) {
+	newPool := postgresTestPools(t)
+	repositorytest.TestUserHistoryRepository(t, func(t *testing.T) repository.UserHistoryRepository {
+		return NewPostgresUserHistoryRepository(newPool(t))
+	})
+}
+
+// postgresTestPools skips t unless POSTGRES_TEST_URL is set. The returned function
+// connects to a new, migrated schema that is dropped when its test ends.
+func postgresTestPools(t *testing.T) func(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+	migrations, err := UserSQLMigrations()
+	if err != nil {
+		t.Fatalf("UserSQLMigrations: %v", err)
+	}
+
+	n := 0
+	return func(t *testing.T) *pgxpool.Pool {
+		n++
+		schema := fmt.Sprintf("mingkwan_test_%d_%d", os.Getpid(), n)
+		if _, err := admin.Exec(context.Background(), "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+		t.Cleanup(func() {
+			admin.Exec(context.Background(), "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+		})
+
+		config, err := pgxpool.ParseConfig(url)
+		if err != nil {
+			t.Fatalf("parse url: %v", err)
+		}
+		config.ConnConfig.RuntimeParams["search_path"] = schema
+		pool, err := pgxpool.NewWithConfig(context.Background(), config)
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		if _, err := migration.NewSQLMigrator(pool, migrations, "schema_migrations").Up(context.Background(), 0); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return pool
+	}
+}
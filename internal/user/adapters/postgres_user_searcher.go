@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// userSearchDocument must match the expression of the users_search_idx index for
+// the index to be used.
+const userSearchDocument = "to_tsvector('simple', name || ' ' || email)"
+
+// PostgresUserSearcher implements UserSearcher with a full-text index on name and
+// email for word matches, and lower-cased pattern indexes for prefix matches. Hits
+// are ranked like those of MongoUserSearcher.
+type PostgresUserSearcher struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserSearcher(pool *pgxpool.Pool) *PostgresUserSearcher {
+	return &PostgresUserSearcher{pool: pool}
+}
+
+func (s *PostgresUserSearcher) SearchUsers(ctx context.Context, query domain.UserSearchQuery) (*domain.UserSearchResult, error) {
+	text := strings.TrimSpace(query.Text)
+	prefixMatches, err := s.findByPrefix(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	textMatches, err := s.findByText(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return rankSearchHits(text, prefixMatches, textMatches, query), nil
+}
+
+// findByPrefix matches emails and names starting with prefix, ignoring case.
+func (s *PostgresUserSearcher) findByPrefix(ctx context.Context, prefix string) ([]domain.User, error) {
+	pattern := escapeLike(strings.ToLower(prefix)) + "%"
+	rows, err := s.pool.Query(ctx, `SELECT `+selectUserColumns+` FROM users
+		WHERE (lower(email) LIKE $1 ESCAPE '\' OR lower(name) LIKE $1 ESCAPE '\') AND deleted_at IS NULL
+		LIMIT $2`, pattern, searchCandidateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run prefix search: %w", err)
+	}
+	users, err := collectUsers(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode prefix search results: %w", err)
+	}
+	return users, nil
+}
+
+func (s *PostgresUserSearcher) findByText(ctx context.Context, text string) ([]domain.UserSearchHit, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+selectUserColumns+`, ts_rank(`+userSearchDocument+`, query) AS score
+		FROM users, plainto_tsquery('simple', $1) AS query
+		WHERE `+userSearchDocument+` @@ query AND deleted_at IS NULL
+		ORDER BY score DESC
+		LIMIT $2`, text, searchCandidateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run text search: %w", err)
+	}
+	hits, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.UserSearchHit, error) {
+		var score float32
+		user, err := scanUser(scoredRow{row: row, score: &score})
+		if err != nil {
+			return domain.UserSearchHit{}, err
+		}
+		return domain.UserSearchHit{User: *user, Score: float64(score)}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode text search results: %w", err)
+	}
+	return hits, nil
+}
+
+// scoredRow scans a user row followed by a score column.
+type scoredRow struct {
+	row   pgx.Row
+	score *float32
+}
+
+func (r scoredRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.score)...)
+}
+
+var _ repository.UserSearcher = (*PostgresUserSearcher)(nil)
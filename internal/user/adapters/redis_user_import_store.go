@@ -0,0 +1,96 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+const (
+	importJobKeyPrefix  = "user:import:job:"
+	importRowsKeyPrefix = "user:import:rows:"
+
+	// importJobTTL is how long job reports stay pollable.
+	importJobTTL = 7 * 24 * time.Hour
+	// importRowsTTL bounds how long pending rows, which include password hashes,
+	// can stay in Redis if a job never completes.
+	importRowsTTL = 24 * time.Hour
+)
+
+// RedisUserImportStore keeps import jobs in Redis. Jobs are short-lived reports, so
+// they expire instead of being stored in the database.
+type RedisUserImportStore struct {
+	client *redis.Client
+}
+
+func NewRedisUserImportStore(client *redis.Client) *RedisUserImportStore {
+	return &RedisUserImportStore{client: client}
+}
+
+func (s *RedisUserImportStore) SaveJob(ctx context.Context, job *domain.ImportJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode import job '%s': %w", job.ID, err)
+	}
+	if err := s.client.Set(ctx, importJobKeyPrefix+job.ID, data, importJobTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store import job '%s': %w", job.ID, err)
+	}
+	return nil
+}
+
+func (s *RedisUserImportStore) GetJob(ctx context.Context, id string) (*domain.ImportJob, error) {
+	data, err := s.client.Get(ctx, importJobKeyPrefix+id).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, domain.ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("failed to load import job '%s': %w", id, err)
+	}
+	var job domain.ImportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode import job '%s': %w", id, err)
+	}
+	return &job, nil
+}
+
+func (s *RedisUserImportStore) SaveRows(ctx context.Context, jobID string, rows []domain.ImportRow) error {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return fmt.Errorf("failed to encode rows of import job '%s': %w", jobID, err)
+	}
+	if err := s.client.Set(ctx, importRowsKeyPrefix+jobID, data, importRowsTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store rows of import job '%s': %w", jobID, err)
+	}
+	return nil
+}
+
+func (s *RedisUserImportStore) GetRows(ctx context.Context, jobID string) ([]domain.ImportRow, error) {
+	data, err := s.client.Get(ctx, importRowsKeyPrefix+jobID).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, domain.ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("failed to load rows of import job '%s': %w", jobID, err)
+	}
+	var rows []domain.ImportRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode rows of import job '%s': %w", jobID, err)
+	}
+	return rows, nil
+}
+
+func (s *RedisUserImportStore) DeleteRows(ctx context.Context, jobID string) error {
+	if err := s.client.Del(ctx, importRowsKeyPrefix+jobID).Err(); err != nil {
+		return fmt.Errorf("failed to delete rows of import job '%s': %w", jobID, err)
+	}
+	return nil
+}
+
+var _ repository.UserImportStore = (*RedisUserImportStore)(nil)
@@ -0,0 +1,84 @@
+package adapters
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// userCursor is the keyset position after the last user of a page: the value of the
+// sort field and the ID as tie-breaker. It records the sort it was issued for, so a
+// cursor cannot be replayed against a different ordering.
+type userCursor struct {
+	SortBy   string `json:"s"`
+	SortDesc bool   `json:"d"`
+	Value    string `json:"v"`
+	ID       string `json:"id"`
+}
+
+func encodeUserCursor(user *domain.User, sortBy string, sortDesc bool) string {
+	cursor := userCursor{SortBy: sortBy, SortDesc: sortDesc, ID: user.ID.String()}
+	switch sortBy {
+	case domain.SortByCreatedAt:
+		cursor.Value = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case domain.SortByUpdatedAt:
+		cursor.Value = user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case domain.SortByName:
+		cursor.Value = user.Name
+	case domain.SortByEmail:
+		cursor.Value = user.Email
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeUserCursor decodes token and returns the sort value and ID of the cursor
+// position. Time sort fields yield a time.Time, the others a string.
+func decodeUserCursor(token, sortBy string, sortDesc bool) (interface{}, domain.UserID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, "", domain.ErrInvalidCursor
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, "", domain.ErrInvalidCursor
+	}
+	if cursor.SortBy != sortBy || cursor.SortDesc != sortDesc {
+		return nil, "", domain.ErrInvalidCursor
+	}
+	id, err := domain.ParseUserID(cursor.ID)
+	if err != nil {
+		return nil, "", domain.ErrInvalidCursor
+	}
+
+	if sortBy == domain.SortByCreatedAt || sortBy == domain.SortByUpdatedAt {
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		return t, id, nil
+	}
+	return cursor.Value, id, nil
+}
+
+// cursorCondition decodes token into a filter matching the users after the cursor
+// position in the given ordering.
+func cursorCondition(token, sortBy string, sortDesc bool) (bson.M, error) {
+	value, id, err := decodeUserCursor(token, sortBy, sortDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	op := "$gt"
+	if sortDesc {
+		op = "$lt"
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{sortBy: bson.M{op: value}},
+		bson.M{sortBy: value, "_id": bson.M{op: id}},
+	}}, nil
+}
@@ -0,0 +1,60 @@
+package delivery
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userModel "github.com/iots1/mingkwan-api/internal/user/models"
+)
+
+// userETag returns the strong entity tag of the given user version.
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch extracts the expected version from an If-Match header. "*" matches
+// any version. Weak tags never match, as If-Match requires strong comparison.
+func parseIfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return userDomain.AnyVersion, true
+	}
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// sendPreconditionFailed answers a stale If-Match with the current representation,
+// so the client can merge its change and retry with the new ETag.
+func (h *UserHandler) sendPreconditionFailed(c *fiber.Ctx, current *userDomain.User) error {
+	c.Set(fiber.HeaderETag, userETag(current.Version))
+	return c.Status(fiber.StatusPreconditionFailed).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   userDomain.ErrVersionConflict.Error(),
+		Code:      fiber.StatusPreconditionFailed * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+		Data:      userModel.ToUserResponse(current),
+	})
+}
+
+// sendVersionConflict reloads the user after a conflicting write and answers with 412.
+func (h *UserHandler) sendVersionConflict(ctx context.Context, c *fiber.Ctx, id userDomain.UserID, conflictErr error) error {
+	current, err := h.userUsecase.GetUserByID(ctx, id)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusPreconditionFailed, conflictErr.Error(), nil, nil)
+	}
+	return h.sendPreconditionFailed(c, current)
+}
@@ -1,15 +1,22 @@
 package delivery
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 
 	sharedAdapter "github.com/iots1/mingkwan-api/internal/shared/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/jsonpatch"
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
 	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
@@ -17,6 +24,9 @@ import (
 	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
 )
 
+// exportTimeout bounds a streaming export, which outlives the request handler.
+const exportTimeout = 10 * time.Minute
+
 var (
 	ErrEmailAlreadyExists = errors.New("email already registered")
 	ErrUserNotFound       = errors.New("user not found")
@@ -26,11 +36,13 @@ var (
 
 type UserHandler struct {
 	userUsecase    userUsecase.UserUsecase
+	historyUsecase userUsecase.UserHistoryUsecase
+	mergeUsecase   *userUsecase.UserMergeUsecase
 	passwordHasher sharedAdapter.PasswordHasher
 }
 
-func NewUserHandler(useUsecase userUsecase.UserUsecase, passswordHasher sharedAdapter.PasswordHasher) *UserHandler {
-	return &UserHandler{userUsecase: useUsecase, passwordHasher: passswordHasher}
+func NewUserHandler(useUsecase userUsecase.UserUsecase, historyUsecase userUsecase.UserHistoryUsecase, mergeUsecase *userUsecase.UserMergeUsecase, passswordHasher sharedAdapter.PasswordHasher) *UserHandler {
+	return &UserHandler{userUsecase: useUsecase, historyUsecase: historyUsecase, mergeUsecase: mergeUsecase, passwordHasher: passswordHasher}
 }
 
 func (h *UserHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
@@ -68,6 +80,40 @@ func (h *UserHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data int
 	})
 }
 
+// authorizeSelfOrAdmin reports whether the authenticated caller may modify the
+// user with the given ID: users may modify themselves, admins anyone.
+func (h *UserHandler) authorizeSelfOrAdmin(c *fiber.Ctx, id string) bool {
+	meta := middleware.GetRequestMeta(c)
+	return meta.ActorID == id || slices.Contains(meta.ActorRoles, userDomain.RoleAdmin)
+}
+
+// userResponse returns the full representation of user to the user themself, admins
+// and support staff, and the public profile to anyone else. A pending email change
+// is only shown to the user and admins, since it is not confirmed yet.
+func (h *UserHandler) userResponse(c *fiber.Ctx, user *userDomain.User) *userModel.UserResponse {
+	meta := middleware.GetRequestMeta(c)
+	if meta.ActorID != user.ID.String() && !slices.ContainsFunc(meta.ActorRoles, func(role string) bool {
+		return role == userDomain.RoleAdmin || role == userDomain.RoleSupport
+	}) {
+		return userModel.ToPublicUserResponse(user)
+	}
+	resp := userModel.ToUserResponse(user)
+	if !h.authorizeSelfOrAdmin(c, user.ID.String()) {
+		resp.PendingEmail = ""
+	}
+	return resp
+}
+
+func (h *UserHandler) sendPageResponse(c *fiber.Ctx, statusCode int, data interface{}, count int, meta *sharedModel.PageMeta) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+		Meta:    meta,
+	})
+}
+
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	var req userModel.CreateUserRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -81,27 +127,10 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
 	}
 
-	existingUser, err := h.userUsecase.GetUserByEmail(c.Context(), req.Email)
-	if err != nil && !errors.Is(err, ErrUserNotFound) {
-		utils.Logger.Error("Error checking existing user by email", zap.Error(err), zap.String("email", req.Email))
-		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to check existing user", err, nil)
-	}
-	if existingUser != nil {
-		utils.Logger.Warn("Registration failed: Email already exists", zap.String("email", req.Email))
-		return h.sendErrorResponse(c, fiber.StatusConflict, ErrEmailAlreadyExists.Error(), nil, nil)
-	}
-
-	hashedPassword, err := h.passwordHasher.HashPassword(req.Password)
-	if err != nil {
-		utils.Logger.Error("Failed to hash password during registration", zap.Error(err))
-		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to hash password", err, nil)
-	}
-
 	newUser := &userDomain.User{
-		ID:        primitive.NewObjectID(),
 		Name:      req.Name,
 		Email:     req.Email,
-		Password:  hashedPassword,
+		Password:  req.Password, // hashed by the use case
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		IsActive:  true,
@@ -120,7 +149,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to create user", err, nil)
 	}
 
-	utils.Logger.Info("User created successfully", zap.String("user_id", user.ID.Hex()), zap.String("email", user.Email))
+	utils.Logger.Info("User created successfully", zap.String("user_id", user.ID.String()), zap.String("email", user.Email))
 	return h.sendSuccessResponse(c, fiber.StatusCreated, userModel.ToUserResponse(user), 1)
 }
 
@@ -131,11 +160,14 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "User ID is required", nil, nil)
 	}
 
-	oid, err := primitive.ObjectIDFromHex(id)
+	oid, err := userDomain.ParseUserID(id)
 	if err != nil {
 		utils.Logger.Warn("GetUserByID: Invalid user ID format", zap.String("id", id), zap.Error(err))
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
 	}
+	if asOf := c.Query("as_of"); asOf != "" {
+		return h.getUserAsOf(c, oid, asOf)
+	}
 
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
@@ -143,6 +175,9 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 	user, err := h.userUsecase.GetUserByID(ctx, oid)
 	if err != nil {
 		if errors.Is(err, userDomain.ErrUserNotFound) {
+			if redirected, err := h.redirectMergedUser(ctx, c, oid); redirected {
+				return err
+			}
 			utils.Logger.Info("GetUserByID: User not found", zap.String("user_id", id))
 			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
 		}
@@ -150,27 +185,181 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve user", err, nil)
 	}
 
-	utils.Logger.Info("User retrieved successfully", zap.String("user_id", user.ID.Hex()))
-	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(user), 1)
+	utils.Logger.Info("User retrieved successfully", zap.String("user_id", user.ID.String()))
+	c.Set(fiber.HeaderETag, userETag(user.Version))
+	return h.sendSuccessResponse(c, fiber.StatusOK, h.userResponse(c, user), 1)
 }
 
-func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
+// ListUsers returns one page of users, filtered and sorted by the query string.
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	var query userModel.ListUsersQuery
+	if err := c.QueryParser(&query); err != nil {
+		utils.Logger.Warn("ListUsers: Invalid query parameters", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("ListUsers: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	filter, err := query.ToFilter()
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+	filter.Normalize()
+
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	users, err := h.userUsecase.GetAllUsers(ctx)
+	page, err := h.userUsecase.ListUsers(ctx, filter)
 	if err != nil {
-		utils.Logger.Error("GetAllUsers: Usecase error", zap.Error(err))
+		if errors.Is(err, userDomain.ErrInvalidCursor) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		utils.Logger.Error("ListUsers: Usecase error", zap.Error(err))
 		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve users", err, nil)
 	}
 
-	var userResponses []userModel.UserResponse
-	for _, user := range users {
-		userRespPtr := userModel.ToUserResponse(&user)
-		userResponses = append(userResponses, *userRespPtr)
+	userResponses := make([]userModel.UserResponse, 0, len(page.Users))
+	for _, user := range page.Users {
+		userResponses = append(userResponses, *h.userResponse(c, &user))
+	}
+
+	meta := &sharedModel.PageMeta{
+		Total:      page.Total,
+		Limit:      filter.Limit,
+		NextCursor: page.NextCursor,
+		HasMore:    page.NextCursor != "",
+	}
+	if filter.Cursor == "" {
+		meta.Page = filter.Page
+	}
+
+	utils.Logger.Info("Users listed successfully", zap.Int("count", len(userResponses)), zap.Int64("total", page.Total))
+	return h.sendPageResponse(c, fiber.StatusOK, userResponses, len(userResponses), meta)
+}
+
+// ExportUsers streams every user matching the listing filters as CSV or NDJSON.
+// Users are read from a database cursor and written as they arrive, so the export
+// never holds more than one cursor batch in memory.
+func (h *UserHandler) ExportUsers(c *fiber.Ctx) error {
+	var query userModel.ExportUsersQuery
+	if err := c.QueryParser(&query); err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+	filter, err := query.ToFilter()
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+
+	filename := "users-" + time.Now().UTC().Format("20060102T150405Z") + "." + query.Format
+	if query.Format == userModel.ExportFormatCSV {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	// The stream writer runs after the handler returns, so it must not use the
+	// request context, which fasthttp recycles. The request metadata is copied so that
+	// the export is still attributed to the caller.
+	meta := *middleware.GetRequestMeta(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(utils.WithRequestMeta(context.Background(), &meta), exportTimeout)
+		defer cancel()
+
+		var write func(user *userDomain.User) error
+		var csvWriter *csv.Writer
+		if query.Format == userModel.ExportFormatCSV {
+			csvWriter = csv.NewWriter(w)
+			if err := csvWriter.Write(userModel.UserExportCSVHeader); err != nil {
+				utils.Logger.Error("ExportUsers: Failed to write CSV header", zap.Error(err))
+				return
+			}
+			write = func(user *userDomain.User) error {
+				return csvWriter.Write(userModel.ToUserExportCSVRecord(user))
+			}
+		} else {
+			encoder := json.NewEncoder(w)
+			write = func(user *userDomain.User) error {
+				return encoder.Encode(userModel.ToUserResponse(user))
+			}
+		}
+
+		written := 0
+		_, err := h.userUsecase.ExportUsers(ctx, filter, query.Format, func(user *userDomain.User) error {
+			if err := write(user); err != nil {
+				return err
+			}
+			written++
+			if written%500 == 0 {
+				if csvWriter != nil {
+					csvWriter.Flush()
+				}
+				return w.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			utils.Logger.Error("ExportUsers: Export aborted", zap.Int("written", written), zap.Error(err))
+		}
+		if csvWriter != nil {
+			csvWriter.Flush()
+		}
+		if err := w.Flush(); err != nil {
+			utils.Logger.Warn("ExportUsers: Failed to flush export stream", zap.Error(err))
+		}
+		utils.Logger.Info("Users exported", zap.Int("count", written), zap.String("format", query.Format))
+	})
+	return nil
+}
+
+// SearchUsers ranks users by how well their name or email matches q.
+func (h *UserHandler) SearchUsers(c *fiber.Ctx) error {
+	var query userModel.SearchUsersQuery
+	if err := c.QueryParser(&query); err != nil {
+		utils.Logger.Warn("SearchUsers: Invalid query parameters", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("SearchUsers: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+	searchQuery := query.ToSearchQuery()
+	searchQuery.Normalize()
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.userUsecase.SearchUsers(ctx, searchQuery)
+	if err != nil {
+		utils.Logger.Error("SearchUsers: Usecase error", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to search users", err, nil)
+	}
+
+	hits := make([]userModel.UserSearchHitResponse, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		hits = append(hits, userModel.UserSearchHitResponse{
+			UserResponse: *h.userResponse(c, &hit.User),
+			Score:        hit.Score,
+		})
+	}
+
+	meta := &sharedModel.PageMeta{
+		Total:   result.Total,
+		Limit:   searchQuery.Limit,
+		Page:    searchQuery.Page,
+		HasMore: int64(searchQuery.Page*searchQuery.Limit) < result.Total,
 	}
-	utils.Logger.Info("All users retrieved successfully", zap.Int("count", len(userResponses)))
-	return h.sendSuccessResponse(c, fiber.StatusOK, userResponses, len(userResponses))
+	return h.sendPageResponse(c, fiber.StatusOK, hits, len(hits), meta)
 }
 
 func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
@@ -179,11 +368,24 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		utils.Logger.Warn("UpdateUser: User ID is empty in request params")
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "User ID is required", nil, nil)
 	}
-	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+	if _, err := userDomain.ParseUserID(id); err != nil {
 		utils.Logger.Warn("UpdateUser: Invalid user ID format", zap.String("id", id), zap.Error(err))
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
 	}
 
+	// Updates must name the version they are based on so that concurrent edits
+	// cannot silently overwrite each other.
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if ifMatch == "" {
+		utils.Logger.Info("UpdateUser: Missing If-Match header", zap.String("user_id", id))
+		return h.sendErrorResponse(c, fiber.StatusPreconditionRequired, "If-Match header with the user's ETag is required", nil, nil)
+	}
+	expectedVersion, ok := parseIfMatch(ifMatch)
+	if !ok {
+		utils.Logger.Info("UpdateUser: Malformed If-Match header", zap.String("user_id", id), zap.String("if_match", ifMatch))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid If-Match header", nil, nil)
+	}
+
 	var req userModel.UpdateUserRequest
 	if err := c.BodyParser(&req); err != nil {
 		utils.Logger.Warn("UpdateUser: Invalid request body", zap.Error(err))
@@ -196,15 +398,41 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
 	}
 
+	if !h.authorizeSelfOrAdmin(c, id) {
+		utils.Logger.Warn("UpdateUser: Caller may not modify this user", zap.String("user_id", id))
+		return h.sendErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil, nil)
+	}
+
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	updatedUser, err := h.userUsecase.UpdateUser(ctx, id, req.Name, req.Email)
+	// Changing the email address moves the account's login identity and therefore
+	// needs a recent proof of identity, like deleting the account.
+	if req.Email != "" && !middleware.HasRecentAuth(middleware.GetRequestMeta(c), middleware.StepUpMaxAge) {
+		oid, _ := userDomain.ParseUserID(id)
+		current, err := h.userUsecase.GetUserByID(ctx, oid)
+		if err != nil {
+			if errors.Is(err, userDomain.ErrUserNotFound) {
+				return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+			}
+			return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to update user", err, nil)
+		}
+		if !strings.EqualFold(current.Email, req.Email) {
+			utils.Logger.Info("UpdateUser: Email change requires recent authentication", zap.String("user_id", id))
+			return middleware.SendStepUpRequired(c, middleware.StepUpMaxAge)
+		}
+	}
+
+	updatedUser, err := h.userUsecase.UpdateUser(ctx, id, req.Name, req.Email, req.Profile(), expectedVersion)
 	if err != nil {
 		if errors.Is(err, userDomain.ErrUserNotFound) {
 			utils.Logger.Info("UpdateUser: User not found", zap.String("user_id", id))
 			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
 		}
+		if errors.Is(err, userDomain.ErrVersionConflict) {
+			oid, _ := userDomain.ParseUserID(id)
+			return h.sendVersionConflict(ctx, c, oid, err)
+		}
 		if errors.Is(err, userDomain.ErrUserAlreadyExists) {
 			utils.Logger.Info("UpdateUser: Email already in use", zap.String("email", req.Email))
 			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
@@ -213,7 +441,125 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to update user", err, nil)
 	}
 
-	utils.Logger.Info("User updated successfully", zap.String("user_id", updatedUser.ID.Hex()))
+	utils.Logger.Info("User updated successfully", zap.String("user_id", updatedUser.ID.String()))
+	c.Set(fiber.HeaderETag, userETag(updatedUser.Version))
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(updatedUser), 1)
+}
+
+// PatchUser applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to the
+// mutable fields of a user. Unlike PUT, a patch can clear optional fields. If-Match is
+// optional: without it the patch is applied to the version read here, and a
+// concurrent write still fails with 412.
+func (h *UserHandler) PatchUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	oid, err := userDomain.ParseUserID(id)
+	if err != nil {
+		utils.Logger.Warn("PatchUser: Invalid user ID format", zap.String("id", id), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0]))
+	if !jsonpatch.IsSupported(contentType) {
+		c.Set("Accept-Patch", jsonpatch.MergePatchContentType+", "+jsonpatch.JSONPatchContentType)
+		return h.sendErrorResponse(c, fiber.StatusUnsupportedMediaType, "Content-Type must be "+jsonpatch.MergePatchContentType+" or "+jsonpatch.JSONPatchContentType, nil, nil)
+	}
+
+	expectedVersion := userDomain.AnyVersion
+	if ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch != "" {
+		var ok bool
+		if expectedVersion, ok = parseIfMatch(ifMatch); !ok {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid If-Match header", nil, nil)
+		}
+	}
+
+	if !h.authorizeSelfOrAdmin(c, id) {
+		utils.Logger.Warn("PatchUser: Caller may not modify this user", zap.String("user_id", id))
+		return h.sendErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil, nil)
+	}
+
+	fields, err := jsonpatch.TopLevelFields(contentType, c.Body())
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid patch document", err, nil)
+	}
+	forbidden := make(map[string][]string)
+	for _, field := range fields {
+		if !userModel.PatchableUserFields[field] {
+			name := field
+			if name == "" {
+				name = "_document_"
+			}
+			forbidden[name] = []string{name + " cannot be modified"}
+		}
+	}
+	if len(forbidden) > 0 {
+		utils.Logger.Info("PatchUser: Patch touches immutable fields", zap.String("user_id", id), zap.Strings("fields", fields))
+		return h.sendErrorResponse(c, fiber.StatusUnprocessableEntity, "Patch modifies fields that are not mutable", nil, forbidden)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	current, err := h.userUsecase.GetUserByID(ctx, oid)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to patch user", err, nil)
+	}
+	if expectedVersion != userDomain.AnyVersion && current.Version != expectedVersion {
+		return h.sendPreconditionFailed(c, current)
+	}
+	expectedVersion = current.Version
+
+	original := userModel.ToUserPatchDocument(current)
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to patch user", err, nil)
+	}
+	patchedJSON, err := jsonpatch.Apply(contentType, originalJSON, c.Body())
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrConflict) {
+			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid patch document", err, nil)
+	}
+
+	var patched userModel.UserPatchDocument
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patched); err != nil {
+		return h.sendErrorResponse(c, fiber.StatusUnprocessableEntity, "Patched user is invalid", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(patched); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("PatchUser: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusUnprocessableEntity, "Validation failed", nil, formattedErrors)
+	}
+
+	changes := patched.Changes(original)
+	if _, ok := changes["email"]; ok && !strings.EqualFold(current.Email, patched.Email) &&
+		!middleware.HasRecentAuth(middleware.GetRequestMeta(c), middleware.StepUpMaxAge) {
+		utils.Logger.Info("PatchUser: Email change requires recent authentication", zap.String("user_id", id))
+		return middleware.SendStepUpRequired(c, middleware.StepUpMaxAge)
+	}
+
+	updatedUser, err := h.userUsecase.PatchUser(ctx, id, changes, expectedVersion)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		if errors.Is(err, userDomain.ErrUserAlreadyExists) {
+			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		if errors.Is(err, userDomain.ErrVersionConflict) {
+			return h.sendVersionConflict(ctx, c, oid, err)
+		}
+		utils.Logger.Error("PatchUser: Failed to patch user in usecase", zap.String("user_id", id), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to patch user", err, nil)
+	}
+
+	utils.Logger.Info("User patched successfully", zap.String("user_id", id), zap.Int("changed_fields", len(changes)))
+	c.Set(fiber.HeaderETag, userETag(updatedUser.Version))
 	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(updatedUser), 1)
 }
 
@@ -223,10 +569,14 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		utils.Logger.Warn("DeleteUser: User ID is empty in request params")
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "User ID is required", nil, nil)
 	}
-	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+	if _, err := userDomain.ParseUserID(id); err != nil {
 		utils.Logger.Warn("DeleteUser: Invalid user ID format", zap.String("id", id), zap.Error(err))
 		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
 	}
+	if !h.authorizeSelfOrAdmin(c, id) {
+		utils.Logger.Warn("DeleteUser: Caller may not delete this user", zap.String("user_id", id))
+		return h.sendErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil, nil)
+	}
 
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
@@ -244,3 +594,148 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	utils.Logger.Info("User deleted successfully", zap.String("user_id", id))
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
+
+// RestoreUser undoes the soft delete of a user that has not been purged yet.
+func (h *UserHandler) RestoreUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := userDomain.ParseUserID(id); err != nil {
+		utils.Logger.Warn("RestoreUser: Invalid user ID format", zap.String("id", id), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	restored, err := h.userUsecase.RestoreUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, "No deleted user with this ID", nil, nil)
+		}
+		utils.Logger.Error("RestoreUser: Failed to restore user in usecase", zap.String("user_id", id), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to restore user", err, nil)
+	}
+
+	utils.Logger.Info("User restored successfully", zap.String("user_id", id))
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(restored), 1)
+}
+
+// ConfirmEmailChange applies a pending email change. It is called with the token of
+// the link sent to the new address, which proves the user controls it.
+func (h *UserHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var req userModel.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("ConfirmEmailChange: Invalid request body", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	updatedUser, err := h.userUsecase.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrEmailChangeInvalid) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		if errors.Is(err, userDomain.ErrUserAlreadyExists) {
+			return h.sendErrorResponse(c, fiber.StatusConflict, "Email already in use by another account", nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to confirm email change", err, nil)
+	}
+
+	c.Set(fiber.HeaderETag, userETag(updatedUser.Version))
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(updatedUser), 1)
+}
+
+// CancelEmailChange drops a pending email change. It is called with the token of the
+// link sent to the current address.
+func (h *UserHandler) CancelEmailChange(c *fiber.Ctx) error {
+	var req userModel.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("CancelEmailChange: Invalid request body", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.userUsecase.CancelEmailChange(ctx, req.Token); err != nil {
+		if errors.Is(err, userDomain.ErrEmailChangeInvalid) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to cancel email change", err, nil)
+	}
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// DeactivateUser blocks an account from logging in and revokes its sessions.
+func (h *UserHandler) DeactivateUser(c *fiber.Ctx) error {
+	var req userModel.DeactivateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.Logger.Warn("DeactivateUser: Invalid request body", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("DeactivateUser: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+	return h.changeActivation(c, false, req.Reason)
+}
+
+// ReactivateUser lets a deactivated account log in again.
+func (h *UserHandler) ReactivateUser(c *fiber.Ctx) error {
+	var req userModel.ReactivateUserRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.Logger.Warn("ReactivateUser: Invalid request body", zap.Error(err))
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+		}
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		formattedErrors := utils.FormatValidationErrors(err)
+		utils.Logger.Warn("ReactivateUser: Validation failed", zap.Any("validation_details", formattedErrors))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, formattedErrors)
+	}
+	return h.changeActivation(c, true, req.Reason)
+}
+
+func (h *UserHandler) changeActivation(c *fiber.Ctx, active bool, reason string) error {
+	id := c.Params("id")
+	if _, err := userDomain.ParseUserID(id); err != nil {
+		utils.Logger.Warn("ChangeActivation: Invalid user ID format", zap.String("id", id), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var (
+		user *userDomain.User
+		err  error
+	)
+	if active {
+		user, err = h.userUsecase.ReactivateUser(ctx, id, reason)
+	} else {
+		user, err = h.userUsecase.DeactivateUser(ctx, id, reason)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, userDomain.ErrUserNotFound):
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		case errors.Is(err, userDomain.ErrUserInactive), errors.Is(err, userDomain.ErrUserAlreadyActive):
+			return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+		}
+		utils.Logger.Error("ChangeActivation: Usecase error", zap.String("user_id", id), zap.Bool("active", active), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to change user activation", err, nil)
+	}
+
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserResponse(user), 1)
+}
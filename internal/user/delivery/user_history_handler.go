@@ -0,0 +1,95 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/middleware"
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userModel "github.com/iots1/mingkwan-api/internal/user/models"
+)
+
+// historyRoles may read the history of users and their past states.
+var historyRoles = []string{userDomain.RoleAdmin, userDomain.RoleSupport}
+
+// GetUserHistory returns one page of the writes to a user, newest first, each with
+// the changed fields, who made it and the ID of the request.
+func (h *UserHandler) GetUserHistory(c *fiber.Ctx) error {
+	id := c.Params("id")
+	oid, err := userDomain.ParseUserID(id)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid user ID format", err, nil)
+	}
+
+	var query userModel.UserHistoryQuery
+	if err := c.QueryParser(&query); err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid query parameters", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(query); err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, utils.FormatValidationErrors(err))
+	}
+	page := query.ToPage()
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	entries, total, err := h.historyUsecase.ListHistory(ctx, oid, page)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve user history", err, nil)
+	}
+	resp := userModel.ToUserHistoryResponse(entries)
+	if !h.authorizeSelfOrAdmin(c, id) {
+		// Unconfirmed email changes are only shown to admins, as in userResponse.
+		for _, entry := range resp {
+			for i := range entry.Changes {
+				if entry.Changes[i].Field == "pending_email" {
+					entry.Changes[i].From, entry.Changes[i].To = nil, nil
+				}
+			}
+		}
+	}
+	return h.sendPageResponse(c, fiber.StatusOK, resp, len(entries), &sharedModel.PageMeta{
+		Total:   total,
+		Limit:   page.Limit,
+		Page:    page.Page,
+		HasMore: int64(page.Skip()+len(entries)) < total,
+	})
+}
+
+// getUserAsOf serves GET /users/:id?as_of=<RFC 3339 timestamp>: the user as it was
+// at that time. Past states may hold data the user has since changed, so unlike the
+// current state they are restricted to historyRoles.
+func (h *UserHandler) getUserAsOf(c *fiber.Ctx, id userDomain.UserID, asOfParam string) error {
+	meta := middleware.GetRequestMeta(c)
+	if meta.ActorID == "" {
+		return h.sendErrorResponse(c, fiber.StatusUnauthorized, "Authentication required", nil, nil)
+	}
+	if !slices.ContainsFunc(meta.ActorRoles, func(role string) bool { return slices.Contains(historyRoles, role) }) {
+		return h.sendErrorResponse(c, fiber.StatusForbidden, "Insufficient permissions", nil, nil)
+	}
+	asOf, err := time.Parse(time.RFC3339, asOfParam)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "as_of must be an RFC 3339 timestamp", err, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.historyUsecase.GetUserAsOf(ctx, id, asOf)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrUserNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, "User did not exist at this time", nil, nil)
+		}
+		utils.Logger.Error("GetUserByID: Failed to rebuild user", zap.String("user_id", id.String()), zap.Time("as_of", asOf), zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve user", err, nil)
+	}
+	// No ETag: a past state cannot be the base of an update.
+	return h.sendSuccessResponse(c, fiber.StatusOK, h.userResponse(c, user), 1)
+}
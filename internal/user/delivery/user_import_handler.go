@@ -0,0 +1,152 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	sharedModel "github.com/iots1/mingkwan-api/internal/shared/models"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userModel "github.com/iots1/mingkwan-api/internal/user/models"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// importCheckTimeout covers parsing, validation, the duplicate check and password
+// hashing of a whole file, which takes longer than a single-user request.
+const importCheckTimeout = 2 * time.Minute
+
+type UserImportHandler struct {
+	importUsecase userUsecase.UserImportUsecase
+}
+
+func NewUserImportHandler(importUsecase userUsecase.UserImportUsecase) *UserImportHandler {
+	return &UserImportHandler{importUsecase: importUsecase}
+}
+
+func (h *UserImportHandler) sendErrorResponse(c *fiber.Ctx, statusCode int, message string, err error, validationErrors map[string][]string) error {
+	logFields := []zap.Field{
+		zap.String("method", c.Method()),
+		zap.String("path", c.Path()),
+		zap.Int("status_code", statusCode),
+		zap.String("message", message),
+	}
+	if err != nil {
+		logFields = append(logFields, zap.Error(err))
+	}
+	if validationErrors != nil {
+		logFields = append(logFields, zap.Any("validation_errors", validationErrors))
+	}
+	utils.Logger.Error("API Error", logFields...)
+
+	return c.Status(statusCode).JSON(sharedModel.CommonErrorResponse{
+		Success:   false,
+		Timestamp: time.Now().UTC(),
+		Message:   message,
+		Errors:    validationErrors,
+		Code:      statusCode * 1000,
+		Method:    c.Method(),
+		Path:      c.Path(),
+	})
+}
+
+func (h *UserImportHandler) sendSuccessResponse(c *fiber.Ctx, statusCode int, data interface{}, count int) error {
+	return c.Status(statusCode).JSON(sharedModel.GenericSuccessResponse{
+		Code:    statusCode,
+		Success: true,
+		Data:    data,
+		Count:   count,
+	})
+}
+
+// ImportUsers accepts a CSV (text/csv) or NDJSON (application/x-ndjson) file of
+// users. Every row is validated; with ?dry_run=true the report is returned right
+// away, otherwise the valid rows are imported by a background job whose progress is
+// available at the returned job's status URL.
+func (h *UserImportHandler) ImportUsers(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run", false)
+
+	var (
+		records []userModel.ImportRecord
+		err     error
+	)
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0]))
+	switch contentType {
+	case "text/csv":
+		records, err = userModel.ParseImportCSV(bytes.NewReader(c.Body()))
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		records, err = userModel.ParseImportNDJSON(bytes.NewReader(c.Body()))
+	default:
+		return h.sendErrorResponse(c, fiber.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson", nil, nil)
+	}
+	if err != nil {
+		if errors.Is(err, userDomain.ErrImportTooLarge) {
+			return h.sendErrorResponse(c, fiber.StatusRequestEntityTooLarge, err.Error(), nil, nil)
+		}
+		if errors.Is(err, userDomain.ErrImportEmpty) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		utils.Logger.Warn("ImportUsers: Malformed import file", zap.Error(err))
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid import file: "+err.Error(), err, nil)
+	}
+
+	validator := utils.GetGlobalValidator()
+	rows := make([]userDomain.ImportRow, 0, len(records))
+	var rejected []userDomain.ImportRowResult
+	for _, record := range records {
+		if record.Err != nil {
+			rejected = append(rejected, userDomain.ImportRowResult{Row: record.Row, Email: record.Data.Email,
+				Status: userDomain.ImportRowFailed, Errors: map[string][]string{"_error_": {record.Err.Error()}}})
+			continue
+		}
+		if err := validator.Struct(record.Data); err != nil {
+			rejected = append(rejected, userDomain.ImportRowResult{Row: record.Row, Email: record.Data.Email,
+				Status: userDomain.ImportRowFailed, Errors: utils.FormatValidationErrors(err)})
+			continue
+		}
+		rows = append(rows, record.Data.ToDomain(record.Row))
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), importCheckTimeout)
+	defer cancel()
+
+	job, err := h.importUsecase.StartImport(ctx, rows, rejected, dryRun)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrImportEmpty) {
+			return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+		}
+		if errors.Is(err, userDomain.ErrImportTooLarge) {
+			return h.sendErrorResponse(c, fiber.StatusRequestEntityTooLarge, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to start import", err, nil)
+	}
+
+	if dryRun {
+		utils.Logger.Info("ImportUsers: Dry run completed", zap.Int("total", job.Total), zap.Int("valid", job.Succeeded), zap.Int("invalid", job.Failed))
+		return h.sendSuccessResponse(c, fiber.StatusOK, job, job.Total)
+	}
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + job.ID)
+	return h.sendSuccessResponse(c, fiber.StatusAccepted, job, job.Total)
+}
+
+// GetImportJob reports the progress and per-row results of an import job.
+func (h *UserImportHandler) GetImportJob(c *fiber.Ctx) error {
+	jobID := c.Params("jobId")
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	job, err := h.importUsecase.GetImportJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, userDomain.ErrImportJobNotFound) {
+			return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+		}
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to retrieve import job", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, job, len(job.Rows))
+}
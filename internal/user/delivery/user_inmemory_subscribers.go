@@ -3,27 +3,32 @@ package delivery
 import (
 	"context"
 	"errors" // Added for errors.Is when checking context.Done
+	"time"
 
 	// Added for fmt.Errorf for better error messages
 	"go.uber.org/zap" // Make sure zap is imported for utils.Logger
 
 	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/utils" // Import utils for utils.Logger
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase"
 )
 
 type UserInmemoryEventSubscribers struct {
-	inMemoryBus *event.InMemPubSub
-	// Add other dependencies needed to process events, e.g., some internal reporting service
+	inMemoryBus    *event.InMemPubSub
+	historyUsecase *usecase.UserHistoryUsecase
 }
 
-func NewUserInmemoryEventSubscribers(bus *event.InMemPubSub /*, other dependencies */) *UserInmemoryEventSubscribers {
+func NewUserInmemoryEventSubscribers(bus *event.InMemPubSub, historyUsecase *usecase.UserHistoryUsecase) *UserInmemoryEventSubscribers {
 	return &UserInmemoryEventSubscribers{
-		inMemoryBus: bus,
+		inMemoryBus:    bus,
+		historyUsecase: historyUsecase,
 	}
 }
 
 func (s *UserInmemoryEventSubscribers) StartAllSubscribers(ctx context.Context) {
 	go s.listenToUserCreatedEvents(ctx)
+	go s.listenToUserErasedEvents(ctx)
 	utils.Logger.Info("UserFeature/In-Memory Subscribers: All listeners started.")
 }
 
@@ -44,7 +49,7 @@ func (s *UserInmemoryEventSubscribers) listenToUserCreatedEvents(ctx context.Con
 			}
 			utils.Logger.Info(
 				"UserFeature/In-Memory Subscriber: UserCreatedInMemoryEvent received.",
-				zap.String("user_id", payload.UserID.Hex()),
+				zap.String("user_id", payload.UserID),
 				zap.String("user_name", payload.Name),
 				zap.String("action", "Performing internal user-specific action..."),
 			)
@@ -67,3 +72,36 @@ func (s *UserInmemoryEventSubscribers) listenToUserCreatedEvents(ctx context.Con
 		}
 	}
 }
+
+// listenToUserErasedEvents deletes history recorded for an erased user while the
+// erasure job ran, e.g. by an update that raced with the anonymization.
+func (s *UserInmemoryEventSubscribers) listenToUserErasedEvents(ctx context.Context) {
+	ch := s.inMemoryBus.SubscribeEvent(event.UserErasedInMemoryEvent)
+	utils.Logger.Info("UserFeature/In-Memory Subscriber: Listening for 'user.erased.inmemory' events.")
+
+	for {
+		select {
+		case eventData := <-ch:
+			payload, ok := eventData.(event.UserErasedPayload)
+			if !ok {
+				utils.Logger.Warn("UserFeature/In-Memory Subscriber: Received unexpected payload type for 'user.erased.inmemory' event.",
+					zap.Any("event_data", eventData))
+				continue
+			}
+
+			eraseCtx, cancel := context.WithTimeout(utils.WithRequestMeta(ctx, &utils.RequestMeta{CorrelationID: payload.RequestID}), 10*time.Second)
+			deleted, err := s.historyUsecase.DeleteHistory(eraseCtx, domain.UserID(payload.UserID))
+			cancel()
+			if err != nil {
+				utils.Logger.Error("UserFeature/In-Memory Subscriber: Failed to erase history of erased user",
+					zap.String("user_id", payload.UserID), zap.Error(err))
+				continue
+			}
+			utils.Logger.Info("UserFeature/In-Memory Subscriber: Erased remaining history of erased user",
+				zap.String("user_id", payload.UserID), zap.Int64("history_entries", deleted))
+		case <-ctx.Done():
+			utils.Logger.Info("UserFeature/In-Memory Subscriber: 'user.erased.inmemory' event listener stopped.", zap.Error(ctx.Err()))
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	userDomain "github.com/iots1/mingkwan-api/internal/user/domain"
+	userModel "github.com/iots1/mingkwan-api/internal/user/models"
+)
+
+// FindDuplicateUsers lists the groups of users that share a normalized email or
+// phone number and are likely the same person.
+func (h *UserHandler) FindDuplicateUsers(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 30*time.Second)
+	defer cancel()
+
+	groups, err := h.mergeUsecase.FindDuplicates(ctx)
+	if err != nil {
+		return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to find duplicate users", err, nil)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToDuplicateGroupsResponse(groups), len(groups))
+}
+
+// PreviewMerge shows what merging source_id into target_id would change, without
+// writing anything.
+func (h *UserHandler) PreviewMerge(c *fiber.Ctx) error {
+	req, sourceID, targetID, err := h.parseMergeRequest(c)
+	if err != nil || req == nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	plan, err := h.mergeUsecase.PreviewMerge(ctx, sourceID, targetID)
+	if err != nil {
+		return h.sendMergeError(c, err)
+	}
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToMergePreviewResponse(plan), 1)
+}
+
+// MergeUsers merges source_id into target_id. The source is soft-deleted and
+// redirects to the target; other modules re-point their records asynchronously.
+func (h *UserHandler) MergeUsers(c *fiber.Ctx) error {
+	req, sourceID, targetID, err := h.parseMergeRequest(c)
+	if err != nil || req == nil {
+		return err
+	}
+	targetVersion := userDomain.AnyVersion
+	if req.TargetVersion != nil {
+		targetVersion = *req.TargetVersion
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	merge, err := h.mergeUsecase.MergeUsers(ctx, sourceID, targetID, targetVersion)
+	if err != nil {
+		return h.sendMergeError(c, err)
+	}
+	utils.Logger.Info("Users merged successfully", zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()))
+	return h.sendSuccessResponse(c, fiber.StatusOK, userModel.ToUserMergeResponse(merge), 1)
+}
+
+// parseMergeRequest parses and validates the body of a merge request. It returns a
+// nil request once it has sent an error response.
+func (h *UserHandler) parseMergeRequest(c *fiber.Ctx) (*userModel.MergeUsersRequest, userDomain.UserID, userDomain.UserID, error) {
+	var req userModel.MergeUsersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, "", "", h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid request body", err, nil)
+	}
+	if err := utils.GetGlobalValidator().Struct(req); err != nil {
+		return nil, "", "", h.sendErrorResponse(c, fiber.StatusBadRequest, "Validation failed", nil, utils.FormatValidationErrors(err))
+	}
+	sourceID, err := userDomain.ParseUserID(req.SourceID)
+	if err != nil {
+		return nil, "", "", h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid source_id format", err, nil)
+	}
+	targetID, err := userDomain.ParseUserID(req.TargetID)
+	if err != nil {
+		return nil, "", "", h.sendErrorResponse(c, fiber.StatusBadRequest, "Invalid target_id format", err, nil)
+	}
+	return &req, sourceID, targetID, nil
+}
+
+func (h *UserHandler) sendMergeError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, userDomain.ErrMergeSameUser):
+		return h.sendErrorResponse(c, fiber.StatusBadRequest, err.Error(), nil, nil)
+	case errors.Is(err, userDomain.ErrUserNotFound):
+		return h.sendErrorResponse(c, fiber.StatusNotFound, err.Error(), nil, nil)
+	case errors.Is(err, userDomain.ErrUserMerged):
+		return h.sendErrorResponse(c, fiber.StatusConflict, err.Error(), nil, nil)
+	case errors.Is(err, userDomain.ErrVersionConflict):
+		return h.sendErrorResponse(c, fiber.StatusPreconditionFailed, "Target user was modified since the preview", nil, nil)
+	}
+	return h.sendErrorResponse(c, fiber.StatusInternalServerError, "Failed to merge users", err, nil)
+}
+
+// redirectMergedUser answers a request for a user that was merged into another with
+// a permanent redirect to the target. It returns false if id was not merged.
+func (h *UserHandler) redirectMergedUser(ctx context.Context, c *fiber.Ctx, id userDomain.UserID) (bool, error) {
+	targetID, err := h.mergeUsecase.MergedInto(ctx, id)
+	if err != nil {
+		if !errors.Is(err, userDomain.ErrUserMergeNotFound) {
+			utils.Logger.Error("GetUserByID: Failed to look up user merge", zap.String("user_id", id.String()), zap.Error(err))
+		}
+		return false, nil
+	}
+	path := c.Path()
+	c.Location(path[:strings.LastIndex(path, "/")+1] + targetID.String())
+	return true, h.sendSuccessResponse(c, fiber.StatusPermanentRedirect, fiber.Map{"merged_into": targetID.String()}, 0)
+}
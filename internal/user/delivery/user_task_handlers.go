@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	userUsecase "github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// purgeActorID identifies the purge job as the actor in audit entries.
+const purgeActorID = "system:user-purge"
+
+// repointMergesAfter leaves alone merges young enough to be re-pointed by their
+// request still.
+const repointMergesAfter = time.Minute
+
+// UserTaskHandlers processes the user module's Asynq tasks.
+type UserTaskHandlers struct {
+	userUsecase    userUsecase.UserUsecase
+	importUsecase  userUsecase.UserImportUsecase
+	historyUsecase userUsecase.UserHistoryUsecase
+	mergeUsecase   *userUsecase.UserMergeUsecase
+	purgeAfterDays int
+}
+
+func NewUserTaskHandlers(userUsecase userUsecase.UserUsecase, importUsecase userUsecase.UserImportUsecase,
+	historyUsecase userUsecase.UserHistoryUsecase, mergeUsecase *userUsecase.UserMergeUsecase, purgeAfterDays int) *UserTaskHandlers {
+	return &UserTaskHandlers{userUsecase: userUsecase, importUsecase: importUsecase, historyUsecase: historyUsecase,
+		mergeUsecase: mergeUsecase, purgeAfterDays: purgeAfterDays}
+}
+
+// ImportUsers handles the 'user:import' task queued by POST /users/import.
+func (h *UserTaskHandlers) ImportUsers(ctx context.Context, t *asynq.Task) error {
+	var payload event.ImportUsersPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode import payload: %v: %w", err, asynq.SkipRetry)
+	}
+	if err := h.importUsecase.RunImport(ctx, payload.JobID); err != nil {
+		utils.Logger.Error("UserTaskHandlers: Import job failed", zap.String("job_id", payload.JobID), zap.Error(err))
+		return fmt.Errorf("import users: %w", err)
+	}
+	return nil
+}
+
+// RecordHistory handles the 'user:record_history' task queued by
+// HistoryUserRepository when it could not store history entries with their write.
+func (h *UserTaskHandlers) RecordHistory(ctx context.Context, t *asynq.Task) error {
+	var payload domain.RecordHistoryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("decode history payload: %v: %w", err, asynq.SkipRetry)
+	}
+	if err := h.historyUsecase.RecordEntries(ctx, payload.Entries); err != nil {
+		utils.Logger.Error("UserTaskHandlers: Failed to record queued user history", zap.Int("entries", len(payload.Entries)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// PurgeDeletedUsers handles the periodic 'user:purge_deleted' task.
+func (h *UserTaskHandlers) PurgeDeletedUsers(ctx context.Context, t *asynq.Task) error {
+	ctx = utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: purgeActorID})
+	olderThan := time.Duration(h.purgeAfterDays) * 24 * time.Hour
+
+	purged, err := h.userUsecase.PurgeDeletedUsers(ctx, olderThan)
+	if err != nil {
+		utils.Logger.Error("UserTaskHandlers: Purge of deleted users failed", zap.Int("purged", purged), zap.Error(err))
+		return fmt.Errorf("purge deleted users: %w", err)
+	}
+	utils.Logger.Info("UserTaskHandlers: Purged deleted users", zap.Int("purged", purged), zap.Int("older_than_days", h.purgeAfterDays))
+	return nil
+}
+
+// RepointMerges handles the periodic 'user:repoint_merges' task, which retries
+// merges whose records other modules did not all move to the target.
+func (h *UserTaskHandlers) RepointMerges(ctx context.Context, t *asynq.Task) error {
+	repointed, err := h.mergeUsecase.RepointPendingMerges(ctx, repointMergesAfter)
+	if repointed > 0 {
+		utils.Logger.Info("UserTaskHandlers: Re-pointed pending user merges", zap.Int("merges", repointed))
+	}
+	if err != nil {
+		utils.Logger.Error("UserTaskHandlers: Re-pointing of user merges failed", zap.Int("repointed", repointed), zap.Error(err))
+		return fmt.Errorf("repoint user merges: %w", err)
+	}
+	return nil
+}
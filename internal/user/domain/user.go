@@ -3,21 +3,92 @@ package domain
 import (
 	"errors"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name      string             `bson:"name" json:"name"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	IsActive  bool               `bson:"is_active" json:"is_active"`
+	ID    UserID `bson:"_id,omitempty" json:"id"`
+	Name  string `bson:"name" json:"name"`
+	Email string `bson:"email" json:"email"`
+	// EmailCanonical is CanonicalEmail(Email), kept unique by an index. It is empty
+	// only for legacy users whose email collides with another account.
+	EmailCanonical string    `bson:"email_canonical,omitempty" json:"-"`
+	Password       string    `bson:"password" json:"-"`
+	CreatedAt      time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `bson:"updated_at" json:"updated_at"`
+	IsActive       bool      `bson:"is_active" json:"is_active"`
+	Roles          []string  `bson:"roles,omitempty" json:"roles,omitempty"`
+	UserProfile    `bson:",inline"`
+	// TOTPSecret is the base32 RFC 6238 secret of users who enrolled an authenticator app.
+	TOTPSecret string `bson:"totp_secret,omitempty" json:"-"`
+	// DeletedAt marks a soft-deleted user. Soft-deleted users are invisible to every
+	// query until they are restored or purged.
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	// Set while IsActive is false: who deactivated the account, when and why.
+	DeactivatedAt      *time.Time `bson:"deactivated_at,omitempty" json:"deactivated_at,omitempty"`
+	DeactivatedBy      string     `bson:"deactivated_by,omitempty" json:"deactivated_by,omitempty"`
+	DeactivationReason string     `bson:"deactivation_reason,omitempty" json:"deactivation_reason,omitempty"`
+	// PendingEmail is an email change waiting for confirmation from the new address.
+	PendingEmail *PendingEmailChange `bson:"pending_email,omitempty" json:"-"`
+	// ErasedAt is set once the user's personal data was erased on their request. The
+	// anonymized record stays so that references to the ID remain valid.
+	ErasedAt *time.Time `bson:"erased_at,omitempty" json:"erased_at,omitempty"`
+	// Version is incremented by every write and guards updates against lost writes.
+	// Users created before versioning was introduced have version 0.
+	Version int64 `bson:"version" json:"version"`
+}
+
+// UserProfile holds the optional, user-editable profile of a user.
+type UserProfile struct {
+	DisplayName string `bson:"display_name,omitempty" json:"display_name,omitempty"`
+	AvatarURL   string `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	// Locale is a BCP 47 language tag, e.g. "th-TH".
+	Locale string `bson:"locale,omitempty" json:"locale,omitempty"`
+	// Timezone is an IANA time zone name, e.g. "Asia/Bangkok".
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// Phone is an E.164 number, e.g. "+66812345678".
+	Phone string `bson:"phone,omitempty" json:"phone,omitempty"`
+	// Metadata is free-form data of client apps, bounded by MaxMetadataEntries.
+	Metadata map[string]string `bson:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+// PendingEmailChange is a requested email change. It takes effect once the link sent
+// to the new address is followed; the link sent to the old address cancels it. Only
+// the SHA-256 hashes of the link tokens are stored.
+type PendingEmailChange struct {
+	Email            string    `bson:"email" json:"email"`
+	ConfirmTokenHash string    `bson:"confirm_token_hash" json:"-"`
+	CancelTokenHash  string    `bson:"cancel_token_hash" json:"-"`
+	RequestedAt      time.Time `bson:"requested_at" json:"requested_at"`
+	ExpiresAt        time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+// EmailChangeTTL is how long the confirmation link of an email change is valid.
+const EmailChangeTTL = 24 * time.Hour
+
+// MaxMetadataEntries bounds UserProfile.Metadata; keys and values are bounded by the
+// validation rules of the request models.
+const MaxMetadataEntries = 20
+
+// IsDeleted reports whether the user has been soft-deleted.
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
 }
 
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleSupport = "support" // Customer support staff: may look users up
+)
+
 var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user with this email already exists")
+	ErrUserInactive      = errors.New("user account is deactivated")
+	ErrUserAlreadyActive = errors.New("user account is already active")
+	ErrVersionConflict   = errors.New("user was modified by another request")
+	// ErrEmailChangeInvalid covers unknown, used and expired email change links.
+	ErrEmailChangeInvalid = errors.New("email change link is invalid or has expired")
 )
+
+// AnyVersion skips the version check of an update (If-Match: *).
+const AnyVersion int64 = -1
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// CanonicalEmail returns the form of email that identifies an account: trimmed,
+// lower-cased, with an internationalized domain converted to its ASCII (punycode)
+// form. Two addresses with the same canonical form belong to the same account.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], strings.TrimSuffix(email[at+1:], ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = strings.ToLower(ascii)
+	}
+	return local + "@" + domain
+}
+
+// EmailCollision lists users whose emails share a canonical form. They are left
+// without a canonical email, and so outside the unique index, until an admin
+// resolves the collision.
+type EmailCollision struct {
+	CanonicalEmail string   `json:"canonical_email"`
+	UserIDs        []string `json:"user_ids"`
+}
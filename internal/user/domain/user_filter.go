@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Fields users can be sorted by. Ties are broken by ID so that pagination is stable.
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByName      = "name"
+	SortByEmail     = "email"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid or expired pagination cursor")
+
+// UserFilter selects and orders a page of users. Zero values are ignored. When
+// Cursor is set it takes precedence over Page.
+type UserFilter struct {
+	IsActive    *bool
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	EmailDomain string
+	SortBy      string
+	SortDesc    bool
+	Page        int
+	Limit       int
+	Cursor      string
+}
+
+// Normalize applies pagination and sort defaults and bounds.
+func (f *UserFilter) Normalize() {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 {
+		f.Limit = DefaultPageLimit
+	}
+	if f.Limit > MaxPageLimit {
+		f.Limit = MaxPageLimit
+	}
+	if f.SortBy == "" {
+		f.SortBy = SortByCreatedAt
+		f.SortDesc = true
+	}
+}
+
+// UserPage is one page of a user listing. Total counts every user matching the
+// filter; NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []User
+	Total      int64
+	NextCursor string
+}
@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Operations recorded in the user history.
+const (
+	HistoryCreate     = "create"
+	HistoryUpdate     = "update"
+	HistoryDelete     = "delete"
+	HistoryRestore    = "restore"
+	HistoryDeactivate = "deactivate"
+	HistoryReactivate = "reactivate"
+	HistoryErase      = "erase"
+)
+
+// Fields whose changes are recorded without their values.
+const (
+	HistoryFieldPassword   = "password"
+	HistoryFieldTOTPSecret = "totp_secret"
+)
+
+var ErrHistoryEntryNotFound = errors.New("user history entry not found")
+
+// UserHistoryEntry is one write to a user: which fields it changed, the user before
+// and after it, and who made it in which request.
+type UserHistoryEntry struct {
+	ID        string `bson:"_id,omitempty" json:"id"`
+	UserID    UserID `bson:"user_id" json:"user_id"`
+	Operation string `bson:"operation" json:"operation"`
+	// Fields are the names of the changed fields, see ChangedFields.
+	Fields []string `bson:"fields" json:"fields"`
+	// Before is nil for creations and for writes whose previous state was not
+	// readable (restores, erasures). After is nil if the user was not readable after
+	// the write.
+	Before    *UserSnapshot `bson:"before,omitempty" json:"before,omitempty"`
+	After     *UserSnapshot `bson:"after,omitempty" json:"after,omitempty"`
+	ActorID   string        `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	RequestID string        `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	At        time.Time     `bson:"at" json:"at"`
+}
+
+// RecordHistoryPayload is the payload of event.RecordUserHistoryTask: entries of
+// writes that happened but whose history could not be stored with them.
+type RecordHistoryPayload struct {
+	Entries []UserHistoryEntry `json:"entries"`
+}
+
+// UserSnapshot is the state of a user at one point of its history. Credentials and
+// token hashes are left out; their changes are only named in the entry's Fields.
+type UserSnapshot struct {
+	Name               string     `bson:"name" json:"name"`
+	Email              string     `bson:"email" json:"email"`
+	IsActive           bool       `bson:"is_active" json:"is_active"`
+	Roles              []string   `bson:"roles,omitempty" json:"roles,omitempty"`
+	PendingEmail       string     `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	DeletedAt          *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeactivatedAt      *time.Time `bson:"deactivated_at,omitempty" json:"deactivated_at,omitempty"`
+	DeactivatedBy      string     `bson:"deactivated_by,omitempty" json:"deactivated_by,omitempty"`
+	DeactivationReason string     `bson:"deactivation_reason,omitempty" json:"deactivation_reason,omitempty"`
+	ErasedAt           *time.Time `bson:"erased_at,omitempty" json:"erased_at,omitempty"`
+	CreatedAt          time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt          time.Time  `bson:"updated_at" json:"updated_at"`
+	Version            int64      `bson:"version" json:"version"`
+	UserProfile        `bson:",inline"`
+}
+
+// NewUserSnapshot captures the recorded fields of user.
+func NewUserSnapshot(user *User) *UserSnapshot {
+	snapshot := &UserSnapshot{
+		Name:               user.Name,
+		Email:              user.Email,
+		IsActive:           user.IsActive,
+		Roles:              slices.Clone(user.Roles),
+		UserProfile:        user.UserProfile,
+		DeletedAt:          user.DeletedAt,
+		DeactivatedAt:      user.DeactivatedAt,
+		DeactivatedBy:      user.DeactivatedBy,
+		DeactivationReason: user.DeactivationReason,
+		ErasedAt:           user.ErasedAt,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+		Version:            user.Version,
+	}
+	if user.PendingEmail != nil {
+		snapshot.PendingEmail = user.PendingEmail.Email
+	}
+	return snapshot
+}
+
+// User rebuilds the user with the given ID from the snapshot, without credentials.
+func (s *UserSnapshot) User(id UserID) *User {
+	user := &User{
+		ID:                 id,
+		Name:               s.Name,
+		Email:              s.Email,
+		IsActive:           s.IsActive,
+		Roles:              slices.Clone(s.Roles),
+		UserProfile:        s.UserProfile,
+		DeletedAt:          s.DeletedAt,
+		DeactivatedAt:      s.DeactivatedAt,
+		DeactivatedBy:      s.DeactivatedBy,
+		DeactivationReason: s.DeactivationReason,
+		ErasedAt:           s.ErasedAt,
+		CreatedAt:          s.CreatedAt,
+		UpdatedAt:          s.UpdatedAt,
+		Version:            s.Version,
+	}
+	if s.PendingEmail != "" {
+		user.PendingEmail = &PendingEmailChange{Email: s.PendingEmail}
+	}
+	return user
+}
+
+// historyFields are the fields compared by ChangedFields, by their BSON name.
+var historyFields = []struct {
+	name  string
+	value func(s *UserSnapshot) interface{}
+}{
+	{"name", func(s *UserSnapshot) interface{} { return s.Name }},
+	{"email", func(s *UserSnapshot) interface{} { return s.Email }},
+	{"pending_email", func(s *UserSnapshot) interface{} { return s.PendingEmail }},
+	{"is_active", func(s *UserSnapshot) interface{} { return s.IsActive }},
+	{"roles", func(s *UserSnapshot) interface{} { return s.Roles }},
+	{"display_name", func(s *UserSnapshot) interface{} { return s.DisplayName }},
+	{"avatar_url", func(s *UserSnapshot) interface{} { return s.AvatarURL }},
+	{"locale", func(s *UserSnapshot) interface{} { return s.Locale }},
+	{"timezone", func(s *UserSnapshot) interface{} { return s.Timezone }},
+	{"phone", func(s *UserSnapshot) interface{} { return s.Phone }},
+	{"metadata", func(s *UserSnapshot) interface{} { return s.Metadata }},
+	{"deleted_at", func(s *UserSnapshot) interface{} { return s.DeletedAt }},
+	{"deactivated_at", func(s *UserSnapshot) interface{} { return s.DeactivatedAt }},
+	{"deactivated_by", func(s *UserSnapshot) interface{} { return s.DeactivatedBy }},
+	{"deactivation_reason", func(s *UserSnapshot) interface{} { return s.DeactivationReason }},
+	{"erased_at", func(s *UserSnapshot) interface{} { return s.ErasedAt }},
+}
+
+// Value returns the value of the named field in the snapshot, nil for fields that
+// are not recorded (credentials) or a nil snapshot.
+func (s *UserSnapshot) Value(field string) interface{} {
+	if s == nil {
+		return nil
+	}
+	for _, f := range historyFields {
+		if f.name == field {
+			return f.value(s)
+		}
+	}
+	return nil
+}
+
+// ChangedFields returns the names of the fields that differ between before and
+// after, a nil before standing for a user that did not exist. Timestamps maintained
+// by the repository (updated_at, version) are not compared.
+func ChangedFields(before, after *User) []string {
+	if before == nil {
+		before = &User{}
+	}
+	beforeSnapshot, afterSnapshot := NewUserSnapshot(before), NewUserSnapshot(after)
+
+	fields := []string{}
+	for _, f := range historyFields {
+		if !sameValue(f.value(beforeSnapshot), f.value(afterSnapshot)) {
+			fields = append(fields, f.name)
+		}
+	}
+	if before.Password != after.Password {
+		fields = append(fields, HistoryFieldPassword)
+	}
+	if before.TOTPSecret != after.TOTPSecret {
+		fields = append(fields, HistoryFieldTOTPSecret)
+	}
+	return fields
+}
+
+// sameValue compares field values: timestamps by instant, empty and nil maps and
+// slices as equal.
+func sameValue(a, b interface{}) bool {
+	if at, ok := a.(*time.Time); ok {
+		bt := b.(*time.Time)
+		if at == nil || bt == nil {
+			return at == nil && bt == nil
+		}
+		return at.Equal(*bt)
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if (va.Kind() == reflect.Map || va.Kind() == reflect.Slice) && va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// HistoryPage selects one page of a user's history, newest entries first.
+type HistoryPage struct {
+	Page  int
+	Limit int
+}
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 200
+)
+
+// Normalize applies pagination defaults and bounds.
+func (p *HistoryPage) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = DefaultHistoryLimit
+	}
+	if p.Limit > MaxHistoryLimit {
+		p.Limit = MaxHistoryLimit
+	}
+}
+
+// Skip returns how many entries precede the page.
+func (p HistoryPage) Skip() int {
+	return (p.Page - 1) * p.Limit
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// UserID identifies a user independently of where users are stored: the hex form of
+// a MongoDB ObjectID or a PostgreSQL UUID. The zero value identifies no user.
+//
+// In BSON an ID that is a valid ObjectID keeps the ObjectID type, so documents and
+// references written before UserID was introduced still match.
+type UserID string
+
+var ErrInvalidUserID = errors.New("invalid user ID")
+
+// ParseUserID validates s as an ObjectID or a UUID and returns it in canonical form.
+func ParseUserID(s string) (UserID, error) {
+	if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+		return UserID(oid.Hex()), nil
+	}
+	if id, err := uuid.Parse(s); err == nil && len(s) == 36 {
+		return UserID(id.String()), nil
+	}
+	return "", ErrInvalidUserID
+}
+
+func (id UserID) String() string { return string(id) }
+
+func (id UserID) IsZero() bool { return id == "" }
+
+// ObjectID returns the ID as an ObjectID; ok is false if it is not one, e.g. a UUID.
+func (id UserID) ObjectID() (oid primitive.ObjectID, ok bool) {
+	oid, err := primitive.ObjectIDFromHex(string(id))
+	return oid, err == nil
+}
+
+// MarshalBSONValue stores ObjectIDs as ObjectIDs and any other ID as a string.
+func (id UserID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if oid, ok := id.ObjectID(); ok {
+		return bson.MarshalValue(oid)
+	}
+	return bson.MarshalValue(string(id))
+}
+
+func (id *UserID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+	switch t {
+	case bsontype.ObjectID:
+		*id = UserID(value.ObjectID().Hex())
+	case bsontype.String:
+		*id = UserID(value.StringValue())
+	case bsontype.Null, bsontype.Undefined:
+		*id = ""
+	default:
+		return fmt.Errorf("cannot decode BSON %s into a user ID", t)
+	}
+	return nil
+}
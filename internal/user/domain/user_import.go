@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// MaxImportRows bounds the rows of one import file.
+	MaxImportRows = 5000
+	// ImportBatchSize is how many users the import job creates per database round trip.
+	ImportBatchSize = 50
+)
+
+// ImportJob statuses.
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+	// ImportStatusDryRun marks the report of a dry run; it is never stored.
+	ImportStatusDryRun = "dry_run"
+)
+
+// Per-row import statuses.
+const (
+	ImportRowValid   = "valid" // dry run only: the row would be imported
+	ImportRowCreated = "created"
+	ImportRowFailed  = "failed"
+)
+
+var (
+	ErrImportJobNotFound = errors.New("import job not found")
+	ErrImportEmpty       = errors.New("import file contains no rows")
+	ErrImportTooLarge    = errors.New("import file has too many rows")
+)
+
+// ImportRow is one user to import. Row is its 1-based position in the file.
+//
+// Password is the plain-text password read from the file. It is hashed into
+// PasswordHash before the row is queued and is never stored. The hash also marks a
+// user created by the row: no other user has the same salted hash.
+type ImportRow struct {
+	Row          int         `json:"row"`
+	Name         string      `json:"name"`
+	Email        string      `json:"email"`
+	Password     string      `json:"-"`
+	PasswordHash string      `json:"password_hash"`
+	Profile      UserProfile `json:"profile"`
+}
+
+// ImportRowResult reports what happened to one row.
+type ImportRowResult struct {
+	Row    int                 `json:"row"`
+	Email  string              `json:"email,omitempty"`
+	Status string              `json:"status"`
+	UserID string              `json:"user_id,omitempty"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// ImportJob tracks a bulk import. Rows holds a result for every row processed so
+// far, which also lets a retried job skip rows it already handled.
+type ImportJob struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	CreatedBy  string            `json:"created_by,omitempty"`
+	Total      int               `json:"total"`
+	Succeeded  int               `json:"succeeded"`
+	Failed     int               `json:"failed"`
+	Rows       []ImportRowResult `json:"rows"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// AddResult appends a row result and updates the counters.
+func (j *ImportJob) AddResult(result ImportRowResult) {
+	j.Rows = append(j.Rows, result)
+	switch result.Status {
+	case ImportRowCreated, ImportRowValid:
+		j.Succeeded++
+	case ImportRowFailed:
+		j.Failed++
+	}
+}
+
+// Handled reports which rows already have a result.
+func (j *ImportJob) Handled() map[int]bool {
+	handled := make(map[int]bool, len(j.Rows))
+	for _, r := range j.Rows {
+		handled[r.Row] = true
+	}
+	return handled
+}
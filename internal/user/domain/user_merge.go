@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Keys duplicate accounts are matched on. Users do not have external (social login)
+// identities yet; once they do, they become a third key.
+const (
+	DuplicateByEmail = "email"
+	DuplicateByPhone = "phone"
+)
+
+var (
+	ErrMergeSameUser = errors.New("a user cannot be merged into itself")
+	// ErrUserMerged is returned for a merge whose source was already merged.
+	ErrUserMerged        = errors.New("user was already merged into another user")
+	ErrUserMergeNotFound = errors.New("user merge not found")
+)
+
+// DuplicateGroup is a set of users that share a match key and are likely the same
+// person.
+type DuplicateGroup struct {
+	MatchedOn string `json:"matched_on"`
+	Key       string `json:"key"`
+	Users     []User `json:"users"`
+}
+
+// DuplicateEmailKey is a looser form of CanonicalEmail that also maps addresses
+// delivered to the same mailbox to one key: "+tags" are dropped, and for Gmail the
+// dots of the local part too. It only suggests duplicates; it is not unique.
+func DuplicateEmailKey(email string) string {
+	canonical := CanonicalEmail(email)
+	at := strings.LastIndex(canonical, "@")
+	if at < 0 {
+		return canonical
+	}
+	local, domain := canonical[:at], canonical[at+1:]
+	if plus := strings.Index(local, "+"); plus > 0 {
+		local = local[:plus]
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local, domain = strings.ReplaceAll(local, ".", ""), "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// DuplicatePhoneKey keeps the digits of a phone number and a leading "+", so that
+// formatting differences do not hide a duplicate. It returns "" for numbers too
+// short to identify anyone.
+func DuplicatePhoneKey(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if r >= '0' && r <= '9' || r == '+' && i == 0 {
+			b.WriteRune(r)
+		}
+	}
+	key := b.String()
+	if len(strings.TrimPrefix(key, "+")) < 6 {
+		return ""
+	}
+	return key
+}
+
+// UserMerge records that the source user was merged into the target. It doubles as
+// the tombstone of the source: requests for the old ID are redirected to the target.
+type UserMerge struct {
+	SourceID UserID `bson:"_id" json:"source_id"`
+	TargetID UserID `bson:"target_id" json:"target_id"`
+	// Fields are the profile fields the target took from the source.
+	Fields    []string  `bson:"fields" json:"fields"`
+	ActorID   string    `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	RequestID string    `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	MergedAt  time.Time `bson:"merged_at" json:"merged_at"`
+	// RepointedAt is set once every module moved what it keeps about the source to
+	// the target; until then the merge is retried.
+	RepointedAt *time.Time `bson:"repointed_at,omitempty" json:"repointed_at,omitempty"`
+}
+
+// MergePlan is what merging Source into Target does to the target: Result is the
+// target afterwards and Update the write that makes it so.
+type MergePlan struct {
+	Source *User `json:"source"`
+	Target *User `json:"target"`
+	Result *User `json:"result"`
+	// TakenFromSource names the fields filled from the source; metadata keys are
+	// named "metadata.<key>".
+	TakenFromSource []string `json:"taken_from_source"`
+	// Conflicts names the fields set differently on both users. The target's value
+	// is kept.
+	Conflicts []string               `json:"conflicts"`
+	Update    map[string]interface{} `json:"-"`
+}
+
+// PlanMerge plans merging source into target. The target keeps its ID, email,
+// credentials and roles; profile fields and metadata keys it lacks are taken from
+// the source. Roles are never merged so that a merge cannot grant privileges.
+func PlanMerge(source, target *User) *MergePlan {
+	plan := &MergePlan{
+		Source:          source,
+		Target:          target,
+		TakenFromSource: []string{},
+		Conflicts:       []string{},
+		Update:          map[string]interface{}{},
+	}
+	result := *target
+	result.Metadata = maps.Clone(target.Metadata)
+
+	profileFields := []struct {
+		name           string
+		source, target *string
+	}{
+		{"display_name", &source.DisplayName, &result.DisplayName},
+		{"avatar_url", &source.AvatarURL, &result.AvatarURL},
+		{"locale", &source.Locale, &result.Locale},
+		{"timezone", &source.Timezone, &result.Timezone},
+		{"phone", &source.Phone, &result.Phone},
+	}
+	for _, f := range profileFields {
+		switch {
+		case *f.source == "" || *f.source == *f.target:
+		case *f.target == "":
+			*f.target = *f.source
+			plan.Update[f.name] = *f.source
+			plan.TakenFromSource = append(plan.TakenFromSource, f.name)
+		default:
+			plan.Conflicts = append(plan.Conflicts, f.name)
+		}
+	}
+
+	takenMetadata := false
+	for _, key := range slices.Sorted(maps.Keys(source.Metadata)) {
+		value, ok := result.Metadata[key]
+		switch {
+		case !ok:
+			if result.Metadata == nil {
+				result.Metadata = map[string]string{}
+			}
+			result.Metadata[key] = source.Metadata[key]
+			plan.TakenFromSource = append(plan.TakenFromSource, "metadata."+key)
+			takenMetadata = true
+		case value != source.Metadata[key]:
+			plan.Conflicts = append(plan.Conflicts, "metadata."+key)
+		}
+	}
+	if takenMetadata {
+		if len(result.Metadata) > MaxMetadataEntries {
+			// Keep the target's metadata rather than fail the merge.
+			result.Metadata = target.Metadata
+			plan.TakenFromSource = slices.DeleteFunc(plan.TakenFromSource, func(f string) bool { return strings.HasPrefix(f, "metadata.") })
+			plan.Conflicts = append(plan.Conflicts, "metadata")
+		} else {
+			plan.Update["metadata"] = result.Metadata
+		}
+	}
+
+	plan.Result = &result
+	return plan
+}
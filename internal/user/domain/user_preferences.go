@@ -0,0 +1,47 @@
+package domain
+
+import preferenceDomain "github.com/iots1/mingkwan-api/internal/preference/domain"
+
+// Preferences declares the interface and notification preferences of users.
+// Clients read and write them through /me/preferences.
+func Preferences() []preferenceDomain.Definition {
+	return []preferenceDomain.Definition{
+		{
+			Key:         "ui.theme",
+			Type:        preferenceDomain.TypeString,
+			Default:     "system",
+			Allowed:     []string{"system", "light", "dark"},
+			Description: "Color scheme of the client interface",
+		},
+		{
+			Key:         "ui.density",
+			Type:        preferenceDomain.TypeString,
+			Default:     "comfortable",
+			Allowed:     []string{"comfortable", "compact"},
+			Description: "Spacing of lists and tables",
+		},
+		{
+			Key:         "ui.page_size",
+			Type:        preferenceDomain.TypeInt,
+			Default:     25,
+			Min:         ptr(int64(10)),
+			Max:         ptr(int64(100)),
+			Description: "Rows per page in lists",
+		},
+		{
+			Key:         "notifications.email_digest",
+			Type:        preferenceDomain.TypeString,
+			Default:     "weekly",
+			Allowed:     []string{"off", "daily", "weekly"},
+			Description: "How often activity is summarized by email",
+		},
+		{
+			Key:         "notifications.product_updates",
+			Type:        preferenceDomain.TypeBool,
+			Default:     false,
+			Description: "Emails about new features",
+		},
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,35 @@
+package domain
+
+// UserSearchQuery is a free-text search over user names and emails.
+type UserSearchQuery struct {
+	Text  string
+	Page  int
+	Limit int
+}
+
+// Normalize applies pagination defaults and bounds.
+func (q *UserSearchQuery) Normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 {
+		q.Limit = DefaultPageLimit
+	}
+	if q.Limit > MaxPageLimit {
+		q.Limit = MaxPageLimit
+	}
+}
+
+// UserSearchHit is a matching user with its relevance score; higher is better.
+// Scores are only comparable within one result set.
+type UserSearchHit struct {
+	User  User
+	Score float64
+}
+
+// UserSearchResult is one page of hits, best first. Total counts all ranked hits,
+// which a searcher may cap for very broad queries.
+type UserSearchResult struct {
+	Hits  []UserSearchHit
+	Total int64
+}
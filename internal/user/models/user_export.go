@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// ExportUsersQuery is the query string of GET /users/export. It takes the filters and
+// sort of GET /users; pagination does not apply.
+type ExportUsersQuery struct {
+	Format      string `query:"format" validate:"required,oneof=csv ndjson"`
+	IsActive    string `query:"is_active" validate:"omitempty,oneof=true false"`
+	CreatedFrom string `query:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `query:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EmailDomain string `query:"email_domain" validate:"omitempty,fqdn"`
+	Sort        string `query:"sort" validate:"omitempty,oneof=created_at -created_at updated_at -updated_at name -name email -email"`
+}
+
+func (q ExportUsersQuery) ToFilter() (domain.UserFilter, error) {
+	return ListUsersQuery{
+		IsActive:    q.IsActive,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+		EmailDomain: q.EmailDomain,
+		Sort:        q.Sort,
+	}.ToFilter()
+}
+
+// UserExportCSVHeader names the columns of ToUserExportCSVRecord. Metadata is only
+// part of the NDJSON export.
+var UserExportCSVHeader = []string{
+	"id", "name", "email", "is_active", "roles", "display_name", "avatar_url",
+	"locale", "timezone", "phone", "created_at", "updated_at",
+}
+
+func ToUserExportCSVRecord(user *domain.User) []string {
+	return []string{
+		user.ID.String(),
+		escapeCSVFormula(user.Name),
+		escapeCSVFormula(user.Email),
+		strconv.FormatBool(user.IsActive),
+		strings.Join(user.Roles, " "),
+		escapeCSVFormula(user.DisplayName),
+		user.AvatarURL,
+		user.Locale,
+		user.Timezone,
+		user.Phone,
+		user.CreatedAt.Format(time.RFC3339),
+		user.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// escapeCSVFormula prefixes free-text values that spreadsheet applications would
+// evaluate as a formula.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// UserHistoryQuery is the query string of GET /users/:id/history.
+type UserHistoryQuery struct {
+	Page  int `query:"page" validate:"omitempty,min=1"`
+	Limit int `query:"limit" validate:"omitempty,min=1,max=200"`
+}
+
+func (q UserHistoryQuery) ToPage() domain.HistoryPage {
+	page := domain.HistoryPage{Page: q.Page, Limit: q.Limit}
+	page.Normalize()
+	return page
+}
+
+// UserHistoryEntryResponse is one write to a user as a list of field changes.
+type UserHistoryEntryResponse struct {
+	ID        string                `json:"id"`
+	Operation string                `json:"operation"`
+	Changes   []FieldChangeResponse `json:"changes"`
+	Version   int64                 `json:"version,omitempty"`
+	ActorID   string                `json:"actor_id,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+	At        string                `json:"at"`
+}
+
+// FieldChangeResponse is the change of one field. From and To are null for
+// credentials, whose values are never recorded, and for unknown states.
+type FieldChangeResponse struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+func ToUserHistoryEntryResponse(entry *domain.UserHistoryEntry) UserHistoryEntryResponse {
+	changes := make([]FieldChangeResponse, 0, len(entry.Fields))
+	for _, field := range entry.Fields {
+		changes = append(changes, FieldChangeResponse{
+			Field: field,
+			From:  entry.Before.Value(field),
+			To:    entry.After.Value(field),
+		})
+	}
+	response := UserHistoryEntryResponse{
+		ID:        entry.ID,
+		Operation: entry.Operation,
+		Changes:   changes,
+		ActorID:   entry.ActorID,
+		RequestID: entry.RequestID,
+		At:        entry.At.Format(time.RFC3339Nano),
+	}
+	if entry.After != nil {
+		response.Version = entry.After.Version
+	}
+	return response
+}
+
+func ToUserHistoryResponse(entries []domain.UserHistoryEntry) []UserHistoryEntryResponse {
+	responses := make([]UserHistoryEntryResponse, len(entries))
+	for i := range entries {
+		responses[i] = ToUserHistoryEntryResponse(&entries[i])
+	}
+	return responses
+}
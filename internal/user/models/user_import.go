@@ -0,0 +1,153 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// ImportUserRow is one line of an import file. CSV headers use the JSON names.
+type ImportUserRow struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required,min=6"`
+	DisplayName string `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL   string `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	Locale      string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone    string `json:"timezone" validate:"omitempty,timezone"`
+	Phone       string `json:"phone" validate:"omitempty,e164"`
+}
+
+func (r ImportUserRow) ToDomain(row int) domain.ImportRow {
+	return domain.ImportRow{
+		Row:      row,
+		Name:     r.Name,
+		Email:    r.Email,
+		Password: r.Password,
+		Profile: domain.UserProfile{
+			DisplayName: r.DisplayName,
+			AvatarURL:   r.AvatarURL,
+			Locale:      r.Locale,
+			Timezone:    r.Timezone,
+			Phone:       r.Phone,
+		},
+	}
+}
+
+// ImportRecord is a parsed line of an import file. Err is set if the line itself
+// could not be decoded; the rest of the file is still imported.
+type ImportRecord struct {
+	Row  int
+	Data ImportUserRow
+	Err  error
+}
+
+var importCSVColumns = map[string]func(*ImportUserRow, string){
+	"name":         func(r *ImportUserRow, v string) { r.Name = v },
+	"email":        func(r *ImportUserRow, v string) { r.Email = v },
+	"password":     func(r *ImportUserRow, v string) { r.Password = v },
+	"display_name": func(r *ImportUserRow, v string) { r.DisplayName = v },
+	"avatar_url":   func(r *ImportUserRow, v string) { r.AvatarURL = v },
+	"locale":       func(r *ImportUserRow, v string) { r.Locale = v },
+	"timezone":     func(r *ImportUserRow, v string) { r.Timezone = v },
+	"phone":        func(r *ImportUserRow, v string) { r.Phone = v },
+}
+
+// ParseImportCSV reads a CSV file whose first line names the columns. Rows are
+// numbered from 1, not counting the header.
+func ParseImportCSV(r io.Reader) ([]ImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // a short row is a row error, not a file error
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, domain.ErrImportEmpty
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	setters := make([]func(*ImportUserRow, string), len(header))
+	seen := make(map[string]bool, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF")))
+		setter, ok := importCSVColumns[column]
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate CSV column %q", column)
+		}
+		seen[column] = true
+		setters[i] = setter
+	}
+	for _, required := range []string{"name", "email", "password"} {
+		if !seen[required] {
+			return nil, fmt.Errorf("missing CSV column %q", required)
+		}
+	}
+
+	var records []ImportRecord
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(records) == domain.MaxImportRows {
+			return nil, domain.ErrImportTooLarge
+		}
+		record := ImportRecord{Row: row}
+		switch {
+		case err != nil:
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			record.Err = parseErr.Err
+		case len(fields) != len(setters):
+			record.Err = fmt.Errorf("expected %d fields, got %d", len(setters), len(fields))
+		default:
+			for i, value := range fields {
+				setters[i](&record.Data, strings.TrimSpace(value))
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ParseImportNDJSON reads one JSON object per line. Blank lines are skipped but
+// still count towards the row numbers, so rows match line numbers.
+func ParseImportNDJSON(r io.Reader) ([]ImportRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var records []ImportRecord
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(records) == domain.MaxImportRows {
+			return nil, domain.ErrImportTooLarge
+		}
+		record := ImportRecord{Row: line}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record.Data); err != nil {
+			record.Err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// MergeUsersRequest is the body of POST /users/merges and /users/merges/preview.
+// TargetVersion is the version of the target shown by the preview; when set, the
+// merge fails with 412 if the target changed since.
+type MergeUsersRequest struct {
+	SourceID      string `json:"source_id" validate:"required"`
+	TargetID      string `json:"target_id" validate:"required"`
+	TargetVersion *int64 `json:"target_version" validate:"omitempty,min=1"`
+}
+
+// DuplicateGroupResponse is a set of users that are likely the same person.
+type DuplicateGroupResponse struct {
+	MatchedOn string          `json:"matched_on"`
+	Key       string          `json:"key"`
+	Users     []*UserResponse `json:"users"`
+}
+
+func ToDuplicateGroupsResponse(groups []domain.DuplicateGroup) []DuplicateGroupResponse {
+	resp := make([]DuplicateGroupResponse, len(groups))
+	for i, group := range groups {
+		users := make([]*UserResponse, len(group.Users))
+		for j := range group.Users {
+			users[j] = ToUserResponse(&group.Users[j])
+		}
+		resp[i] = DuplicateGroupResponse{MatchedOn: group.MatchedOn, Key: group.Key, Users: users}
+	}
+	return resp
+}
+
+// MergePreviewResponse shows both users and the target as it would be after the
+// merge.
+type MergePreviewResponse struct {
+	Source          *UserResponse `json:"source"`
+	Target          *UserResponse `json:"target"`
+	Result          *UserResponse `json:"result"`
+	TakenFromSource []string      `json:"taken_from_source"`
+	Conflicts       []string      `json:"conflicts"`
+	TargetVersion   int64         `json:"target_version"`
+}
+
+func ToMergePreviewResponse(plan *domain.MergePlan) MergePreviewResponse {
+	return MergePreviewResponse{
+		Source:          ToUserResponse(plan.Source),
+		Target:          ToUserResponse(plan.Target),
+		Result:          ToUserResponse(plan.Result),
+		TakenFromSource: plan.TakenFromSource,
+		Conflicts:       plan.Conflicts,
+		TargetVersion:   plan.Target.Version,
+	}
+}
+
+type UserMergeResponse struct {
+	SourceID string   `json:"source_id"`
+	TargetID string   `json:"target_id"`
+	Fields   []string `json:"fields"`
+	ActorID  string   `json:"actor_id,omitempty"`
+	MergedAt string   `json:"merged_at"`
+}
+
+func ToUserMergeResponse(merge *domain.UserMerge) UserMergeResponse {
+	return UserMergeResponse{
+		SourceID: merge.SourceID.String(),
+		TargetID: merge.TargetID.String(),
+		Fields:   merge.Fields,
+		ActorID:  merge.ActorID,
+		MergedAt: merge.MergedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"maps"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// UserPatchDocument is the view of a user that PATCH /users/:id operates on. Patches
+// are applied to it and the validator runs on the patched result. Optional fields are
+// always present so that JSON Patch can replace them; an empty value clears them.
+type UserPatchDocument struct {
+	Name        string            `json:"name" validate:"required,min=2,max=100"`
+	Email       string            `json:"email" validate:"required,email"`
+	DisplayName string            `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL   string            `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	Locale      string            `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone    string            `json:"timezone" validate:"omitempty,timezone"`
+	Phone       string            `json:"phone" validate:"omitempty,e164"`
+	Metadata    map[string]string `json:"metadata" validate:"max=20,dive,keys,min=1,max=64,printascii,endkeys,max=512"`
+}
+
+// PatchableUserFields is the allowlist of members a patch may touch.
+var PatchableUserFields = map[string]bool{
+	"name":         true,
+	"email":        true,
+	"display_name": true,
+	"avatar_url":   true,
+	"locale":       true,
+	"timezone":     true,
+	"phone":        true,
+	"metadata":     true,
+}
+
+func ToUserPatchDocument(user *domain.User) UserPatchDocument {
+	metadata := maps.Clone(user.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return UserPatchDocument{
+		Name:        user.Name,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Phone:       user.Phone,
+		Metadata:    metadata,
+	}
+}
+
+// Changes returns the stored fields that differ between original and d, mapped to
+// their new values. A nil value clears the field.
+func (d UserPatchDocument) Changes(original UserPatchDocument) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if d.Name != original.Name {
+		changes["name"] = d.Name
+	}
+	if d.Email != original.Email {
+		changes["email"] = d.Email
+	}
+	optional := []struct {
+		field      string
+		value, old string
+	}{
+		{"display_name", d.DisplayName, original.DisplayName},
+		{"avatar_url", d.AvatarURL, original.AvatarURL},
+		{"locale", d.Locale, original.Locale},
+		{"timezone", d.Timezone, original.Timezone},
+		{"phone", d.Phone, original.Phone},
+	}
+	for _, f := range optional {
+		if f.value == f.old {
+			continue
+		}
+		if f.value == "" {
+			changes[f.field] = nil
+		} else {
+			changes[f.field] = f.value
+		}
+	}
+	if !maps.Equal(d.Metadata, original.Metadata) {
+		if len(d.Metadata) == 0 {
+			changes["metadata"] = nil
+		} else {
+			changes["metadata"] = d.Metadata
+		}
+	}
+	return changes
+}
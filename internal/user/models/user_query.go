@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// ListUsersQuery is the query string of GET /users. Sort takes a field name,
+// optionally prefixed with "-" for descending order (default "-created_at").
+type ListUsersQuery struct {
+	IsActive    string `query:"is_active" validate:"omitempty,oneof=true false"`
+	CreatedFrom string `query:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `query:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EmailDomain string `query:"email_domain" validate:"omitempty,fqdn"`
+	Sort        string `query:"sort" validate:"omitempty,oneof=created_at -created_at updated_at -updated_at name -name email -email"`
+	Page        int    `query:"page" validate:"omitempty,min=1,excluded_with=Cursor"`
+	Limit       int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor      string `query:"cursor" validate:"omitempty,max=512"`
+}
+
+// ToFilter converts the validated query into a domain filter.
+func (q ListUsersQuery) ToFilter() (domain.UserFilter, error) {
+	filter := domain.UserFilter{
+		EmailDomain: strings.ToLower(q.EmailDomain),
+		Page:        q.Page,
+		Limit:       q.Limit,
+		Cursor:      q.Cursor,
+	}
+	if q.IsActive != "" {
+		isActive := q.IsActive == "true"
+		filter.IsActive = &isActive
+	}
+	if q.Sort != "" {
+		filter.SortBy = strings.TrimPrefix(q.Sort, "-")
+		filter.SortDesc = strings.HasPrefix(q.Sort, "-")
+	}
+	var err error
+	if q.CreatedFrom != "" {
+		if filter.CreatedFrom, err = time.Parse(time.RFC3339, q.CreatedFrom); err != nil {
+			return filter, fmt.Errorf("invalid 'created_from' timestamp: %w", err)
+		}
+	}
+	if q.CreatedTo != "" {
+		if filter.CreatedTo, err = time.Parse(time.RFC3339, q.CreatedTo); err != nil {
+			return filter, fmt.Errorf("invalid 'created_to' timestamp: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// SearchUsersQuery is the query string of GET /users/search.
+type SearchUsersQuery struct {
+	Q     string `query:"q" validate:"required,min=2,max=100"`
+	Page  int    `query:"page" validate:"omitempty,min=1"`
+	Limit int    `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+func (q SearchUsersQuery) ToSearchQuery() domain.UserSearchQuery {
+	return domain.UserSearchQuery{Text: q.Q, Page: q.Page, Limit: q.Limit}
+}
@@ -1,12 +1,47 @@
 package models
 
+import "github.com/iots1/mingkwan-api/internal/user/domain"
+
 type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=100"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+type DeactivateUserRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500"`
+}
+
+type ReactivateUserRequest struct {
+	Reason string `json:"reason" validate:"omitempty,max=500"`
+}
+
+// UpdateUserRequest replaces the given fields; empty fields are left unchanged. Use
+// PATCH to clear optional fields.
 type UpdateUserRequest struct {
-	Name  string `json:"name" validate:"omitempty,min=2,max=100"`
-	Email string `json:"email" validate:"omitempty,email"`
+	Name        string            `json:"name" validate:"omitempty,min=2,max=100"`
+	Email       string            `json:"email" validate:"omitempty,email"`
+	DisplayName string            `json:"display_name" validate:"omitempty,max=100"`
+	AvatarURL   string            `json:"avatar_url" validate:"omitempty,http_url,max=2048"`
+	Locale      string            `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone    string            `json:"timezone" validate:"omitempty,timezone"`
+	Phone       string            `json:"phone" validate:"omitempty,e164"`
+	Metadata    map[string]string `json:"metadata" validate:"omitempty,max=20,dive,keys,min=1,max=64,printascii,endkeys,max=512"`
+}
+
+func (r UpdateUserRequest) Profile() domain.UserProfile {
+	return domain.UserProfile{
+		DisplayName: r.DisplayName,
+		AvatarURL:   r.AvatarURL,
+		Locale:      r.Locale,
+		Timezone:    r.Timezone,
+		Phone:       r.Phone,
+		Metadata:    r.Metadata,
+	}
+}
+
+// EmailChangeTokenRequest carries the token of an email change confirmation or
+// cancel link.
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
 }
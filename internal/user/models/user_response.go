@@ -10,19 +10,62 @@ type UserResponse struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
 	Email     string `json:"email"`
+	IsActive  bool   `json:"is_active"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	Version   int64  `json:"version"`
+	// PendingEmail is the new address of an email change awaiting confirmation.
+	PendingEmail string `json:"pending_email,omitempty"`
+
+	DisplayName string            `json:"display_name,omitempty"`
+	AvatarURL   string            `json:"avatar_url,omitempty"`
+	Locale      string            `json:"locale,omitempty"`
+	Timezone    string            `json:"timezone,omitempty"`
+	Phone       string            `json:"phone,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 func ToUserResponse(user *domain.User) *UserResponse {
 	if user == nil {
 		return nil
 	}
+	var pendingEmail string
+	if user.PendingEmail != nil {
+		pendingEmail = user.PendingEmail.Email
+	}
 	return &UserResponse{
-		ID:        user.ID.Hex(),
+		ID:        user.ID.String(),
 		Name:      user.Name,
 		Email:     user.Email,
+		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: user.UpdatedAt.Format(time.RFC3339),
+		Version:   user.Version,
+
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		Phone:       user.Phone,
+		Metadata:    user.Metadata,
+
+		PendingEmail: pendingEmail,
+	}
+}
+
+// ToPublicUserResponse is the profile of a user as shown to anyone: contact and
+// account details beyond name and email are left out.
+func ToPublicUserResponse(user *domain.User) *UserResponse {
+	resp := ToUserResponse(user)
+	if resp == nil {
+		return nil
 	}
+	resp.Locale, resp.Timezone, resp.Phone, resp.Metadata, resp.PendingEmail = "", "", "", nil, ""
+	return resp
+}
+
+// UserSearchHitResponse is a search result: the user and its relevance score.
+type UserSearchHitResponse struct {
+	UserResponse
+	Score float64 `json:"score"`
 }
@@ -0,0 +1,473 @@
+// Package repositorytest holds conformance suites that every UserRepository and
+// UserHistoryRepository implementation must pass, so that the in-memory
+// repositories used by tests keep behaving like the database ones.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// TestUserRepository runs the conformance suite. newRepo must return an empty
+// repository for every call.
+func TestUserRepository(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.UserRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"DuplicateEmail", testDuplicateEmail},
+		{"NotFound", testNotFound},
+		{"UpdateUser", testUpdateUser},
+		{"SoftDelete", testSoftDelete},
+		{"SetActive", testSetActive},
+		{"CreateUsers", testCreateUsers},
+		{"ListUsers", testListUsers},
+		{"StreamUsers", testStreamUsers},
+		{"EmailChangeToken", testEmailChangeToken},
+		{"AnonymizeUser", testAnonymizeUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+func newUser(name, email string) *domain.User {
+	return &domain.User{Name: name, Email: email, Password: "hash", IsActive: true, Roles: []string{domain.RoleUser}}
+}
+
+func mustCreate(t *testing.T, repo repository.UserRepository, name, email string) *domain.User {
+	t.Helper()
+	user, err := repo.CreateUser(context.Background(), newUser(name, email))
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", email, err)
+	}
+	return user
+}
+
+func testCreateAndGet(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	created := mustCreate(t, repo, "Alice", "Alice@Example.com")
+	if created.ID.IsZero() {
+		t.Fatal("CreateUser did not assign an ID")
+	}
+	if created.Version != 1 {
+		t.Errorf("Version = %d, want 1", created.Version)
+	}
+
+	byID, err := repo.GetUserByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if byID.Name != "Alice" || byID.Email != "Alice@Example.com" || byID.Password != "hash" {
+		t.Errorf("GetUserByID = %+v, want the created user", byID)
+	}
+	if byID.EmailCanonical != "alice@example.com" {
+		t.Errorf("EmailCanonical = %q, want %q", byID.EmailCanonical, "alice@example.com")
+	}
+
+	for _, email := range []string{"Alice@Example.com", "alice@example.com", " ALICE@EXAMPLE.COM "} {
+		byEmail, err := repo.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetUserByEmail(%q): %v", email, err)
+		}
+		if byEmail.ID != created.ID {
+			t.Errorf("GetUserByEmail(%q) = %s, want %s", email, byEmail.ID.String(), created.ID.String())
+		}
+	}
+}
+
+func testDuplicateEmail(t *testing.T, repo repository.UserRepository) {
+	mustCreate(t, repo, "Bob", "bob@example.com")
+	for _, email := range []string{"bob@example.com", "BOB@example.com", "bob@EXAMPLE.com "} {
+		if _, err := repo.CreateUser(context.Background(), newUser("Other", email)); !errors.Is(err, domain.ErrUserAlreadyExists) {
+			t.Errorf("CreateUser(%q) error = %v, want ErrUserAlreadyExists", email, err)
+		}
+	}
+}
+
+func testNotFound(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	active := mustCreate(t, repo, "Carol", "carol@example.com")
+
+	// IDs in the format of another backend must not be an error either.
+	for _, missing := range []domain.UserID{
+		domain.UserID(primitive.NewObjectID().Hex()),
+		domain.UserID(uuid.NewString()),
+	} {
+		checks := []struct {
+			name string
+			call func() error
+		}{
+			{"GetUserByID", func() error { _, err := repo.GetUserByID(ctx, missing); return err }},
+			{"UpdateUser", func() error {
+				_, err := repo.UpdateUser(ctx, missing, map[string]interface{}{"name": "x"}, domain.AnyVersion)
+				return err
+			}},
+			{"UpdateUser with version", func() error {
+				_, err := repo.UpdateUser(ctx, missing, map[string]interface{}{"name": "x"}, 1)
+				return err
+			}},
+			{"DeleteUser", func() error { return repo.DeleteUser(ctx, missing) }},
+			{"SetActive", func() error { _, err := repo.SetActive(ctx, missing, false, "", ""); return err }},
+			{"RestoreUser", func() error { _, err := repo.RestoreUser(ctx, missing); return err }},
+			{"PurgeUser", func() error { return repo.PurgeUser(ctx, missing) }},
+			{"AnonymizeUser", func() error { return repo.AnonymizeUser(ctx, missing, time.Now()) }},
+		}
+		for _, check := range checks {
+			if err := check.call(); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("%s(%s) error = %v, want ErrUserNotFound", check.name, missing, err)
+			}
+		}
+	}
+
+	checks := []struct {
+		name string
+		call func() error
+	}{
+		{"GetUserByEmail", func() error { _, err := repo.GetUserByEmail(ctx, "nobody@example.com"); return err }},
+		{"GetUserByEmailChangeToken", func() error { _, err := repo.GetUserByEmailChangeToken(ctx, "unknown"); return err }},
+		{"RestoreUser of active user", func() error { _, err := repo.RestoreUser(ctx, active.ID); return err }},
+		{"PurgeUser of active user", func() error { return repo.PurgeUser(ctx, active.ID) }},
+	}
+	for _, check := range checks {
+		if err := check.call(); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("%s error = %v, want ErrUserNotFound", check.name, err)
+		}
+	}
+}
+
+func testUpdateUser(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "Dave", "dave@example.com")
+	other := mustCreate(t, repo, "Erin", "erin@example.com")
+
+	updated, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"name": "David", "locale": "th-TH"}, 1)
+	if err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if updated.Name != "David" || updated.Locale != "th-TH" || updated.Version != 2 {
+		t.Errorf("UpdateUser = name %q locale %q version %d, want David th-TH 2", updated.Name, updated.Locale, updated.Version)
+	}
+
+	if _, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"name": "Stale"}, 1); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("UpdateUser with stale version error = %v, want ErrVersionConflict", err)
+	}
+
+	cleared, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"locale": nil}, domain.AnyVersion)
+	if err != nil {
+		t.Fatalf("UpdateUser clearing a field: %v", err)
+	}
+	if cleared.Locale != "" || cleared.Version != 3 {
+		t.Errorf("UpdateUser clearing locale = %q version %d, want empty and 3", cleared.Locale, cleared.Version)
+	}
+
+	if _, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"email": "ERIN@example.com"}, domain.AnyVersion); !errors.Is(err, domain.ErrUserAlreadyExists) {
+		t.Errorf("UpdateUser to a taken email error = %v, want ErrUserAlreadyExists", err)
+	}
+	if _, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"email": "David@example.com"}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser email: %v", err)
+	}
+	if found, err := repo.GetUserByEmail(ctx, "david@example.com"); err != nil || found.ID != user.ID {
+		t.Errorf("GetUserByEmail after email change = %v, %v, want the user", found, err)
+	}
+	if _, err := repo.CreateUser(ctx, newUser("Dave", "dave@example.com")); err != nil {
+		t.Errorf("CreateUser with the released email: %v", err)
+	}
+	if got, _ := repo.GetUserByID(ctx, other.ID); got == nil || got.Version != 1 {
+		t.Errorf("UpdateUser changed another user: %+v", got)
+	}
+}
+
+func testSoftDelete(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "Frank", "frank@example.com")
+
+	if err := repo.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := repo.DeleteUser(ctx, user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("second DeleteUser error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.GetUserByID(ctx, user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("GetUserByID of deleted user error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.GetUserByEmail(ctx, "frank@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("GetUserByEmail of deleted user error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.CreateUser(ctx, newUser("Frank", "frank@example.com")); !errors.Is(err, domain.ErrUserAlreadyExists) {
+		t.Errorf("CreateUser with a deleted user's email error = %v, want ErrUserAlreadyExists", err)
+	}
+	if existing, _ := repo.ExistingEmails(ctx, []string{"FRANK@example.com", "new@example.com"}); !existing["frank@example.com"] || existing["new@example.com"] {
+		t.Errorf("ExistingEmails = %v, want only frank@example.com", existing)
+	}
+	page, err := repo.ListUsers(ctx, listFilter(10))
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if page.Total != 0 || len(page.Users) != 0 {
+		t.Errorf("ListUsers returned deleted users: %+v", page)
+	}
+
+	deleted, err := repo.ListDeletedBefore(ctx, time.Now().Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("ListDeletedBefore: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != user.ID {
+		t.Errorf("ListDeletedBefore = %d users, want the deleted user", len(deleted))
+	}
+	if deleted, _ := repo.ListDeletedBefore(ctx, time.Now().Add(-time.Hour), 10); len(deleted) != 0 {
+		t.Errorf("ListDeletedBefore with an earlier cutoff = %d users, want none", len(deleted))
+	}
+
+	restored, err := repo.RestoreUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if restored.IsDeleted() {
+		t.Error("RestoreUser returned a deleted user")
+	}
+	if err := repo.PurgeUser(ctx, user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("PurgeUser of restored user error = %v, want ErrUserNotFound", err)
+	}
+
+	if err := repo.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := repo.PurgeUser(ctx, user.ID); err != nil {
+		t.Fatalf("PurgeUser: %v", err)
+	}
+	if _, err := repo.RestoreUser(ctx, user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("RestoreUser of purged user error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := repo.CreateUser(ctx, newUser("Frank", "frank@example.com")); err != nil {
+		t.Errorf("CreateUser with a purged user's email: %v", err)
+	}
+}
+
+func testSetActive(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "Grace", "grace@example.com")
+
+	deactivated, err := repo.SetActive(ctx, user.ID, false, "admin-1", "abuse")
+	if err != nil {
+		t.Fatalf("SetActive(false): %v", err)
+	}
+	if deactivated.IsActive || deactivated.DeactivatedAt == nil || deactivated.DeactivatedBy != "admin-1" || deactivated.DeactivationReason != "abuse" {
+		t.Errorf("SetActive(false) = %+v, want deactivation recorded", deactivated)
+	}
+
+	reactivated, err := repo.SetActive(ctx, user.ID, true, "admin-1", "")
+	if err != nil {
+		t.Fatalf("SetActive(true): %v", err)
+	}
+	if !reactivated.IsActive || reactivated.DeactivatedAt != nil || reactivated.DeactivatedBy != "" || reactivated.DeactivationReason != "" {
+		t.Errorf("SetActive(true) = %+v, want deactivation cleared", reactivated)
+	}
+	if reactivated.Version != 3 {
+		t.Errorf("Version = %d, want 3", reactivated.Version)
+	}
+}
+
+func testCreateUsers(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	mustCreate(t, repo, "Heidi", "heidi@example.com")
+
+	users := []*domain.User{
+		newUser("Ivan", "ivan@example.com"),
+		newUser("Heidi again", "HEIDI@example.com"),
+		newUser("Judy", "judy@example.com"),
+		newUser("Ivan again", "ivan@example.com"),
+	}
+	errs, err := repo.CreateUsers(ctx, users)
+	if err != nil {
+		t.Fatalf("CreateUsers: %v", err)
+	}
+	want := []error{nil, domain.ErrUserAlreadyExists, nil, domain.ErrUserAlreadyExists}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("CreateUsers error %d = %v, want %v", i, errs[i], want[i])
+		}
+	}
+	for _, email := range []string{"ivan@example.com", "judy@example.com"} {
+		if _, err := repo.GetUserByEmail(ctx, email); err != nil {
+			t.Errorf("GetUserByEmail(%s) after CreateUsers: %v", email, err)
+		}
+	}
+}
+
+func listFilter(limit int) domain.UserFilter {
+	filter := domain.UserFilter{Limit: limit}
+	filter.Normalize()
+	return filter
+}
+
+func testListUsers(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		// Names repeat, so that ties are broken by ID.
+		user := mustCreate(t, repo, fmt.Sprintf("user %d", i%3), fmt.Sprintf("user%d@example.com", i))
+		if i == 6 {
+			if _, err := repo.SetActive(ctx, user.ID, false, "", ""); err != nil {
+				t.Fatalf("SetActive: %v", err)
+			}
+		}
+	}
+
+	for _, sortBy := range []string{domain.SortByCreatedAt, domain.SortByName, domain.SortByEmail} {
+		for _, desc := range []bool{false, true} {
+			filter := domain.UserFilter{SortBy: sortBy, SortDesc: desc, Limit: 3}
+			filter.Normalize()
+
+			var byPage, byCursor []string
+			for page := 1; ; page++ {
+				filter.Page = page
+				result, err := repo.ListUsers(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListUsers page %d: %v", page, err)
+				}
+				if result.Total != 7 {
+					t.Errorf("Total = %d, want 7", result.Total)
+				}
+				if len(result.Users) == 0 {
+					break
+				}
+				for _, user := range result.Users {
+					byPage = append(byPage, user.ID.String())
+				}
+			}
+
+			filter.Page = 1
+			for {
+				result, err := repo.ListUsers(ctx, filter)
+				if err != nil {
+					t.Fatalf("ListUsers cursor %q: %v", filter.Cursor, err)
+				}
+				for _, user := range result.Users {
+					byCursor = append(byCursor, user.ID.String())
+				}
+				if result.NextCursor == "" {
+					break
+				}
+				filter.Cursor = result.NextCursor
+			}
+
+			if fmt.Sprint(byPage) != fmt.Sprint(byCursor) || len(byPage) != 7 {
+				t.Errorf("sort %s desc=%v: pages %v, cursors %v", sortBy, desc, byPage, byCursor)
+			}
+		}
+	}
+
+	active := true
+	filter := listFilter(10)
+	filter.IsActive = &active
+	if result, err := repo.ListUsers(ctx, filter); err != nil || result.Total != 6 {
+		t.Errorf("ListUsers of active users = %v, %v, want 6", result, err)
+	}
+
+	filter = listFilter(10)
+	filter.Cursor = "not-a-cursor"
+	if _, err := repo.ListUsers(ctx, filter); !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Errorf("ListUsers with a bad cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func testStreamUsers(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, repo, "Kim", fmt.Sprintf("kim%d@example.com", i))
+	}
+	var emails []string
+	err := repo.StreamUsers(ctx, domain.UserFilter{SortBy: domain.SortByEmail}, func(user *domain.User) error {
+		if user.Password != "" || user.TOTPSecret != "" {
+			t.Errorf("StreamUsers passed credentials of %s", user.Email)
+		}
+		emails = append(emails, user.Email)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamUsers: %v", err)
+	}
+	if fmt.Sprint(emails) != "[kim0@example.com kim1@example.com kim2@example.com]" {
+		t.Errorf("StreamUsers order = %v", emails)
+	}
+
+	stop := errors.New("stop")
+	if err := repo.StreamUsers(ctx, domain.UserFilter{SortBy: domain.SortByEmail}, func(*domain.User) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("StreamUsers error = %v, want the callback error", err)
+	}
+}
+
+func testEmailChangeToken(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "Leo", "leo@example.com")
+	change := &domain.PendingEmailChange{
+		Email:            "leo@new.example.com",
+		ConfirmTokenHash: "confirm-hash",
+		CancelTokenHash:  "cancel-hash",
+		RequestedAt:      time.Now(),
+		ExpiresAt:        time.Now().Add(domain.EmailChangeTTL),
+	}
+	if _, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"pending_email": change}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	for _, hash := range []string{"confirm-hash", "cancel-hash"} {
+		found, err := repo.GetUserByEmailChangeToken(ctx, hash)
+		if err != nil {
+			t.Fatalf("GetUserByEmailChangeToken(%s): %v", hash, err)
+		}
+		if found.ID != user.ID || found.PendingEmail == nil || found.PendingEmail.Email != change.Email {
+			t.Errorf("GetUserByEmailChangeToken(%s) = %+v", hash, found)
+		}
+	}
+	if _, err := repo.UpdateUser(ctx, user.ID, map[string]interface{}{"pending_email": nil}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := repo.GetUserByEmailChangeToken(ctx, "confirm-hash"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("GetUserByEmailChangeToken after clearing error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func testAnonymizeUser(t *testing.T, repo repository.UserRepository) {
+	ctx := context.Background()
+	user := newUser("Mia", "mia@example.com")
+	user.Phone = "+66812345678"
+	user.TOTPSecret = "SECRET"
+	created, err := repo.CreateUser(ctx, user)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := repo.DeleteUser(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// Deleted users are anonymized, too.
+	if err := repo.AnonymizeUser(ctx, created.ID, time.Now()); err != nil {
+		t.Fatalf("AnonymizeUser: %v", err)
+	}
+	if _, err := repo.RestoreUser(ctx, created.ID); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	erased, err := repo.GetUserByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if erased.Name == "Mia" || erased.Email == "mia@example.com" || erased.Phone != "" || erased.TOTPSecret != "" ||
+		erased.Password != "" || erased.IsActive || erased.ErasedAt == nil {
+		t.Errorf("AnonymizeUser left personal data: %+v", erased)
+	}
+	if _, err := repo.CreateUser(ctx, newUser("Mia", "mia@example.com")); err != nil {
+		t.Errorf("CreateUser with an erased user's email: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// TestUserHistoryRepository runs the conformance suite of UserHistoryRepository.
+// newRepo must return an empty repository for every call.
+func TestUserHistoryRepository(t *testing.T, newRepo func(t *testing.T) repository.UserHistoryRepository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.UserHistoryRepository)
+	}{
+		{"AddAndList", testHistoryAddAndList},
+		{"AddEntriesTwice", testHistoryAddEntriesTwice},
+		{"LatestAndEarliest", testHistoryLatestAndEarliest},
+		{"DeleteEntries", testHistoryDeleteEntries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t))
+		})
+	}
+}
+
+// historyEntries returns n entries of a new user, one millisecond apart, the first
+// a creation.
+func historyEntries(n int) []domain.UserHistoryEntry {
+	userID := domain.UserID(uuid.NewString())
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	entries := make([]domain.UserHistoryEntry, n)
+	for i := range entries {
+		entries[i] = domain.UserHistoryEntry{
+			UserID:    userID,
+			Operation: domain.HistoryUpdate,
+			Fields:    []string{"name"},
+			Before:    &domain.UserSnapshot{Name: "Somchai", Email: "somchai@example.com", Version: int64(i)},
+			After:     &domain.UserSnapshot{Name: "Somchai", Email: "somchai@example.com", Version: int64(i + 1)},
+			ActorID:   "admin-1",
+			RequestID: "req-1",
+			At:        start.Add(time.Duration(i) * time.Millisecond),
+		}
+	}
+	entries[0].Operation, entries[0].Before = domain.HistoryCreate, nil
+	entries[0].After.UserProfile = domain.UserProfile{Locale: "th-TH", Metadata: map[string]string{"plan": "pro"}}
+	return entries
+}
+
+func mustAddEntries(t *testing.T, repo repository.UserHistoryRepository, entries []domain.UserHistoryEntry) {
+	t.Helper()
+	if err := repo.AddEntries(context.Background(), entries); err != nil {
+		t.Fatalf("AddEntries: %v", err)
+	}
+}
+
+func testHistoryAddAndList(t *testing.T, repo repository.UserHistoryRepository) {
+	ctx := context.Background()
+	entries := historyEntries(3)
+	// Entries recorded at the same time keep their insertion order.
+	entries[2].At = entries[1].At
+	mustAddEntries(t, repo, entries)
+	for i, entry := range entries {
+		if entry.ID == "" {
+			t.Fatalf("entry %d has no ID after AddEntries", i)
+		}
+	}
+
+	listed, total, err := repo.ListEntries(ctx, entries[0].UserID, domain.HistoryPage{Page: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListEntries: %v", err)
+	}
+	if total != 3 || len(listed) != 2 {
+		t.Fatalf("ListEntries = %d entries of %d, want 2 of 3", len(listed), total)
+	}
+	if listed[0].ID != entries[2].ID || listed[1].ID != entries[1].ID {
+		t.Errorf("first page = %s, %s; want %s, %s", listed[0].ID, listed[1].ID, entries[2].ID, entries[1].ID)
+	}
+
+	listed, _, err = repo.ListEntries(ctx, entries[0].UserID, domain.HistoryPage{Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListEntries: %v", err)
+	}
+	if len(listed) != 1 {
+		t.Fatalf("second page has %d entries, want 1", len(listed))
+	}
+	created := listed[0]
+	if created.ID != entries[0].ID || created.Operation != domain.HistoryCreate || created.Before != nil {
+		t.Errorf("second page = %+v, want the creation without a previous state", created)
+	}
+	if created.After == nil || created.After.Email != "somchai@example.com" || created.After.Locale != "th-TH" ||
+		created.After.Metadata["plan"] != "pro" || created.After.Version != 1 {
+		t.Errorf("After = %+v, want the stored snapshot", created.After)
+	}
+	if !created.At.Equal(entries[0].At) || created.ActorID != "admin-1" || created.RequestID != "req-1" ||
+		len(created.Fields) != 1 || created.Fields[0] != "name" {
+		t.Errorf("entry = %+v, want the stored entry", created)
+	}
+
+	listed, total, err = repo.ListEntries(ctx, domain.UserID(uuid.NewString()), domain.HistoryPage{Page: 1, Limit: 10})
+	if err != nil || total != 0 || len(listed) != 0 {
+		t.Errorf("ListEntries(unknown) = %d entries of %d, %v; want none", len(listed), total, err)
+	}
+}
+
+// testHistoryAddEntriesTwice checks that repeating a call, e.g. after it failed
+// with the entries partially stored, stores every entry once.
+func testHistoryAddEntriesTwice(t *testing.T, repo repository.UserHistoryRepository) {
+	entries := historyEntries(2)
+	mustAddEntries(t, repo, entries[:1])
+	mustAddEntries(t, repo, entries)
+	mustAddEntries(t, repo, entries)
+
+	_, total, err := repo.ListEntries(context.Background(), entries[0].UserID, domain.HistoryPage{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListEntries: %v", err)
+	}
+	if total != 2 {
+		t.Errorf("total = %d after repeated AddEntries, want 2", total)
+	}
+}
+
+func testHistoryLatestAndEarliest(t *testing.T, repo repository.UserHistoryRepository) {
+	ctx := context.Background()
+	entries := historyEntries(3)
+	mustAddEntries(t, repo, entries)
+	userID := entries[0].UserID
+
+	latest, err := repo.LatestEntryAt(ctx, userID, entries[1].At)
+	if err != nil || latest.ID != entries[1].ID {
+		t.Errorf("LatestEntryAt(second) = %v, %v; want the second entry", latest, err)
+	}
+	latest, err = repo.LatestEntryAt(ctx, userID, entries[2].At.Add(time.Hour))
+	if err != nil || latest.ID != entries[2].ID {
+		t.Errorf("LatestEntryAt(later) = %v, %v; want the last entry", latest, err)
+	}
+	if _, err := repo.LatestEntryAt(ctx, userID, entries[0].At.Add(-time.Millisecond)); !errors.Is(err, domain.ErrHistoryEntryNotFound) {
+		t.Errorf("LatestEntryAt(before) error = %v, want ErrHistoryEntryNotFound", err)
+	}
+
+	earliest, err := repo.EarliestEntry(ctx, userID)
+	if err != nil || earliest.ID != entries[0].ID {
+		t.Errorf("EarliestEntry = %v, %v; want the first entry", earliest, err)
+	}
+	if _, err := repo.EarliestEntry(ctx, domain.UserID(uuid.NewString())); !errors.Is(err, domain.ErrHistoryEntryNotFound) {
+		t.Errorf("EarliestEntry(unknown) error = %v, want ErrHistoryEntryNotFound", err)
+	}
+}
+
+func testHistoryDeleteEntries(t *testing.T, repo repository.UserHistoryRepository) {
+	ctx := context.Background()
+	entries, other := historyEntries(2), historyEntries(1)
+	mustAddEntries(t, repo, entries)
+	mustAddEntries(t, repo, other)
+
+	deleted, err := repo.DeleteEntries(ctx, entries[0].UserID)
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteEntries = %d, %v; want 2", deleted, err)
+	}
+	if _, total, _ := repo.ListEntries(ctx, entries[0].UserID, domain.HistoryPage{Page: 1, Limit: 10}); total != 0 {
+		t.Errorf("%d entries left after DeleteEntries", total)
+	}
+	if _, total, _ := repo.ListEntries(ctx, other[0].UserID, domain.HistoryPage{Page: 1, Limit: 10}); total != 1 {
+		t.Errorf("DeleteEntries removed entries of another user: %d left, want 1", total)
+	}
+}
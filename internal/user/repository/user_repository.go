@@ -2,17 +2,89 @@ package repository
 
 import (
 	"context"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 
 	"github.com/iots1/mingkwan-api/internal/user/domain"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) (*domain.User, error)
-	GetUserByID(ctx context.Context, id primitive.ObjectID) (*domain.User, error)
+	GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
-	GetAllUsers(ctx context.Context) ([]domain.User, error)
-	UpdateUser(ctx context.Context, id primitive.ObjectID, update map[string]interface{}) (*domain.User, error)
-	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+	// CreateUsers inserts users in one batch and returns an error per user, nil for
+	// the users that were created. The returned error is set only if the batch failed
+	// as a whole.
+	CreateUsers(ctx context.Context, users []*domain.User) ([]error, error)
+	// ExistingEmails returns the canonical forms (see domain.CanonicalEmail) of the
+	// given emails that belong to a user, including soft-deleted ones.
+	ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error)
+	// StreamUsers calls fn for every user matching the filter, in the filter's sort
+	// order and ignoring pagination. Users are passed without credentials.
+	StreamUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error
+	// UpdateUser applies update only if the stored version equals expectedVersion
+	// (or expectedVersion is domain.AnyVersion) and returns domain.ErrVersionConflict
+	// otherwise. Fields mapped to nil are removed.
+	UpdateUser(ctx context.Context, id domain.UserID, update map[string]interface{}, expectedVersion int64) (*domain.User, error)
+	// DeleteUser soft-deletes a user; RestoreUser undoes it and PurgeUser removes a
+	// soft-deleted user for good.
+	DeleteUser(ctx context.Context, id domain.UserID) error
+	// SetActive activates or deactivates a user, recording actorID and reason on
+	// deactivation and clearing them on reactivation.
+	SetActive(ctx context.Context, id domain.UserID, active bool, actorID, reason string) (*domain.User, error)
+	RestoreUser(ctx context.Context, id domain.UserID) (*domain.User, error)
+	ListDeletedBefore(ctx context.Context, cutoff time.Time, limit int) ([]domain.User, error)
+	PurgeUser(ctx context.Context, id domain.UserID) error
+	// GetUserByEmailChangeToken returns the user whose pending email change has
+	// tokenHash as its confirm or cancel token hash.
+	GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*domain.User, error)
+	// AnonymizeUser replaces the personal data of a user, deleted or not, with
+	// placeholders and disables the account for good.
+	AnonymizeUser(ctx context.Context, id domain.UserID, erasedAt time.Time) error
+}
+
+// UserHistoryRepository stores the history of writes to users. Entries of a user are
+// ordered by time, newest first.
+type UserHistoryRepository interface {
+	// AddEntries gives entries without an ID one, in place, and skips entries whose
+	// ID is already stored, so that a failed call can be repeated with the same
+	// entries.
+	AddEntries(ctx context.Context, entries []domain.UserHistoryEntry) error
+	ListEntries(ctx context.Context, userID domain.UserID, page domain.HistoryPage) ([]domain.UserHistoryEntry, int64, error)
+	// LatestEntryAt returns the newest entry recorded at or before t and
+	// EarliestEntry the oldest one; both return domain.ErrHistoryEntryNotFound if
+	// there is none.
+	LatestEntryAt(ctx context.Context, userID domain.UserID, t time.Time) (*domain.UserHistoryEntry, error)
+	EarliestEntry(ctx context.Context, userID domain.UserID) (*domain.UserHistoryEntry, error)
+	DeleteEntries(ctx context.Context, userID domain.UserID) (int64, error)
+}
+
+// UserMergeRepository stores user merges, keyed by the merged (source) user.
+type UserMergeRepository interface {
+	// CreateMerge returns domain.ErrUserMerged if the source was already merged.
+	CreateMerge(ctx context.Context, merge *domain.UserMerge) error
+	// GetMerge returns domain.ErrUserMergeNotFound if the user was not merged.
+	GetMerge(ctx context.Context, sourceID domain.UserID) (*domain.UserMerge, error)
+	DeleteMerge(ctx context.Context, sourceID domain.UserID) error
+	// MarkRepointed sets the RepointedAt of a merge.
+	MarkRepointed(ctx context.Context, sourceID domain.UserID, at time.Time) error
+	// PendingRepoints returns up to limit merges made before mergedBefore whose
+	// RepointedAt is unset, oldest first.
+	PendingRepoints(ctx context.Context, mergedBefore time.Time, limit int) ([]domain.UserMerge, error)
+}
+
+// UserImportStore keeps bulk import jobs and the rows still waiting to be imported.
+type UserImportStore interface {
+	SaveJob(ctx context.Context, job *domain.ImportJob) error
+	GetJob(ctx context.Context, id string) (*domain.ImportJob, error)
+	SaveRows(ctx context.Context, jobID string, rows []domain.ImportRow) error
+	GetRows(ctx context.Context, jobID string) ([]domain.ImportRow, error)
+	DeleteRows(ctx context.Context, jobID string) error
+}
+
+// UserSearcher finds users by partial name or email. It is separate from
+// UserRepository so that a dedicated search engine can replace the database-backed
+// implementation without touching persistence.
+type UserSearcher interface {
+	SearchUsers(ctx context.Context, query domain.UserSearchQuery) (*domain.UserSearchResult, error)
 }
@@ -0,0 +1,54 @@
+// Package usecasetest builds the user use case over in-memory storage for the tests
+// of the user module and of the modules that depend on it.
+package usecasetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	"github.com/iots1/mingkwan-api/internal/user/adapters"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+// AppURL is the frontend URL that links in emails are built with.
+const AppURL = "https://app.example.com"
+
+// Fixture is a UserUsecase over an in-memory repository whose published events
+// are recorded.
+type Fixture struct {
+	Users   *usecase.UserUsecase
+	Repo    *adapters.MemoryUserRepository
+	LowPub  *eventtest.RecordingPublisher
+	HighPub *eventtest.RecordingPublisher
+}
+
+// NewFixture returns an empty fixture. wrap, if given, decorates the repository the
+// use case writes through, e.g. to record history; Repo stays the inner store.
+func NewFixture(wrap ...func(repository.UserRepository) repository.UserRepository) *Fixture {
+	f := &Fixture{
+		Repo:    adapters.NewMemoryUserRepository(),
+		LowPub:  eventtest.NewRecordingPublisher(),
+		HighPub: eventtest.NewRecordingPublisher(),
+	}
+	var repo repository.UserRepository = f.Repo
+	for _, w := range wrap {
+		repo = w(repo)
+	}
+	f.Users = usecase.NewUserUsecase(repo, nil, f.LowPub, f.HighPub, nil, AppURL)
+	return f
+}
+
+// Seed stores an active user directly in the repository, bypassing password
+// hashing and events.
+func (f *Fixture) Seed(t *testing.T, user *domain.User) *domain.User {
+	t.Helper()
+	user.IsActive = true
+	created, err := f.Repo.CreateUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("seed %s: %v", user.Email, err)
+	}
+	return created
+}
@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+)
+
+// emailChangeLinks holds the plain tokens of a pending email change until they are
+// sent; only their hashes are stored.
+type emailChangeLinks struct {
+	confirmToken string
+	cancelToken  string
+}
+
+func newPendingEmailChange(email string) (*domain.PendingEmailChange, *emailChangeLinks, error) {
+	confirmToken, err := generateEmailChangeToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	cancelToken, err := generateEmailChangeToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	change := &domain.PendingEmailChange{
+		Email:            email,
+		ConfirmTokenHash: hashEmailChangeToken(confirmToken),
+		CancelTokenHash:  hashEmailChangeToken(cancelToken),
+		RequestedAt:      now,
+		ExpiresAt:        now.Add(domain.EmailChangeTTL),
+	}
+	return change, &emailChangeLinks{confirmToken: confirmToken, cancelToken: cancelToken}, nil
+}
+
+// sendEmailChangeMessages queues the confirmation link to the new address and the
+// notice with the cancel link to the current one. A failed message leaves the
+// change pending; the user can request it again to get new links.
+func (s *UserUsecase) sendEmailChangeMessages(ctx context.Context, user *domain.User, links *emailChangeLinks) {
+	change := user.PendingEmail
+	if change == nil {
+		return
+	}
+	confirmation := event.EmailChangeConfirmationPayload{
+		UserID:     user.ID.String(),
+		Name:       user.Name,
+		NewEmail:   change.Email,
+		ConfirmURL: s.publicURL + "/email-change/confirm?token=" + url.QueryEscape(links.confirmToken),
+		ExpiresAt:  change.ExpiresAt,
+	}
+	if err := s.highPub.Publish(ctx, event.EmailChangeConfirmationTask, confirmation); err != nil {
+		utils.Logger.Error("UserUsecase: Failed to enqueue email change confirmation", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+
+	notice := event.EmailChangeNoticePayload{
+		UserID:    user.ID.String(),
+		Name:      user.Name,
+		OldEmail:  user.Email,
+		NewEmail:  change.Email,
+		CancelURL: s.publicURL + "/email-change/cancel?token=" + url.QueryEscape(links.cancelToken),
+	}
+	if err := s.highPub.Publish(ctx, event.EmailChangeNoticeTask, notice); err != nil {
+		utils.Logger.Error("UserUsecase: Failed to enqueue email change notice", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionUserEmailChange, user.ID.String(), nil, map[string]interface{}{"stage": "requested"})
+}
+
+// ConfirmEmailChange applies the pending email change whose confirmation link
+// carried token. The address must still be free.
+func (s *UserUsecase) ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error) {
+	tokenHash := hashEmailChangeToken(token)
+	user, err := s.findEmailChange(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	change := user.PendingEmail
+	if change.ConfirmTokenHash != tokenHash || time.Now().After(change.ExpiresAt) {
+		return nil, domain.ErrEmailChangeInvalid
+	}
+	if err := s.ensureEmailAvailable(ctx, user.ID, change.Email); err != nil {
+		if errors.Is(err, domain.ErrUserAlreadyExists) {
+			s.recordAudit(ctx, auditDomain.ActionUserEmailChange, user.ID.String(), err, map[string]interface{}{"stage": "confirmed"})
+		}
+		return nil, err
+	}
+
+	update := map[string]interface{}{"email": change.Email, "pending_email": nil}
+	updatedUser, err := s.repo.UpdateUser(ctx, user.ID, update, user.Version)
+	if err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrUserNotFound) {
+			// Cancelled, replaced or confirmed by a concurrent request.
+			return nil, domain.ErrEmailChangeInvalid
+		}
+		utils.Logger.Error("ConfirmEmailChange: Failed to apply email change", zap.String("user_id", user.ID.String()), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionUserEmailChange, user.ID.String(), err, map[string]interface{}{"stage": "confirmed"})
+		return nil, fmt.Errorf("failed to apply email change: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionUserEmailChange, user.ID.String(), nil, map[string]interface{}{"stage": "confirmed"})
+	utils.Logger.Info("ConfirmEmailChange: Email changed", zap.String("user_id", user.ID.String()))
+	return updatedUser, nil
+}
+
+// CancelEmailChange drops the pending email change whose cancel link carried token.
+// Cancelling works after the confirmation link expired, too.
+func (s *UserUsecase) CancelEmailChange(ctx context.Context, token string) error {
+	tokenHash := hashEmailChangeToken(token)
+	user, err := s.findEmailChange(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail.CancelTokenHash != tokenHash {
+		return domain.ErrEmailChangeInvalid
+	}
+
+	if _, err := s.repo.UpdateUser(ctx, user.ID, map[string]interface{}{"pending_email": nil}, user.Version); err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrEmailChangeInvalid
+		}
+		utils.Logger.Error("CancelEmailChange: Failed to cancel email change", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return fmt.Errorf("failed to cancel email change: %w", err)
+	}
+
+	s.recordAudit(ctx, auditDomain.ActionUserEmailChange, user.ID.String(), nil, map[string]interface{}{"stage": "cancelled"})
+	utils.Logger.Info("CancelEmailChange: Email change cancelled", zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+func (s *UserUsecase) findEmailChange(ctx context.Context, tokenHash string) (*domain.User, error) {
+	user, err := s.repo.GetUserByEmailChangeToken(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrEmailChangeInvalid
+		}
+		utils.Logger.Error("UserUsecase: Failed to look up email change", zap.Error(err))
+		return nil, fmt.Errorf("failed to look up email change: %w", err)
+	}
+	if user.PendingEmail == nil {
+		return nil, domain.ErrEmailChangeInvalid
+	}
+	return user, nil
+}
+
+func generateEmailChangeToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
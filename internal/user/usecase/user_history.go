@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// UserHistoryUsecase reads the history recorded by adapters.HistoryUserRepository.
+type UserHistoryUsecase struct {
+	repo    repository.UserRepository
+	history repository.UserHistoryRepository
+}
+
+func NewUserHistoryUsecase(repo repository.UserRepository, history repository.UserHistoryRepository) *UserHistoryUsecase {
+	return &UserHistoryUsecase{repo: repo, history: history}
+}
+
+// ListHistory returns one page of the writes to a user, newest first, and the total
+// number of entries. The history of deleted users stays readable until they are
+// purged.
+func (s *UserHistoryUsecase) ListHistory(ctx context.Context, id domain.UserID, page domain.HistoryPage) ([]domain.UserHistoryEntry, int64, error) {
+	page.Normalize()
+	entries, total, err := s.history.ListEntries(ctx, id, page)
+	if err != nil {
+		utils.Logger.Error("UserHistoryUsecase: Failed to list user history", zap.String("user_id", id.String()), zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to list user history: %w", err)
+	}
+	return entries, total, nil
+}
+
+// GetUserAsOf rebuilds the user as it was at asOf, without credentials. It returns
+// domain.ErrUserNotFound if the user did not exist or was deleted at that time.
+//
+// The state is taken from the last history entry up to asOf. Users whose history
+// starts later, e.g. because they were created before it was recorded, are rebuilt
+// from the state before their first entry, or read as they are if they have none.
+func (s *UserHistoryUsecase) GetUserAsOf(ctx context.Context, id domain.UserID, asOf time.Time) (*domain.User, error) {
+	snapshot, err := s.snapshotAt(ctx, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil || snapshot.DeletedAt != nil || snapshot.CreatedAt.After(asOf) {
+		return nil, domain.ErrUserNotFound
+	}
+	return snapshot.User(id), nil
+}
+
+// snapshotAt returns the recorded state of the user at asOf, nil if unknown.
+func (s *UserHistoryUsecase) snapshotAt(ctx context.Context, id domain.UserID, asOf time.Time) (*domain.UserSnapshot, error) {
+	entry, err := s.history.LatestEntryAt(ctx, id, asOf)
+	if err == nil {
+		return entry.After, nil
+	}
+	if !errors.Is(err, domain.ErrHistoryEntryNotFound) {
+		utils.Logger.Error("UserHistoryUsecase: Failed to read user history", zap.String("user_id", id.String()), zap.Error(err))
+		return nil, fmt.Errorf("failed to read user history: %w", err)
+	}
+
+	first, err := s.history.EarliestEntry(ctx, id)
+	if err == nil {
+		return first.Before, nil
+	}
+	if !errors.Is(err, domain.ErrHistoryEntryNotFound) {
+		utils.Logger.Error("UserHistoryUsecase: Failed to read user history", zap.String("user_id", id.String()), zap.Error(err))
+		return nil, fmt.Errorf("failed to read user history: %w", err)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	return domain.NewUserSnapshot(user), nil
+}
+
+// ExportHistory calls fn for every history entry of a user, newest first.
+func (s *UserHistoryUsecase) ExportHistory(ctx context.Context, id domain.UserID, fn func(entry *domain.UserHistoryEntry) error) error {
+	page := domain.HistoryPage{Limit: domain.MaxHistoryLimit}
+	page.Normalize()
+	for {
+		entries, total, err := s.history.ListEntries(ctx, id, page)
+		if err != nil {
+			return fmt.Errorf("failed to list user history: %w", err)
+		}
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if len(entries) == 0 || int64(page.Skip()+len(entries)) >= total {
+			return nil
+		}
+		page.Page++
+	}
+}
+
+// RecordEntries stores history entries that HistoryUserRepository could not store
+// with their write. Entries stored before are skipped.
+func (s *UserHistoryUsecase) RecordEntries(ctx context.Context, entries []domain.UserHistoryEntry) error {
+	if err := s.history.AddEntries(ctx, entries); err != nil {
+		return fmt.Errorf("failed to record user history: %w", err)
+	}
+	return nil
+}
+
+// DeleteHistory removes the history of a user, for erasure requests.
+func (s *UserHistoryUsecase) DeleteHistory(ctx context.Context, id domain.UserID) (int64, error) {
+	n, err := s.history.DeleteEntries(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user history: %w", err)
+	}
+	return n, nil
+}
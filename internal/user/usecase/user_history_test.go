@@ -0,0 +1,232 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/adapters"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+	"github.com/iots1/mingkwan-api/internal/user/usecase"
+	"github.com/iots1/mingkwan-api/internal/user/usecase/usecasetest"
+)
+
+type historyFixture struct {
+	*usecasetest.Fixture
+	history *usecase.UserHistoryUsecase
+	store   *failingHistoryRepository
+	outbox  *eventtest.RecordingPublisher
+}
+
+func newHistoryFixture() *historyFixture {
+	store := &failingHistoryRepository{UserHistoryRepository: adapters.NewMemoryUserHistoryRepository()}
+	outbox := eventtest.NewRecordingPublisher()
+	f := &historyFixture{store: store, outbox: outbox}
+	f.Fixture = usecasetest.NewFixture(func(repo repository.UserRepository) repository.UserRepository {
+		return adapters.NewHistoryUserRepository(repo, store, outbox)
+	})
+	f.history = usecase.NewUserHistoryUsecase(f.Repo, store)
+	return f
+}
+
+// failingHistoryRepository fails to add entries while err is set.
+type failingHistoryRepository struct {
+	repository.UserHistoryRepository
+	err error
+}
+
+func (r *failingHistoryRepository) AddEntries(ctx context.Context, entries []domain.UserHistoryEntry) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.UserHistoryRepository.AddEntries(ctx, entries)
+}
+
+// checkpoint returns a time between the writes before and after it. History times
+// are stored with millisecond precision.
+func checkpoint() time.Time {
+	time.Sleep(2 * time.Millisecond)
+	t := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	return t
+}
+
+func TestUserHistory_RecordsWrites(t *testing.T) {
+	f := newHistoryFixture()
+	ctx := utils.WithRequestMeta(context.Background(), &utils.RequestMeta{ActorID: "admin-1", CorrelationID: "req-1"})
+
+	user, err := f.Users.CreateUser(ctx, &domain.User{Name: "Somchai", Email: "somchai@example.com", Password: "secret123", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := f.Users.UpdateUser(ctx, user.ID.String(), "Somchai J.", "", domain.UserProfile{Locale: "th-TH"}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := f.Users.DeleteUser(ctx, user.ID.String()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	entries, total, err := f.history.ListHistory(ctx, user.ID, domain.HistoryPage{})
+	if err != nil {
+		t.Fatalf("ListHistory: %v", err)
+	}
+	if total != 3 || len(entries) != 3 {
+		t.Fatalf("history has %d entries (total %d), want 3", len(entries), total)
+	}
+	wantOperations := []string{domain.HistoryDelete, domain.HistoryUpdate, domain.HistoryCreate}
+	for i, entry := range entries {
+		if entry.Operation != wantOperations[i] {
+			t.Errorf("entry %d operation = %s, want %s", i, entry.Operation, wantOperations[i])
+		}
+		if entry.ActorID != "admin-1" || entry.RequestID != "req-1" {
+			t.Errorf("entry %d actor = %q, request = %q", i, entry.ActorID, entry.RequestID)
+		}
+	}
+
+	update := entries[1]
+	if !slices.Equal(update.Fields, []string{"name", "locale"}) {
+		t.Errorf("update fields = %v, want [name locale]", update.Fields)
+	}
+	if update.Before.Value("name") != "Somchai" || update.After.Value("name") != "Somchai J." {
+		t.Errorf("name changed from %v to %v", update.Before.Value("name"), update.After.Value("name"))
+	}
+	create := entries[2]
+	if !slices.Contains(create.Fields, domain.HistoryFieldPassword) {
+		t.Errorf("create fields = %v, want the password change named", create.Fields)
+	}
+	if create.Before != nil || create.After.Email != "somchai@example.com" {
+		t.Errorf("create entry before = %+v, after = %+v", create.Before, create.After)
+	}
+	if !slices.Equal(entries[0].Fields, []string{"deleted_at"}) {
+		t.Errorf("delete fields = %v, want [deleted_at]", entries[0].Fields)
+	}
+}
+
+func TestUserHistory_GetUserAsOf(t *testing.T) {
+	f := newHistoryFixture()
+	ctx := context.Background()
+
+	beforeCreate := checkpoint()
+	user, err := f.Users.CreateUser(ctx, &domain.User{Name: "Malee", Email: "malee@example.com", Password: "secret123", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	afterCreate := checkpoint()
+	if _, err := f.Users.PatchUser(ctx, user.ID.String(), map[string]interface{}{"phone": "+66812345678"}, domain.AnyVersion); err != nil {
+		t.Fatalf("PatchUser: %v", err)
+	}
+	afterPatch := checkpoint()
+	if _, err := f.Users.DeactivateUser(ctx, user.ID.String(), "fraud review"); err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+	afterDeactivate := checkpoint()
+	if err := f.Users.DeleteUser(ctx, user.ID.String()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	afterDelete := checkpoint()
+	if _, err := f.Users.RestoreUser(ctx, user.ID.String()); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		asOf       time.Time
+		wantErr    error
+		wantPhone  string
+		wantActive bool
+	}{
+		{name: "before creation", asOf: beforeCreate, wantErr: domain.ErrUserNotFound},
+		{name: "after creation", asOf: afterCreate, wantActive: true},
+		{name: "after patch", asOf: afterPatch, wantPhone: "+66812345678", wantActive: true},
+		{name: "after deactivation", asOf: afterDeactivate, wantPhone: "+66812345678"},
+		{name: "while deleted", asOf: afterDelete, wantErr: domain.ErrUserNotFound},
+		{name: "now", asOf: time.Now().Add(time.Second), wantPhone: "+66812345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.history.GetUserAsOf(ctx, user.ID, tt.asOf)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUserAsOf error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.ID != user.ID || got.Email != "malee@example.com" || got.Phone != tt.wantPhone || got.IsActive != tt.wantActive {
+				t.Errorf("GetUserAsOf = %+v, want phone %q, active %v", got, tt.wantPhone, tt.wantActive)
+			}
+			if got.Password != "" {
+				t.Error("rebuilt user carries a password hash")
+			}
+		})
+	}
+}
+
+func TestUserHistory_GetUserAsOfWithoutHistory(t *testing.T) {
+	f := newHistoryFixture()
+	ctx := context.Background()
+
+	// Users written before history was recorded have no entries, or only later ones.
+	user, err := f.Repo.CreateUser(ctx, &domain.User{Name: "Legacy", Email: "legacy@example.com", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	beforeUpdate := checkpoint()
+	got, err := f.history.GetUserAsOf(ctx, user.ID, beforeUpdate)
+	if err != nil || got.Name != "Legacy" {
+		t.Fatalf("GetUserAsOf without history = %+v, %v", got, err)
+	}
+
+	if _, err := f.Users.UpdateUser(ctx, user.ID.String(), "Renamed", "", domain.UserProfile{}, domain.AnyVersion); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	got, err = f.history.GetUserAsOf(ctx, user.ID, beforeUpdate)
+	if err != nil || got.Name != "Legacy" {
+		t.Errorf("GetUserAsOf before the first entry = %+v, %v", got, err)
+	}
+	if _, err := f.history.GetUserAsOf(ctx, user.ID, user.CreatedAt.Add(-time.Hour)); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("GetUserAsOf before creation error = %v, want not found", err)
+	}
+}
+
+func TestUserHistory_QueuesEntriesThatFailToRecord(t *testing.T) {
+	f := newHistoryFixture()
+	ctx := context.Background()
+	f.store.err = errors.New("history store down")
+
+	user, err := f.Users.CreateUser(ctx, &domain.User{Name: "Somchai", Email: "somchai@example.com", Password: "secret123", IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser with the history store down: %v", err)
+	}
+	queued := f.outbox.MessagesFor(event.RecordUserHistoryTask)
+	if len(queued) != 1 {
+		t.Fatalf("queued %d history tasks, want 1", len(queued))
+	}
+	payload := queued[0].Payload.(domain.RecordHistoryPayload)
+	if len(payload.Entries) != 1 || payload.Entries[0].ID == "" || payload.Entries[0].Operation != domain.HistoryCreate {
+		t.Fatalf("queued entries = %+v, want the creation with its ID", payload.Entries)
+	}
+
+	// The task stores the entries once, however often it runs.
+	f.store.err = nil
+	for range 2 {
+		if err := f.history.RecordEntries(ctx, payload.Entries); err != nil {
+			t.Fatalf("RecordEntries: %v", err)
+		}
+	}
+	if _, total, err := f.history.ListHistory(ctx, user.ID, domain.HistoryPage{}); err != nil || total != 1 {
+		t.Errorf("ListHistory = %d entries, %v; want 1", total, err)
+	}
+
+	// Without a way to record the write later, the write fails.
+	f.store.err = errors.New("history store down")
+	f.outbox.Err = errors.New("queue down")
+	if _, err := f.Users.UpdateUser(ctx, user.ID.String(), "Somchai J.", "", domain.UserProfile{}, domain.AnyVersion); err == nil {
+		t.Error("UpdateUser succeeded although its history was neither recorded nor queued")
+	}
+}
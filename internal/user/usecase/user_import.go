@@ -0,0 +1,407 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	sharedAdapter "github.com/iots1/mingkwan-api/internal/shared/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// UserImportUsecase creates users in bulk from uploaded files.
+type UserImportUsecase struct {
+	repo    repository.UserRepository
+	store   repository.UserImportStore
+	hasher  sharedAdapter.PasswordHasher
+	highPub event.Publisher
+	audit   *auditUsecase.AuditUsecase
+}
+
+func NewUserImportUsecase(
+	repo repository.UserRepository,
+	store repository.UserImportStore,
+	hasher sharedAdapter.PasswordHasher,
+	highPub event.Publisher,
+	audit *auditUsecase.AuditUsecase,
+) *UserImportUsecase {
+	return &UserImportUsecase{
+		repo:    repo,
+		store:   store,
+		hasher:  hasher,
+		highPub: highPub,
+		audit:   audit,
+	}
+}
+
+// StartImport takes the rows that passed request validation and the results of those
+// that did not. It rejects rows whose email is taken or repeated in the file, then
+// either reports what would happen (dryRun) or hashes the passwords of the remaining
+// rows, queues them for the import job and returns the pending job. Plain-text
+// passwords are never stored.
+func (s *UserImportUsecase) StartImport(ctx context.Context, rows []domain.ImportRow, rejected []domain.ImportRowResult, dryRun bool) (*domain.ImportJob, error) {
+	total := len(rows) + len(rejected)
+	if total == 0 {
+		return nil, domain.ErrImportEmpty
+	}
+	if total > domain.MaxImportRows {
+		return nil, domain.ErrImportTooLarge
+	}
+
+	now := time.Now()
+	job := &domain.ImportJob{
+		Status:    domain.ImportStatusPending,
+		Total:     total,
+		CreatedBy: utils.RequestMetaFromContext(ctx).ActorID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if dryRun {
+		job.Status = domain.ImportStatusDryRun
+	}
+	for _, result := range rejected {
+		job.AddResult(result)
+	}
+
+	accepted, err := s.rejectDuplicates(ctx, job, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		for _, row := range accepted {
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowValid})
+		}
+		sortResults(job)
+		return job, nil
+	}
+
+	accepted, err = s.hashPasswords(ctx, job, accepted)
+	if err != nil {
+		return nil, err
+	}
+
+	job.ID = uuid.NewString()
+	sortResults(job)
+	if err := s.store.SaveRows(ctx, job.ID, accepted); err != nil {
+		utils.Logger.Error("UserImportUsecase: Failed to store import rows", zap.String("job_id", job.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store import rows: %w", err)
+	}
+	if err := s.store.SaveJob(ctx, job); err != nil {
+		utils.Logger.Error("UserImportUsecase: Failed to store import job", zap.String("job_id", job.ID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store import job: %w", err)
+	}
+	if err := s.highPub.Publish(ctx, event.ImportUsersTask, event.ImportUsersPayload{JobID: job.ID}); err != nil {
+		utils.Logger.Error("UserImportUsecase: Failed to enqueue import job", zap.String("job_id", job.ID), zap.Error(err))
+		s.failJob(ctx, job, err)
+		return nil, fmt.Errorf("failed to enqueue import job: %w", err)
+	}
+
+	s.recordAudit(ctx, job.ID, nil, map[string]interface{}{
+		"stage":    "queued",
+		"total":    job.Total,
+		"rejected": job.Failed,
+	})
+	utils.Logger.Info("UserImportUsecase: Import job queued", zap.String("job_id", job.ID),
+		zap.Int("total", job.Total), zap.Int("accepted", len(accepted)))
+	return job, nil
+}
+
+// rejectDuplicates records a failure for rows whose email is already registered or
+// appears earlier in the file, and returns the remaining rows.
+func (s *UserImportUsecase) rejectDuplicates(ctx context.Context, job *domain.ImportJob, rows []domain.ImportRow) ([]domain.ImportRow, error) {
+	firstRow := make(map[string]int, len(rows))
+	unique := make([]domain.ImportRow, 0, len(rows))
+	for _, row := range rows {
+		key := domain.CanonicalEmail(row.Email)
+		if first, seen := firstRow[key]; seen {
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+				Errors: map[string][]string{"email": {fmt.Sprintf("email is already used by row %d of the file", first)}}})
+			continue
+		}
+		firstRow[key] = row.Row
+		unique = append(unique, row)
+	}
+
+	emails := make([]string, len(unique))
+	for i, row := range unique {
+		emails[i] = row.Email
+	}
+	existing, err := s.repo.ExistingEmails(ctx, emails)
+	if err != nil {
+		utils.Logger.Error("UserImportUsecase: Failed to check existing emails", zap.Error(err))
+		return nil, fmt.Errorf("failed to check existing emails: %w", err)
+	}
+
+	accepted := unique[:0]
+	for _, row := range unique {
+		if existing[domain.CanonicalEmail(row.Email)] {
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+				Errors: map[string][]string{"email": {domain.ErrUserAlreadyExists.Error()}}})
+			continue
+		}
+		accepted = append(accepted, row)
+	}
+	return accepted, nil
+}
+
+// hashPasswords replaces the plain-text password of every row by its hash, hashing
+// concurrently. Rows whose password cannot be hashed are rejected.
+func (s *UserImportUsecase) hashPasswords(ctx context.Context, job *domain.ImportJob, rows []domain.ImportRow) ([]domain.ImportRow, error) {
+	errs := make([]error, len(rows))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			rows[i].PasswordHash, errs[i] = s.hasher.HashPassword(rows[i].Password)
+			rows[i].Password = ""
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		utils.Logger.Error("UserImportUsecase: Hashing import passwords timed out", zap.Int("rows", len(rows)), zap.Error(err))
+		return nil, fmt.Errorf("failed to hash import passwords: %w", err)
+	}
+
+	hashed := rows[:0]
+	for i, row := range rows {
+		if errs[i] != nil {
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+				Errors: map[string][]string{"password": {"failed to hash password"}}})
+			continue
+		}
+		hashed = append(hashed, row)
+	}
+	return hashed, nil
+}
+
+// RunImport creates the users of a queued job in batches, saving the job after each
+// batch so that progress can be polled. A retried job skips rows it already handled,
+// and reports the users it created before it could save its progress as created.
+func (s *UserImportUsecase) RunImport(ctx context.Context, jobID string) error {
+	job, err := s.store.GetJob(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to load import job: %w", err)
+	}
+	if job.Status == domain.ImportStatusCompleted || job.Status == domain.ImportStatusFailed {
+		return nil
+	}
+	// Audit entries of the import are attributed to the admin who started it.
+	ctx = utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: job.CreatedBy})
+
+	rows, err := s.store.GetRows(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, domain.ErrImportJobNotFound) {
+			utils.Logger.Error("UserImportUsecase: Rows of import job expired", zap.String("job_id", jobID))
+			s.failJob(ctx, job, errors.New("import rows expired before the job ran"))
+			return nil
+		}
+		return fmt.Errorf("failed to load import rows: %w", err)
+	}
+
+	job.Status = domain.ImportStatusRunning
+	handled := job.Handled()
+	pending := make([]domain.ImportRow, 0, len(rows))
+	for _, row := range rows {
+		if !handled[row.Row] {
+			pending = append(pending, row)
+		}
+	}
+
+	for start := 0; start < len(pending); start += domain.ImportBatchSize {
+		end := min(start+domain.ImportBatchSize, len(pending))
+		if err := s.importBatch(ctx, job, pending[start:end]); err != nil {
+			job.Error = err.Error()
+			if saveErr := s.saveJob(ctx, job); saveErr != nil {
+				utils.Logger.Error("UserImportUsecase: Failed to save import progress", zap.String("job_id", jobID), zap.Error(saveErr))
+			}
+			return fmt.Errorf("import batch failed: %w", err)
+		}
+		if err := s.saveJob(ctx, job); err != nil {
+			return fmt.Errorf("failed to save import progress: %w", err)
+		}
+	}
+
+	finishedAt := time.Now()
+	job.Status = domain.ImportStatusCompleted
+	job.Error = ""
+	job.FinishedAt = &finishedAt
+	sortResults(job)
+	if err := s.saveJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to save completed import job: %w", err)
+	}
+	if err := s.store.DeleteRows(ctx, jobID); err != nil {
+		utils.Logger.Warn("UserImportUsecase: Failed to delete import rows", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	s.recordAudit(ctx, jobID, nil, map[string]interface{}{
+		"stage":     "completed",
+		"total":     job.Total,
+		"succeeded": job.Succeeded,
+		"failed":    job.Failed,
+	})
+	utils.Logger.Info("UserImportUsecase: Import job completed", zap.String("job_id", jobID),
+		zap.Int("succeeded", job.Succeeded), zap.Int("failed", job.Failed))
+	return nil
+}
+
+// importBatch creates the users of one batch and records a result for every row. It
+// only returns an error if the batch could not be written at all.
+func (s *UserImportUsecase) importBatch(ctx context.Context, job *domain.ImportJob, batch []domain.ImportRow) error {
+	emails := make([]string, len(batch))
+	for i, row := range batch {
+		emails[i] = row.Email
+	}
+	existing, err := s.repo.ExistingEmails(ctx, emails)
+	if err != nil {
+		return err
+	}
+
+	toInsert := make([]*domain.User, 0, len(batch))
+	insertRows := make([]domain.ImportRow, 0, len(batch))
+	for _, row := range batch {
+		if existing[domain.CanonicalEmail(row.Email)] {
+			result, err := s.existingRowResult(ctx, row)
+			if err != nil {
+				return err
+			}
+			job.AddResult(result)
+			continue
+		}
+		if row.PasswordHash == "" {
+			// Queued before passwords were hashed up front.
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+				Errors: map[string][]string{"password": {"password was not hashed, import the row again"}}})
+			continue
+		}
+		toInsert = append(toInsert, &domain.User{
+			Name:        row.Name,
+			Email:       row.Email,
+			Password:    row.PasswordHash,
+			IsActive:    true,
+			Roles:       []string{domain.RoleUser},
+			UserProfile: row.Profile,
+		})
+		insertRows = append(insertRows, row)
+	}
+
+	insertErrs, err := s.repo.CreateUsers(ctx, toInsert)
+	if err != nil {
+		return err
+	}
+	for i, user := range toInsert {
+		row := insertRows[i]
+		if insertErrs[i] != nil {
+			job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+				Errors: map[string][]string{"_error_": {insertErrs[i].Error()}}})
+			continue
+		}
+		job.AddResult(domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowCreated, UserID: user.ID.String()})
+
+		s.audit.Record(ctx, auditDomain.AuditEntry{
+			Action:     auditDomain.ActionUserCreate,
+			TargetType: auditDomain.TargetTypeUser,
+			TargetID:   user.ID.String(),
+			Result:     auditDomain.ResultSuccess,
+			Metadata:   map[string]interface{}{"email": user.Email, "import_job": job.ID},
+		})
+		emailPayload := event.SendWelcomeEmailPayload{UserID: user.ID.String(), Email: user.Email, Name: user.Name}
+		if err := s.highPub.Publish(ctx, event.SendWelcomeEmailTaskName, emailPayload); err != nil {
+			utils.Logger.Error("UserImportUsecase: Failed to publish welcome email task",
+				zap.String("user_email", user.Email), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// existingRowResult reports a row whose email is already registered. If the user
+// has the row's password hash, a previous run of the job created it before it could
+// save its progress, and the row is reported as created.
+func (s *UserImportUsecase) existingRowResult(ctx context.Context, row domain.ImportRow) (domain.ImportRowResult, error) {
+	result := domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowFailed,
+		Errors: map[string][]string{"email": {domain.ErrUserAlreadyExists.Error()}}}
+	if row.PasswordHash == "" {
+		return result, nil
+	}
+	user, err := s.repo.GetUserByEmail(ctx, row.Email)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if user.Password == row.PasswordHash {
+		return domain.ImportRowResult{Row: row.Row, Email: row.Email, Status: domain.ImportRowCreated, UserID: user.ID.String()}, nil
+	}
+	return result, nil
+}
+
+// GetImportJob returns the current state of an import job.
+func (s *UserImportUsecase) GetImportJob(ctx context.Context, id string) (*domain.ImportJob, error) {
+	job, err := s.store.GetJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrImportJobNotFound) {
+			return nil, err
+		}
+		utils.Logger.Error("GetImportJob: Failed to load import job", zap.String("job_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to load import job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *UserImportUsecase) saveJob(ctx context.Context, job *domain.ImportJob) error {
+	job.UpdatedAt = time.Now()
+	return s.store.SaveJob(ctx, job)
+}
+
+// failJob marks a job as failed and discards its pending rows.
+func (s *UserImportUsecase) failJob(ctx context.Context, job *domain.ImportJob, cause error) {
+	now := time.Now()
+	job.Status = domain.ImportStatusFailed
+	job.Error = cause.Error()
+	job.FinishedAt = &now
+	if err := s.saveJob(ctx, job); err != nil {
+		utils.Logger.Error("UserImportUsecase: Failed to save failed import job", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	if err := s.store.DeleteRows(ctx, job.ID); err != nil {
+		utils.Logger.Warn("UserImportUsecase: Failed to delete import rows", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	s.recordAudit(ctx, job.ID, cause, map[string]interface{}{"stage": "failed"})
+}
+
+func (s *UserImportUsecase) recordAudit(ctx context.Context, jobID string, opErr error, metadata map[string]interface{}) {
+	entry := auditDomain.AuditEntry{
+		Action:     auditDomain.ActionUserImport,
+		TargetType: auditDomain.TargetTypeUserImport,
+		TargetID:   jobID,
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
+
+func sortResults(job *domain.ImportJob) {
+	sort.Slice(job.Rows, func(i, j int) bool { return job.Rows[i].Row < job.Rows[j].Row })
+}
@@ -0,0 +1,73 @@
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	sharedAdapter "github.com/iots1/mingkwan-api/internal/shared/adapters"
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	"github.com/iots1/mingkwan-api/internal/user/adapters"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase"
+)
+
+func TestUserImport_RetryAfterUnsavedProgress(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	store := adapters.NewRedisUserImportStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	repo := adapters.NewMemoryUserRepository()
+	hasher := sharedAdapter.NewPasswordHasher()
+	imports := usecase.NewUserImportUsecase(repo, store, hasher, eventtest.NewRecordingPublisher(), nil)
+
+	rows := []domain.ImportRow{
+		{Row: 1, Name: "Ann", Email: "ann@example.com", Password: "ann-secret"},
+		{Row: 2, Name: "Bob", Email: "bob@example.com", Password: "bob-secret"},
+	}
+	job, err := imports.StartImport(ctx, rows, nil, false)
+	if err != nil {
+		t.Fatalf("StartImport: %v", err)
+	}
+	for _, key := range server.Keys() {
+		if value, _ := server.Get(key); strings.Contains(value, "ann-secret") {
+			t.Fatalf("plain-text password stored under %s", key)
+		}
+	}
+
+	// A first run created Ann but crashed before saving its progress.
+	queued, err := store.GetRows(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetRows: %v", err)
+	}
+	if !hasher.CheckPasswordHash("ann-secret", queued[0].PasswordHash) {
+		t.Fatal("queued row does not carry the password hash")
+	}
+	ann, err := repo.CreateUser(ctx, &domain.User{Name: "Ann", Email: "ann@example.com", Password: queued[0].PasswordHash, IsActive: true})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	// Someone else registered Bob's email in the meantime.
+	if _, err := repo.CreateUser(ctx, &domain.User{Name: "Other Bob", Email: "bob@example.com", IsActive: true}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if err := imports.RunImport(ctx, job.ID); err != nil {
+		t.Fatalf("RunImport: %v", err)
+	}
+	job, err = imports.GetImportJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if len(job.Rows) != 2 {
+		t.Fatalf("results = %d, want 2", len(job.Rows))
+	}
+	if got := job.Rows[0]; got.Status != domain.ImportRowCreated || got.UserID != ann.ID.String() {
+		t.Errorf("row 1 = %s %s, want created %s", got.Status, got.UserID, ann.ID)
+	}
+	if got := job.Rows[1]; got.Status != domain.ImportRowFailed {
+		t.Errorf("row 2 status = %s, want %s", got.Status, domain.ImportRowFailed)
+	}
+}
@@ -0,0 +1,285 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/repository"
+)
+
+// maxMergeRedirects bounds the chain followed by MergedInto, for users merged into
+// a user that was merged in turn.
+const maxMergeRedirects = 10
+
+// repointBatchSize bounds how many pending merges one RepointPendingMerges call
+// retries.
+const repointBatchSize = 100
+
+// MergeRepointFunc moves what one module keeps about a merged user to the user it
+// was merged into. It must be safe to run again for the same merge.
+type MergeRepointFunc func(ctx context.Context, sourceID, targetID domain.UserID) error
+
+type mergeRepointer struct {
+	name    string
+	repoint MergeRepointFunc
+}
+
+// UserMergeUsecase finds likely duplicate accounts and merges one into another.
+type UserMergeUsecase struct {
+	repo       repository.UserRepository
+	merges     repository.UserMergeRepository
+	repointers []mergeRepointer
+	audit      *auditUsecase.AuditUsecase
+}
+
+func NewUserMergeUsecase(
+	repo repository.UserRepository,
+	merges repository.UserMergeRepository,
+	audit *auditUsecase.AuditUsecase,
+) *UserMergeUsecase {
+	return &UserMergeUsecase{repo: repo, merges: merges, audit: audit}
+}
+
+// AddRepointer registers the re-pointer of a module, which runs on every merge in
+// the order of registration. It is not safe to call once merges run.
+func (s *UserMergeUsecase) AddRepointer(name string, repoint MergeRepointFunc) {
+	s.repointers = append(s.repointers, mergeRepointer{name: name, repoint: repoint})
+}
+
+// FindDuplicates returns the groups of users sharing an email key (see
+// domain.DuplicateEmailKey) or a phone key, oldest user first. Deleted and erased
+// users are left out. It reads every user, so it is meant for an admin tool and not
+// for request paths. Users have no external identities to match on yet.
+func (s *UserMergeUsecase) FindDuplicates(ctx context.Context) ([]domain.DuplicateGroup, error) {
+	byKey := map[[2]string][]domain.User{}
+	err := s.repo.StreamUsers(ctx, domain.UserFilter{SortBy: domain.SortByCreatedAt}, func(user *domain.User) error {
+		if user.ErasedAt != nil {
+			return nil
+		}
+		if key := domain.DuplicateEmailKey(user.Email); key != "" {
+			byKey[[2]string{domain.DuplicateByEmail, key}] = append(byKey[[2]string{domain.DuplicateByEmail, key}], *user)
+		}
+		if key := domain.DuplicatePhoneKey(user.Phone); key != "" {
+			byKey[[2]string{domain.DuplicateByPhone, key}] = append(byKey[[2]string{domain.DuplicateByPhone, key}], *user)
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Logger.Error("UserMergeUsecase: Failed to stream users", zap.Error(err))
+		return nil, fmt.Errorf("failed to stream users: %w", err)
+	}
+
+	groups := []domain.DuplicateGroup{}
+	for key, users := range byKey {
+		if len(users) > 1 {
+			groups = append(groups, domain.DuplicateGroup{MatchedOn: key[0], Key: key[1], Users: users})
+		}
+	}
+	slices.SortFunc(groups, func(a, b domain.DuplicateGroup) int {
+		return cmp.Or(cmp.Compare(a.MatchedOn, b.MatchedOn), cmp.Compare(a.Key, b.Key))
+	})
+	return groups, nil
+}
+
+// PreviewMerge returns what merging source into target would do, without writing
+// anything.
+func (s *UserMergeUsecase) PreviewMerge(ctx context.Context, sourceID, targetID domain.UserID) (*domain.MergePlan, error) {
+	if sourceID == targetID {
+		return nil, domain.ErrMergeSameUser
+	}
+	if _, err := s.merges.GetMerge(ctx, sourceID); err == nil {
+		return nil, domain.ErrUserMerged
+	} else if !errors.Is(err, domain.ErrUserMergeNotFound) {
+		return nil, fmt.Errorf("failed to get user merge: %w", err)
+	}
+
+	source, err := s.mergeableUser(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.mergeableUser(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return domain.PlanMerge(source, target), nil
+}
+
+func (s *UserMergeUsecase) mergeableUser(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	if user.ErasedAt != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// MergeUsers merges source into target as planned by PreviewMerge: the target takes
+// the profile fields it lacks, the source is soft-deleted and its ID redirects to the
+// target from then on. The registered re-pointers then move the records of other
+// modules; if one fails, the merge still succeeds and RepointPendingMerges retries
+// it.
+//
+// targetVersion is the version of the target the admin previewed; the merge fails
+// with domain.ErrVersionConflict if it changed since. domain.AnyVersion skips the
+// check. Restoring the source later does not undo the merge.
+func (s *UserMergeUsecase) MergeUsers(ctx context.Context, sourceID, targetID domain.UserID, targetVersion int64) (*domain.UserMerge, error) {
+	auditMetadata := map[string]interface{}{"merged_into": targetID.String()}
+	plan, err := s.PreviewMerge(ctx, sourceID, targetID)
+	if err == nil && targetVersion != domain.AnyVersion && plan.Target.Version != targetVersion {
+		err = domain.ErrVersionConflict
+	}
+	if err != nil {
+		s.recordAudit(ctx, sourceID.String(), err, auditMetadata)
+		return nil, err
+	}
+	auditMetadata["fields"] = plan.TakenFromSource
+	auditMetadata["conflicts"] = plan.Conflicts
+
+	meta := utils.RequestMetaFromContext(ctx)
+	merge := &domain.UserMerge{
+		SourceID:  sourceID,
+		TargetID:  targetID,
+		Fields:    plan.TakenFromSource,
+		ActorID:   meta.ActorID,
+		RequestID: meta.CorrelationID,
+		MergedAt:  time.Now().UTC().Truncate(time.Millisecond),
+	}
+	// The merge record is written first: its key on the source stops a concurrent
+	// merge of the same user.
+	if err := s.merges.CreateMerge(ctx, merge); err != nil {
+		if !errors.Is(err, domain.ErrUserMerged) {
+			utils.Logger.Error("UserMergeUsecase: Failed to record user merge", zap.String("source_id", sourceID.String()), zap.Error(err))
+			err = fmt.Errorf("failed to record user merge: %w", err)
+		}
+		s.recordAudit(ctx, sourceID.String(), err, auditMetadata)
+		return nil, err
+	}
+
+	if err := s.applyMerge(ctx, plan); err != nil {
+		if delErr := s.merges.DeleteMerge(ctx, sourceID); delErr != nil {
+			utils.Logger.Error("UserMergeUsecase: Failed to remove record of failed merge", zap.String("source_id", sourceID.String()), zap.Error(delErr))
+		}
+		s.recordAudit(ctx, sourceID.String(), err, auditMetadata)
+		return nil, err
+	}
+	s.recordAudit(ctx, sourceID.String(), nil, auditMetadata)
+	utils.Logger.Info("UserMergeUsecase: Users merged", zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()))
+
+	if err := s.repoint(ctx, merge); err != nil {
+		// The merge record keeps the merge pending for RepointPendingMerges.
+		utils.Logger.Error("UserMergeUsecase: Failed to re-point merged user, will retry",
+			zap.String("source_id", sourceID.String()), zap.String("target_id", targetID.String()), zap.Error(err))
+	}
+	return merge, nil
+}
+
+// RepointPendingMerges retries the re-pointing of merges made more than olderThan
+// ago that did not complete, oldest first, and returns how many completed now.
+func (s *UserMergeUsecase) RepointPendingMerges(ctx context.Context, olderThan time.Duration) (int, error) {
+	pending, err := s.merges.PendingRepoints(ctx, time.Now().Add(-olderThan), repointBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find pending user merges: %w", err)
+	}
+	repointed := 0
+	var errs []error
+	for i := range pending {
+		merge := &pending[i]
+		mergeCtx := utils.WithRequestMeta(ctx, &utils.RequestMeta{ActorID: merge.ActorID, CorrelationID: merge.RequestID})
+		if err := s.repoint(mergeCtx, merge); err != nil {
+			errs = append(errs, fmt.Errorf("merge of %s: %w", merge.SourceID, err))
+			continue
+		}
+		repointed++
+	}
+	return repointed, errors.Join(errs...)
+}
+
+// repoint runs every re-pointer, also after one failed, and marks the merge
+// re-pointed once all of them succeeded.
+func (s *UserMergeUsecase) repoint(ctx context.Context, merge *domain.UserMerge) error {
+	var errs []error
+	for _, r := range s.repointers {
+		if err := r.repoint(ctx, merge.SourceID, merge.TargetID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if err := s.merges.MarkRepointed(ctx, merge.SourceID, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		return fmt.Errorf("failed to mark user merge repointed: %w", err)
+	}
+	return nil
+}
+
+// applyMerge updates the target and soft-deletes the source. If the source cannot
+// be deleted, the target keeps the fields it took: they only filled empty ones.
+func (s *UserMergeUsecase) applyMerge(ctx context.Context, plan *domain.MergePlan) error {
+	if len(plan.Update) > 0 {
+		if _, err := s.repo.UpdateUser(ctx, plan.Target.ID, plan.Update, plan.Target.Version); err != nil {
+			if errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrUserNotFound) {
+				return err
+			}
+			utils.Logger.Error("UserMergeUsecase: Failed to update merge target", zap.String("target_id", plan.Target.ID.String()), zap.Error(err))
+			return fmt.Errorf("failed to update merge target: %w", err)
+		}
+	}
+	if err := s.repo.DeleteUser(ctx, plan.Source.ID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		utils.Logger.Error("UserMergeUsecase: Failed to delete merge source", zap.String("source_id", plan.Source.ID.String()), zap.Error(err))
+		return fmt.Errorf("failed to delete merge source: %w", err)
+	}
+	return nil
+}
+
+// MergedInto returns the user that id was merged into, following merges of the
+// target in turn, or domain.ErrUserMergeNotFound if id was not merged.
+func (s *UserMergeUsecase) MergedInto(ctx context.Context, id domain.UserID) (domain.UserID, error) {
+	current := id
+	for range maxMergeRedirects {
+		merge, err := s.merges.GetMerge(ctx, current)
+		if errors.Is(err, domain.ErrUserMergeNotFound) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get user merge: %w", err)
+		}
+		current = merge.TargetID
+	}
+	if current == id {
+		return "", domain.ErrUserMergeNotFound
+	}
+	return current, nil
+}
+
+func (s *UserMergeUsecase) recordAudit(ctx context.Context, sourceID string, opErr error, metadata map[string]interface{}) {
+	entry := auditDomain.AuditEntry{
+		Action:     auditDomain.ActionUserMerge,
+		TargetType: auditDomain.TargetTypeUser,
+		TargetID:   sourceID,
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
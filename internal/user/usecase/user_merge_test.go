@@ -0,0 +1,227 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/adapters"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase"
+	"github.com/iots1/mingkwan-api/internal/user/usecase/usecasetest"
+)
+
+type mergeFixture struct {
+	*usecasetest.Fixture
+	merges *usecase.UserMergeUsecase
+	store  *adapters.MemoryUserMergeRepository
+	// repointed holds the merges re-pointed so far; repointErr fails the re-pointer.
+	repointed  [][2]domain.UserID
+	repointErr error
+}
+
+func newMergeFixture() *mergeFixture {
+	f := &mergeFixture{Fixture: usecasetest.NewFixture(), store: adapters.NewMemoryUserMergeRepository()}
+	f.merges = usecase.NewUserMergeUsecase(f.Repo, f.store, nil)
+	f.merges.AddRepointer("test", func(ctx context.Context, sourceID, targetID domain.UserID) error {
+		if f.repointErr != nil {
+			return f.repointErr
+		}
+		f.repointed = append(f.repointed, [2]domain.UserID{sourceID, targetID})
+		return nil
+	})
+	return f
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	emails := []struct{ email, want string }{
+		{"Somchai.J@Gmail.com", "somchaij@gmail.com"},
+		{"somchaij+shop@googlemail.com", "somchaij@gmail.com"},
+		{"s.j+news@example.com", "s.j@example.com"},
+		{"+tag@example.com", "+tag@example.com"},
+	}
+	for _, tt := range emails {
+		if got := domain.DuplicateEmailKey(tt.email); got != tt.want {
+			t.Errorf("DuplicateEmailKey(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+	phones := []struct{ phone, want string }{
+		{"+66 81-234-5678", "+66812345678"},
+		{"(081) 234 5678", "0812345678"},
+		{"12+34", ""},
+		{"", ""},
+	}
+	for _, tt := range phones {
+		if got := domain.DuplicatePhoneKey(tt.phone); got != tt.want {
+			t.Errorf("DuplicatePhoneKey(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestUserMerge_FindDuplicates(t *testing.T) {
+	f := newMergeFixture()
+	a := f.Seed(t, &domain.User{Name: "A", Email: "somchai.j@gmail.com", UserProfile: domain.UserProfile{Phone: "+66812345678"}})
+	b := f.Seed(t, &domain.User{Name: "B", Email: "somchaij+shop@gmail.com"})
+	c := f.Seed(t, &domain.User{Name: "C", Email: "other@example.com", UserProfile: domain.UserProfile{Phone: "+66 81 234 5678"}})
+	f.Seed(t, &domain.User{Name: "D", Email: "alone@example.com"})
+	deleted := f.Seed(t, &domain.User{Name: "E", Email: "somchaij@gmail.com"})
+	if err := f.Repo.DeleteUser(context.Background(), deleted.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	groups, err := f.merges.FindDuplicates(context.Background())
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("FindDuplicates returned %d groups, want 2: %+v", len(groups), groups)
+	}
+	ids := func(group domain.DuplicateGroup) []domain.UserID {
+		var ids []domain.UserID
+		for _, user := range group.Users {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+	if groups[0].MatchedOn != domain.DuplicateByEmail || !slices.Equal(ids(groups[0]), []domain.UserID{a.ID, b.ID}) {
+		t.Errorf("email group = %s %v", groups[0].MatchedOn, ids(groups[0]))
+	}
+	if groups[1].MatchedOn != domain.DuplicateByPhone || !slices.Equal(ids(groups[1]), []domain.UserID{a.ID, c.ID}) {
+		t.Errorf("phone group = %s %v", groups[1].MatchedOn, ids(groups[1]))
+	}
+}
+
+func TestUserMerge_MergeUsers(t *testing.T) {
+	f := newMergeFixture()
+	ctx := utils.WithRequestMeta(context.Background(), &utils.RequestMeta{ActorID: "admin-1", CorrelationID: "req-1"})
+	source := f.Seed(t, &domain.User{Name: "Social", Email: "malee@gmail.com", Roles: []string{domain.RoleAdmin}, UserProfile: domain.UserProfile{
+		AvatarURL: "https://cdn.example.com/malee.png",
+		Locale:    "en-US",
+		Phone:     "+66812345678",
+		Metadata:  map[string]string{"source": "social", "plan": "free"},
+	}})
+	target := f.Seed(t, &domain.User{Name: "Password", Email: "malee+app@gmail.com", Roles: []string{domain.RoleUser}, UserProfile: domain.UserProfile{
+		Locale:   "th-TH",
+		Metadata: map[string]string{"plan": "pro"},
+	}})
+
+	plan, err := f.merges.PreviewMerge(ctx, source.ID, target.ID)
+	if err != nil {
+		t.Fatalf("PreviewMerge: %v", err)
+	}
+	if want := []string{"avatar_url", "phone", "metadata.source"}; !slices.Equal(plan.TakenFromSource, want) {
+		t.Errorf("taken from source = %v, want %v", plan.TakenFromSource, want)
+	}
+	if want := []string{"locale", "metadata.plan"}; !slices.Equal(plan.Conflicts, want) {
+		t.Errorf("conflicts = %v, want %v", plan.Conflicts, want)
+	}
+	if _, err := f.Repo.GetUserByID(ctx, target.ID); err != nil || len(f.repointed) != 0 {
+		t.Fatalf("preview wrote something: %v", err)
+	}
+
+	if _, err := f.merges.MergeUsers(ctx, source.ID, target.ID, target.Version+1); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Fatalf("MergeUsers with a stale version error = %v, want version conflict", err)
+	}
+	merge, err := f.merges.MergeUsers(ctx, source.ID, target.ID, target.Version)
+	if err != nil {
+		t.Fatalf("MergeUsers: %v", err)
+	}
+	if merge.ActorID != "admin-1" || merge.RequestID != "req-1" {
+		t.Errorf("merge actor = %q, request = %q", merge.ActorID, merge.RequestID)
+	}
+
+	merged, err := f.Repo.GetUserByID(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID target: %v", err)
+	}
+	if merged.Email != target.Email || merged.Locale != "th-TH" || merged.Phone != "+66812345678" || merged.AvatarURL != source.AvatarURL {
+		t.Errorf("merged target = %+v", merged)
+	}
+	if merged.Metadata["plan"] != "pro" || merged.Metadata["source"] != "social" {
+		t.Errorf("merged metadata = %v", merged.Metadata)
+	}
+	if !slices.Equal(merged.Roles, []string{domain.RoleUser}) {
+		t.Errorf("merged roles = %v, want the target's roles only", merged.Roles)
+	}
+	if _, err := f.Repo.GetUserByID(ctx, source.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("source after merge error = %v, want not found", err)
+	}
+
+	if want := [][2]domain.UserID{{source.ID, target.ID}}; !slices.Equal(f.repointed, want) {
+		t.Errorf("re-pointed %v, want %v", f.repointed, want)
+	}
+	if stored, err := f.store.GetMerge(ctx, source.ID); err != nil || stored.RepointedAt == nil {
+		t.Errorf("stored merge = %+v, %v; want it re-pointed", stored, err)
+	}
+
+	if got, err := f.merges.MergedInto(ctx, source.ID); err != nil || got != target.ID {
+		t.Errorf("MergedInto = %s, %v, want %s", got, err, target.ID)
+	}
+	if _, err := f.merges.MergedInto(ctx, target.ID); !errors.Is(err, domain.ErrUserMergeNotFound) {
+		t.Errorf("MergedInto target error = %v, want not found", err)
+	}
+	if _, err := f.merges.MergeUsers(ctx, source.ID, target.ID, domain.AnyVersion); !errors.Is(err, domain.ErrUserMerged) {
+		t.Errorf("second merge error = %v, want already merged", err)
+	}
+}
+
+func TestUserMerge_MergedIntoFollowsChains(t *testing.T) {
+	f := newMergeFixture()
+	ctx := context.Background()
+	a := f.Seed(t, &domain.User{Name: "A", Email: "a@example.com"})
+	b := f.Seed(t, &domain.User{Name: "B", Email: "b@example.com"})
+	c := f.Seed(t, &domain.User{Name: "C", Email: "c@example.com"})
+
+	if _, err := f.merges.MergeUsers(ctx, a.ID, a.ID, domain.AnyVersion); !errors.Is(err, domain.ErrMergeSameUser) {
+		t.Errorf("self merge error = %v, want ErrMergeSameUser", err)
+	}
+	if _, err := f.merges.MergeUsers(ctx, a.ID, b.ID, domain.AnyVersion); err != nil {
+		t.Fatalf("merge a into b: %v", err)
+	}
+	if _, err := f.merges.MergeUsers(ctx, c.ID, a.ID, domain.AnyVersion); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("merge into a merged user error = %v, want not found", err)
+	}
+	if _, err := f.merges.MergeUsers(ctx, b.ID, c.ID, domain.AnyVersion); err != nil {
+		t.Fatalf("merge b into c: %v", err)
+	}
+	if got, err := f.merges.MergedInto(ctx, a.ID); err != nil || got != c.ID {
+		t.Errorf("MergedInto(a) = %s, %v, want %s", got, err, c.ID)
+	}
+}
+
+func TestUserMerge_RetriesFailedRepoints(t *testing.T) {
+	f := newMergeFixture()
+	ctx := context.Background()
+	source := f.Seed(t, &domain.User{Name: "A", Email: "a@example.com"})
+	target := f.Seed(t, &domain.User{Name: "B", Email: "b@example.com"})
+
+	f.repointErr = errors.New("groups unavailable")
+	if _, err := f.merges.MergeUsers(ctx, source.ID, target.ID, domain.AnyVersion); err != nil {
+		t.Fatalf("MergeUsers with a failing re-pointer: %v", err)
+	}
+	if stored, err := f.store.GetMerge(ctx, source.ID); err != nil || stored.RepointedAt != nil {
+		t.Fatalf("stored merge = %+v, %v; want it pending", stored, err)
+	}
+
+	// Merges younger than the delay are left to their request.
+	if n, err := f.merges.RepointPendingMerges(ctx, time.Hour); n != 0 || err != nil {
+		t.Errorf("RepointPendingMerges(young merge) = %d, %v; want 0, nil", n, err)
+	}
+	if n, err := f.merges.RepointPendingMerges(ctx, 0); n != 0 || err == nil {
+		t.Errorf("RepointPendingMerges(still failing) = %d, %v; want an error", n, err)
+	}
+
+	f.repointErr = nil
+	if n, err := f.merges.RepointPendingMerges(ctx, 0); n != 1 || err != nil {
+		t.Fatalf("RepointPendingMerges = %d, %v; want 1, nil", n, err)
+	}
+	if want := [][2]domain.UserID{{source.ID, target.ID}}; !slices.Equal(f.repointed, want) {
+		t.Errorf("re-pointed %v, want %v", f.repointed, want)
+	}
+	if n, err := f.merges.RepointPendingMerges(ctx, 0); n != 0 || err != nil {
+		t.Errorf("RepointPendingMerges after success = %d, %v; want nothing left", n, err)
+	}
+}
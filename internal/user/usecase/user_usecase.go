@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
+	auditDomain "github.com/iots1/mingkwan-api/internal/audit/domain"
+	auditUsecase "github.com/iots1/mingkwan-api/internal/audit/usecase"
 	"github.com/iots1/mingkwan-api/internal/shared/event"
 	"github.com/iots1/mingkwan-api/internal/shared/utils"
 	"github.com/iots1/mingkwan-api/internal/user/domain"
@@ -16,23 +20,50 @@ import (
 )
 
 type UserUsecase struct {
-	repo    repository.UserRepository
-	lowPub  event.Publisher
-	highPub event.Publisher
+	repo     repository.UserRepository
+	searcher repository.UserSearcher
+	lowPub   event.Publisher
+	highPub  event.Publisher
+	audit    *auditUsecase.AuditUsecase
+	// publicURL is the base of the links in email change messages.
+	publicURL string
 }
 
 func NewUserUsecase(
 	repo repository.UserRepository,
+	searcher repository.UserSearcher,
 	lowPub event.Publisher,
 	highPub event.Publisher,
+	audit *auditUsecase.AuditUsecase,
+	publicURL string,
 ) *UserUsecase {
 	return &UserUsecase{
-		repo:    repo,
-		lowPub:  lowPub,
-		highPub: highPub,
+		repo:      repo,
+		searcher:  searcher,
+		lowPub:    lowPub,
+		highPub:   highPub,
+		audit:     audit,
+		publicURL: publicURL,
 	}
 }
 
+// recordAudit writes an audit entry for an operation on the user identified by targetID.
+// A non-nil opErr marks the entry as a failure.
+func (s *UserUsecase) recordAudit(ctx context.Context, action, targetID string, opErr error, metadata map[string]interface{}) {
+	entry := auditDomain.AuditEntry{
+		Action:     action,
+		TargetType: auditDomain.TargetTypeUser,
+		TargetID:   targetID,
+		Result:     auditDomain.ResultSuccess,
+		Metadata:   metadata,
+	}
+	if opErr != nil {
+		entry.Result = auditDomain.ResultFailure
+		entry.Reason = opErr.Error()
+	}
+	s.audit.Record(ctx, entry)
+}
+
 func (s *UserUsecase) CreateUser(ctx context.Context, data *domain.User) (*domain.User, error) {
 	existingUser, err := s.repo.GetUserByEmail(ctx, data.Email)
 	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
@@ -41,9 +72,14 @@ func (s *UserUsecase) CreateUser(ctx context.Context, data *domain.User) (*domai
 	}
 	if existingUser != nil {
 		utils.Logger.Info("UserUsecase: User with this email already exists", zap.String("email", data.Email))
+		s.recordAudit(ctx, auditDomain.ActionUserCreate, "", domain.ErrUserAlreadyExists, map[string]interface{}{"email": data.Email})
 		return nil, domain.ErrUserAlreadyExists
 	}
 
+	if len(data.Roles) == 0 {
+		data.Roles = []string{domain.RoleUser}
+	}
+
 	hashPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
 	if err != nil {
 		utils.Logger.Error("UserUsecase: Failed to hash password", zap.Error(err))
@@ -55,24 +91,27 @@ func (s *UserUsecase) CreateUser(ctx context.Context, data *domain.User) (*domai
 	if err != nil {
 		if errors.Is(err, domain.ErrUserAlreadyExists) {
 			utils.Logger.Warn("UserUsecase: User already exists after creation attempt", zap.String("email", data.Email))
+			s.recordAudit(ctx, auditDomain.ActionUserCreate, "", domain.ErrUserAlreadyExists, map[string]interface{}{"email": data.Email})
 			return nil, domain.ErrUserAlreadyExists
 		}
 		utils.Logger.Error("UserUsecase: Failed to save user to database", zap.Error(err), zap.String("email", data.Email))
+		s.recordAudit(ctx, auditDomain.ActionUserCreate, "", err, map[string]interface{}{"email": data.Email})
 		return nil, fmt.Errorf("failed to save user to database: %w", err)
 	}
+	s.recordAudit(ctx, auditDomain.ActionUserCreate, createdUser.ID.String(), nil, map[string]interface{}{"email": createdUser.Email})
 
-	emailPayload := event.SendWelcomeEmailPayload{UserID: createdUser.ID.Hex(), Email: createdUser.Email, Name: createdUser.Name}
+	emailPayload := event.SendWelcomeEmailPayload{UserID: createdUser.ID.String(), Email: createdUser.Email, Name: createdUser.Name}
 	if err := s.highPub.Publish(ctx, event.SendWelcomeEmailTaskName, emailPayload); err != nil {
 		utils.Logger.Error("UserUsecase: Failed to publish high importance send welcome email task",
 			zap.String("user_email", createdUser.Email), zap.Error(err),
 		)
 	}
 
-	utils.Logger.Debug("UserUsecase: User created and events published", zap.String("name", data.Name), zap.String("user_id", createdUser.ID.Hex()))
+	utils.Logger.Debug("UserUsecase: User created and events published", zap.String("name", data.Name), zap.String("user_id", createdUser.ID.String()))
 	return createdUser, nil
 }
 
-func (s *UserUsecase) GetUserByID(ctx context.Context, oid primitive.ObjectID) (*domain.User, error) {
+func (s *UserUsecase) GetUserByID(ctx context.Context, oid domain.UserID) (*domain.User, error) {
 
 	user, err := s.repo.GetUserByID(ctx, oid)
 	if err != nil {
@@ -99,17 +138,75 @@ func (s *UserUsecase) GetUserByEmail(ctx context.Context, email string) (*domain
 	return user, nil
 }
 
-func (s *UserUsecase) GetAllUsers(ctx context.Context) ([]domain.User, error) {
-	users, err := s.repo.GetAllUsers(ctx)
+func (s *UserUsecase) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserPage, error) {
+	filter.Normalize()
+	page, err := s.repo.ListUsers(ctx, filter)
 	if err != nil {
-		utils.Logger.Error("GetAllUsers: Failed to get all users", zap.Error(err))
-		return nil, fmt.Errorf("failed to get all users: %w", err)
+		if errors.Is(err, domain.ErrInvalidCursor) {
+			return nil, err
+		}
+		utils.Logger.Error("ListUsers: Failed to list users", zap.Error(err))
+		return nil, fmt.Errorf("failed to list users: %w", err)
 	}
-	return users, nil
+	return page, nil
 }
 
-func (s *UserUsecase) UpdateUser(ctx context.Context, idStr, name, email string) (*domain.User, error) {
-	objID, err := primitive.ObjectIDFromHex(idStr)
+// AnonymizeUser erases the personal data of a user on their request.
+func (s *UserUsecase) AnonymizeUser(ctx context.Context, id domain.UserID) error {
+	if err := s.repo.AnonymizeUser(ctx, id, time.Now()); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		utils.Logger.Error("AnonymizeUser: Failed to anonymize user", zap.String("user_id", id.String()), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionUserErase, id.String(), err, nil)
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	s.recordAudit(ctx, auditDomain.ActionUserErase, id.String(), nil, nil)
+	return nil
+}
+
+// ExportUsers calls fn for every user matching the filter and audits the export,
+// which hands personal data of many users to the caller.
+func (s *UserUsecase) ExportUsers(ctx context.Context, filter domain.UserFilter, format string, fn func(user *domain.User) error) (int, error) {
+	filter.Normalize()
+	exported := 0
+	err := s.repo.StreamUsers(ctx, filter, func(user *domain.User) error {
+		if err := fn(user); err != nil {
+			return err
+		}
+		exported++
+		return nil
+	})
+
+	metadata := map[string]interface{}{"format": format, "count": exported}
+	if err != nil {
+		utils.Logger.Error("ExportUsers: Export aborted", zap.Int("exported", exported), zap.Error(err))
+		s.audit.Record(ctx, auditDomain.AuditEntry{Action: auditDomain.ActionUserExport, TargetType: auditDomain.TargetTypeUser,
+			Result: auditDomain.ResultFailure, Reason: err.Error(), Metadata: metadata})
+		return exported, fmt.Errorf("failed to export users: %w", err)
+	}
+	s.audit.Record(ctx, auditDomain.AuditEntry{Action: auditDomain.ActionUserExport, TargetType: auditDomain.TargetTypeUser,
+		Result: auditDomain.ResultSuccess, Metadata: metadata})
+	return exported, nil
+}
+
+// SearchUsers returns users whose name or email matches query.Text, best match first.
+func (s *UserUsecase) SearchUsers(ctx context.Context, query domain.UserSearchQuery) (*domain.UserSearchResult, error) {
+	query.Normalize()
+	result, err := s.searcher.SearchUsers(ctx, query)
+	if err != nil {
+		utils.Logger.Error("SearchUsers: Failed to search users", zap.String("query", query.Text), zap.Error(err))
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateUser changes the name, email and profile of a user whose current version is
+// expectedVersion, or any version with domain.AnyVersion. Empty values are left
+// unchanged. A stale version fails with domain.ErrVersionConflict. A new email only
+// takes effect once confirmed, see ConfirmEmailChange.
+func (s *UserUsecase) UpdateUser(ctx context.Context, idStr, name, email string, profile domain.UserProfile, expectedVersion int64) (*domain.User, error) {
+	objID, err := domain.ParseUserID(idStr)
 	if err != nil {
 		utils.Logger.Debug("UpdateUser: Invalid user ID format", zap.String("id_string", idStr))
 		return nil, fmt.Errorf("invalid user ID format: %w", err)
@@ -124,6 +221,11 @@ func (s *UserUsecase) UpdateUser(ctx context.Context, idStr, name, email string)
 		utils.Logger.Error("UpdateUser: Error finding existing user by ID", zap.String("user_id", idStr), zap.Error(err))
 		return nil, fmt.Errorf("error finding user for update: %w", err)
 	}
+	if expectedVersion != domain.AnyVersion && existingUser.Version != expectedVersion {
+		utils.Logger.Info("UpdateUser: Stale version", zap.String("user_id", idStr),
+			zap.Int64("expected_version", expectedVersion), zap.Int64("current_version", existingUser.Version))
+		return nil, domain.ErrVersionConflict
+	}
 
 	updateMap := make(map[string]interface{})
 	if name != "" {
@@ -133,42 +235,136 @@ func (s *UserUsecase) UpdateUser(ctx context.Context, idStr, name, email string)
 	}
 	if email != "" {
 		if existingUser.Email != email {
-			existingUserByEmail, err := s.repo.GetUserByEmail(ctx, email)
-			if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
-				utils.Logger.Error("UpdateUser: Error checking new email for existing user", zap.String("email", email), zap.Error(err))
-				return nil, fmt.Errorf("error checking new email: %w", err)
-			}
-			if existingUserByEmail != nil && existingUserByEmail.ID != objID {
-				utils.Logger.Warn("UpdateUser: New email already exists for another user", zap.String("email", email), zap.String("existing_user_id", existingUserByEmail.ID.Hex()))
-				return nil, domain.ErrUserAlreadyExists
+			if err := s.ensureEmailAvailable(ctx, objID, email); err != nil {
+				return nil, err
 			}
 			updateMap["email"] = email
 		}
 	}
+	profileFields := map[string][2]string{
+		"display_name": {profile.DisplayName, existingUser.DisplayName},
+		"avatar_url":   {profile.AvatarURL, existingUser.AvatarURL},
+		"locale":       {profile.Locale, existingUser.Locale},
+		"timezone":     {profile.Timezone, existingUser.Timezone},
+		"phone":        {profile.Phone, existingUser.Phone},
+	}
+	for field, values := range profileFields {
+		if values[0] != "" && values[0] != values[1] {
+			updateMap[field] = values[0]
+		}
+	}
+	if profile.Metadata != nil && !maps.Equal(profile.Metadata, existingUser.Metadata) {
+		updateMap["metadata"] = profile.Metadata
+	}
+
+	return s.saveChanges(ctx, existingUser, updateMap, expectedVersion)
+}
+
+// PatchUser stores the changes produced by applying a patch to the user, with the same
+// version semantics as UpdateUser. A nil value in changes clears the field.
+func (s *UserUsecase) PatchUser(ctx context.Context, idStr string, changes map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	objID, err := domain.ParseUserID(idStr)
+	if err != nil {
+		utils.Logger.Debug("PatchUser: Invalid user ID format", zap.String("id_string", idStr))
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	existingUser, err := s.repo.GetUserByID(ctx, objID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		utils.Logger.Error("PatchUser: Error finding existing user by ID", zap.String("user_id", idStr), zap.Error(err))
+		return nil, fmt.Errorf("error finding user for patch: %w", err)
+	}
+	if expectedVersion != domain.AnyVersion && existingUser.Version != expectedVersion {
+		return nil, domain.ErrVersionConflict
+	}
+
+	if email, ok := changes["email"].(string); ok && email != existingUser.Email {
+		if err := s.ensureEmailAvailable(ctx, objID, email); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.saveChanges(ctx, existingUser, changes, expectedVersion)
+}
+
+// ensureEmailAvailable returns domain.ErrUserAlreadyExists if another user owns email.
+func (s *UserUsecase) ensureEmailAvailable(ctx context.Context, userID domain.UserID, email string) error {
+	existingUserByEmail, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		utils.Logger.Error("UpdateUser: Error checking new email for existing user", zap.String("email", email), zap.Error(err))
+		return fmt.Errorf("error checking new email: %w", err)
+	}
+	if existingUserByEmail != nil && existingUserByEmail.ID != userID {
+		utils.Logger.Warn("UpdateUser: New email already exists for another user", zap.String("email", email), zap.String("existing_user_id", existingUserByEmail.ID.String()))
+		return domain.ErrUserAlreadyExists
+	}
+	return nil
+}
+
+// saveChanges writes updateMap to the user if it changes anything and audits the update.
+// A new email is not applied but stored as a pending change, see startEmailChange.
+func (s *UserUsecase) saveChanges(ctx context.Context, existingUser *domain.User, updateMap map[string]interface{}, expectedVersion int64) (*domain.User, error) {
+	idStr := existingUser.ID.String()
+
+	var emailLinks *emailChangeLinks
+	if email, ok := updateMap["email"].(string); ok {
+		updateMap = maps.Clone(updateMap)
+		delete(updateMap, "email")
+		if email != existingUser.Email {
+			change, links, err := newPendingEmailChange(email)
+			if err != nil {
+				utils.Logger.Error("UpdateUser: Failed to create email change tokens", zap.String("user_id", idStr), zap.Error(err))
+				return nil, fmt.Errorf("failed to start email change: %w", err)
+			}
+			updateMap["pending_email"] = change
+			emailLinks = links
+		}
+	}
 
 	if len(updateMap) == 0 {
 		utils.Logger.Info("UpdateUser: No fields to update", zap.String("user_id", idStr))
 		return existingUser, nil
 	}
 
-	utils.Logger.Debug("UpdateUser: Preparing to update user with map",
-		zap.String("user_id", objID.Hex()), zap.Any("update_map", updateMap))
+	// Only the field names are logged: the values include personal data and the
+	// pending email change token.
+	utils.Logger.Debug("UpdateUser: Preparing to update user",
+		zap.String("user_id", idStr), zap.Strings("fields", slices.Sorted(maps.Keys(updateMap))))
 
-	updatedUser, err := s.repo.UpdateUser(ctx, objID, updateMap)
+	updatedUser, err := s.repo.UpdateUser(ctx, existingUser.ID, updateMap, expectedVersion)
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
 			utils.Logger.Info("UpdateUser: User not found", zap.String("user_id", idStr))
 			return nil, domain.ErrUserNotFound
 		}
+		if errors.Is(err, domain.ErrVersionConflict) {
+			utils.Logger.Info("UpdateUser: Concurrent update detected", zap.String("user_id", idStr))
+			return nil, domain.ErrVersionConflict
+		}
 		utils.Logger.Error("UpdateUser: Failed to update user in repository", zap.String("user_id", idStr), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionUserUpdate, idStr, err, nil)
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	changedFields := make([]string, 0, len(updateMap))
+	for field := range updateMap {
+		if field != "updated_at" {
+			changedFields = append(changedFields, field)
+		}
+	}
+	s.recordAudit(ctx, auditDomain.ActionUserUpdate, idStr, nil, map[string]interface{}{"changed_fields": changedFields})
+
+	if emailLinks != nil {
+		s.sendEmailChangeMessages(ctx, updatedUser, emailLinks)
+	}
 	return updatedUser, nil
 }
 
 func (s *UserUsecase) DeleteUser(ctx context.Context, idStr string) error {
-	objID, err := primitive.ObjectIDFromHex(idStr)
+	objID, err := domain.ParseUserID(idStr)
 	if err != nil {
 		utils.Logger.Debug("DeleteUser: Invalid user ID format", zap.String("id_string", idStr))
 		return fmt.Errorf("invalid user ID format: %w", err)
@@ -178,10 +374,128 @@ func (s *UserUsecase) DeleteUser(ctx context.Context, idStr string) error {
 	if err != nil {
 		if errors.Is(err, domain.ErrUserNotFound) {
 			utils.Logger.Info("DeleteUser: User not found", zap.String("user_id", idStr))
+			s.recordAudit(ctx, auditDomain.ActionUserDelete, idStr, domain.ErrUserNotFound, nil)
 			return domain.ErrUserNotFound
 		}
 		utils.Logger.Error("DeleteUser: Failed to delete user from repository", zap.String("user_id", idStr), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionUserDelete, idStr, err, nil)
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
+	s.recordAudit(ctx, auditDomain.ActionUserDelete, idStr, nil, map[string]interface{}{"soft_delete": true})
 	return nil
 }
+
+// DeactivateUser blocks a user from logging in or refreshing tokens. The auth module
+// revokes the user's sessions when it receives UserDeactivatedInMemoryEvent.
+func (s *UserUsecase) DeactivateUser(ctx context.Context, idStr, reason string) (*domain.User, error) {
+	return s.setActive(ctx, idStr, false, reason)
+}
+
+// ReactivateUser lets a deactivated user log in again. Sessions revoked on
+// deactivation stay revoked.
+func (s *UserUsecase) ReactivateUser(ctx context.Context, idStr, reason string) (*domain.User, error) {
+	return s.setActive(ctx, idStr, true, reason)
+}
+
+func (s *UserUsecase) setActive(ctx context.Context, idStr string, active bool, reason string) (*domain.User, error) {
+	action, topic, stateErr := auditDomain.ActionUserDeactivate, event.UserDeactivatedInMemoryEvent, domain.ErrUserInactive
+	if active {
+		action, topic, stateErr = auditDomain.ActionUserReactivate, event.UserReactivatedInMemoryEvent, domain.ErrUserAlreadyActive
+	}
+
+	objID, err := domain.ParseUserID(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+	existingUser, err := s.repo.GetUserByID(ctx, objID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("error finding user: %w", err)
+	}
+	if existingUser.IsActive == active {
+		s.recordAudit(ctx, action, idStr, stateErr, map[string]interface{}{"reason": reason})
+		return nil, stateErr
+	}
+
+	actorID := utils.RequestMetaFromContext(ctx).ActorID
+	updatedUser, err := s.repo.SetActive(ctx, objID, active, actorID, reason)
+	if err != nil {
+		utils.Logger.Error("UserUsecase: Failed to change user activation", zap.String("user_id", idStr), zap.Bool("active", active), zap.Error(err))
+		s.recordAudit(ctx, action, idStr, err, map[string]interface{}{"reason": reason})
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to change user activation: %w", err)
+	}
+	s.recordAudit(ctx, action, idStr, nil, map[string]interface{}{"reason": reason})
+
+	payload := event.UserActivationPayload{UserID: objID.String(), ActorID: actorID, Reason: reason, ChangedAt: updatedUser.UpdatedAt}
+	if err := s.lowPub.Publish(ctx, string(topic), payload); err != nil {
+		utils.Logger.Error("UserUsecase: Failed to publish user activation event", zap.String("user_id", idStr), zap.Error(err))
+	}
+
+	utils.Logger.Info("User activation changed", zap.String("user_id", idStr), zap.Bool("active", active), zap.String("actor_id", actorID))
+	return updatedUser, nil
+}
+
+// RestoreUser undoes a soft delete. It returns domain.ErrUserNotFound if the user
+// does not exist, is not deleted, or has already been purged.
+func (s *UserUsecase) RestoreUser(ctx context.Context, idStr string) (*domain.User, error) {
+	objID, err := domain.ParseUserID(idStr)
+	if err != nil {
+		utils.Logger.Debug("RestoreUser: Invalid user ID format", zap.String("id_string", idStr))
+		return nil, fmt.Errorf("invalid user ID format: %w", err)
+	}
+
+	restored, err := s.repo.RestoreUser(ctx, objID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			utils.Logger.Info("RestoreUser: No deleted user found", zap.String("user_id", idStr))
+			s.recordAudit(ctx, auditDomain.ActionUserRestore, idStr, domain.ErrUserNotFound, nil)
+			return nil, domain.ErrUserNotFound
+		}
+		utils.Logger.Error("RestoreUser: Failed to restore user in repository", zap.String("user_id", idStr), zap.Error(err))
+		s.recordAudit(ctx, auditDomain.ActionUserRestore, idStr, err, nil)
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+	s.recordAudit(ctx, auditDomain.ActionUserRestore, idStr, nil, nil)
+	return restored, nil
+}
+
+// purgeBatchSize bounds how many users one repository round trip handles.
+const purgeBatchSize = 100
+
+// PurgeDeletedUsers permanently removes users soft-deleted more than olderThan ago
+// and publishes UserDeletedHighImportance for each. It returns how many were purged.
+func (s *UserUsecase) PurgeDeletedUsers(ctx context.Context, olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	purged := 0
+	for {
+		users, err := s.repo.ListDeletedBefore(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list deleted users: %w", err)
+		}
+
+		for _, user := range users {
+			if err := s.repo.PurgeUser(ctx, user.ID); err != nil {
+				if errors.Is(err, domain.ErrUserNotFound) {
+					continue // Restored or purged concurrently
+				}
+				s.recordAudit(ctx, auditDomain.ActionUserPurge, user.ID.String(), err, nil)
+				return purged, fmt.Errorf("failed to purge user %s: %w", user.ID.String(), err)
+			}
+			purged++
+			s.recordAudit(ctx, auditDomain.ActionUserPurge, user.ID.String(), nil, map[string]interface{}{"deleted_at": user.DeletedAt})
+
+			if err := s.highPub.Publish(ctx, event.UserDeletedHighImportance, event.UserDeletedPayload{UserID: user.ID.String()}); err != nil {
+				utils.Logger.Error("UserUsecase: Failed to publish user deleted task", zap.String("user_id", user.ID.String()), zap.Error(err))
+			}
+		}
+
+		if len(users) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/iots1/mingkwan-api/internal/shared/event"
+	"github.com/iots1/mingkwan-api/internal/shared/event/eventtest"
+	"github.com/iots1/mingkwan-api/internal/shared/utils"
+	"github.com/iots1/mingkwan-api/internal/user/domain"
+	"github.com/iots1/mingkwan-api/internal/user/usecase/usecasetest"
+)
+
+func TestUserUsecase_CreateUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  string
+		email     string
+		wantErr   error
+		wantTasks int
+	}{
+		{name: "new user", email: "new@example.com", wantTasks: 1},
+		{name: "email taken", existing: "taken@example.com", email: "taken@example.com", wantErr: domain.ErrUserAlreadyExists},
+		{name: "email taken in other case", existing: "taken@example.com", email: "Taken@Example.COM", wantErr: domain.ErrUserAlreadyExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := usecasetest.NewFixture()
+			if tt.existing != "" {
+				f.Seed(t, &domain.User{Name: "Existing", Email: tt.existing})
+			}
+
+			created, err := f.Users.CreateUser(context.Background(), &domain.User{Name: "New", Email: tt.email, Password: "secret123", IsActive: true})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateUser error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(f.HighPub.MessagesFor(event.SendWelcomeEmailTaskName)); got != tt.wantTasks {
+				t.Errorf("welcome email tasks = %d, want %d", got, tt.wantTasks)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if bcrypt.CompareHashAndPassword([]byte(created.Password), []byte("secret123")) != nil {
+				t.Error("stored password is not a bcrypt hash of the given password")
+			}
+			if len(created.Roles) != 1 || created.Roles[0] != domain.RoleUser {
+				t.Errorf("Roles = %v, want [%s]", created.Roles, domain.RoleUser)
+			}
+			payload := f.HighPub.MessagesFor(event.SendWelcomeEmailTaskName)[0].Payload.(event.SendWelcomeEmailPayload)
+			if payload.UserID != created.ID.String() || payload.Email != tt.email {
+				t.Errorf("welcome email payload = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestUserUsecase_UpdateUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           func(user *domain.User) string
+		newName      string
+		email        string
+		version      int64
+		wantErr      error
+		wantName     string
+		wantPending  string
+		wantMessages int
+		wantAnyErr   bool
+	}{
+		{name: "rename", newName: "Renamed", version: 1, wantName: "Renamed"},
+		{name: "any version", newName: "Renamed", version: domain.AnyVersion, wantName: "Renamed"},
+		{name: "stale version", newName: "Renamed", version: 7, wantErr: domain.ErrVersionConflict},
+		{name: "unchanged", newName: "Owner", version: 1, wantName: "Owner"},
+		{name: "email change is pending", email: "new@example.com", version: 1, wantName: "Owner", wantPending: "new@example.com", wantMessages: 2},
+		{name: "email taken", email: "Other@example.com", version: 1, wantErr: domain.ErrUserAlreadyExists},
+		{name: "unknown user", id: func(*domain.User) string { return primitive.NewObjectID().Hex() }, newName: "x", version: 1, wantErr: domain.ErrUserNotFound},
+		{name: "malformed id", id: func(*domain.User) string { return "nope" }, newName: "x", version: 1, wantAnyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := usecasetest.NewFixture()
+			user := f.Seed(t, &domain.User{Name: "Owner", Email: "owner@example.com"})
+			f.Seed(t, &domain.User{Name: "Other", Email: "other@example.com"})
+			id := user.ID.String()
+			if tt.id != nil {
+				id = tt.id(user)
+			}
+
+			updated, err := f.Users.UpdateUser(context.Background(), id, tt.newName, tt.email, domain.UserProfile{}, tt.version)
+			if tt.wantAnyErr {
+				if err == nil {
+					t.Fatal("UpdateUser succeeded, want an error")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if updated.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", updated.Name, tt.wantName)
+			}
+			if updated.Email != "owner@example.com" {
+				t.Errorf("Email = %q, want it unchanged until confirmed", updated.Email)
+			}
+			if tt.wantPending == "" && updated.PendingEmail != nil {
+				t.Errorf("PendingEmail = %+v, want none", updated.PendingEmail)
+			}
+			if tt.wantPending != "" && (updated.PendingEmail == nil || updated.PendingEmail.Email != tt.wantPending) {
+				t.Errorf("PendingEmail = %+v, want %s", updated.PendingEmail, tt.wantPending)
+			}
+			if got := len(f.HighPub.Messages()); got != tt.wantMessages {
+				t.Errorf("published %d messages, want %d", got, tt.wantMessages)
+			}
+		})
+	}
+}
+
+// emailChangeToken returns the token of the link in the last published message of task.
+func emailChangeToken(t *testing.T, pub *eventtest.RecordingPublisher, task string) string {
+	t.Helper()
+	messages := pub.MessagesFor(task)
+	if len(messages) == 0 {
+		t.Fatalf("no %s message published", task)
+	}
+	var link string
+	switch payload := messages[len(messages)-1].Payload.(type) {
+	case event.EmailChangeConfirmationPayload:
+		link = payload.ConfirmURL
+	case event.EmailChangeNoticePayload:
+		link = payload.CancelURL
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse %q: %v", link, err)
+	}
+	return parsed.Query().Get("token")
+}
+
+func TestUserUsecase_EmailChange(t *testing.T) {
+	tests := []struct {
+		name      string
+		prepare   func(t *testing.T, f *usecasetest.Fixture, user *domain.User)
+		confirm   bool
+		token     func(t *testing.T, f *usecasetest.Fixture) string
+		wantErr   error
+		wantEmail string
+	}{
+		{
+			name:    "confirm",
+			confirm: true,
+			token: func(t *testing.T, f *usecasetest.Fixture) string {
+				return emailChangeToken(t, f.HighPub, event.EmailChangeConfirmationTask)
+			},
+			wantEmail: "new@example.com",
+		},
+		{
+			name: "cancel",
+			token: func(t *testing.T, f *usecasetest.Fixture) string {
+				return emailChangeToken(t, f.HighPub, event.EmailChangeNoticeTask)
+			},
+			wantEmail: "owner@example.com",
+		},
+		{
+			name:    "confirm with cancel token",
+			confirm: true,
+			token: func(t *testing.T, f *usecasetest.Fixture) string {
+				return emailChangeToken(t, f.HighPub, event.EmailChangeNoticeTask)
+			},
+			wantErr: domain.ErrEmailChangeInvalid,
+		},
+		{
+			name:    "unknown token",
+			confirm: true,
+			token:   func(*testing.T, *usecasetest.Fixture) string { return "unknown" },
+			wantErr: domain.ErrEmailChangeInvalid,
+		},
+		{
+			name: "address taken in the meantime",
+			prepare: func(t *testing.T, f *usecasetest.Fixture, _ *domain.User) {
+				f.Seed(t, &domain.User{Name: "Squatter", Email: "new@example.com"})
+			},
+			confirm: true,
+			token: func(t *testing.T, f *usecasetest.Fixture) string {
+				return emailChangeToken(t, f.HighPub, event.EmailChangeConfirmationTask)
+			},
+			wantErr: domain.ErrUserAlreadyExists,
+		},
+		{
+			name: "expired",
+			prepare: func(t *testing.T, f *usecasetest.Fixture, user *domain.User) {
+				current, _ := f.Repo.GetUserByID(context.Background(), user.ID)
+				change := *current.PendingEmail
+				change.ExpiresAt = time.Now().Add(-time.Minute)
+				if _, err := f.Repo.UpdateUser(context.Background(), user.ID, map[string]interface{}{"pending_email": &change}, domain.AnyVersion); err != nil {
+					t.Fatalf("expire change: %v", err)
+				}
+			},
+			confirm: true,
+			token: func(t *testing.T, f *usecasetest.Fixture) string {
+				return emailChangeToken(t, f.HighPub, event.EmailChangeConfirmationTask)
+			},
+			wantErr: domain.ErrEmailChangeInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := usecasetest.NewFixture()
+			user := f.Seed(t, &domain.User{Name: "Owner", Email: "owner@example.com"})
+			if _, err := f.Users.UpdateUser(ctx, user.ID.String(), "", "new@example.com", domain.UserProfile{}, domain.AnyVersion); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, f, user)
+			}
+
+			token := tt.token(t, f)
+			var err error
+			if tt.confirm {
+				_, err = f.Users.ConfirmEmailChange(ctx, token)
+			} else {
+				err = f.Users.CancelEmailChange(ctx, token)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			stored, err := f.Repo.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID: %v", err)
+			}
+			if stored.Email != tt.wantEmail || stored.PendingEmail != nil {
+				t.Errorf("stored email %q pending %+v, want %q and none", stored.Email, stored.PendingEmail, tt.wantEmail)
+			}
+			// The links are single-use.
+			if _, err := f.Users.ConfirmEmailChange(ctx, token); !errors.Is(err, domain.ErrEmailChangeInvalid) {
+				t.Errorf("reusing the link error = %v, want ErrEmailChangeInvalid", err)
+			}
+		})
+	}
+}
+
+func TestUserUsecase_SetActive(t *testing.T) {
+	tests := []struct {
+		name      string
+		deactive  bool
+		startOff  bool
+		wantErr   error
+		wantTopic event.Topic
+	}{
+		{name: "deactivate", deactive: true, wantTopic: event.UserDeactivatedInMemoryEvent},
+		{name: "deactivate twice", deactive: true, startOff: true, wantErr: domain.ErrUserInactive},
+		{name: "reactivate", startOff: true, wantTopic: event.UserReactivatedInMemoryEvent},
+		{name: "reactivate active user", wantErr: domain.ErrUserAlreadyActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := utils.WithRequestMeta(context.Background(), &utils.RequestMeta{ActorID: "admin-1"})
+			f := usecasetest.NewFixture()
+			user := f.Seed(t, &domain.User{Name: "Owner", Email: "owner@example.com"})
+			if tt.startOff {
+				if _, err := f.Repo.SetActive(ctx, user.ID, false, "admin-0", "setup"); err != nil {
+					t.Fatalf("SetActive: %v", err)
+				}
+			}
+
+			var (
+				updated *domain.User
+				err     error
+			)
+			if tt.deactive {
+				updated, err = f.Users.DeactivateUser(ctx, user.ID.String(), "abuse")
+			} else {
+				updated, err = f.Users.ReactivateUser(ctx, user.ID.String(), "appeal")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if got := len(f.LowPub.Messages()); got != 0 {
+					t.Errorf("published %d events, want none", got)
+				}
+				return
+			}
+
+			if updated.IsActive == tt.deactive {
+				t.Errorf("IsActive = %v", updated.IsActive)
+			}
+			messages := f.LowPub.MessagesFor(string(tt.wantTopic))
+			if len(messages) != 1 {
+				t.Fatalf("published %d %s events, want 1", len(messages), tt.wantTopic)
+			}
+			payload := messages[0].Payload.(event.UserActivationPayload)
+			if payload.UserID != user.ID.String() || payload.ActorID != "admin-1" {
+				t.Errorf("payload = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestUserUsecase_DeleteRestorePurge(t *testing.T) {
+	ctx := context.Background()
+	f := usecasetest.NewFixture()
+	kept := f.Seed(t, &domain.User{Name: "Kept", Email: "kept@example.com"})
+	restored := f.Seed(t, &domain.User{Name: "Restored", Email: "restored@example.com"})
+	purged := f.Seed(t, &domain.User{Name: "Purged", Email: "purged@example.com"})
+
+	for _, user := range []*domain.User{restored, purged} {
+		if err := f.Users.DeleteUser(ctx, user.ID.String()); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+	}
+	if err := f.Users.DeleteUser(ctx, purged.ID.String()); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("second DeleteUser error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := f.Users.RestoreUser(ctx, restored.ID.String()); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	if _, err := f.Users.RestoreUser(ctx, kept.ID.String()); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("RestoreUser of a user that is not deleted error = %v, want ErrUserNotFound", err)
+	}
+
+	n, err := f.Users.PurgeDeletedUsers(ctx, 0)
+	if err != nil {
+		t.Fatalf("PurgeDeletedUsers: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("purged %d users, want 1", n)
+	}
+	tasks := f.HighPub.MessagesFor(event.UserDeletedHighImportance)
+	if len(tasks) != 1 || tasks[0].Payload.(event.UserDeletedPayload).UserID != purged.ID.String() {
+		t.Errorf("user deleted tasks = %+v, want one for the purged user", tasks)
+	}
+	for _, user := range []*domain.User{kept, restored} {
+		if _, err := f.Users.GetUserByID(ctx, user.ID); err != nil {
+			t.Errorf("GetUserByID(%s): %v", user.Name, err)
+		}
+	}
+}